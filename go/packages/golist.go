@@ -29,6 +29,7 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 	"golang.org/x/tools/go/internal/packagesdriver"
 	"golang.org/x/tools/internal/gopathwalk"
+	"golang.org/x/tools/internal/packagesinternal"
 	"golang.org/x/tools/internal/semver"
 )
 
@@ -191,8 +192,18 @@ extractQueries:
 	if sizeserr != nil {
 		return nil, sizeserr
 	}
-	// types.SizesFor always returns nil or a *types.StdSizes
-	response.dr.Sizes, _ = sizes.(*types.StdSizes)
+	// types.SizesFor returns nil or a *types.StdSizes up to go1.20, and the sizes of the gc compiler of its own since
+	// then, which are approximated by the standard sizes of the same word size and alignment. A nil *types.StdSizes
+	// must not end up in the types.Sizes of the packages.
+	switch sizes := sizes.(type) {
+	case *types.StdSizes:
+		response.dr.Sizes = sizes
+	case types.Sizes:
+		response.dr.Sizes = &types.StdSizes{
+			WordSize: sizes.Sizeof(types.Typ[types.Uintptr]),
+			MaxAlign: sizes.Alignof(types.Typ[types.Complex128]),
+		}
+	}
 
 	var containsCandidates []string
 
@@ -298,7 +309,7 @@ func runContainsQueries(cfg *Config, driver driver, response *responseDeduper, q
 			// Special case to handle issue #33482:
 			// If this is a file= query for ad-hoc packages where the file only exists on an overlay,
 			// and exists outside of a module, add the file in for the package.
-			if len(dirResponse.Packages) == 1 && len(dirResponse.Packages) == 1 &&
+			if len(dirResponse.Packages) == 1 &&
 				dirResponse.Packages[0].ID == "command-line-arguments" && len(dirResponse.Packages[0].GoFiles) == 0 {
 				filename := filepath.Join(pattern, filepath.Base(query)) // avoid recomputing abspath
 				// TODO(matloob): check if the file is outside of a root dir?
@@ -669,6 +680,19 @@ func golistDriverLRUCached(cfg *Config, rootsDirs func() *goInfo, words ...strin
 	}
 }
 
+func init() {
+	packagesinternal.PurgeGoListCache = purgeGoListCache
+}
+
+// purgeGoListCache drops the cached responses of "go list", so that the files created, deleted or changed since are
+// seen by the next loads.
+func purgeGoListCache() {
+	createGoListLRUCache.Do(func() {
+		goListLRUCache, _ = lru.New(goListLRUEntries)
+	})
+	goListLRUCache.Purge()
+}
+
 // golistDriver uses the "go list" command to expand the pattern
 // words and return metadata for the specified packages. dir may be
 // "" and env may be nil, as per os/exec.Command.
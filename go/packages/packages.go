@@ -211,7 +211,10 @@ func Load(cfg *Config, patterns ...string) ([]*Package, error) {
 	if err != nil {
 		return nil, err
 	}
-	l.sizes = response.Sizes
+	// A nil *types.StdSizes is left out, rather than becoming the non-nil types.Sizes holding it.
+	if response.Sizes != nil {
+		l.sizes = response.Sizes
+	}
 	return l.refine(response.Roots, response.Packages...)
 }
 
@@ -19,6 +19,7 @@ import (
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/lsp/telemetry"
 	"golang.org/x/tools/internal/memoize"
+	"golang.org/x/tools/internal/telemetry/log"
 	"golang.org/x/tools/internal/telemetry/trace"
 	errors "golang.org/x/xerrors"
 )
@@ -59,6 +60,15 @@ type checkPackageHandle struct {
 
 	// key is the hashed key for the package.
 	key []byte
+
+	// lastUsed is the time in unix nanoseconds when the package was last checked, it must be accessed atomically.
+	lastUsed int64
+
+	// exportData tells whether the dependency is imported from its export data in the generation gen, then source
+	// type-checks it from the source once a location inside it is requested.
+	exportData bool
+	gen        int
+	source     *checkPackageHandle
 }
 
 func (cph *checkPackageHandle) packageKey() packageKey {
@@ -98,10 +108,30 @@ func (imp *importer) checkPackageHandle(ctx context.Context, id packageID) (*che
 		return data
 	})
 	cph.handle = h
+	if cph.exportData {
+		cph.source = imp.sourceHandle(cph)
+	}
 
 	return cph, nil
 }
 
+// sourceHandle returns the handle type-checking the dependency imported from the export data from its source instead.
+func (imp *importer) sourceHandle(cph *checkPackageHandle) *checkPackageHandle {
+	src := &checkPackageHandle{
+		files:   cph.files,
+		mode:    cph.mode,
+		imports: cph.imports,
+		m:       cph.m,
+		key:     []byte(hashContents([]byte(fmt.Sprintf("%ssource", cph.key)))),
+	}
+	src.handle = imp.snapshot.view.session.cache.store.Bind(string(src.key), func(ctx context.Context) interface{} {
+		data := &checkPackageData{}
+		data.pkg, data.err = imp.typeCheck(ctx, src)
+		return data
+	})
+	return src
+}
+
 // buildKey computes the checkPackageKey for a given checkPackageHandle.
 func (imp *importer) buildKey(ctx context.Context, id packageID, mode source.ParseMode) (*checkPackageHandle, error) {
 	m := imp.snapshot.getMetadata(id)
@@ -131,6 +161,10 @@ func (imp *importer) buildKey(ctx context.Context, id packageID, mode source.Par
 		snapshot:          imp.snapshot,
 		topLevelPackageID: imp.topLevelPackageID,
 	}
+	// The dependencies are imported from the export data only if all of their own are, since the types of the packages
+	// type-checked from the source aren't shared by the export data.
+	exportData := mode == source.ParseExported && m.exportFile != "" && imp.snapshot.view.Options().ExportDataDependencies
+
 	// Begin computing the key by getting the depKeys for all dependencies.
 	var depKeys [][]byte
 	for _, dep := range deps {
@@ -140,8 +174,16 @@ func (imp *importer) buildKey(ctx context.Context, id packageID, mode source.Par
 		}
 		cph.imports[depHandle.m.pkgPath] = depHandle.m.id
 		depKeys = append(depKeys, depHandle.key)
+		if !depHandle.exportData && depHandle.m.pkgPath != "unsafe" {
+			exportData = false
+		}
 	}
 	cph.key = checkPackageKey(cph.m.id, cph.files, m.config, depKeys)
+	if exportData {
+		cph.exportData = true
+		cph.gen = imp.snapshot.view.exports.generation(imp.snapshot.view.session.cache.FileSet(), m)
+		cph.key = []byte(hashContents([]byte(fmt.Sprintf("%s%s%d", cph.key, m.exportFile, cph.gen))))
+	}
 
 	// Cache the CheckPackageHandle in the snapshot.
 	imp.snapshot.addPackage(cph)
@@ -178,6 +220,7 @@ func (cph *checkPackageHandle) check(ctx context.Context) (*pkg, error) {
 	ctx, done := trace.StartSpan(ctx, "cache.checkPackageHandle.check", telemetry.Package.Of(cph.m.id))
 	defer done()
 
+	cph.touch()
 	v := cph.handle.Get(ctx)
 	if v == nil {
 		return nil, errors.Errorf("no package for %s", cph.m.id)
@@ -207,6 +250,7 @@ func (cph *checkPackageHandle) Cached(ctx context.Context) (source.Package, erro
 }
 
 func (cph *checkPackageHandle) cached(ctx context.Context) (*pkg, error) {
+	cph.touch()
 	v := cph.handle.Cached()
 	if v == nil {
 		return nil, errors.Errorf("no cached type information for %s", cph.m.pkgPath)
@@ -286,6 +330,13 @@ func (imp *importer) typeCheck(ctx context.Context, cph *checkPackageHandle) (*p
 	for _, err := range cph.m.errors {
 		pkg.errors = append(cph.m.errors, err)
 	}
+	if cph.exportData {
+		err := imp.importExportData(ctx, cph, pkg)
+		if err == nil {
+			return pkg, nil
+		}
+		log.Error(ctx, "failed to import the export data, type-checking the source", err, telemetry.Package.Of(cph.m.id))
+	}
 	var (
 		files       = make([]*ast.File, len(pkg.files))
 		parseErrors = make([]error, len(pkg.files))
@@ -339,6 +390,29 @@ func (imp *importer) typeCheck(ctx context.Context, cph *checkPackageHandle) (*p
 	return pkg, nil
 }
 
+// importExportData imports the types of the dependency from its export data instead of type-checking its source. The
+// dependencies of the package are recorded as its imports, so that their files are found by FindFile.
+func (imp *importer) importExportData(ctx context.Context, cph *checkPackageHandle, pkg *pkg) error {
+	for path, id := range cph.imports {
+		depHandle := imp.snapshot.getPackage(id, source.ParseExported)
+		if depHandle == nil {
+			return errors.Errorf("no package for %s", id)
+		}
+		dep, err := depHandle.check(ctx)
+		if err != nil {
+			return err
+		}
+		pkg.imports[path] = dep
+	}
+	types, err := imp.snapshot.view.exports.importPackage(cph.gen, cph.m)
+	if err != nil {
+		return err
+	}
+	pkg.types = types
+	pkg.source = cph.source
+	return nil
+}
+
 func (imp *importer) depImporter(ctx context.Context, cph *checkPackageHandle, pkg *pkg) *importer {
 	// Handle circular imports by copying previously seen imports.
 	seen := make(map[packageID]struct{})
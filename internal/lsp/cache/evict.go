@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// touch records the usage of the CheckPackageHandle, which is used to find the least recently used packages.
+func (cph *checkPackageHandle) touch() {
+	atomic.StoreInt64(&cph.lastUsed, time.Now().UnixNano())
+}
+
+// EvictPackages drops the least recently used fraction of the CheckPackageHandles held by the current snapshot, so
+// that the type information and the parsed files of them can be garbage collected. The evicted packages will be
+// loaded and type-checked again the next time they are requested. The retained snapshots are dropped as well.
+func (v *view) EvictPackages(ctx context.Context, fraction float64) int {
+	if fraction <= 0 {
+		return 0
+	}
+	// The retained snapshots are dropped first, since they hold the packages of the stale contents.
+	if n := v.dropHistory(); n > 0 {
+		log.Print(ctx, "dropped snapshots", tag.Of("View", v.Name()), tag.Of("Count", n))
+	}
+	s := v.getSnapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]packageKey, 0, len(s.packages))
+	for key := range s.packages {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return atomic.LoadInt64(&s.packages[keys[i]].lastUsed) < atomic.LoadInt64(&s.packages[keys[j]].lastUsed)
+	})
+	n := int(float64(len(keys))*fraction + 0.5)
+	if n > len(keys) {
+		n = len(keys)
+	}
+	for _, key := range keys[:n] {
+		delete(s.packages, key)
+	}
+	if n > 0 {
+		log.Print(ctx, "evicted packages", tag.Of("View", v.Name()), tag.Of("Count", n))
+	}
+	return n
+}
+
+// EvictPackage drops the CheckPackageHandles of the package with the given ID from the current snapshot, in all the
+// parse modes.
+func (v *view) EvictPackage(ctx context.Context, id string) bool {
+	s := v.getSnapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evicted := false
+	for key := range s.packages {
+		if key.id == packageID(id) {
+			delete(s.packages, key)
+			evicted = true
+		}
+	}
+	return evicted
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	goimporter "go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	errors "golang.org/x/xerrors"
+)
+
+// exportImporter imports the dependencies of the view from the export data of the compiler, which is found by
+// 'go list -export'. The packages imported are shared by all the packages of the view, so that the types of a
+// dependency are identical in all the packages importing it, either directly or through the export data of others.
+type exportImporter struct {
+	mu sync.Mutex
+
+	// gen is the generation of the packages imported, see generation.
+	gen      int
+	files    map[packagePath]string
+	importer types.Importer
+}
+
+// generation records the export file of the package and returns the generation of the packages it's imported with.
+// Another generation is started once the export file of a package changes, like after its module is upgraded, since
+// the packages already imported keep the types of the former export data.
+func (e *exportImporter) generation(fset *token.FileSet, m *metadata) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if file, ok := e.files[m.pkgPath]; e.importer == nil || ok && file != m.exportFile {
+		e.gen++
+		e.files = make(map[packagePath]string)
+		e.importer = goimporter.ForCompiler(fset, "gc", e.lookup)
+	}
+	e.files[m.pkgPath] = m.exportFile
+	return e.gen
+}
+
+// importPackage imports the package from its export data, in the generation it's recorded by.
+func (e *exportImporter) importPackage(gen int, m *metadata) (*types.Package, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if gen != e.gen {
+		return nil, errors.Errorf("stale export data for %s", m.pkgPath)
+	}
+	return e.importer.Import(string(m.pkgPath))
+}
+
+// lookup opens the export file of the package, it's called by the importer with the lock held.
+func (e *exportImporter) lookup(path string) (io.ReadCloser, error) {
+	file, ok := e.files[packagePath(path)]
+	if !ok {
+		return nil, errors.Errorf("no export data for %s", path)
+	}
+	return os.Open(file)
+}
+
+// exportFiles sets the export files of the dependencies of the packages loaded, i.e. the ones out of the folder of
+// the view, by 'go list -export'. The go command compiles the dependencies missing from its build cache, the packages
+// in the folder are never compiled, since their errors are reported by the type-checks.
+func (v *view) exportFiles(ctx context.Context, cfg *packages.Config, pkgs []*packages.Package) {
+	deps := make(map[string]*packages.Package)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.PkgPath == "unsafe" || len(pkg.CompiledGoFiles) == 0 || v.contains(pkg.CompiledGoFiles[0]) {
+			return
+		}
+		deps[pkg.PkgPath] = pkg
+	})
+	if len(deps) == 0 {
+		return
+	}
+	patterns := make([]string, 0, len(deps))
+	for path := range deps {
+		patterns = append(patterns, path)
+	}
+	sort.Strings(patterns)
+
+	exportCfg := *cfg
+	// The imports are needed, since the go command doesn't find the export data otherwise.
+	exportCfg.Mode = packages.NeedName | packages.NeedImports | packages.NeedExportsFile
+	exportCfg.Tests = false
+	exported, err := packages.Load(&exportCfg, patterns...)
+	if err != nil {
+		log.Error(ctx, "failed to find the export data of the dependencies", err, tag.Of("View", v.Name()))
+		return
+	}
+	for _, pkg := range exported {
+		if dep := deps[pkg.PkgPath]; dep != nil {
+			dep.ExportFile = pkg.ExportFile
+		}
+	}
+}
+
+// contains tells whether the file is in the folder of the view.
+func (v *view) contains(filename string) bool {
+	return strings.HasPrefix(filename, v.folder.Filename()+string(filepath.Separator))
+}
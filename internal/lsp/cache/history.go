@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// ID returns the identifier of the snapshot, which increases monotonically within the view.
+func (s *snapshot) ID() uint64 {
+	return s.id
+}
+
+// CheckPackageHandles returns the CheckPackageHandles for the packages that the file belongs to as of the snapshot.
+func (s *snapshot) CheckPackageHandles(ctx context.Context, f source.File) ([]source.CheckPackageHandle, error) {
+	cphs, err := s.checkPackageHandles(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	if len(cphs) == 0 {
+		return nil, errors.Errorf("no CheckPackageHandles for %s", f.URI())
+	}
+	return cphs, nil
+}
+
+// CachedPackageHandles returns the CheckPackageHandles of the packages that the file belongs to which are known to the
+// snapshot already, the metadata of the file is never loaded.
+func (s *snapshot) CachedPackageHandles(uri span.URI) []source.CheckPackageHandle {
+	return s.getPackages(uri, source.ParseFull)
+}
+
+// MetadataFiles returns the files of the packages whose metadata is loaded in the snapshot, the dependencies included.
+func (s *snapshot) MetadataFiles() []span.URI {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var uris []span.URI
+	for _, m := range s.metadata {
+		uris = append(uris, m.files...)
+	}
+	return uris
+}
+
+// retainSnapshot keeps the snapshot which is being replaced, so that it can be queried for a while. At most the number
+// of the snapshots configured by 'SnapshotHistory' are retained, the oldest ones are dropped first.
+// The caller must hold the snapshotMu.
+func (v *view) retainSnapshot(s *snapshot) {
+	n := v.options.SnapshotHistory
+	if n <= 0 {
+		v.history = nil
+		return
+	}
+	v.history = append(v.history, s)
+	if len(v.history) > n {
+		v.history = append([]*snapshot(nil), v.history[len(v.history)-n:]...)
+	}
+}
+
+// SnapshotAt returns the snapshot with the given ID, it is either the current snapshot or one of the retained ones.
+func (v *view) SnapshotAt(id uint64) (source.Snapshot, bool) {
+	v.snapshotMu.Lock()
+	defer v.snapshotMu.Unlock()
+
+	if v.snapshot.id == id {
+		return v.snapshot, true
+	}
+	for _, s := range v.history {
+		if s.id == id {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// dropHistory drops the retained snapshots, so that the packages held by them can be garbage collected.
+func (v *view) dropHistory() int {
+	v.snapshotMu.Lock()
+	defer v.snapshotMu.Unlock()
+
+	n := len(v.history)
+	v.history = nil
+	return n
+}
@@ -34,6 +34,9 @@ type metadata struct {
 	deps        []packageID
 	missingDeps map[packagePath]struct{}
 
+	// exportFile is the export data of the dependency, if it's loaded from the export data.
+	exportFile string
+
 	// config is the *packages.Config associated with the loaded package.
 	config *packages.Config
 }
@@ -62,6 +65,9 @@ func (s *snapshot) load(ctx context.Context, uri span.URI) ([]*metadata, error)
 		// Return this error as a diagnostic to the user.
 		return nil, err
 	}
+	if s.view.Options().ExportDataDependencies {
+		s.view.exportFiles(ctx, cfg, pkgs)
+	}
 	m, prevMissingImports, err := s.updateMetadata(ctx, uri, pkgs, cfg)
 	if err != nil {
 		return nil, err
@@ -190,6 +196,7 @@ func (s *snapshot) updateImports(ctx context.Context, pkgPath packagePath, pkg *
 		name:       pkg.Name,
 		typesSizes: pkg.TypesSizes,
 		errors:     pkg.Errors,
+		exportFile: pkg.ExportFile,
 		config:     cfg,
 	}
 	for _, filename := range pkg.CompiledGoFiles {
@@ -13,6 +13,7 @@ import (
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
@@ -33,6 +34,9 @@ type pkg struct {
 	typesInfo  *types.Info
 	typesSizes types.Sizes
 
+	// source type-checks the package from the source, if it's imported from the export data.
+	source *checkPackageHandle
+
 	// The analysis cache holds analysis information for all the packages in a view.
 	// Each graph node (action) is one unit of analysis.
 	// Edges express package-to-package (vertical) dependencies,
@@ -245,6 +249,12 @@ func (p *pkg) FindFile(ctx context.Context, uri span.URI) (source.ParseGoHandle,
 
 		for _, ph := range pkg.files {
 			if ph.File().Identity().URI == uri {
+				// The package imported from the export data is declared by its source, once it's type-checked.
+				if pkg.source != nil {
+					if src, err := pkg.source.cached(ctx); err == nil {
+						return ph, src, nil
+					}
+				}
 				return ph, pkg, nil
 			}
 		}
@@ -256,3 +266,45 @@ func (p *pkg) FindFile(ctx context.Context, uri span.URI) (source.ParseGoHandle,
 	}
 	return nil, nil, errors.Errorf("no file for %s", uri)
 }
+
+// SourceObject returns the object declared by the source of the dependency imported from the export data, whose
+// positions are only the lines of the declarations. The dependency is type-checked from the source once, the objects
+// of the other packages are returned as is.
+func (p *pkg) SourceObject(ctx context.Context, obj types.Object) (types.Object, error) {
+	if obj.Pkg() == nil {
+		return obj, nil
+	}
+	dep := p.findImport(packagePath(obj.Pkg().Path()))
+	if dep == nil || dep.source == nil || dep.types != obj.Pkg() {
+		return obj, nil
+	}
+	path, err := objectpath.For(obj)
+	if err != nil {
+		return nil, err
+	}
+	src, err := dep.source.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return objectpath.Object(src.types, path)
+}
+
+// findImport returns the package of the path, which is either p or one of its dependencies.
+func (p *pkg) findImport(pkgPath packagePath) *pkg {
+	queue := []*pkg{p}
+	seen := make(map[packageID]bool)
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if pkg.pkgPath == pkgPath {
+			return pkg
+		}
+		for _, dep := range pkg.imports {
+			if !seen[dep.id] {
+				seen[dep.id] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil
+}
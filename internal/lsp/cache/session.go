@@ -17,6 +17,7 @@ import (
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/lsp/telemetry"
+	"golang.org/x/tools/internal/packagesinternal"
 	"golang.org/x/tools/internal/span"
 	"golang.org/x/tools/internal/telemetry/log"
 	"golang.org/x/tools/internal/telemetry/trace"
@@ -99,6 +100,8 @@ func (s *session) NewView(ctx context.Context, name string, folder span.URI, opt
 		filesByURI:    make(map[span.URI]viewFile),
 		filesByBase:   make(map[string][]viewFile),
 		snapshot: &snapshot{
+			// The IDs start from 1, so that the zero ID can be used by the clients to denote the current snapshot.
+			id:         1,
 			packages:   make(map[packageKey]*checkPackageHandle),
 			ids:        make(map[span.URI][]packageID),
 			metadata:   make(map[packageID]*metadata),
@@ -169,6 +172,23 @@ func (s *session) viewsOf(uri span.URI) []*view {
 	return views
 }
 
+// modViewsOf returns the views affected by the module file, i.e. the ones containing the module file and the ones in
+// the module of it.
+func (s *session) modViewsOf(uri span.URI) []*view {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+
+	dir := filepath.Dir(uri.Filename())
+	var views []*view
+	for _, view := range s.views {
+		folder := view.Folder().Filename()
+		if strings.HasPrefix(uri.Filename(), folder+string(filepath.Separator)) || folder == dir || strings.HasPrefix(folder, dir+string(filepath.Separator)) {
+			views = append(views, view)
+		}
+	}
+	return views
+}
+
 func (s *session) Views() []source.View {
 	s.viewMu.Lock()
 	defer s.viewMu.Unlock()
@@ -188,6 +208,9 @@ func (s *session) bestView(uri span.URI) source.View {
 		if longest != nil && len(longest.Folder()) > len(view.Folder()) {
 			continue
 		}
+		if view.options.BuildContextView {
+			continue
+		}
 		if strings.HasPrefix(string(uri), string(view.Folder())) {
 			longest = view
 		}
@@ -353,8 +376,15 @@ func (s *session) buildOverlay() map[string][]byte {
 }
 
 func (s *session) DidChangeOutOfBand(ctx context.Context, uri span.URI, changeType protocol.FileChangeType) {
-	if changeType == protocol.Deleted {
-		// After a deletion we must invalidate the package's metadata to
+	// The responses of "go list" are cached by the patterns, which would hide the change of the files or the imports.
+	packagesinternal.PurgeGoListCache()
+	if kind := source.DetectLanguage("", uri.Filename()); kind == source.Mod || kind == source.Sum {
+		// The module files may change the versions of any of the dependencies, no matter how they're changed.
+		for _, v := range s.modViewsOf(uri) {
+			v.invalidateModules(ctx, uri)
+		}
+	} else if changeType == protocol.Deleted || changeType == protocol.Created {
+		// After a deletion or a creation we must invalidate the package's metadata to
 		// force a go/packages invocation to refresh the package's file list.
 		views := s.viewsOf(uri)
 		for _, v := range views {
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"path/filepath"
 	"sync"
 
 	"golang.org/x/tools/internal/lsp/source"
@@ -143,6 +144,27 @@ func (s *snapshot) getIDs(uri span.URI) []packageID {
 	return s.ids[uri]
 }
 
+// getIDsInDir returns the IDs of the packages of the files in the directory.
+func (s *snapshot) getIDsInDir(dir string) []packageID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []packageID
+	seen := make(map[packageID]struct{})
+	for uri, ids := range s.ids {
+		if filepath.Dir(uri.Filename()) != dir {
+			continue
+		}
+		for _, id := range ids {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				results = append(results, id)
+			}
+		}
+	}
+	return results
+}
+
 func (s *snapshot) getFile(uri span.URI) source.FileHandle {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -255,6 +277,7 @@ func (v *view) invalidateContent(ctx context.Context, uri span.URI, kind source.
 		// TODO: If a package's name has changed,
 		// we should invalidate the metadata for the new package name (if it exists).
 	}
+	v.retainSnapshot(v.snapshot)
 	v.snapshot = v.snapshot.clone(ctx, &uri, withoutTypes, withoutMetadata)
 }
 
@@ -270,12 +293,36 @@ func (v *view) invalidateMetadata(ctx context.Context, uri span.URI) {
 
 	withoutMetadata := make(map[span.URI]struct{})
 
-	for _, id := range v.snapshot.getIDs(uri) {
+	ids := v.snapshot.getIDs(uri)
+	// A file unknown to the snapshot, like a new file only existing in the overlay, may be added to the packages of
+	// the other files in the same directory.
+	if len(ids) == 0 {
+		ids = v.snapshot.getIDsInDir(filepath.Dir(uri.Filename()))
+	}
+	for _, id := range ids {
 		v.snapshot.reverseDependencies(id, withoutMetadata, map[packageID]struct{}{})
 	}
+	v.retainSnapshot(v.snapshot)
 	v.snapshot = v.snapshot.clone(ctx, nil, withoutMetadata, withoutMetadata)
 }
 
+// invalidateModules invalidates the metadata and the type information of all the packages of the view, since the
+// change of the module file may change the versions of any of the dependencies. The module file is read again as
+// well, so that the resolver of the imports is refreshed.
+func (v *view) invalidateModules(ctx context.Context, uri span.URI) {
+	v.snapshotMu.Lock()
+	defer v.snapshotMu.Unlock()
+
+	withoutMetadata := make(map[span.URI]struct{})
+	v.snapshot.mu.Lock()
+	for uri := range v.snapshot.ids {
+		withoutMetadata[uri] = struct{}{}
+	}
+	v.snapshot.mu.Unlock()
+	v.retainSnapshot(v.snapshot)
+	v.snapshot = v.snapshot.clone(ctx, &uri, withoutMetadata, withoutMetadata)
+}
+
 // reverseDependencies populates the uris map with file URIs belonging to the
 // provided package and its transitive reverse dependencies.
 func (s *snapshot) reverseDependencies(id packageID, uris map[span.URI]struct{}, seen map[packageID]struct{}) {
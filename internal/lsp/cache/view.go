@@ -6,6 +6,7 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"go/ast"
@@ -73,6 +74,8 @@ type view struct {
 
 	snapshotMu sync.Mutex
 	snapshot   *snapshot
+	// history holds the recently replaced snapshots from the oldest to the newest, see retainSnapshot.
+	history []*snapshot
 
 	// builtin is used to resolve builtin types.
 	builtin *builtinPkg
@@ -82,6 +85,9 @@ type view struct {
 	ignoredURIs   map[span.URI]struct{}
 
 	analyzers []*analysis.Analyzer
+
+	// exports imports the dependencies from the export data, if the option 'exportDataDependencies' is set.
+	exports exportImporter
 }
 
 func (v *view) Session() source.Session {
@@ -198,17 +204,32 @@ func (v *view) buildProcessEnv(ctx context.Context) (*imports.ProcessEnv, error)
 	}
 
 	if env.GOPATH == "" {
-		cmd := exec.CommandContext(ctx, "go", "env", "GOPATH")
-		cmd.Env = cfg.Env
-		if out, err := cmd.CombinedOutput(); err != nil {
+		out, err := v.runGoCommand(ctx, cfg.Dir, cfg.Env, "env", "GOPATH")
+		if err != nil {
 			return nil, err
-		} else {
-			env.GOPATH = strings.TrimSpace(string(out))
 		}
+		env.GOPATH = strings.TrimSpace(out.String())
 	}
 	return env, nil
 }
 
+// runGoCommand runs the go command by the runner of the options of the view, if any, and returns its stdout.
+func (v *view) runGoCommand(ctx context.Context, dir string, env []string, args ...string) (*bytes.Buffer, error) {
+	if run := v.Options().RunCommand; run != nil {
+		return run(ctx, dir, env, "go", args...)
+	}
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("'go %s' failed: %v: %s", strings.Join(args, " "), err, stderr)
+	}
+	return stdout, nil
+}
+
 func (v *view) modFilesChanged() bool {
 	// Check the versions of the 'go.mod' files of the main module
 	// and modules included by a replace directive. Return true if
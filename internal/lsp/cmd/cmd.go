@@ -138,9 +138,14 @@ func (app *Application) commands() []tool.Application {
 		&app.Serve,
 		&bug{},
 		&check{app: app},
+		&doctor{app: app},
 		&format{app: app},
+		&migrate{app: app},
+		&modgraph{app: app},
 		&query{app: app},
 		&rename{app: app},
+		&scip{app: app},
+		&tags{app: app},
 		&version{app: app},
 	}
 }
@@ -0,0 +1,59 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/source"
+	errors "golang.org/x/xerrors"
+)
+
+// doctor implements the doctor verb for gopls.
+type doctor struct {
+	app *Application
+
+	MinFreeSpace float64 `flag:"min-free-space" help:"the least free disk space of the module cache in MB, the default is 1024"`
+	MemoryLimit  float64 `flag:"memory-limit" help:"the memory limit of the server in MB to validate"`
+	Offline      bool    `flag:"offline" help:"skip the module proxy check"`
+}
+
+func (d *doctor) Name() string      { return "doctor" }
+func (d *doctor) Usage() string     { return "" }
+func (d *doctor) ShortHelp() string { return "check whether the environment is ready for indexing" }
+func (d *doctor) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+Example: check the environment with at least 10GB free disk space:
+
+  $ gopls doctor -min-free-space=10240
+
+	gopls doctor flags are:
+`)
+	f.PrintDefaults()
+}
+
+// Run runs the checks of the environment and prints the report, it fails if
+// any of the checks fails.
+func (d *doctor) Run(ctx context.Context, args ...string) error {
+	options := source.DefaultOptions
+	if d.app.env != nil {
+		options.Env = d.app.env
+	}
+	options.Offline = d.Offline
+	options.MemoryLimit = uint64(d.MemoryLimit * (1 << 20))
+	report := lsp.DiagnoseEnvironment(ctx, options, d.MinFreeSpace)
+	for _, check := range report.Checks {
+		fmt.Fprintf(os.Stdout, "%-4s %-8s %s\n", strings.ToUpper(string(check.Status)), check.Name, check.Detail)
+	}
+	if !report.Passed {
+		return errors.Errorf("the environment isn't ready for indexing")
+	}
+	return nil
+}
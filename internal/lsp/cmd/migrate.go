@@ -0,0 +1,76 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/tool"
+	errors "golang.org/x/xerrors"
+)
+
+// migrate implements the migrate verb for gopls.
+type migrate struct {
+	app *Application
+
+	Write bool `flag:"w" help:"rewrite the files in place instead of printing them"`
+}
+
+func (m *migrate) Name() string      { return "migrate" }
+func (m *migrate) Usage() string     { return "<file...>" }
+func (m *migrate) ShortHelp() string { return "upgrade the index documents to the current schema" }
+func (m *migrate) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprintf(f.Output(), `
+The files carry the JSON of the textDocument/full responses, the
+elastic/indexChanged responses or the symbol locators of the
+textDocument/edefinition responses, either a single document, an array or the
+JSON lines of the documents. The documents are upgraded to the schema version
+%d, the ones without the version are of the schema version 1.
+
+Example: upgrade the stored documents of an index in place:
+
+  $ gopls migrate -w index/*.json
+
+	gopls migrate flags are:
+`, protocol.IndexSchemaVersion)
+	f.PrintDefaults()
+}
+
+// Run upgrades the documents of the files, the files already up to date are left untouched.
+func (m *migrate) Run(ctx context.Context, args ...string) error {
+	if len(args) == 0 {
+		return tool.CommandLineErrorf("migrate expects at least 1 argument (file)")
+	}
+	for _, path := range args {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		migrated, err := lsp.MigrateIndex(bytes.NewReader(data), &buf)
+		if err != nil {
+			return errors.Errorf("%s: %w", path, err)
+		}
+		if !m.Write {
+			os.Stdout.Write(buf.Bytes())
+			continue
+		}
+		if migrated == 0 {
+			continue
+		}
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: upgraded %d documents\n", path, migrated)
+	}
+	return nil
+}
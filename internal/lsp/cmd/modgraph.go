@@ -0,0 +1,65 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// modgraph implements the modgraph verb for gopls.
+type modgraph struct {
+	app *Application
+}
+
+func (m *modgraph) Name() string      { return "modgraph" }
+func (m *modgraph) Usage() string     { return "[folder...]" }
+func (m *modgraph) ShortHelp() string { return "print the module graph of the folders" }
+func (m *modgraph) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The folders default to the working directory. The graph is printed as the JSON
+of the elastic/moduleGraph responses.
+
+Example: print the module graph of two modules of a repository:
+
+  $ gopls modgraph ./api ./server
+`)
+	f.PrintDefaults()
+}
+
+// Run resolves the module graphs of the folders and prints the merged graph.
+func (m *modgraph) Run(ctx context.Context, args ...string) error {
+	if len(args) == 0 {
+		args = []string{m.app.wd}
+	}
+	options := source.DefaultOptions
+	if m.app.env != nil {
+		options.Env = m.app.env
+	}
+	session := m.app.cache.NewSession(ctx)
+	session.SetOptions(options)
+	var views []source.View
+	for _, arg := range args {
+		folder, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		views = append(views, session.NewView(ctx, filepath.Base(folder), span.FileURI(folder), options))
+	}
+	data, err := json.MarshalIndent(lsp.ModuleGraphOf(ctx, views, views), "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", data)
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/tool"
+	"golang.org/x/tools/internal/xcontext"
+)
+
+// scip implements the scip verb for gopls.
+type scip struct {
+	app *Application
+
+	Output  string `flag:"o" help:"the file to write the index to, the default is index.scip in the folder"`
+	Refs    string `flag:"refs" help:"the comma separated git refs to index in one run instead of the folder"`
+	Upload  string `flag:"upload" help:"the URI layout to upload the indexes to, like s3://bucket/{folder}/{ref}.scip"`
+	Webhook string `flag:"webhook" help:"the HTTP URL to post the completion event of the run to"`
+}
+
+// uploadNotice is printed once an index is uploaded.
+type uploadNotice struct {
+	Artifact string `json:"artifact"`
+	Ref      string `json:"ref"`
+	File     string `json:"file"`
+	Size     int    `json:"size"`
+}
+
+func (s *scip) Name() string      { return "scip" }
+func (s *scip) Usage() string     { return "[folder]" }
+func (s *scip) ShortHelp() string { return "export the SCIP index of the workspace folder" }
+func (s *scip) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The folder defaults to the working directory.
+
+Example: export the SCIP index of the module in the working directory:
+
+  $ gopls scip -o /tmp/index.scip
+
+With -refs, the refs are checked out in turn into a temporary worktree, which
+shares the type-checks of the unchanged packages, and -o is the directory to
+write the index of each ref to as <ref>.scip, the slashes of the refs replaced
+by dashes:
+
+  $ gopls scip -refs master,release-branch.go1.13 -o /tmp/indexes
+
+With -upload, the indexes are uploaded to the object storage once written, and
+a JSON line carrying the URI of each uploaded index is printed. The layout may
+contain {folder}, {ref} and {file}, i.e. the base name of the folder, the ref,
+or HEAD without -refs, and the base name of the index file. The s3, gs and
+azblob URIs are uploaded with the credentials of the environment, like
+AWS_ACCESS_KEY_ID, GOOGLE_OAUTH_ACCESS_TOKEN or AZURE_STORAGE_SAS_TOKEN, and
+the https URIs are put as they are:
+
+  $ gopls scip -refs master -o /tmp/indexes -upload s3://indexes/{folder}/{ref}.scip
+
+With -webhook, the completion event of the run is posted to the URL once the
+run ends, carrying the folder, the duration, the errors and the URIs of the
+indexes, i.e. the uploaded ones with -upload, or the written files.
+
+	gopls scip flags are:
+`)
+	f.PrintDefaults()
+}
+
+// Run indexes the workspace folder in process and writes the SCIP index.
+func (s *scip) Run(ctx context.Context, args ...string) error {
+	if len(args) > 1 {
+		return tool.CommandLineErrorf("scip expects at most 1 argument (folder)")
+	}
+	folder := s.app.wd
+	if len(args) == 1 {
+		folder = args[0]
+	}
+	folder, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+	if s.Webhook == "" {
+		_, err := s.index(ctx, folder)
+		return err
+	}
+	start := time.Now()
+	artifacts, err := s.index(ctx, folder)
+	completion := protocol.CompletionEvent{
+		Event:     protocol.CompletionIndex,
+		Folders:   []protocol.DocumentURI{protocol.NewURI(span.FileURI(folder))},
+		Duration:  time.Since(start).Seconds(),
+		Canceled:  ctx.Err() != nil,
+		Artifacts: artifacts,
+	}
+	if err != nil {
+		completion.Errors = []string{err.Error()}
+	}
+	// The event of the canceled run is posted as well.
+	if postErr := lsp.PostCompletion(xcontext.Detach(ctx), s.Webhook, completion); postErr != nil && err == nil {
+		return postErr
+	}
+	return err
+}
+
+// index indexes the workspace folder, and returns the URIs of the indexes.
+func (s *scip) index(ctx context.Context, folder string) ([]string, error) {
+	options := source.DefaultOptions
+	if s.app.env != nil {
+		options.Env = s.app.env
+	}
+	session := s.app.cache.NewSession(ctx)
+	session.SetOptions(options)
+	// The written index files by the refs.
+	outputs := make(map[string]string)
+	var refs []string
+	if s.Refs != "" {
+		dir := s.Output
+		if dir == "" {
+			dir = folder
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		refs = strings.Split(s.Refs, ",")
+		if err := lsp.WriteSCIPIndexesOfRefs(ctx, session, options, folder, refs, func(ref string) (io.WriteCloser, error) {
+			outputs[ref] = filepath.Join(dir, strings.Replace(ref, "/", "-", -1)+".scip")
+			return os.Create(outputs[ref])
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		output := s.Output
+		if output == "" {
+			output = filepath.Join(folder, "index.scip")
+		}
+		view := session.NewView(ctx, filepath.Base(folder), span.FileURI(folder), options)
+		f, err := os.Create(output)
+		if err != nil {
+			return nil, err
+		}
+		if err := lsp.WriteSCIPIndex(ctx, view, f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+		refs = []string{"HEAD"}
+		outputs["HEAD"] = output
+	}
+	var artifacts []string
+	if s.Upload == "" {
+		for _, ref := range refs {
+			artifacts = append(artifacts, string(protocol.NewURI(span.FileURI(outputs[ref]))))
+		}
+		return artifacts, nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, ref := range refs {
+		data, err := ioutil.ReadFile(outputs[ref])
+		if err != nil {
+			return nil, err
+		}
+		artifact := lsp.ArtifactURI(s.Upload, filepath.Base(folder), ref, filepath.Base(outputs[ref]))
+		if err := lsp.UploadArtifact(ctx, artifact, data, os.Getenv); err != nil {
+			return artifacts, err
+		}
+		if err := enc.Encode(uploadNotice{Artifact: artifact, Ref: ref, File: outputs[ref], Size: len(data)}); err != nil {
+			return artifacts, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
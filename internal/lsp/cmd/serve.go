@@ -14,12 +14,12 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"golang.org/x/tools/internal/jsonrpc2"
 	"golang.org/x/tools/internal/lsp"
 	"golang.org/x/tools/internal/lsp/debug"
-	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/telemetry"
 	"golang.org/x/tools/internal/telemetry/trace"
 	"golang.org/x/tools/internal/tool"
@@ -33,8 +33,21 @@ type Serve struct {
 	Mode    string `flag:"mode" help:"no effect"`
 	Port    int    `flag:"port" help:"port on which to run gopls for debugging purposes"`
 	Address string `flag:"listen" help:"address on which to listen for remote connections"`
+	Socket  string `flag:"socket" help:"path of the unix domain socket on which to listen for remote connections"`
 	Trace   bool   `flag:"rpc.trace" help:"Print the full rpc trace in lsp inspector format"`
 	Debug   string `flag:"debug" help:"Serve debug information on the supplied address"`
+	Health  string `flag:"health" help:"Serve the health checks, i.e. /healthz and /readyz, on the supplied address"`
+	GRPC    string `flag:"grpc" help:"Serve the elastic operations of the connected clients over gRPC on the supplied address"`
+
+	IdleTimeout time.Duration `flag:"idle.timeout" help:"Shut down gracefully once no request has been active for the duration, zero means never"`
+
+	TypeCheckLimit   int           `flag:"typecheck.limit" help:"Bound the concurrent type-checks of all the connections, zero means no limit"`
+	TypeCheckQueue   int           `flag:"typecheck.queue" help:"Number of the type-checks waiting once the limit is reached, the others are rejected as busy"`
+	TypeCheckTimeout time.Duration `flag:"typecheck.timeout" help:"Longest time a type-check waits for the limit, zero means no timeout"`
+
+	ExecWrapper     string        `flag:"exec.wrapper" help:"Run the go and git commands by the wrapper command split by the spaces, like a container or a chroot"`
+	ExecRestrictEnv bool          `flag:"exec.restrictenv" help:"Pass only the environment variables needed by the toolchain to the go and git commands"`
+	ExecTimeout     time.Duration `flag:"exec.timeout" help:"Kill the go and git commands running longer than the duration, zero means no timeout"`
 
 	app *Application
 }
@@ -76,6 +89,23 @@ func (s *Serve) Run(ctx context.Context, args ...string) error {
 	}
 
 	debug.Serve(ctx, s.Debug)
+	if err := lsp.ServeHealth(ctx, s.Health); err != nil {
+		return err
+	}
+	ctx, servers := lsp.NewServers(ctx, lsp.ServerOptions{
+		IdleTimeout:      s.IdleTimeout,
+		TypeCheckLimit:   s.TypeCheckLimit,
+		TypeCheckQueue:   s.TypeCheckQueue,
+		TypeCheckTimeout: s.TypeCheckTimeout,
+		ExecWrapper:      strings.Fields(s.ExecWrapper),
+		ExecRestrictEnv:  s.ExecRestrictEnv,
+		ExecTimeout:      s.ExecTimeout,
+
+		KillSubprocessesOnExit: true,
+	})
+	if err := lsp.ServeGRPC(ctx, servers, s.GRPC); err != nil {
+		return err
+	}
 
 	if s.app.Remote != "" {
 		return s.forward()
@@ -83,20 +113,22 @@ func (s *Serve) Run(ctx context.Context, args ...string) error {
 
 	// For debugging purposes only.
 	run := func(ctx context.Context, srv *lsp.ElasticServer) {
-		go srv.Run(ctx)
+		go srv.RunElasticServer(ctx)
 	}
 	if s.Address != "" {
-		return lsp.RunElasticServerOnAddress(ctx, s.app.cache, s.Address, run)
+		return lsp.RunElasticServerOnAddress(ctx, s.app.cache, servers, s.Address, run)
 	}
 	if s.Port != 0 {
-		return lsp.RunElasticServerOnPort(ctx, s.app.cache, s.Port, run)
+		return lsp.RunElasticServerOnPort(ctx, s.app.cache, servers, s.Port, run)
+	}
+	if s.Socket != "" {
+		return lsp.RunElasticServerOnSocket(ctx, s.app.cache, servers, s.Socket, run)
 	}
-	stream := jsonrpc2.NewHeaderStream(os.Stdin, os.Stdout)
+	var trace io.Writer
 	if s.Trace {
-		stream = protocol.LoggingStream(stream, out)
+		trace = out
 	}
-	ctx, srv := lsp.NewElasticServer(ctx, s.app.cache, stream)
-	return srv.Run(ctx)
+	return lsp.RunElasticServerOnStdio(ctx, s.app.cache, servers, trace)
 }
 
 func (s *Serve) forward() error {
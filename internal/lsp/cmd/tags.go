@@ -0,0 +1,81 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/tool"
+)
+
+// tags implements the tags verb for gopls.
+type tags struct {
+	app *Application
+
+	Etags  bool   `flag:"e" help:"write the etags format of Emacs instead of the ctags format"`
+	Output string `flag:"o" help:"the file to write the tags to, the default is tags, or TAGS with -e, in the folder"`
+}
+
+func (t *tags) Name() string      { return "tags" }
+func (t *tags) Usage() string     { return "[folder]" }
+func (t *tags) ShortHelp() string { return "write the tags file of the workspace folder" }
+func (t *tags) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The folder defaults to the working directory. The ctags entries are compatible
+with universal-ctags and carry the qualified names in the qname fields.
+
+Example: write the etags file of the module in the working directory:
+
+  $ gopls tags -e
+
+	gopls tags flags are:
+`)
+	f.PrintDefaults()
+}
+
+// Run indexes the workspace folder in process and writes the tags file.
+func (t *tags) Run(ctx context.Context, args ...string) error {
+	if len(args) > 1 {
+		return tool.CommandLineErrorf("tags expects at most 1 argument (folder)")
+	}
+	folder := t.app.wd
+	if len(args) == 1 {
+		folder = args[0]
+	}
+	folder, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+	output := t.Output
+	if output == "" {
+		output = filepath.Join(folder, "tags")
+		if t.Etags {
+			output = filepath.Join(folder, "TAGS")
+		}
+	}
+	options := source.DefaultOptions
+	if t.app.env != nil {
+		options.Env = t.app.env
+	}
+	session := t.app.cache.NewSession(ctx)
+	session.SetOptions(options)
+	view := session.NewView(ctx, filepath.Base(folder), span.FileURI(folder), options)
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := lsp.WriteTags(ctx, view, f, t.Etags); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -17,6 +17,12 @@ import (
 )
 
 func (s *Server) completion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
+	return s.completionList(ctx, params, nil)
+}
+
+// completionList completes at the position of the params, annotate is called
+// for each item converted from a candidate if it's set.
+func (s *Server) completionList(ctx context.Context, params *protocol.CompletionParams, annotate func(*protocol.CompletionItem, source.CompletionItem)) (*protocol.CompletionList, error) {
 	uri := span.NewURI(params.TextDocument.URI)
 	view := s.session.ViewOf(uri)
 	options := view.Options()
@@ -47,11 +53,11 @@ func (s *Server) completion(ctx context.Context, params *protocol.CompletionPara
 		// When using deep completions/fuzzy matching, report results as incomplete so
 		// client fetches updated completions after every key stroke.
 		IsIncomplete: options.Completion.Deep || options.Completion.FuzzyMatching,
-		Items:        toProtocolCompletionItems(candidates, rng, options),
+		Items:        toProtocolCompletionItems(candidates, rng, options, annotate),
 	}, nil
 }
 
-func toProtocolCompletionItems(candidates []source.CompletionItem, rng protocol.Range, options source.Options) []protocol.CompletionItem {
+func toProtocolCompletionItems(candidates []source.CompletionItem, rng protocol.Range, options source.Options, annotate func(*protocol.CompletionItem, source.CompletionItem)) []protocol.CompletionItem {
 	var (
 		items                  = make([]protocol.CompletionItem, 0, len(candidates))
 		numDeepCompletionsSeen int
@@ -106,6 +112,9 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, rng protocol.
 				Command: "editor.action.triggerParameterHints",
 			}
 		}
+		if annotate != nil {
+			annotate(&item, candidate)
+		}
 		items = append(items, item)
 	}
 	return items
@@ -0,0 +1,93 @@
+package lsp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+)
+
+// admission bounds the concurrent type-checks. The type-checks beyond the limit wait in the bounded queue until a slot
+// is freed or the timeout expires, and the ones beyond the queue are rejected right away. The nil admission admits
+// everything.
+type admission struct {
+	slots   chan struct{}
+	queue   int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	waiting int
+}
+
+// newAdmission returns the admission of the limit, it's nil if there is no limit.
+func newAdmission(limit, queue int, timeout time.Duration) *admission {
+	if limit <= 0 {
+		return nil
+	}
+	return &admission{slots: make(chan struct{}, limit), queue: queue, timeout: timeout}
+}
+
+// acquire waits for a free slot, the returned function frees the slot. It returns a retryable error if the queue is
+// full or the timeout expires.
+func (a *admission) acquire(ctx context.Context) (func(), error) {
+	if a == nil {
+		return func() {}, nil
+	}
+	release := func() { <-a.slots }
+	select {
+	case a.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	a.mu.Lock()
+	if a.waiting >= a.queue {
+		a.mu.Unlock()
+		return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeServerOverloaded, "server is busy with %d type-checks, retry later", cap(a.slots))
+	}
+	a.waiting++
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.waiting--
+		a.mu.Unlock()
+	}()
+	var expired <-chan time.Time
+	if a.timeout > 0 {
+		timer := time.NewTimer(a.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-expired:
+		return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeServerOverloaded, "server is busy, no type-check slot is freed in %v, retry later", a.timeout)
+	}
+}
+
+// admitTypeCheck admits the request type-checking the packages by the limit of the connection first and then by the
+// global one of the servers. The full and edefinition requests of a connection are served in parallel, so a connection flooding them
+// is bounded by its own slots before it takes the global ones, while its other requests are served one at a time. The
+// returned function frees the slots once the request is done.
+func (s *ElasticServer) admitTypeCheck(ctx context.Context) (func(), error) {
+	releaseConn, err := s.typeChecks.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var global *admission
+	if s.servers != nil {
+		global = s.servers.typeChecks
+	}
+	releaseGlobal, err := global.acquire(ctx)
+	if err != nil {
+		releaseConn()
+		return nil, err
+	}
+	return func() {
+		releaseGlobal()
+		releaseConn()
+	}, nil
+}
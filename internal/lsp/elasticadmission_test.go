@@ -0,0 +1,150 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+func isBusy(err error) bool {
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	return ok && rpcErr.Code == jsonrpc2.CodeServerOverloaded
+}
+
+func TestAdmission(t *testing.T) {
+	ctx := context.Background()
+	if a := newAdmission(0, 0, 0); a != nil {
+		t.Fatalf("got %v of no limit, want nil", a)
+	}
+	a := newAdmission(1, 1, 50*time.Millisecond)
+	release, err := a.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The type-check beyond the limit waits in the queue, the one beyond the queue is rejected right away.
+	waited := make(chan error, 1)
+	go func() {
+		release, err := a.acquire(ctx)
+		if err == nil {
+			release()
+		}
+		waited <- err
+	}()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+		a.mu.Lock()
+		waiting := a.waiting
+		a.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the type-check is expected to wait in the queue")
+		}
+	}
+	if _, err := a.acquire(ctx); !isBusy(err) {
+		t.Errorf("got %v beyond the queue, want the busy error", err)
+	}
+	if err := <-waited; !isBusy(err) {
+		t.Errorf("got %v once the timeout expires, want the busy error", err)
+	}
+
+	// The slot freed is taken by the type-check waiting.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	a.timeout = 10 * time.Second
+	release, err = a.acquire(ctx)
+	if err != nil {
+		t.Fatalf("got %v, want the freed slot", err)
+	}
+	release()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	release, _ = a.acquire(ctx)
+	defer release()
+	if _, err := a.acquire(canceled); err != context.Canceled {
+		t.Errorf("got %v under the canceled context, want %v", err, context.Canceled)
+	}
+}
+
+func TestAdmitTypeCheck(t *testing.T) {
+	ctx := context.Background()
+	_, servers := NewServers(ctx, ServerOptions{TypeCheckLimit: 1})
+	a := &ElasticServer{servers: servers, typeChecks: newAdmission(2, 0, 0)}
+	b := &ElasticServer{servers: servers}
+
+	release, err := a.admitTypeCheck(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The global limit applies to all the connections.
+	if _, err := b.admitTypeCheck(ctx); !isBusy(err) {
+		t.Errorf("got %v beyond the global limit, want the busy error", err)
+	}
+	// The slot of the connection is freed once the global one is rejected.
+	if _, err := a.admitTypeCheck(ctx); !isBusy(err) {
+		t.Errorf("got %v beyond the global limit, want the busy error", err)
+	}
+	if n := len(a.typeChecks.slots); n != 1 {
+		t.Errorf("got %d slots of the connection taken, want 1", n)
+	}
+	release()
+	release, err = b.admitTypeCheck(ctx)
+	if err != nil {
+		t.Errorf("got %v once the slot is freed, want it admitted", err)
+	} else {
+		release()
+	}
+}
+
+func TestAdmitTypeCheckByHandler(t *testing.T) {
+	dir := newTestDir(t, "admission", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package a\n\ntype A int\n",
+		"b.go":   "package a\n\ntype B int\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, servers := NewServers(ctx, ServerOptions{TypeCheckLimit: 1, TypeCheckQueue: 1})
+	s, conn, stop := newTestConn(ctx, servers, dir, "m")
+	defer stop()
+	s.typeChecks = newAdmission(1, 0, 0)
+	// The global slot is held, so that the first request keeps the slot of the connection while it waits.
+	hold, err := servers.typeChecks.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := func(name string) error {
+		params := &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, name)))}}
+		var resp protocol.FullResponse
+		return conn.Call(ctx, "textDocument/full", params, &resp)
+	}
+	first := make(chan error, 1)
+	go func() {
+		first <- full("a.go")
+	}()
+	for ; len(s.typeChecks.slots) == 0; time.Sleep(time.Millisecond) {
+		if ctx.Err() != nil {
+			t.Fatal("the first request is expected to take the slot of the connection")
+		}
+	}
+	// The requests of the connection are served in parallel, the one beyond the limit of the connection is rejected
+	// without waiting for the first one.
+	if err := full("b.go"); !isBusy(err) {
+		t.Errorf("got %v beyond the limit of the connection, want the busy error", err)
+	}
+	hold()
+	if err := <-first; err != nil {
+		t.Errorf("got %v once the global slot is freed, want it served", err)
+	}
+}
@@ -0,0 +1,160 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// APISurface exports the API surface of the workspace package, or of all the workspace packages of the module, keyed
+// by the qualified names so that the surfaces of the versions of a repository can be diffed. The test packages are
+// left out, and the package found in several workspace folders is exported once.
+func (s *ElasticServer) APISurface(ctx context.Context, params *protocol.APISurfaceParams) (protocol.APISurface, error) {
+	surface := protocol.APISurface{Symbols: make(map[string]protocol.APISymbol)}
+	if params.Path == "" {
+		return surface, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected the import path of a package or a module")
+	}
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return surface, err
+	}
+	defer release()
+	seen := make(map[string]bool)
+	for _, view := range s.session.Views() {
+		err := workspacePackages(ctx, view, func(pkg source.Package) {
+			path := pkg.PkgPath()
+			matched := path == params.Path || params.Module && strings.HasPrefix(path, params.Path+"/")
+			// The test variants are identified by their test binaries.
+			if !matched || pkg.ID() != path || seen[path] {
+				return
+			}
+			seen[path] = true
+			for qname, sym := range packageAPI(ctx, view, pkg) {
+				surface.Symbols[qname] = sym
+			}
+		})
+		if err != nil {
+			return surface, err
+		}
+	}
+	if len(seen) == 0 {
+		return surface, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "no workspace package is found at %s", params.Path)
+	}
+	return surface, nil
+}
+
+// packageAPI returns the exported objects of the package, along with the exported methods of the exported named types
+// and the exported fields and the explicit methods of their structs and interfaces.
+func packageAPI(ctx context.Context, view source.View, pkg source.Package) map[string]protocol.APISymbol {
+	docs := make(map[token.Pos]string)
+	for _, file := range pkg.GetSyntax(ctx) {
+		collectDocs(file, docs)
+	}
+	c := newReferenceCollector(ctx, view, pkg, "", nil)
+	qualifier := types.RelativeTo(pkg.GetTypes())
+	api := make(map[string]protocol.APISymbol)
+	add := func(obj types.Object) {
+		loc := c.target(obj)
+		if loc == nil || !obj.Exported() {
+			return
+		}
+		api[loc.Qname] = protocol.APISymbol{
+			Kind:      loc.Kind,
+			Signature: types.ObjectString(obj, qualifier),
+			Doc:       docs[obj.Pos()],
+			Package:   loc.Package,
+		}
+	}
+	scope := pkg.GetTypes().Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		add(obj)
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		for i := 0; i < named.NumMethods(); i++ {
+			add(named.Method(i))
+		}
+		switch u := named.Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				add(u.Field(i))
+			}
+		case *types.Interface:
+			for i := 0; i < u.NumExplicitMethods(); i++ {
+				add(u.ExplicitMethod(i))
+			}
+		}
+	}
+	return api
+}
+
+// collectDocs collects the doc comments of the declarations of the file by the positions of the declared names. The
+// specs of the grouped declarations without their own docs take the docs of the groups.
+func collectDocs(file *ast.File, docs map[token.Pos]string) {
+	add := func(doc *ast.CommentGroup, names ...*ast.Ident) {
+		if doc == nil {
+			return
+		}
+		for _, name := range names {
+			docs[name.Pos()] = doc.Text()
+		}
+	}
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			add(field.Doc, field.Names...)
+			// The embedded fields are named by their types.
+			if len(field.Names) == 0 {
+				if id := embeddedTypeName(field.Type); id != nil {
+					add(field.Doc, id)
+				}
+			}
+		}
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			add(decl.Doc, decl.Name)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					doc := spec.Doc
+					if doc == nil {
+						doc = decl.Doc
+					}
+					add(doc, spec.Name)
+					switch t := spec.Type.(type) {
+					case *ast.StructType:
+						addFields(t.Fields)
+					case *ast.InterfaceType:
+						addFields(t.Methods)
+					}
+				case *ast.ValueSpec:
+					doc := spec.Doc
+					if doc == nil {
+						doc = decl.Doc
+					}
+					add(doc, spec.Names...)
+				}
+			}
+		}
+	}
+}
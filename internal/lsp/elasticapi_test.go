@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestAPISurface(t *testing.T) {
+	dir := newTestDir(t, "api", map[string]string{
+		"go.mod":      "module example.com/m\n",
+		"a/a.go":      "package a\n\n// T is a type.\ntype T struct {\n\t// F is a field.\n\tF int\n\tg int\n}\n\n// M is a method.\nfunc (*T) M(x int) error { return nil }\n\nfunc (T) m() {}\n\n// I is an interface.\ntype I interface {\n\tN() string\n}\n\nconst (\n\t// C is a constant.\n\tC = 1\n)\n\ntype t struct{ E int }\n",
+		"a/a_test.go": "package a\n\nfunc Helper() {}\n",
+		"a/b/b.go":    "package b\n\nvar V int\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	surface, err := s.APISurface(ctx, &protocol.APISurfaceParams{Path: "example.com/m/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]protocol.APISymbol{
+		"a.T":   {Kind: protocol.Struct, Signature: "type T struct{F int; g int}", Doc: "T is a type.\n"},
+		"a.T.F": {Kind: protocol.Field, Signature: "field F int", Doc: "F is a field.\n"},
+		"a.T.M": {Kind: protocol.Method, Signature: "func (*T).M(x int) error", Doc: "M is a method.\n"},
+		"a.I":   {Kind: protocol.Interface, Signature: "type I interface{N() string}", Doc: "I is an interface.\n"},
+		"a.I.N": {Kind: protocol.Method, Signature: "func (I).N() string"},
+		"a.C":   {Kind: protocol.Constant, Signature: "const C untyped int", Doc: "C is a constant.\n"},
+	}
+	if len(surface.Symbols) != len(want) {
+		t.Errorf("got the symbols %v, want %v", surface.Symbols, want)
+	}
+	for qname, sym := range want {
+		got, ok := surface.Symbols[qname]
+		if !ok || got.Kind != sym.Kind || got.Signature != sym.Signature || got.Doc != sym.Doc || got.Package.Name != "a" {
+			t.Errorf("got the symbol %+v of %s, want %+v", got, qname, sym)
+		}
+	}
+
+	surface, err = s.APISurface(ctx, &protocol.APISurfaceParams{Path: "example.com/m", Module: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := surface.Symbols["b.V"]; !ok || len(surface.Symbols) != len(want)+1 {
+		t.Errorf("got the symbols %v of the module, want b.V along with the ones of a", surface.Symbols)
+	}
+	if _, err := s.APISurface(ctx, &protocol.APISurfaceParams{Path: "example.com/none"}); err == nil {
+		t.Errorf("the path without packages is expected to be rejected")
+	}
+}
@@ -0,0 +1,258 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// approximateDefinition resolves the identifier at the position from the syntax alone, when the package of the file
+// can't be type checked or the identifier has no object, like the ones of the missing dependencies. The identifier is
+// resolved by the declarations of the enclosing functions preceding it, then by the package level declarations of the
+// files in the same directory and package, and the qualified identifiers by the imports of the file. The results are
+// marked as approximate, nil is returned if the identifier can't be resolved.
+func (s *ElasticServer) approximateDefinition(ctx context.Context, view source.View, snapshot source.Snapshot, f source.File, pos protocol.Position) []protocol.SymbolLocator {
+	fset := view.Session().Cache().FileSet()
+	file, m, _, err := view.Session().Cache().ParseGoHandle(snapshot.Handle(ctx, f), source.ParseFull).Parse(ctx)
+	if err != nil || file == nil || file.Name == nil {
+		return nil
+	}
+	spn, err := m.PointSpan(pos)
+	if err != nil {
+		return nil
+	}
+	rng, err := spn.Range(m.Converter)
+	if err != nil {
+		return nil
+	}
+	astPath, _ := astutil.PathEnclosingInterval(file, rng.Start, rng.Start)
+	if len(astPath) < 2 {
+		return nil
+	}
+	id, ok := astPath[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	// The qualifiers and the identifiers qualified by them are resolved to the imported packages.
+	sel, _ := astPath[1].(*ast.SelectorExpr)
+	selected := sel != nil && sel.Sel == id
+	if selected {
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if imported := importedByName(file, x.Name); imported != "" {
+				return []protocol.SymbolLocator{approximateImport(view, imported, id.Name)}
+			}
+		}
+	} else {
+		if sel != nil {
+			if imported := importedByName(file, id.Name); imported != "" {
+				return []protocol.SymbolLocator{approximateImport(view, imported, "")}
+			}
+		}
+		if decl := localDeclaration(astPath, id); decl != nil {
+			rng, err := toProtocolRange(fset, m, decl.Pos(), decl.End())
+			if err != nil {
+				return nil
+			}
+			loc := protocol.Location{URI: protocol.NewURI(m.URI), Range: rng}
+			return []protocol.SymbolLocator{{Loc: &loc, Approximate: true}}
+		}
+	}
+
+	// The package level declarations, and the fields and the methods named by the selectors if they're unique in the
+	// package.
+	uri := f.URI()
+	var found []protocol.DetailSymbolInformation
+	for _, sibling := range packageSiblings(uri.Filename()) {
+		syntax, sm := file, m
+		if sibling != uri.Filename() {
+			sf, err := view.GetFile(ctx, span.FileURI(sibling))
+			if err != nil {
+				continue
+			}
+			syntax, sm, _, err = view.Session().Cache().ParseGoHandle(snapshot.Handle(ctx, sf), source.ParseFull).Parse(ctx)
+			if err != nil || syntax == nil || syntax.Name == nil || syntax.Name.Name != file.Name.Name {
+				continue
+			}
+		}
+		for _, sym := range syntacticSymbols(fset, syntax, sm, protocol.NewURI(sm.URI)) {
+			if sym.Symbol.Name == id.Name && (sym.Symbol.ContainerName != "") == selected {
+				found = append(found, sym)
+			}
+		}
+	}
+	if len(found) == 0 || (len(found) > 1 && selected) {
+		return nil
+	}
+	sym := found[0]
+	locator := protocol.SymbolLocator{Loc: &sym.Symbol.Location, Approximate: true}
+	if view.Options().QualifyLocalDefinitions {
+		pkg := types.NewPackage(guessImportPath(view.Folder().Filename(), uri.Filename(), file.Name.Name), file.Name.Name)
+		locator.Package = collectPkgMetadata(pkg, view.Folder().Filename(), uri.Filename(), view.Options())
+		locator.Qname = qualifyQname(sym.Qname, pkg, locator.Package.Version, view.Options().QnameStyle)
+		locator.Kind = sym.Symbol.Kind
+	}
+	return []protocol.SymbolLocator{locator}
+}
+
+// approximateImport returns the locator of the symbol of the imported package, or of the package itself if the symbol
+// is empty. The package name is assumed from the import path, and the kind of the symbol is unknown without the
+// package.
+func approximateImport(view source.View, importPath, symbol string) protocol.SymbolLocator {
+	name := assumedPackageName(importPath)
+	locator := protocol.SymbolLocator{
+		Qname:       name,
+		Kind:        protocol.Package,
+		Package:     protocol.PackageLocator{Name: name, RepoURI: applyImportPathAlias(importPath, view.Options().ImportPathAliases)},
+		Approximate: true,
+	}
+	if symbol != "" {
+		locator.Qname, locator.Kind = name+"."+symbol, 0
+	}
+	locator.Qname = qualifyQname(locator.Qname, types.NewPackage(importPath, name), "", view.Options().QnameStyle)
+	return locator
+}
+
+// importedByName returns the import path of the package imported by the file under the name, either the alias or the
+// name assumed from the import path. It returns an empty string if there is no such import.
+func importedByName(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return importPath
+			}
+		} else if assumedPackageName(importPath) == name {
+			return importPath
+		}
+	}
+	return ""
+}
+
+// assumedPackageName returns the package name assumed from the import path, i.e. the last element of the path which
+// isn't a major version, without the 'go-' prefix.
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil && path.Dir(importPath) != "." {
+			base = path.Base(path.Dir(importPath))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexAny(base, ".-"); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// localDeclaration returns the identifier declaring the local name of id, which is the nearest declaration preceding
+// id in the enclosing functions whose scope encloses id. The path is the path from id to the root of the file.
+func localDeclaration(path []ast.Node, id *ast.Ident) *ast.Ident {
+	fn := outermostFunc(path)
+	if fn == nil {
+		return nil
+	}
+	var decl *ast.Ident
+	var stack []ast.Node
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if n.Pos() > id.Pos() {
+			return false
+		}
+		stack = append(stack, n)
+		declare := func(name *ast.Ident) {
+			if name == nil || name.Name != id.Name || name.Pos() > id.Pos() {
+				return
+			}
+			// The scope of the declaration is the innermost block or statement enclosing it.
+			for i := len(stack) - 1; i >= 0; i-- {
+				switch scope := stack[i].(type) {
+				case *ast.BlockStmt, *ast.FuncDecl, *ast.FuncLit, *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt,
+					*ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.CaseClause, *ast.CommClause:
+					if scope.Pos() <= id.Pos() && id.End() <= scope.End() {
+						decl = name
+					}
+					return
+				}
+			}
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				for _, field := range n.Recv.List {
+					for _, name := range field.Names {
+						declare(name)
+					}
+				}
+			}
+		case *ast.FuncType:
+			for _, list := range []*ast.FieldList{n.Params, n.Results} {
+				if list == nil {
+					continue
+				}
+				for _, field := range list.List {
+					for _, name := range field.Names {
+						declare(name)
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					name, _ := lhs.(*ast.Ident)
+					declare(name)
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, x := range []ast.Expr{n.Key, n.Value} {
+					name, _ := x.(*ast.Ident)
+					declare(name)
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				declare(name)
+			}
+		case *ast.TypeSpec:
+			declare(n.Name)
+		case *ast.LabeledStmt:
+			declare(n.Label)
+		}
+		return true
+	})
+	return decl
+}
+
+// packageSiblings returns the Go files in the directory of the file, including the file itself.
+func packageSiblings(filename string) []string {
+	dir := filepath.Dir(filename)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return []string{filename}
+	}
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	return files
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestApproximateDefinition(t *testing.T) {
+	dir := newTestDir(t, "approximate", map[string]string{
+		// The go.mod is broken, so that the packages fail to load.
+		"go.mod": "module example.com/m\n\ngo 1.11\n\nrequire\n",
+		"a.go": `package m
+
+import (
+	"example.com/missing/v2"
+	alias "example.com/other"
+)
+
+func F(n int) int {
+	x := n
+	if x := 1; x > 0 {
+		_ = x
+	}
+	return x + G() + missing.H() + alias.I
+}
+`,
+		"b.go": "package m\n\nfunc G() int { return 0 }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	uriOf := func(name string) protocol.DocumentURI {
+		return protocol.NewURI(span.FileURI(filepath.Join(dir, name)))
+	}
+	definition := func(line, character float64) protocol.SymbolLocator {
+		t.Helper()
+		locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uriOf("a.go")},
+				Position:     protocol.Position{Line: line, Character: character},
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locs) != 1 || !locs[0].Approximate {
+			t.Fatalf("got the definitions %+v, want one approximate definition", locs)
+		}
+		return locs[0]
+	}
+	location := func(name string, line, character float64) protocol.Location {
+		return protocol.Location{URI: uriOf(name), Range: protocol.Range{
+			Start: protocol.Position{Line: line, Character: character},
+			End:   protocol.Position{Line: line, Character: character + 1},
+		}}
+	}
+
+	for _, test := range []struct {
+		line, character float64
+		want            protocol.Location
+	}{
+		// The local declarations, the inner scopes are skipped once they end.
+		{10, 6, location("a.go", 9, 4)},
+		{12, 8, location("a.go", 8, 1)},
+		{8, 6, location("a.go", 7, 7)},
+		// The package level declarations of the other files.
+		{12, 12, location("b.go", 2, 5)},
+	} {
+		got := definition(test.line, test.character)
+		if got.Loc == nil || *got.Loc != test.want {
+			t.Errorf("%v:%v: got the definition %+v, want %v", test.line, test.character, got, test.want)
+		}
+	}
+	if got := definition(12, 26); got.Qname != "missing.H" || got.Package.RepoURI != "example.com/missing/v2" || got.Loc != nil {
+		t.Errorf("got the definition %+v, want missing.H of example.com/missing/v2", got)
+	}
+	if got := definition(12, 32); got.Qname != "other" || got.Kind != protocol.Package || got.Package.RepoURI != "example.com/other" {
+		t.Errorf("got the definition %+v, want the package example.com/other", got)
+	}
+}
@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// AST returns a trimmed serialization of the AST of the document, so that the tools can consume the structure of the
+// source without embedding 'go/parser' of a mismatching version. It's enabled by the option 'astDump'.
+func (s *ElasticServer) AST(ctx context.Context, params *protocol.ASTParams) (*protocol.ASTNode, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	if !view.Options().ASTDump {
+		return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidRequest, "elastic/ast is disabled, enable it by the option 'astDump'")
+	}
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	cph := source.NarrowestCheckPackageHandle(cphs)
+	pkg, err := cph.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.packages.use(ctx, view, cph)
+	ph, err := pkg.File(uri)
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := newReferenceCollector(ctx, view, pkg, uri, m)
+	rangeOf := func(n ast.Node) (protocol.Range, bool) {
+		rng, err := toProtocolRange(c.fset, m, n.Pos(), n.End())
+		return rng, err == nil
+	}
+	targetOf := func(id *ast.Ident) *protocol.SymbolLocator {
+		if obj := c.info.ObjectOf(id); obj != nil {
+			return c.target(obj)
+		}
+		return nil
+	}
+	return buildASTTree(file, rangeOf, targetOf), nil
+}
+
+// buildASTTree converts the AST to the protocol nodes, the comments are trimmed. The identifiers are linked to the
+// symbols they define or refer to by targetOf.
+func buildASTTree(file *ast.File, rangeOf func(ast.Node) (protocol.Range, bool), targetOf func(*ast.Ident) *protocol.SymbolLocator) *protocol.ASTNode {
+	var root *protocol.ASTNode
+	var stack []*protocol.ASTNode
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		switch n.(type) {
+		case *ast.CommentGroup, *ast.Comment:
+			return false
+		}
+		node := &protocol.ASTNode{Kind: strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")}
+		if rng, ok := rangeOf(n); ok {
+			node.Range = rng
+		}
+		switch n := n.(type) {
+		case *ast.Ident:
+			node.Name = n.Name
+			node.Target = targetOf(n)
+		case *ast.BasicLit:
+			node.Value = n.Value
+		case *ast.BinaryExpr:
+			node.Value = n.Op.String()
+		case *ast.UnaryExpr:
+			node.Value = n.Op.String()
+		case *ast.AssignStmt:
+			node.Value = n.Tok.String()
+		case *ast.GenDecl:
+			node.Value = n.Tok.String()
+		}
+		if len(stack) == 0 {
+			root = node
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, node)
+		return true
+	})
+	return root
+}
@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestBuildASTTree(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", "package p\n\n// x is one.\nvar x = 1 + y\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rangeOf := func(n ast.Node) (protocol.Range, bool) {
+		pos := fset.Position(n.Pos())
+		return protocol.Range{Start: protocol.Position{Line: float64(pos.Line - 1), Character: float64(pos.Column - 1)}}, true
+	}
+	targetOf := func(id *ast.Ident) *protocol.SymbolLocator {
+		return &protocol.SymbolLocator{Qname: "p." + id.Name}
+	}
+	root := buildASTTree(file, rangeOf, targetOf)
+
+	var dump func(n *protocol.ASTNode) string
+	dump = func(n *protocol.ASTNode) string {
+		s := n.Kind
+		if n.Name != "" {
+			s += " " + n.Name + "->" + n.Target.Qname
+		}
+		if n.Value != "" {
+			s += " " + n.Value
+		}
+		if len(n.Children) > 0 {
+			s += "("
+			for i, c := range n.Children {
+				if i > 0 {
+					s += ", "
+				}
+				s += dump(c)
+			}
+			s += ")"
+		}
+		return s
+	}
+	want := "File(Ident p->p.p, GenDecl var(ValueSpec(Ident x->p.x, BinaryExpr +(BasicLit 1, Ident y->p.y))))"
+	if got := dump(root); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if root.Children[1].Range.Start.Line != 3 {
+		t.Errorf("got the declaration at line %v", root.Children[1].Range.Start.Line)
+	}
+}
@@ -0,0 +1,207 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"math"
+	"runtime"
+	"sort"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const (
+	benchFull        = "full"
+	benchEDefinition = "edefinition"
+
+	defaultBenchDefinitions = 10
+)
+
+// benchSamples accumulates the measurements of the requests of a method.
+type benchSamples struct {
+	latencies []time.Duration
+	errors    int
+	hits      int
+	mallocs   uint64
+	bytes     uint64
+}
+
+// Bench runs the 'full' and 'edefinition' requests over the files of the corpus for a number of iterations, and reports
+// the latency percentiles, the cache hit rates and the allocations by the methods, so that the regressions between the
+// releases of the server are measured on the real repositories. The requests run one after another on the caches of
+// the session as they are, and the allocations are the ones of the whole process during the requests.
+func (s *ElasticServer) Bench(ctx context.Context, params *protocol.BenchParams) (protocol.BenchReport, error) {
+	report := protocol.BenchReport{Files: len(params.Files), Iterations: params.Iterations, Results: []protocol.BenchResult{}}
+	if len(params.Files) == 0 {
+		return report, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "no files to bench")
+	}
+	if report.Iterations <= 0 {
+		report.Iterations = 1
+	}
+	methods := params.Methods
+	if len(methods) == 0 {
+		methods = []string{benchFull, benchEDefinition}
+	}
+	for _, method := range methods {
+		if method != benchFull && method != benchEDefinition {
+			return report, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "unknown method %q to bench", method)
+		}
+	}
+	definitions := params.Definitions
+	if definitions <= 0 {
+		definitions = defaultBenchDefinitions
+	}
+
+	start := time.Now()
+	samples := make(map[string]*benchSamples)
+	for _, method := range methods {
+		samples[method] = &benchSamples{}
+	}
+	// The positions of the definitions are picked by the syntax, so that the packages aren't type-checked by picking.
+	positions := make(map[protocol.DocumentURI][]protocol.Position)
+	for i := 0; i < report.Iterations; i++ {
+		for _, uri := range params.Files {
+			for _, method := range methods {
+				if ctx.Err() != nil {
+					return report, ctx.Err()
+				}
+				var requests []func() error
+				switch method {
+				case benchFull:
+					requests = append(requests, func() error {
+						_, err := s.Full(ctx, &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}, Reference: params.Reference})
+						return err
+					})
+				case benchEDefinition:
+					if _, ok := positions[uri]; !ok {
+						positions[uri] = s.benchPositions(ctx, span.NewURI(uri), definitions)
+					}
+					for _, pos := range positions[uri] {
+						pos := pos
+						requests = append(requests, func() error {
+							_, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+								TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+									TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+									Position:     pos,
+								},
+							}})
+							return err
+						})
+					}
+				}
+				for _, request := range requests {
+					samples[method].measure(s.packageCached(ctx, span.NewURI(uri)), request)
+				}
+			}
+		}
+	}
+	for _, method := range methods {
+		report.Results = append(report.Results, samples[method].result(method))
+	}
+	report.Elapsed = milliseconds(time.Since(start))
+	return report, nil
+}
+
+// measure runs the request and records its latency and the allocations of the process during it, hit tells whether
+// the package of the request is type-checked already.
+func (b *benchSamples) measure(hit bool, request func() error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	err := request()
+	latency := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	b.latencies = append(b.latencies, latency)
+	if err != nil {
+		b.errors++
+	}
+	if hit {
+		b.hits++
+	}
+	b.mallocs += after.Mallocs - before.Mallocs
+	b.bytes += after.TotalAlloc - before.TotalAlloc
+}
+
+func (b *benchSamples) result(method string) protocol.BenchResult {
+	result := protocol.BenchResult{Method: method, Requests: len(b.latencies), Errors: b.errors}
+	if len(b.latencies) == 0 {
+		return result
+	}
+	sorted := append([]time.Duration(nil), b.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := float64(len(sorted))
+	result.P50 = milliseconds(percentile(sorted, 0.5))
+	result.P90 = milliseconds(percentile(sorted, 0.9))
+	result.P99 = milliseconds(percentile(sorted, 0.99))
+	result.Max = milliseconds(sorted[len(sorted)-1])
+	result.CacheHitRate = float64(b.hits) / n
+	result.AllocsPerRequest = float64(b.mallocs) / n
+	result.BytesPerRequest = float64(b.bytes) / n
+	return result
+}
+
+// percentile returns the percentile of the sorted latencies by the nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// packageCached tells whether the package of the file is type-checked already, by the packages known to the current
+// snapshot alone, so that neither the metadata of the file is loaded nor the package is checked before the request.
+func (s *ElasticServer) packageCached(ctx context.Context, uri span.URI) bool {
+	cphs := s.session.ViewOf(uri).Snapshot().CachedPackageHandles(uri)
+	if len(cphs) == 0 {
+		return false
+	}
+	_, err := source.NarrowestCheckPackageHandle(cphs).Cached(ctx)
+	return err == nil
+}
+
+// benchPositions picks at most n identifiers of the file spread over it evenly, the declarations of the package name
+// and the blank identifiers are skipped.
+func (s *ElasticServer) benchPositions(ctx context.Context, uri span.URI, n int) []protocol.Position {
+	view := s.session.ViewOf(uri)
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil
+	}
+	ph := view.Session().Cache().ParseGoHandle(view.Snapshot().Handle(ctx, f), source.ParseFull)
+	file, m, _, err := ph.Parse(ctx)
+	if err != nil || file == nil {
+		return nil
+	}
+	var idents []*ast.Ident
+	ast.Inspect(file, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id != file.Name && id.Name != "_" {
+			idents = append(idents, id)
+		}
+		return true
+	})
+	if len(idents) > n {
+		spread := make([]*ast.Ident, n)
+		for i := range spread {
+			spread[i] = idents[i*len(idents)/n]
+		}
+		idents = spread
+	}
+	fset := view.Session().Cache().FileSet()
+	var positions []protocol.Position
+	for _, id := range idents {
+		if rng, err := toProtocolRange(fset, m, id.Pos(), id.End()); err == nil {
+			positions = append(positions, rng.Start)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,79 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestBench(t *testing.T) {
+	dir := newTestDir(t, "bench", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package m\n\ntype T struct{ F int }\n\nfunc A(t T) int { return t.F }\n",
+		"b.go":   "package m\n\nfunc B() int { return A(T{}) }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, view := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	files := []protocol.DocumentURI{
+		protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go"))),
+		protocol.NewURI(span.FileURI(filepath.Join(dir, "b.go"))),
+	}
+	// The cache is looked up without loading the package.
+	uri := span.NewURI(files[0])
+	if s.packageCached(ctx, uri) || len(view.Snapshot().CachedPackageHandles(uri)) != 0 {
+		t.Errorf("the package of %s is expected to be neither cached nor loaded by the lookup", uri)
+	}
+	report, err := s.Bench(ctx, &protocol.BenchParams{Files: files, Iterations: 2, Reference: true, Definitions: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 2 || report.Results[0].Method != "full" || report.Results[1].Method != "edefinition" {
+		t.Fatalf("got the results %+v, want the ones of full and edefinition", report.Results)
+	}
+	full, def := report.Results[0], report.Results[1]
+	if full.Requests != 4 || def.Requests != 12 || full.Errors != 0 || def.Errors != 0 {
+		t.Errorf("got %d full and %d edefinition requests with %d and %d errors, want 4 and 12 without errors", full.Requests, def.Requests, full.Errors, def.Errors)
+	}
+	// Only the first request of the package is cold.
+	if full.CacheHitRate != 0.75 || def.CacheHitRate != 1 {
+		t.Errorf("got the cache hit rates %v of full and %v of edefinition, want 0.75 and 1", full.CacheHitRate, def.CacheHitRate)
+	}
+	if full.P50 > full.P99 || full.P99 > full.Max || full.AllocsPerRequest == 0 {
+		t.Errorf("got the measurements %+v of full", full)
+	}
+
+	if _, err := s.Bench(ctx, &protocol.BenchParams{Files: files, Methods: []string{"hover"}}); err == nil {
+		t.Errorf("the unknown method is expected to be rejected")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	for _, test := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.5, 50},
+		{0.9, 90},
+		{0.99, 99},
+		{0, 1},
+	} {
+		if got := percentile(sorted, test.p); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.p, got, test.want)
+		}
+	}
+	if got := percentile([]time.Duration{7}, 0.99); got != 7 {
+		t.Errorf("got %v of the single latency, want 7", got)
+	}
+}
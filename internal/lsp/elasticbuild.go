@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// buildWordRx matches the valid GOOS, GOARCH and build tags.
+var buildWordRx = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// buildContextView returns the view loading the folder of view under the build context, so that the files excluded
+// by the build constraints of view are checked in their own packages rather than as the orphans. The views are
+// created on the first use and named after the base views, like 'folder@windows/amd64,tag'. The view itself is
+// returned for the empty build context.
+func (s *ElasticServer) buildContextView(ctx context.Context, view source.View, bc protocol.BuildContext) (source.View, error) {
+	if bc.GOOS == "" && bc.GOARCH == "" && len(bc.Tags) == 0 {
+		return view, nil
+	}
+	words := append([]string{bc.GOOS, bc.GOARCH}, bc.Tags...)
+	for i, word := range words {
+		if (i >= 2 || word != "") && !buildWordRx.MatchString(word) {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "invalid build context %q", word)
+		}
+	}
+	tags := append([]string{}, bc.Tags...)
+	sort.Strings(tags)
+	name := view.Name() + "@" + bc.GOOS + "/" + bc.GOARCH
+	if len(tags) > 0 {
+		name += "," + strings.Join(tags, ",")
+	}
+
+	s.buildViewsMu.Lock()
+	defer s.buildViewsMu.Unlock()
+	if v := s.session.View(name); v != nil {
+		return v, nil
+	}
+	options := view.Options()
+	options.BuildContextView = true
+	options.Env = append([]string{}, options.Env...)
+	if bc.GOOS != "" {
+		options.Env = append(options.Env, "GOOS="+bc.GOOS)
+	}
+	if bc.GOARCH != "" {
+		options.Env = append(options.Env, "GOARCH="+bc.GOARCH)
+	}
+	// The last '-tags' flag takes effect, i.e. the tags replace the ones of the base view.
+	if len(tags) > 0 {
+		options.BuildFlags = append(append([]string{}, options.BuildFlags...), "-tags="+strings.Join(tags, ","))
+	}
+	return s.session.NewView(ctx, name, view.Folder(), options), nil
+}
+
+// shutdownBuildContextViews shuts down the views created for the build contexts of the base view.
+func (s *Server) shutdownBuildContextViews(ctx context.Context, base string) {
+	for _, view := range s.session.Views() {
+		if view.Options().BuildContextView && strings.HasPrefix(view.Name(), base+"@") {
+			view.Shutdown(ctx)
+		}
+	}
+}
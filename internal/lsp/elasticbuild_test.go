@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestBuildContextFull(t *testing.T) {
+	dir := newTestDir(t, "buildcontext", map[string]string{
+		"go.mod":       "module example.com/a\n",
+		"a.go":         "package a\n\nfunc A() int { return sys() }\n",
+		"a_linux.go":   "package a\n\nfunc sys() int { return 1 }\n",
+		"a_windows.go": "package a\n\nfunc sys() int { return winOnly }\n",
+		"w_windows.go": "package a\n\nconst winOnly = 2\n",
+		"tagged.go":    "// +build special\n\npackage a\n\nfunc Special() int { return winOnly }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.Env = append(append([]string{}, options.Env...), "GOOS=linux", "GOARCH=amd64")
+	s, _ := newTestServer(ctx, dir, "a", options)
+
+	full := func(name string, bc protocol.BuildContext) protocol.FullResponse {
+		t.Helper()
+		resp, err := s.Full(ctx, &protocol.FullParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, name)))},
+			Reference:    true,
+			BuildContext: bc,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	for _, test := range []struct {
+		file  string
+		bc    protocol.BuildContext
+		qname string
+	}{
+		{"a_windows.go", protocol.BuildContext{GOOS: "windows"}, "a.sys"},
+		{"tagged.go", protocol.BuildContext{GOOS: "windows", Tags: []string{"special"}}, "a.Special"},
+	} {
+		resp := full(test.file, test.bc)
+		if len(resp.Symbols) != 1 || resp.Symbols[0].Qname != test.qname || resp.Symbols[0].Package.RepoURI != "example.com/a" {
+			t.Errorf("got symbols %+v of %s, want %s in example.com/a", resp.Symbols, test.file, test.qname)
+		}
+		// The windows only declarations are resolved in the package.
+		var resolved bool
+		for _, ref := range resp.References {
+			resolved = resolved || (ref.Target.Qname == "a.winOnly" && ref.Target.Package.Name == "a")
+		}
+		if !resolved {
+			t.Errorf("a.winOnly isn't resolved in %s", test.file)
+		}
+	}
+	// The views of the build contexts are reused, and they are never picked for the files.
+	full("a_windows.go", protocol.BuildContext{GOOS: "windows"})
+	if got := len(s.session.Views()); got != 3 {
+		t.Errorf("got %d views, want 3", got)
+	}
+	if view := s.session.ViewOf(span.FileURI(filepath.Join(dir, "a_windows.go"))); view.Name() != "a" {
+		t.Errorf("got view %s of a_windows.go, want a", view.Name())
+	}
+	if _, err := s.Full(ctx, &protocol.FullParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))},
+		BuildContext: protocol.BuildContext{Tags: []string{"-x"}},
+	}); err == nil {
+		t.Errorf("the invalid build tag is expected to be rejected")
+	}
+	s.shutdownBuildContextViews(ctx, "a")
+	if got := len(s.session.Views()); got != 1 {
+		t.Errorf("got %d views after shutting down the build context views, want 1", got)
+	}
+}
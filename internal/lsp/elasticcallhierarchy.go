@@ -0,0 +1,241 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// PrepareCallHierarchy returns the function or the method at the position as the item of the call hierarchy, there is
+// no item if the position isn't on a function.
+func (s *ElasticServer) PrepareCallHierarchy(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	items := []protocol.CallHierarchyItem{}
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return items, err
+	}
+	defer release()
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	fn, pkg, err := funcAt(ctx, view, uri, params.Position)
+	if err != nil || fn == nil {
+		return items, err
+	}
+	c := newReferenceCollector(ctx, view, pkg, uri, nil)
+	return append(items, callItem(ctx, view, c, fn)), nil
+}
+
+// OutgoingCalls returns the functions called by the item, the functions declared out of the workspace folders have no
+// body to look into.
+func (s *ElasticServer) OutgoingCalls(ctx context.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	calls := []protocol.CallHierarchyOutgoingCall{}
+	item := params.Item
+	if item.URI == "" || item.SelectionRange == nil {
+		return calls, nil
+	}
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return calls, err
+	}
+	defer release()
+	uri := span.NewURI(item.URI)
+	view := s.session.ViewOf(uri)
+	fn, pkg, err := funcAt(ctx, view, uri, item.SelectionRange.Start)
+	if err != nil || fn == nil {
+		return calls, err
+	}
+	ph, err := pkg.File(uri)
+	if err != nil {
+		return calls, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return calls, err
+	}
+	var decl *ast.FuncDecl
+	for _, d := range file.Decls {
+		if d, ok := d.(*ast.FuncDecl); ok && d.Name.Pos() == fn.Pos() {
+			decl = d
+		}
+	}
+	if decl == nil || decl.Body == nil {
+		return calls, nil
+	}
+	c := newReferenceCollector(ctx, view, pkg, uri, m)
+	index := make(map[*types.Func]int)
+	walkReferences(file, c.info, func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) {
+		callee, ok := obj.(*types.Func)
+		if !ok || kind != protocol.CallReference || n.Pos() < decl.Body.Pos() || n.End() > decl.Body.End() {
+			return
+		}
+		rng, err := toProtocolRange(c.fset, m, n.Pos(), n.End())
+		if err != nil {
+			return
+		}
+		i, ok := index[callee]
+		if !ok {
+			i = len(calls)
+			index[callee] = i
+			calls = append(calls, protocol.CallHierarchyOutgoingCall{To: callItem(ctx, view, c, callee)})
+		}
+		calls[i].FromRanges = append(calls[i].FromRanges, rng)
+	})
+	return calls, nil
+}
+
+// IncomingCalls returns the functions of the workspace folders calling the item, the callers are matched by the
+// qualified name and the package of the item, so that the item may be declared in another repository.
+func (s *ElasticServer) IncomingCalls(ctx context.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	calls := []protocol.CallHierarchyIncomingCall{}
+	item := params.Item
+	if item.Qname == "" {
+		return calls, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "the item %q has no qname", item.Name)
+	}
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return calls, err
+	}
+	defer release()
+	index := make(map[*types.Func]int)
+	for _, view := range s.session.Views() {
+		err := workspacePackages(ctx, view, func(pkg source.Package) {
+			calls = appendIncomingCalls(ctx, view, pkg, item, calls, index)
+		})
+		if err != nil {
+			return calls, err
+		}
+	}
+	return calls, nil
+}
+
+// workspacePackages calls fn with each of the packages of the workspace folder of the view, the packages failing to
+// be checked are skipped.
+func workspacePackages(ctx context.Context, view source.View, fn func(pkg source.Package)) error {
+	files, err := packageFiles(view.Folder().Filename())
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		f, err := view.GetFile(ctx, span.FileURI(file))
+		if err != nil {
+			continue
+		}
+		_, cphs, err := view.CheckPackageHandles(ctx, f)
+		if err != nil {
+			continue
+		}
+		pkg, err := source.NarrowestCheckPackageHandle(cphs).Check(ctx)
+		if err != nil || seen[pkg.ID()] {
+			continue
+		}
+		seen[pkg.ID()] = true
+		fn(pkg)
+	}
+	return nil
+}
+
+// appendIncomingCalls appends the calls of the item in the package, grouped by the calling functions.
+func appendIncomingCalls(ctx context.Context, view source.View, pkg source.Package, item protocol.CallHierarchyItem, calls []protocol.CallHierarchyIncomingCall, index map[*types.Func]int) []protocol.CallHierarchyIncomingCall {
+	for _, ph := range pkg.Files() {
+		uri := ph.File().Identity().URI
+		file, m, _, err := ph.Cached(ctx)
+		if err != nil || file == nil {
+			continue
+		}
+		c := newReferenceCollector(ctx, view, pkg, uri, m)
+		walkReferences(file, c.info, func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) {
+			if _, ok := obj.(*types.Func); !ok || kind != protocol.CallReference || enclosing == nil {
+				return
+			}
+			caller, ok := c.info.Defs[enclosing].(*types.Func)
+			if !ok {
+				return
+			}
+			target := c.target(obj)
+			if target == nil || target.Qname != item.Qname || target.Package.Name != item.Package.Name || target.Package.RepoURI != item.Package.RepoURI {
+				return
+			}
+			rng, err := toProtocolRange(c.fset, m, n.Pos(), n.End())
+			if err != nil {
+				return
+			}
+			i, ok := index[caller]
+			if !ok {
+				i = len(calls)
+				index[caller] = i
+				calls = append(calls, protocol.CallHierarchyIncomingCall{From: callItem(ctx, view, c, caller)})
+			}
+			calls[i].FromRanges = append(calls[i].FromRanges, rng)
+		})
+	}
+	return calls
+}
+
+// funcAt returns the function or the method whose name is at the position, along with the package of the file. The
+// function is nil if there is no such name.
+func funcAt(ctx context.Context, view source.View, uri span.URI, pos protocol.Position) (*types.Func, source.Package, error) {
+	obj, pkg, err := declAt(ctx, view, uri, pos)
+	fn, _ := obj.(*types.Func)
+	return fn, pkg, err
+}
+
+// declAt returns the object declared by the identifier at the position, along with the package of the file. The object
+// is nil if there is no identifier at the position.
+func declAt(ctx context.Context, view source.View, uri span.URI, pos protocol.Position) (types.Object, source.Package, error) {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg, err := source.NarrowestCheckPackageHandle(cphs).Check(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ident, err := source.IdentifierAt(ctx, view, view.Snapshot(), f, pos)
+	if err != nil {
+		return nil, nil, nil
+	}
+	return ident.GetDeclObject(), pkg, nil
+}
+
+// callItem returns the item of the function, it's located by the range of the name if it's declared in the workspace
+// folder of the view, or by the qualified name and the package otherwise.
+func callItem(ctx context.Context, view source.View, c *referenceCollector, fn *types.Func) protocol.CallHierarchyItem {
+	item := protocol.CallHierarchyItem{Name: fn.Name(), Kind: protocol.Function, Detail: fn.FullName()}
+	if loc := c.target(fn); loc != nil {
+		item.Kind, item.Qname, item.Package = loc.Kind, loc.Qname, loc.Package
+	}
+	if loc, ok := workspaceLocation(ctx, view, c.fset, fn); ok {
+		item.URI, item.Range, item.SelectionRange = loc.URI, &loc.Range, &loc.Range
+	}
+	return item
+}
+
+// workspaceLocation returns the location of the name of the object if it's declared in the workspace folder of the
+// view, the vendored packages are out of the workspace under the vendor mode.
+func workspaceLocation(ctx context.Context, view source.View, fset *token.FileSet, obj types.Object) (protocol.Location, bool) {
+	if obj.Pkg() == nil || !obj.Pos().IsValid() {
+		return protocol.Location{}, false
+	}
+	posn := fset.PositionFor(obj.Pos(), false)
+	declInVendor := view.Options().VendorMode && strings.Contains(posn.Filename, folderSkip)
+	if !inFolder(posn.Filename, view.Folder().Filename()) || declInVendor {
+		return protocol.Location{}, false
+	}
+	loc, err := originalLocation(ctx, view.Session(), posn, obj.Name())
+	return loc, err == nil
+}
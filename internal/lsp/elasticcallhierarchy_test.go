@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCallHierarchy(t *testing.T) {
+	dir := newTestDir(t, "callhierarchy", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\nimport \"strings\"\n\nfunc F() {\n\tG()\n\tG()\n\tstrings.ToUpper(\"f\")\n}\n\nfunc G() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nfunc H() {\n\ta.F()\n}\n",
+		"c/c.go": "package c\n\nfunc I() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a", "a.go")))
+	items, err := s.PrepareCallHierarchy(ctx, &protocol.CallHierarchyPrepareParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: a},
+		Position:     protocol.Position{Line: 4, Character: 5},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "F" || items[0].URI != a || items[0].Qname == "" {
+		t.Fatalf("got the items %+v, want F located in a.go", items)
+	}
+
+	outgoing, err := s.OutgoingCalls(ctx, &protocol.CallHierarchyOutgoingCallsParams{Item: items[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outgoing) != 2 {
+		t.Fatalf("got %d callees, want G and strings.ToUpper", len(outgoing))
+	}
+	if g := outgoing[0]; g.To.Name != "G" || g.To.URI != a || len(g.FromRanges) != 2 {
+		t.Errorf("got the callee %+v, want G called twice", g)
+	}
+	// The callees out of the workspace are located by the qname only.
+	if upper := outgoing[1]; upper.To.Name != "ToUpper" || upper.To.URI != "" || upper.To.Qname == "" || upper.To.Package.Name != "strings" {
+		t.Errorf("got the callee %+v, want strings.ToUpper without the location", upper)
+	}
+
+	incoming, err := s.IncomingCalls(ctx, &protocol.CallHierarchyIncomingCallsParams{Item: items[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incoming) != 1 || incoming[0].From.Name != "H" || len(incoming[0].FromRanges) != 1 {
+		t.Errorf("got the callers %+v, want H", incoming)
+	}
+}
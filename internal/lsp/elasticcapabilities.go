@@ -0,0 +1,184 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// elasticMethods are the extension requests and notifications handled by the elastic server.
+var elasticMethods = []string{
+	"textDocument/edefinition",
+	"textDocument/full",
+	"elastic/moduleAnomalies",
+	"elastic/moduleGraph",
+	"elastic/apiSurface",
+	"elastic/indexChanged",
+	"elastic/workspaceHash",
+	"elastic/prepare",
+	"elastic/cancelWarmUp",
+	"elastic/tokens",
+	"elastic/ast",
+	"elastic/symbol",
+	"elastic/doctor",
+	"elastic/bench",
+	"elastic/effectiveConfig",
+	"textDocument/prepareCallHierarchy",
+	"callHierarchy/incomingCalls",
+	"callHierarchy/outgoingCalls",
+	"elastic/typeHierarchy",
+	"textDocument/semanticTokens/full",
+	"textDocument/semanticTokens/range",
+}
+
+// elasticNotifications are the extension notifications sent by the elastic server.
+var elasticNotifications = []string{
+	"elastic/prepared",
+	"elastic/warmUpProgress",
+	"elastic/sessionSummary",
+}
+
+// elasticCompressions are the content codings which the large responses may be compressed by.
+var elasticCompressions = []string{"gzip"}
+
+// elasticCapabilities returns the capabilities advertised under 'experimental.elastic'.
+func elasticCapabilities() protocol.ElasticServerCapabilities {
+	return protocol.ElasticServerCapabilities{
+		Version:       protocol.ElasticProtocolVersion,
+		Methods:       elasticMethods,
+		Notifications: elasticNotifications,
+		Commands:      elasticCommands,
+		References:    true,
+		Compression:   elasticCompressions,
+		SemanticTokens: protocol.SemanticTokensLegend{
+			TokenTypes:     semanticTokenTypes,
+			TokenModifiers: semanticTokenModifiers,
+		},
+	}
+}
+
+// clientCapabilities holds the elastic capabilities declared by the client. The zero value, i.e. the one before the
+// initialization, speaks the current version of the elastic protocol.
+type clientCapabilities struct {
+	protocol.ElasticClientCapabilities
+	// The version of the elastic protocol negotiated with the client.
+	version int
+}
+
+// parseClientCapabilities reads the capabilities under 'elastic' of the experimental client capabilities, which are
+// ignored if they're malformed.
+func parseClientCapabilities(experimental interface{}) clientCapabilities {
+	var caps struct {
+		Elastic protocol.ElasticClientCapabilities `json:"elastic"`
+	}
+	data, err := json.Marshal(experimental)
+	if err != nil || json.Unmarshal(data, &caps) != nil {
+		caps.Elastic = protocol.ElasticClientCapabilities{}
+	}
+	version := caps.Elastic.Version
+	if version <= 0 {
+		version = protocol.LegacyElasticProtocol
+	}
+	if version > protocol.ElasticProtocolVersion {
+		version = protocol.ElasticProtocolVersion
+	}
+	return clientCapabilities{caps.Elastic, version}
+}
+
+// legacy tells whether the client speaks the legacy version of the elastic protocol.
+func (c clientCapabilities) legacy() bool {
+	return c.version != 0 && c.version < protocol.ElasticProtocolVersion
+}
+
+// schemaVersion returns the version of the shapes of the index documents emitted to the client.
+func (c clientCapabilities) schemaVersion() int {
+	if c.legacy() {
+		return protocol.LegacySchemaVersion
+	}
+	return protocol.IndexSchemaVersion
+}
+
+// references tells whether the client handles the references of the 'textDocument/full' responses.
+func (c clientCapabilities) references() bool {
+	return c.References == nil || *c.References
+}
+
+// notifies tells whether the extension notification of the method is handled by the client.
+func (c clientCapabilities) notifies(method string) bool {
+	if c.Notifications == nil {
+		return true
+	}
+	for _, m := range c.Notifications {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// compression returns the content coding which the large responses are compressed by, it's the first one accepted by
+// the client and served by the server, or empty if there is none.
+func (c clientCapabilities) compression() string {
+	for _, coding := range c.Compression {
+		for _, served := range elasticCompressions {
+			if coding == served {
+				return coding
+			}
+		}
+	}
+	return ""
+}
+
+// versionLocators sets the schema version of the symbol locators of the 'textDocument/edefinition' response, and of
+// their package locators.
+func versionLocators(locators []protocol.SymbolLocator, version int) []protocol.SymbolLocator {
+	for i := range locators {
+		locators[i].SchemaVersion = version
+		locators[i].Package.SchemaVersion = version
+	}
+	return locators
+}
+
+// legacyLocators strips the symbol locators of the fields unknown to the legacy clients, which are of the
+// LegacySchemaVersion.
+func legacyLocators(locators []protocol.SymbolLocator) []protocol.SymbolLocator {
+	for i := range locators {
+		locators[i] = legacyLocator(locators[i])
+	}
+	return versionLocators(locators, protocol.LegacySchemaVersion)
+}
+
+func legacyLocator(locator protocol.SymbolLocator) protocol.SymbolLocator {
+	locator.Generated = false
+	locator.Package.Module = ""
+	locator.Signature, locator.Snippet = "", ""
+	locator.Approximate = false
+	return locator
+}
+
+// legacyFull strips a copy of the 'full' response, which may be shared by the retried requests, of the fields unknown
+// to the legacy clients, which is of the LegacySchemaVersion. The snapshot of the response is zero, which pins the
+// current snapshot if it's sent back. The cursor and the status of the response are kept, since the responses of the
+// legacy clients are paginated and truncated by the server as well.
+func legacyFull(resp protocol.FullResponse) protocol.FullResponse {
+	legacy := protocol.FullResponse{
+		SchemaVersion: protocol.LegacySchemaVersion,
+		Symbols:       make([]protocol.DetailSymbolInformation, len(resp.Symbols)),
+		References:    make([]protocol.Reference, len(resp.References)),
+		Partial:       resp.Partial,
+		Errors:        resp.Errors,
+		Truncated:     resp.Truncated,
+		NextCursor:    resp.NextCursor,
+	}
+	for i, sym := range resp.Symbols {
+		sym.Package.Module = ""
+		sym.ConstGroup = nil
+		legacy.Symbols[i] = sym
+	}
+	for i, ref := range resp.References {
+		ref.Kind = ""
+		ref.Target = legacyLocator(ref.Target)
+		legacy.References[i] = ref
+	}
+	return legacy
+}
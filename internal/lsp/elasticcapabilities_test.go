@@ -0,0 +1,115 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestClientCapabilities(t *testing.T) {
+	var experimental interface{}
+	if err := json.Unmarshal([]byte(`{"elastic": {"references": false, "notifications": ["elastic/prepared"]}}`), &experimental); err != nil {
+		t.Fatal(err)
+	}
+	caps := parseClientCapabilities(experimental)
+	if caps.references() {
+		t.Errorf("the references are expected to be declared unsupported")
+	}
+	if !caps.notifies("elastic/prepared") || caps.notifies("elastic/warmUpProgress") {
+		t.Errorf("got the notifications %v, want only elastic/prepared", caps.Notifications)
+	}
+
+	// The clients declaring nothing, or the malformed capabilities, get everything.
+	for _, experimental := range []interface{}{nil, map[string]interface{}{"elastic": "all"}} {
+		caps := parseClientCapabilities(experimental)
+		if !caps.references() || !caps.notifies("elastic/sessionSummary") {
+			t.Errorf("got %+v for %v, want the defaults", caps, experimental)
+		}
+	}
+}
+
+func TestFullWithoutReferences(t *testing.T) {
+	dir := newTestDir(t, "capabilities", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "package a\n\nfunc F() { F() }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "a", source.DefaultOptions)
+	references := false
+	s.clientCaps.References = &references
+
+	resp, err := s.Full(ctx, &protocol.FullParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))},
+		Reference:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Symbols) != 1 || len(resp.References) != 0 {
+		t.Errorf("got %d symbols and %d references, want 1 symbol and no references", len(resp.Symbols), len(resp.References))
+	}
+}
+
+func TestProtocolVersion(t *testing.T) {
+	for _, c := range []struct {
+		experimental interface{}
+		want         int
+	}{
+		{nil, protocol.LegacyElasticProtocol},
+		{map[string]interface{}{"elastic": map[string]interface{}{"version": 2}}, protocol.ElasticProtocolVersion},
+		// The clients newer than the server get the version of the server.
+		{map[string]interface{}{"elastic": map[string]interface{}{"version": 99}}, protocol.ElasticProtocolVersion},
+	} {
+		if got := parseClientCapabilities(c.experimental).version; got != c.want {
+			t.Errorf("got the version %d for %v, want %d", got, c.experimental, c.want)
+		}
+	}
+	if (clientCapabilities{}).legacy() {
+		t.Errorf("the server is expected to speak the current version before the initialization")
+	}
+
+	resp := protocol.FullResponse{
+		SchemaVersion: protocol.IndexSchemaVersion,
+		Symbols:       []protocol.DetailSymbolInformation{{Qname: "a.C", ConstGroup: &protocol.ConstGroup{Group: "a.T"}}},
+		References:    []protocol.Reference{{Kind: protocol.CallReference, Target: protocol.SymbolLocator{Qname: "a.F", Generated: true}}},
+		Snapshot:      3,
+		NextCursor:    "3:100",
+		Truncated:     true,
+		Partial:       true,
+		Errors:        []string{"a.go:1:1: expected 'package'"},
+	}
+	data, err := json.Marshal(legacyFull(resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// The documents of the legacy clients carry the legacy schema version.
+	if fields["schemaVersion"] != float64(protocol.LegacySchemaVersion) {
+		t.Errorf("got %s, want the schema version %d", data, protocol.LegacySchemaVersion)
+	}
+	if fields["snapshot"] != 0.0 {
+		t.Errorf("got %s, want the legacy shape", data)
+	}
+	// The pages and the status of the response are kept, like the first page capped by 'maxResponseSize'.
+	if fields["nextCursor"] != "3:100" || fields["truncated"] != true || fields["partial"] != true || fields["errors"] == nil {
+		t.Errorf("got %s, want the cursor and the status of the response", data)
+	}
+	if resp.Symbols[0].ConstGroup == nil || !resp.References[0].Target.Generated {
+		t.Errorf("the shared response is expected to be kept intact")
+	}
+
+	locators := legacyLocators([]protocol.SymbolLocator{{Qname: "a.F", Signature: "func F()"}})
+	if locators[0].SchemaVersion != protocol.LegacySchemaVersion || locators[0].Package.SchemaVersion != protocol.LegacySchemaVersion || locators[0].Signature != "" {
+		t.Errorf("got the locator %+v, want the legacy shape of the legacy schema version", locators[0])
+	}
+}
@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cleanupRegistry records the folders which need to be cleaned up once the server shuts down, like the folders
+// containing the 'go.mod' created manually. It's owned by the server and shared with the dependency management, which
+// runs again for the folders added later.
+type cleanupRegistry struct {
+	mu      sync.Mutex
+	folders []string
+}
+
+// add records the folder, the folder recorded already is kept once.
+func (r *cleanupRegistry) add(folder string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.folders {
+		if hostPaths.equal(f, folder) {
+			return
+		}
+	}
+	r.folders = append(r.folders, folder)
+}
+
+// list returns a copy of the folders recorded.
+func (r *cleanupRegistry) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.folders...)
+}
+
+// cleanup removes the 'go.mod' and the 'go.sum' of the folders recorded, and forgets the folders so that they're
+// cleaned up once. It returns the folders cleaned up.
+func (r *cleanupRegistry) cleanup() []string {
+	r.mu.Lock()
+	folders := r.folders
+	r.folders = nil
+	r.mu.Unlock()
+	removeGoMods(folders)
+	return folders
+}
+
+// cleanupUnder cleans up the folders recorded under root, like the module folders of a workspace folder removed, the
+// other folders are kept. It returns the folders cleaned up.
+func (r *cleanupRegistry) cleanupUnder(root string) []string {
+	r.mu.Lock()
+	var folders, kept []string
+	for _, folder := range r.folders {
+		if hostPaths.hasPrefix(folder, root) {
+			folders = append(folders, folder)
+		} else {
+			kept = append(kept, folder)
+		}
+	}
+	r.folders = kept
+	r.mu.Unlock()
+	removeGoMods(folders)
+	return folders
+}
+
+// removeGoMods removes the 'go.mod' and the 'go.sum' of the folders.
+func removeGoMods(folders []string) {
+	for _, folder := range folders {
+		goMod := filepath.Join(folder, "go.mod")
+		goSum := filepath.Join(folder, "go.sum")
+		if _, err := os.Stat(goMod); err == nil {
+			os.Remove(goMod) // ignore the errors
+		}
+		if _, err := os.Stat(goSum); err == nil {
+			os.Remove(goSum) // ignore the errors
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCleanupRegistry(t *testing.T) {
+	var r cleanupRegistry
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.add("/repo/" + strconv.Itoa(i%4))
+			r.add("/repo/" + strconv.Itoa(i%4) + "/")
+		}(i)
+	}
+	wg.Wait()
+	if got := r.list(); len(got) != 4 {
+		t.Errorf("got the folders %v, want 4 of them", got)
+	}
+	r.cleanup()
+	if got := r.list(); len(got) != 0 {
+		t.Errorf("got the folders %v after the cleanup, want none", got)
+	}
+}
+
+func TestCleanupSynthesizedModule(t *testing.T) {
+	root := newTestDir(t, "cleanup", map[string]string{"main.go": "package main\n"})
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	s := newTestSessionServer(ctx, source.DefaultOptions)
+	s.ManageDeps(ctx, []protocol.WorkspaceFolder{{URI: string(span.FileURI(root)), Name: filepath.Base(root)}}, nil)
+	goMod := filepath.Join(root, "go.mod")
+	if _, err := os.Stat(goMod); err != nil {
+		t.Fatalf("the go.mod is expected to be synthesized: %v", err)
+	}
+	if got := s.FolderNeedsCleanup.list(); len(got) != 1 || !hostPaths.equal(got[0], root) {
+		t.Errorf("got the folders %v to clean up, want %s", got, root)
+	}
+
+	// The go.mod synthesized by the dependency management is removed once the server shuts down.
+	s.Cleanup()
+	if _, err := os.Stat(goMod); !os.IsNotExist(err) {
+		t.Errorf("the synthesized go.mod is expected to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "main.go")); err != nil {
+		t.Errorf("the source files are expected to be kept: %v", err)
+	}
+}
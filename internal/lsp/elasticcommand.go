@@ -0,0 +1,146 @@
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// The commands of the elastic server served by 'workspace/executeCommand', so that the dependency management and the
+// indexing are triggered on demand rather than only at the initialization. The arguments of the commands are the URIs
+// of the workspace folders, all the folders are selected if there is none.
+const (
+	// depsDownloadCommand downloads the dependencies of the folders.
+	depsDownloadCommand = "elastic.deps.download"
+	// depsCleanCommand removes the 'go.mod' and the 'go.sum' files synthesized by the dependency management, it takes
+	// no arguments.
+	depsCleanCommand = "elastic.deps.clean"
+	// depsRetryCommand downloads the dependencies of exactly one folder again, the running download of the folder is
+	// aborted and the demoted proxies are tried again.
+	depsRetryCommand = "elastic.deps.retry"
+	// indexRebuildCommand drops the views and the symbols indexed of the folders, and warms them up again.
+	indexRebuildCommand = "elastic.index.rebuild"
+)
+
+var elasticCommands = []string{depsDownloadCommand, depsCleanCommand, depsRetryCommand, depsRequireCommand, indexRebuildCommand}
+
+// ExecuteCommand serves the elastic commands, the other commands are served by the embedded server.
+func (s *ElasticServer) ExecuteCommand(ctx context.Context, params *protocol.ExecuteCommandParams) (interface{}, error) {
+	switch params.Command {
+	case depsDownloadCommand:
+		folders, err := s.commandFolders(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.downloadFolders(ctx, folders)
+	case depsCleanCommand:
+		result := protocol.ElasticCommandResult{Folders: []protocol.DocumentURI{}}
+		for _, folder := range s.FolderNeedsCleanup.cleanup() {
+			result.Folders = append(result.Folders, protocol.NewURI(span.FileURI(folder)))
+		}
+		return result, nil
+	case depsRetryCommand:
+		if len(params.Arguments) != 1 {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected one folder URI for %s, got %v", params.Command, params.Arguments)
+		}
+		folders, err := s.commandFolders(params)
+		if err != nil {
+			return nil, err
+		}
+		s.depsRuns.abort([]string{span.NewURI(folders[0].URI).Filename()})
+		s.proxies.reset()
+		return s.downloadFolders(ctx, folders)
+	case depsRequireCommand:
+		return s.requireModules(ctx, params)
+	case indexRebuildCommand:
+		folders, err := s.commandFolders(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.rebuildFolders(ctx, folders)
+	}
+	return s.Server.ExecuteCommand(ctx, params)
+}
+
+// commandFolders returns the workspace folders selected by the arguments of the command.
+func (s *ElasticServer) commandFolders(params *protocol.ExecuteCommandParams) ([]protocol.WorkspaceFolder, error) {
+	var folders []protocol.WorkspaceFolder
+	if len(params.Arguments) == 0 {
+		for _, view := range s.session.Views() {
+			folders = append(folders, protocol.WorkspaceFolder{URI: protocol.NewURI(view.Folder()), Name: view.Name()})
+		}
+		return folders, nil
+	}
+	for _, arg := range params.Arguments {
+		uri, ok := arg.(string)
+		if !ok {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected the folder URIs for %s, got %v", params.Command, params.Arguments)
+		}
+		view := s.folderView(span.NewURI(uri).Filename())
+		if view == nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%s is not a workspace folder", uri)
+		}
+		folders = append(folders, protocol.WorkspaceFolder{URI: protocol.NewURI(view.Folder()), Name: view.Name()})
+	}
+	return folders, nil
+}
+
+// folderView returns the view of the folder, it's nil if the folder isn't a workspace folder.
+func (s *ElasticServer) folderView(dir string) source.View {
+	for _, view := range s.session.Views() {
+		if hostPaths.equal(view.Folder().Filename(), dir) {
+			return view
+		}
+	}
+	return nil
+}
+
+// downloadFolders runs the dependency management of the folders, the nested modules found are added as the workspace
+// folders like the ones found at the initialization.
+func (s *ElasticServer) downloadFolders(ctx context.Context, folders []protocol.WorkspaceFolder) (protocol.ElasticCommandResult, error) {
+	result := protocol.ElasticCommandResult{Folders: []protocol.DocumentURI{}}
+	modules := s.ManageDeps(ctx, folders, protocol.ElasticOptions{InstallGoDependency: true})
+	var added []protocol.WorkspaceFolder
+	for _, module := range modules {
+		if s.folderView(span.NewURI(module.URI).Filename()) == nil {
+			added = append(added, module)
+		}
+	}
+	if len(added) > 0 {
+		if err := s.Server.DidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{
+			Event: protocol.WorkspaceFoldersChangeEvent{Added: added},
+		}); err != nil {
+			return result, err
+		}
+	}
+	for _, folder := range append(folders, modules...) {
+		result.Folders = append(result.Folders, folder.URI)
+	}
+	return result, ctx.Err()
+}
+
+// rebuildFolders recreates the views of the folders, so that the packages are loaded again, like after the
+// dependencies are downloaded, and warms up the new views in the background.
+func (s *ElasticServer) rebuildFolders(ctx context.Context, folders []protocol.WorkspaceFolder) (protocol.ElasticCommandResult, error) {
+	result := protocol.ElasticCommandResult{Folders: []protocol.DocumentURI{}}
+	if err := s.Server.DidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{Removed: folders, Added: folders},
+	}); err != nil {
+		return result, err
+	}
+	var views []source.View
+	for _, folder := range folders {
+		dir := span.NewURI(folder.URI).Filename()
+		s.symbols.forget(dir)
+		s.references.forget(dir)
+		if view := s.folderView(dir); view != nil {
+			views = append(views, view)
+		}
+		result.Folders = append(result.Folders, folder.URI)
+	}
+	s.startWarmUp(ctx, views)
+	return result, nil
+}
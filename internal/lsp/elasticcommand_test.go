@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestExecuteElasticCommands(t *testing.T) {
+	dir := newTestDir(t, "command", map[string]string{"go.mod": "module example.com/a\n"})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.ConfigurationSupported = false
+	s, view := newTestServer(ctx, dir, "a", options)
+	s.state = serverInitialized
+	uri := span.FileURI(filepath.Join(dir, "a.go"))
+	s.symbols.update(uri, "v1", "", []protocol.DetailSymbolInformation{{Qname: "a.F"}})
+
+	// The folders which aren't workspace folders are rejected.
+	if _, err := s.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: indexRebuildCommand, Arguments: []interface{}{"file:///elsewhere"}}); err == nil {
+		t.Errorf("the unknown folder is expected to be rejected")
+	}
+	if _, err := s.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: depsRetryCommand}); err == nil {
+		t.Errorf("the retry without a folder is expected to be rejected")
+	}
+
+	resp, err := s.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: indexRebuildCommand})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.warmUps.stop()
+	if result := resp.(protocol.ElasticCommandResult); len(result.Folders) != 1 || result.Folders[0] != protocol.NewURI(span.FileURI(dir)) {
+		t.Errorf("got the folders %v, want %s", result.Folders, dir)
+	}
+	if rebuilt := s.folderView(dir); rebuilt == nil || rebuilt == view {
+		t.Errorf("the view of the folder is expected to be recreated")
+	}
+	if _, ok := s.symbols.get(uri, "v1"); ok {
+		t.Errorf("the symbols of the folder are expected to be dropped")
+	}
+
+	s.FolderNeedsCleanup.add(dir)
+	resp, err = s.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: depsCleanCommand})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := resp.(protocol.ElasticCommandResult); len(result.Folders) != 1 {
+		t.Errorf("got the folders %v cleaned up, want %s", result.Folders, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); !os.IsNotExist(err) {
+		t.Errorf("the go.mod is expected to be removed")
+	}
+}
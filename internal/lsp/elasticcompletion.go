@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// Completion serves the completion of the embedded server, the items of the objects declared by the other packages
+// carry the symbol locators of the objects as their data, so that the client can go to the definitions in the other
+// repositories and deduplicate the items against its search index.
+func (s *ElasticServer) Completion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
+	if err := s.rejectUnderPressure(); err != nil {
+		return nil, err
+	}
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	// The collector is only created once an item of an object is met, the objects of the package itself are located by
+	// the symbols of the document already.
+	var (
+		collector *referenceCollector
+		failed    bool
+	)
+	return s.Server.completionList(ctx, params, func(item *protocol.CompletionItem, candidate source.CompletionItem) {
+		obj := candidate.Object
+		if obj == nil || obj.Pkg() == nil || failed {
+			return
+		}
+		if collector == nil {
+			c, err := s.fileCollector(ctx, view, uri)
+			if err != nil {
+				log.Error(ctx, "failed to locate the completion items", err, tag.Of("File", uri))
+				failed = true
+				return
+			}
+			collector = c
+		}
+		if obj.Pkg().Path() == collector.pkg.GetTypes().Path() {
+			return
+		}
+		if loc := collector.target(obj); loc != nil && loc.Qname != "" {
+			item.Data = loc
+		}
+	})
+}
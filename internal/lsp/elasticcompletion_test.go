@@ -0,0 +1,50 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCompletionLocators(t *testing.T) {
+	dir := newTestDir(t, "completion", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"b/b.go": "package b\n\nfunc Upper() {}\n",
+		"a/a.go": "package a\n\nimport \"example.com/m/b\"\n\nfunc Up() {}\n\nfunc F() {\n\tb.Up\n\tUp\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a", "a.go")))
+	complete := func(line, character float64, label string) protocol.CompletionItem {
+		list, err := s.Completion(ctx, &protocol.CompletionParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: a},
+			Position:     protocol.Position{Line: line, Character: character},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range list.Items {
+			if item.Label == label {
+				return item
+			}
+		}
+		t.Fatalf("got the items %+v, want %s", list.Items, label)
+		return protocol.CompletionItem{}
+	}
+
+	loc, ok := complete(7, 5, "Upper").Data.(*protocol.SymbolLocator)
+	if !ok || loc.Qname != "b.Upper" || loc.Kind != protocol.Function || loc.Package.Name != "b" {
+		t.Errorf("got the data %+v, want the locator of b.Upper", loc)
+	}
+	// The items of the package itself aren't annotated.
+	if item := complete(8, 3, "Up"); item.Data != nil {
+		t.Errorf("got the data %+v of the local item, want none", item.Data)
+	}
+}
@@ -0,0 +1,164 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// redacted replaces the values of the environment variables and the git configuration looking like secrets.
+const redacted = "<redacted>"
+
+// secretMarkers are the substrings of the names of the environment variables holding the secrets, in upper case.
+var secretMarkers = []string{"TOKEN", "PASSWORD", "SECRET", "_KEY", "CREDENTIAL"}
+
+// EffectiveConfig returns the configuration of the session and of the views, i.e. the options resolved from the
+// defaults, the initialization options, the configuration of the folders and the environment of the go commands.
+func (s *ElasticServer) EffectiveConfig(ctx context.Context, params *protocol.EffectiveConfigParams) (protocol.EffectiveConfig, error) {
+	// The session runs the go command out of any module like the doctor, the views run it in their folders.
+	config := protocol.EffectiveConfig{Session: folderConfig(s.session.Options(), os.TempDir()), Folders: []protocol.FolderConfig{}}
+	for _, view := range s.session.Views() {
+		if params.Folder != "" && span.NewURI(params.Folder) != view.Folder() {
+			continue
+		}
+		folder := folderConfig(view.Options(), view.Folder().Filename())
+		folder.URI = protocol.NewURI(view.Folder())
+		folder.Name = view.Name()
+		config.Folders = append(config.Folders, folder)
+	}
+	return config, nil
+}
+
+// folderConfig returns the configuration reported for the options, the go command is resolved in dir.
+func folderConfig(options source.Options, dir string) protocol.FolderConfig {
+	env := resolveGoEnv(options, dir, options.Env)
+	config := protocol.FolderConfig{
+		Options:    elasticOptionsOf(options),
+		BuildFlags: options.BuildFlags,
+		GoEnv: protocol.EnvMap{
+			"GOROOT":     env.GOROOT,
+			"GOPATH":     env.GOPATH,
+			"GOMODCACHE": env.modCache(),
+			"GOPROXY":    env.GOPROXY,
+			"GOFLAGS":    env.GOFLAGS,
+			"GOPRIVATE":  env.GOPRIVATE,
+		},
+	}
+	for _, kv := range options.Env {
+		if i := strings.IndexByte(kv, '='); i >= 0 && isSecret(kv[:i]) {
+			kv = kv[:i+1] + redacted
+		}
+		config.Env = append(config.Env, kv)
+	}
+	return config
+}
+
+// elasticOptionsOf converts the options back to the elastic options, in the units of the initialization options.
+func elasticOptionsOf(o source.Options) protocol.ElasticOptions {
+	opts := protocol.ElasticOptions{
+		InstallGoDependency: o.InstallGoDependency,
+		DownloadOnModChange: o.DownloadOnModChange,
+		VendorMode:          o.VendorMode,
+		Offline:             o.Offline,
+
+		GoProxies:       o.GoProxies,
+		ProxyCooldown:   o.ProxyCooldown.Seconds(),
+		DownloadRetries: o.DownloadRetries,
+		DownloadBackoff: o.DownloadBackoff.Seconds(),
+		ModCacheQuota:   megabytes(o.ModCacheQuota),
+		ExcludePatterns: o.ExcludePatterns,
+
+		TrustedRoots: o.TrustedRoots,
+		ConfirmTrust: o.ConfirmTrust,
+
+		ImportPathAliases:       o.ImportPathAliases,
+		DetectRepoRedirects:     o.DetectRepoRedirects,
+		MaxQnameDepth:           o.MaxQnameDepth,
+		QualifyLocalDefinitions: o.QualifyLocalDefinitions,
+
+		MemoryLimit:               megabytes(o.MemoryLimit),
+		RejectUnderMemoryPressure: o.RejectUnderMemoryPressure,
+		TypeCheckLimit:            o.TypeCheckLimit,
+		TypeCheckQueue:            o.TypeCheckQueue,
+		TypeCheckQueueTimeout:     o.TypeCheckQueueTimeout.Seconds(),
+		PackageCacheEntries:       o.PackageCacheEntries,
+		PackageCacheBudget:        megabytes(o.PackageCacheBudget),
+		MaxResponseSize:           megabytes(o.MaxResponseSize),
+		SnapshotHistory:           o.SnapshotHistory,
+		LoadParallelism:           o.LoadParallelism,
+		ExportDataDependencies:    o.ExportDataDependencies,
+
+		WarmUpWorkspace:          o.WarmUpWorkspace,
+		WarmStateDir:             o.WarmStateDir,
+		SessionSummaryFile:       o.SessionSummaryFile,
+		CompletionWebhook:        o.CompletionWebhook,
+		ASTDump:                  o.ASTDump,
+		DisableReferenceIndexing: o.DisableReferenceIndexing,
+		FeatureFlags:             o.FeatureFlags,
+	}
+	switch o.QnameStyle {
+	case source.ImportPathQname:
+		opts.QnameStyle = "importPath"
+	case source.ImportPathVersionQname:
+		opts.QnameStyle = "importPathVersion"
+	default:
+		opts.QnameStyle = "packageName"
+	}
+	if creds := o.Credentials; len(credentialEnv(creds)) > 0 {
+		opts.Credentials = &protocol.Credentials{
+			GoPrivate:     creds.GoPrivate,
+			NetrcFile:     creds.NetrcFile,
+			GitAskPass:    creds.GitAskPass,
+			SSHAuthSock:   creds.SSHAuthSock,
+			GitSSHCommand: creds.GitSSHCommand,
+		}
+		// The git configuration may carry the tokens, like the 'http.extraHeader' or the URLs rewritten with the
+		// credentials, only the keys are reported.
+		if len(creds.GitConfig) > 0 {
+			opts.Credentials.GitConfig = make(map[string]string, len(creds.GitConfig))
+			for key := range creds.GitConfig {
+				opts.Credentials.GitConfig[key] = redacted
+			}
+		}
+	}
+	if len(o.RequestTimeouts) > 0 {
+		opts.RequestTimeouts = make(map[string]float64, len(o.RequestTimeouts))
+		for method, timeout := range o.RequestTimeouts {
+			opts.RequestTimeouts[method] = timeout.Seconds()
+		}
+	}
+	if len(o.FolderEnv) > 0 {
+		opts.FolderEnv = make(map[string]protocol.EnvMap, len(o.FolderEnv))
+		for folder, env := range o.FolderEnv {
+			m := make(protocol.EnvMap, len(env))
+			for k, v := range env {
+				if isSecret(k) {
+					v = redacted
+				}
+				m[k] = v
+			}
+			opts.FolderEnv[folder] = m
+		}
+	}
+	return opts
+}
+
+// megabytes converts the bytes to the megabytes of the options.
+func megabytes(n uint64) float64 {
+	return float64(n) / (1 << 20)
+}
+
+// isSecret tells whether the environment variable looks like holding a secret.
+func isSecret(name string) bool {
+	name = strings.ToUpper(name)
+	for _, marker := range secretMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
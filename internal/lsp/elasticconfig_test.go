@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	dir := newTestDir(t, "config", map[string]string{
+		"a/go.mod": "module example.com/a\n\ngo 1.11\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.11\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.TypeCheckQueueTimeout = 1500 * time.Millisecond
+	options.MemoryLimit = 512 << 20
+	options.Credentials = source.CredentialOptions{GoPrivate: "git.example.com/*", GitConfig: map[string]string{"http.extraHeader": "Authorization: token"}}
+	options.FolderEnv = map[string]map[string]string{"b": {"GOFLAGS": "-tags=b", "GITHUB_TOKEN": "token"}}
+	s, _ := newTestServer(ctx, filepath.Join(dir, "a"), "a", options)
+	folderOpts := options
+	applyFolderEnv(&folderOpts, span.FileURI(filepath.Join(dir, "b")), "b")
+	s.session.NewView(ctx, "b", span.FileURI(filepath.Join(dir, "b")), folderOpts)
+
+	config, err := s.EffectiveConfig(ctx, &protocol.EffectiveConfigParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Session.Options; got.TypeCheckQueueTimeout != 1.5 || got.MemoryLimit != 512 || got.Credentials == nil || got.Credentials.GoPrivate != "git.example.com/*" {
+		t.Errorf("got the session options %+v", got)
+	}
+	if got := config.Session.Options.Credentials.GitConfig["http.extraHeader"]; got != redacted {
+		t.Errorf("got the git configuration %q, want it redacted", got)
+	}
+	if got := config.Session.Options.FolderEnv["b"]["GITHUB_TOKEN"]; got != redacted {
+		t.Errorf("got the folder environment %q, want it redacted", got)
+	}
+	if config.Session.GoEnv["GOROOT"] == "" {
+		t.Errorf("got the go environment %v, want GOROOT", config.Session.GoEnv)
+	}
+	if len(config.Folders) != 2 {
+		t.Fatalf("got the folders %+v, want a and b", config.Folders)
+	}
+
+	config, err = s.EffectiveConfig(ctx, &protocol.EffectiveConfigParams{Folder: protocol.NewURI(span.FileURI(filepath.Join(dir, "b")))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Folders) != 1 || config.Folders[0].Name != "b" {
+		t.Fatalf("got the folders %+v, want b", config.Folders)
+	}
+	b := config.Folders[0]
+	env := strings.Join(b.Env, " ")
+	if !strings.Contains(env, "GITHUB_TOKEN="+redacted) || strings.Contains(env, "GITHUB_TOKEN=token") {
+		t.Errorf("got the environment %q, want GITHUB_TOKEN redacted", b.Env)
+	}
+	// The go command resolves the environment of the folder.
+	if got := b.GoEnv["GOFLAGS"]; got != "-tags=b" {
+		t.Errorf("got GOFLAGS %q of the folder, want -tags=b", got)
+	}
+}
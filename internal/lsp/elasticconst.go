@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// collectConstGroups collects the enum-like groups of the constants declared in the file, i.e. the constants declared
+// in the same const block with iota. The constants are keyed by their qualified names.
+//
+// If the constants are of a named type, the group is identified by the qualified name of the type, so that the blocks
+// of the same type declared across the files are rendered as one group. Otherwise the group is identified by the
+// qualified name of the first constant in the block. The identifiers of the groups are styled by qualify, while the
+// constants are keyed by the short forms.
+func collectConstGroups(file *ast.File, info *types.Info, pkg *types.Package, qualify func(string, *types.Package) string) map[string]protocol.ConstGroup {
+	groups := make(map[string]protocol.ConstGroup)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST || !gen.Lparen.IsValid() || !usesIota(gen, info) {
+			continue
+		}
+		var group string
+		ordinal := 0
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				obj, ok := info.Defs[name].(*types.Const)
+				if !ok {
+					continue
+				}
+				if group == "" {
+					group = constGroupOf(obj, pkg, qualify)
+				}
+				if name.Name != "_" {
+					groups[pkg.Name()+"."+name.Name] = protocol.ConstGroup{Group: group, Ordinal: ordinal}
+				}
+				ordinal++
+			}
+		}
+	}
+	return groups
+}
+
+// constGroupOf returns the identifier of the group led by the constant.
+func constGroupOf(obj *types.Const, pkg *types.Package, qualify func(string, *types.Package) string) string {
+	if named, ok := obj.Type().(*types.Named); ok && named.Obj().Pkg() != nil {
+		typePkg := named.Obj().Pkg()
+		return qualify(typePkg.Name()+"."+named.Obj().Name(), typePkg)
+	}
+	return qualify(pkg.Name()+"."+obj.Name(), pkg)
+}
+
+// usesIota reports whether any constant in the const block is defined with iota.
+func usesIota(gen *ast.GenDecl, info *types.Info) bool {
+	found := false
+	for _, spec := range gen.Specs {
+		for _, value := range spec.(*ast.ValueSpec).Values {
+			ast.Inspect(value, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == "iota" && info.Uses[id] == types.Universe.Lookup("iota") {
+					found = true
+				}
+				return !found
+			})
+		}
+	}
+	return found
+}
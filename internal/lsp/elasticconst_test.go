@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+const constGroupsSrc = `package p
+
+type State int
+
+const (
+	StateA State = iota
+	_
+	StateB
+	StateC
+)
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+
+const (
+	X = 1
+	Y = 2
+)
+
+const Single = iota
+`
+
+func TestCollectConstGroups(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", constGroupsSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	pkg, err := (&types.Config{}).Check("p", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]protocol.ConstGroup{
+		"p.StateA": {Group: "p.State", Ordinal: 0},
+		"p.StateB": {Group: "p.State", Ordinal: 2},
+		"p.StateC": {Group: "p.State", Ordinal: 3},
+		"p.KB":     {Group: "p.KB", Ordinal: 0},
+		"p.MB":     {Group: "p.KB", Ordinal: 1},
+	}
+	if got := collectConstGroups(file, info, pkg, func(qname string, _ *types.Package) string { return qname }); !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// credentialEnv returns the environment variables passing the credentials to the go commands, which override the
+// ones of the process. The git configuration is passed by 'GIT_CONFIG_COUNT', which is supported since git 2.31, in
+// the order of the keys so that the environment is stable.
+func credentialEnv(creds source.CredentialOptions) []string {
+	var env []string
+	for _, v := range []struct{ name, value string }{
+		{"GOPRIVATE", creds.GoPrivate},
+		{"NETRC", creds.NetrcFile},
+		{"GIT_ASKPASS", creds.GitAskPass},
+		{"SSH_AUTH_SOCK", creds.SSHAuthSock},
+		{"GIT_SSH_COMMAND", creds.GitSSHCommand},
+	} {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	if len(creds.GitConfig) == 0 {
+		return env
+	}
+	keys := make([]string, 0, len(creds.GitConfig))
+	for key := range creds.GitConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(keys)))
+	for i, key := range keys {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, creds.GitConfig[key]))
+	}
+	return env
+}
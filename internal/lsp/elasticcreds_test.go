@@ -0,0 +1,65 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestCredentialEnv(t *testing.T) {
+	options := source.DefaultOptions
+	results := source.SetOptions(&options, map[string]interface{}{
+		"credentials": map[string]interface{}{
+			"goPrivate":   "git.example.com/*",
+			"netrcFile":   "/secrets/netrc",
+			"sshAuthSock": "/run/agent.sock",
+			"gitConfig": map[string]interface{}{
+				"url.https://token@git.example.com/.insteadOf": "https://git.example.com/",
+				"credential.helper":                            "store",
+			},
+		},
+	})
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	want := []string{
+		"GOPRIVATE=git.example.com/*",
+		"NETRC=/secrets/netrc",
+		"SSH_AUTH_SOCK=/run/agent.sock",
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=credential.helper",
+		"GIT_CONFIG_VALUE_0=store",
+		"GIT_CONFIG_KEY_1=url.https://token@git.example.com/.insteadOf",
+		"GIT_CONFIG_VALUE_1=https://git.example.com/",
+	}
+	got := credentialEnv(options.Credentials)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got the environment %q, want %q", got, want)
+	}
+	if env := credentialEnv(source.CredentialOptions{}); len(env) != 0 {
+		t.Errorf("got the environment %q of no credentials, want none", env)
+	}
+
+	// The credentials override the ones of the process in the go commands.
+	env := append(append(os.Environ(), "GOPRIVATE=other.example.com"), got...)
+	stdout, err := runGoCommand(context.Background(), source.DefaultOptions, os.TempDir(), env, "env", "GOPRIVATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goPrivate := strings.TrimSpace(stdout.String()); goPrivate != "git.example.com/*" {
+		t.Errorf("got GOPRIVATE %q, want git.example.com/*", goPrivate)
+	}
+
+	for _, creds := range []interface{}{"token", map[string]interface{}{"netrcFile": 1}, map[string]interface{}{"password": "x"}} {
+		options := source.DefaultOptions
+		results := source.SetOptions(&options, map[string]interface{}{"credentials": creds})
+		if len(results) != 1 || results[0].Error == nil {
+			t.Errorf("the credentials %v are expected to be rejected", creds)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// depsRuns keeps track of the running dependency management, so that it's aborted, along with the go commands it
+// spawns, once the server shuts down or the workspace folders it manages are removed.
+type depsRuns struct {
+	mu   sync.Mutex
+	next int
+	runs map[int]depsRun
+}
+
+type depsRun struct {
+	folders []string
+	cancel  context.CancelFunc
+}
+
+// start registers the run managing the folders, the returned context is canceled once the run is aborted, and done
+// must be called once the run finishes.
+func (r *depsRuns) start(ctx context.Context, folders []string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.runs == nil {
+		r.runs = make(map[int]depsRun)
+	}
+	id := r.next
+	r.next++
+	r.runs[id] = depsRun{folders: folders, cancel: cancel}
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.runs, id)
+		r.mu.Unlock()
+		cancel()
+	}
+}
+
+// abort cancels the runs managing any of the folders.
+func (r *depsRuns) abort(folders []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, run := range r.runs {
+	FOLDERS:
+		for _, folder := range run.folders {
+			for _, removed := range folders {
+				if hostPaths.equal(folder, removed) {
+					run.cancel()
+					break FOLDERS
+				}
+			}
+		}
+	}
+}
+
+// running returns the number of the runs in progress.
+func (r *depsRuns) running() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.runs)
+}
+
+// managing reports whether any of the runs manages the folder.
+func (r *depsRuns) managing(folder string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, run := range r.runs {
+		for _, f := range run.folders {
+			if hostPaths.equal(f, folder) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stop cancels all the runs.
+func (r *depsRuns) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, run := range r.runs {
+		run.cancel()
+	}
+}
+
+// DidChangeWorkspaceFolders aborts the dependency management of the removed folders before removing their views, and
+// tears down the state of the folders once their views are removed.
+func (s *ElasticServer) DidChangeWorkspaceFolders(ctx context.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	var removed []string
+	for _, folder := range params.Event.Removed {
+		removed = append(removed, span.NewURI(folder.URI).Filename())
+	}
+	s.depsRuns.abort(removed)
+	views := s.removedViews(params.Event)
+	if err := s.Server.DidChangeWorkspaceFolders(ctx, params); err != nil {
+		return err
+	}
+	s.teardownFolders(ctx, views, params.Event)
+	return nil
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDepsRuns(t *testing.T) {
+	var runs depsRuns
+	ctx := context.Background()
+	ctxA, doneA := runs.start(ctx, []string{"/repo/a"})
+	defer doneA()
+	ctxB, doneB := runs.start(ctx, []string{"/repo/b", "/repo/c"})
+	defer doneB()
+
+	runs.abort([]string{"/repo/c/"})
+	if ctxA.Err() != nil || ctxB.Err() == nil {
+		t.Errorf("got %v of the run of a and %v of the run of b, c, want only the latter to be aborted", ctxA.Err(), ctxB.Err())
+	}
+	runs.stop()
+	if ctxA.Err() == nil {
+		t.Errorf("all the runs are expected to be aborted")
+	}
+	doneA()
+	doneB()
+	if len(runs.runs) != 0 {
+		t.Errorf("got %d runs left, want none", len(runs.runs))
+	}
+}
+
+func TestRunCommandKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" && runtime.GOOS != "linux" {
+		t.Skip("the process groups are only supported on unix")
+	}
+	dir, err := ioutil.TempDir("", "exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		// The grandchild holds the stdout of the command, which blocks the command until it exits.
+		_, err := sandbox{}.run(ctx, dir, os.Environ(), "sh", "-c", "sleep 60 & echo $! > "+pidFile+"; wait")
+		errc <- err
+	}()
+	var pid int
+	for deadline := time.Now().Add(10 * time.Second); pid == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the command never started")
+		}
+		if data, err := ioutil.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+	}
+	cancel()
+	select {
+	case err := <-errc:
+		if err == nil || !strings.Contains(err.Error(), "canceled") {
+			t.Errorf("got %v, want the cancellation", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the command is expected to return once canceled")
+	}
+	// The grandchild is killed along with the command.
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		p, err := os.FindProcess(pid)
+		if err != nil || p.Signal(syscall.Signal(0)) != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the process %d outlives the command", pid)
+		}
+	}
+
+	if _, err := (sandbox{}).run(ctx, dir, os.Environ(), "true"); err == nil {
+		t.Errorf("the command is expected not to start under the canceled context")
+	}
+}
@@ -0,0 +1,104 @@
+package lsp
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// checkDiagnostics keeps track of the files which the errors of the type-checks by the elastic requests are published
+// for, so that the diagnostics are cleared once the errors are fixed.
+type checkDiagnostics struct {
+	mu        sync.Mutex
+	published map[span.URI]bool
+}
+
+// publishCheckErrors checks the package of the file and publishes its errors, it's used once a request fails since the
+// package is broken.
+func (s *ElasticServer) publishCheckErrors(ctx context.Context, snapshot source.Snapshot, f source.File) {
+	var pkg source.Package
+	cphs, err := snapshot.CheckPackageHandles(ctx, f)
+	if err == nil {
+		pkg, err = source.NarrowestCheckPackageHandle(cphs).Check(ctx)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	s.publishPackageErrors(ctx, f.URI(), pkg, err)
+}
+
+// publishPackageErrors publishes the errors of the type-check of the package of the file, like the compiler errors and
+// the missing modules, so that the client tells why the results are empty or partial. The error failing the check is
+// reported at the top of the file. The open files are left to the diagnostics of the embedded server.
+func (s *ElasticServer) publishPackageErrors(ctx context.Context, uri span.URI, pkg source.Package, checkErr error) {
+	if s.client == nil {
+		return
+	}
+	// The file of the request is always reported, so that its diagnostics are cleared once fixed.
+	reports := map[span.URI][]protocol.Diagnostic{uri: {}}
+	if checkErr != nil {
+		reports[uri] = append(reports[uri], protocol.Diagnostic{
+			Severity: protocol.SeverityError,
+			Source:   "go list",
+			Message:  strings.TrimSpace(checkErr.Error()),
+		})
+	}
+	if pkg != nil {
+		for _, perr := range pkg.GetErrors() {
+			errURI, diag := packageErrorDiagnostic(perr)
+			if errURI == "" {
+				errURI = uri
+			}
+			reports[errURI] = append(reports[errURI], diag)
+		}
+	}
+
+	s.checkDiags.mu.Lock()
+	defer s.checkDiags.mu.Unlock()
+	if s.checkDiags.published == nil {
+		s.checkDiags.published = make(map[span.URI]bool)
+	}
+	for errURI, diags := range reports {
+		if s.session.IsOpen(errURI) || len(diags) == 0 && !s.checkDiags.published[errURI] {
+			continue
+		}
+		s.checkDiags.published[errURI] = len(diags) > 0
+		s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+			URI:         protocol.NewURI(errURI),
+			Diagnostics: diags,
+		})
+	}
+}
+
+// packageErrorDiagnostic converts the error of the package to a diagnostic located by the position of the error, the
+// URI is empty if the error has no position, like the errors of the missing modules.
+func packageErrorDiagnostic(perr packages.Error) (span.URI, protocol.Diagnostic) {
+	diag := protocol.Diagnostic{
+		Severity: protocol.SeverityError,
+		Source:   "compiler",
+		Message:  strings.TrimSpace(perr.Msg),
+	}
+	switch perr.Kind {
+	case packages.ListError:
+		diag.Source = "go list"
+	case packages.ParseError:
+		diag.Source = "syntax"
+	}
+	if perr.Pos == "" || perr.Pos == "-" {
+		return "", diag
+	}
+	spn := span.Parse(perr.Pos)
+	if !spn.IsValid() {
+		return "", diag
+	}
+	if spn.HasPosition() {
+		pos := protocol.Position{Line: float64(spn.Start().Line() - 1), Character: float64(spn.Start().Column() - 1)}
+		diag.Range = protocol.Range{Start: pos, End: pos}
+	}
+	return spn.URI(), diag
+}
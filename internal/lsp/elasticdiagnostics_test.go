@@ -0,0 +1,76 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// diagnosticsClient records the diagnostics published.
+type diagnosticsClient struct {
+	protocol.Client
+	mu          sync.Mutex
+	diagnostics map[protocol.DocumentURI][]protocol.Diagnostic
+}
+
+func (c *diagnosticsClient) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.diagnostics[params.URI] = params.Diagnostics
+	return nil
+}
+
+func TestFullPublishesCheckErrors(t *testing.T) {
+	dir := newTestDir(t, "diagnostics", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "package a\n\nfunc F() {}\n",
+		"b.go":   "package a\n\nvar V int = \"v\"\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	client := &diagnosticsClient{diagnostics: make(map[protocol.DocumentURI][]protocol.Diagnostic)}
+	s, _ := newTestServer(ctx, dir, "a", options)
+	s.client = client
+
+	a := span.FileURI(filepath.Join(dir, "a.go"))
+	if _, err := s.Full(ctx, &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(a)}}); err != nil {
+		t.Fatal(err)
+	}
+	b := protocol.NewURI(span.FileURI(filepath.Join(dir, "b.go")))
+	if diags := client.diagnostics[b]; len(diags) != 1 || diags[0].Range.Start.Line != 2 {
+		t.Errorf("got the diagnostics %v of b.go, want the type error at the line 3", diags)
+	}
+	if _, ok := client.diagnostics[protocol.NewURI(a)]; ok {
+		t.Errorf("nothing is expected to be published for a.go without the errors")
+	}
+
+	// The failure of the check is reported at the top of the file, and cleared once the package is fixed.
+	s.publishPackageErrors(ctx, a, nil, errors.New("cannot find module providing package example.com/missing"))
+	if diags := client.diagnostics[protocol.NewURI(a)]; len(diags) != 1 || diags[0].Source != "go list" {
+		t.Errorf("got the diagnostics %v of a.go, want the failure of the check", diags)
+	}
+	s.publishPackageErrors(ctx, a, nil, nil)
+	if diags, ok := client.diagnostics[protocol.NewURI(a)]; !ok || len(diags) != 0 {
+		t.Errorf("got the diagnostics %v of a.go, want them cleared", diags)
+	}
+}
+
+func TestPackageErrorDiagnostic(t *testing.T) {
+	uri, diag := packageErrorDiagnostic(packages.Error{Pos: "/src/a/a.go:3:5", Msg: "undeclared name: x", Kind: packages.TypeError})
+	if uri != span.FileURI("/src/a/a.go") || diag.Range.Start.Line != 2 || diag.Range.Start.Character != 4 || diag.Source != "compiler" {
+		t.Errorf("got %s %+v, want the diagnostic at 3:5 of a.go", uri, diag)
+	}
+	uri, diag = packageErrorDiagnostic(packages.Error{Pos: "-", Msg: "cannot find module", Kind: packages.ListError})
+	if uri != "" || diag.Source != "go list" {
+		t.Errorf("got %s %+v, want the diagnostic without a position", uri, diag)
+	}
+}
@@ -0,0 +1,209 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+const (
+	// defaultMinFreeSpace is the least free disk space of the module cache in MB if the client doesn't specify one.
+	defaultMinFreeSpace = 1024
+	// proxyTimeout is the time box of probing the module proxy.
+	proxyTimeout = 5 * time.Second
+)
+
+// The names of the checks of the doctor.
+const (
+	checkGo          = "go"
+	checkProxy       = "proxy"
+	checkModCache    = "modcache"
+	checkDiskSpace   = "disk"
+	checkMemoryLimit = "memory"
+)
+
+// Doctor validates whether the environment of the server is ready for indexing, the misconfiguration of the
+// environment, like the missing go binary or the unreachable module proxy, is reported before the indexing jobs fail.
+func (s *ElasticServer) Doctor(ctx context.Context, params *protocol.DoctorParams) (protocol.DoctorReport, error) {
+	return DiagnoseEnvironment(ctx, s.session.Options(), params.MinFreeSpace), nil
+}
+
+// DiagnoseEnvironment runs the checks of the environment under the options, minFreeSpace is the least free disk space
+// of the module cache in MB, the default is used if it's not positive.
+func DiagnoseEnvironment(ctx context.Context, options source.Options, minFreeSpace float64) protocol.DoctorReport {
+	if minFreeSpace <= 0 {
+		minFreeSpace = defaultMinFreeSpace
+	}
+	report := protocol.DoctorReport{Passed: true}
+	add := func(check protocol.DoctorCheck) {
+		if check.Status == protocol.DoctorFail {
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	// The go command runs out of any module, so that the checks aren't affected by the go.mod of the working directory.
+	dir := os.TempDir()
+	version, err := runGoCommand(ctx, options, dir, options.Env, "version")
+	if err != nil {
+		add(protocol.DoctorCheck{Name: checkGo, Status: protocol.DoctorFail, Detail: err.Error()})
+		for _, name := range []string{checkProxy, checkModCache, checkDiskSpace} {
+			add(protocol.DoctorCheck{Name: name, Status: protocol.DoctorSkip, Detail: "the go command is unavailable"})
+		}
+		add(diagnoseMemoryLimit(options.MemoryLimit, availableMemory()))
+		return report
+	}
+	add(protocol.DoctorCheck{Name: checkGo, Status: protocol.DoctorPass, Detail: strings.TrimSpace(version.String())})
+
+	var goproxy, modCache, gopath string
+	if stdout, err := runGoCommand(ctx, options, dir, options.Env, "env", "GOPROXY", "GOMODCACHE", "GOPATH"); err == nil {
+		vars := strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+		if len(vars) == 3 {
+			goproxy, modCache, gopath = vars[0], vars[1], vars[2]
+		}
+	}
+	modCache = goEnv{GOPATH: gopath, GOMODCACHE: modCache}.modCache()
+
+	if options.Offline {
+		add(protocol.DoctorCheck{Name: checkProxy, Status: protocol.DoctorSkip, Detail: "offline mode"})
+	} else {
+		add(diagnoseProxy(ctx, goproxy))
+	}
+	if modCache == "" {
+		add(protocol.DoctorCheck{Name: checkModCache, Status: protocol.DoctorFail, Detail: "failed to locate the module cache"})
+		add(protocol.DoctorCheck{Name: checkDiskSpace, Status: protocol.DoctorSkip, Detail: "the module cache is unknown"})
+	} else {
+		add(diagnoseModCache(modCache))
+		add(diagnoseDiskSpace(modCache, uint64(minFreeSpace*(1<<20))))
+	}
+	add(diagnoseMemoryLimit(options.MemoryLimit, availableMemory()))
+	return report
+}
+
+// diagnoseProxy checks whether the first module proxy in goproxy is reachable, any response of the proxy other than
+// the server errors means it's reachable.
+func diagnoseProxy(ctx context.Context, goproxy string) protocol.DoctorCheck {
+	check := protocol.DoctorCheck{Name: checkProxy}
+	proxy := strings.TrimSpace(strings.FieldsFunc(goproxy+",", func(r rune) bool { return r == ',' || r == '|' })[0])
+	switch proxy {
+	case "", "off":
+		check.Status, check.Detail = protocol.DoctorFail, "the module proxy is disabled by GOPROXY="+goproxy
+		return check
+	case "direct":
+		check.Status, check.Detail = protocol.DoctorSkip, "the modules are downloaded from the repositories directly"
+		return check
+	}
+	ctx, cancel := context.WithTimeout(ctx, proxyTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, proxy, nil)
+	if err != nil {
+		check.Status, check.Detail = protocol.DoctorFail, err.Error()
+		return check
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		check.Status, check.Detail = protocol.DoctorFail, err.Error()
+		return check
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		check.Status, check.Detail = protocol.DoctorFail, proxy+" responded "+resp.Status
+		return check
+	}
+	check.Status, check.Detail = protocol.DoctorPass, proxy+" is reachable"
+	return check
+}
+
+// diagnoseModCache checks whether the module cache is writable, the cache is created if it doesn't exist yet.
+func diagnoseModCache(modCache string) protocol.DoctorCheck {
+	check := protocol.DoctorCheck{Name: checkModCache, Status: protocol.DoctorFail}
+	if err := os.MkdirAll(modCache, 0755); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	f, err := ioutil.TempFile(modCache, ".doctor-")
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+	check.Status, check.Detail = protocol.DoctorPass, modCache+" is writable"
+	return check
+}
+
+// diagnoseDiskSpace checks whether the free disk space of the module cache is at least minFree bytes.
+func diagnoseDiskSpace(modCache string, minFree uint64) protocol.DoctorCheck {
+	check := protocol.DoctorCheck{Name: checkDiskSpace}
+	free, err := freeDiskSpace(modCache)
+	if err != nil {
+		check.Status, check.Detail = protocol.DoctorSkip, err.Error()
+		return check
+	}
+	if free < minFree {
+		check.Status = protocol.DoctorFail
+		check.Detail = fmt.Sprintf("%d MB free in %s, at least %d MB is required", free>>20, modCache, minFree>>20)
+		return check
+	}
+	check.Status, check.Detail = protocol.DoctorPass, fmt.Sprintf("%d MB free in %s", free>>20, modCache)
+	return check
+}
+
+// diagnoseMemoryLimit checks whether the configured memory limit fits in the available memory, both are in bytes and
+// zero means unknown or unlimited.
+func diagnoseMemoryLimit(limit, available uint64) protocol.DoctorCheck {
+	check := protocol.DoctorCheck{Name: checkMemoryLimit}
+	switch {
+	case available == 0:
+		check.Status, check.Detail = protocol.DoctorSkip, "the available memory is unknown"
+	case limit == 0:
+		check.Status = protocol.DoctorPass
+		check.Detail = fmt.Sprintf("%d MB available, the memory limit isn't configured", available>>20)
+	case limit > available:
+		check.Status = protocol.DoctorFail
+		check.Detail = fmt.Sprintf("the memory limit %d MB exceeds the available %d MB", limit>>20, available>>20)
+	default:
+		check.Status = protocol.DoctorPass
+		check.Detail = fmt.Sprintf("the memory limit %d MB fits in the available %d MB", limit>>20, available>>20)
+	}
+	return check
+}
+
+// availableMemory returns the memory available to the process in bytes, i.e. the smaller one of the cgroup limit and
+// the physical memory. It returns 0 if neither is known.
+func availableMemory() uint64 {
+	var available uint64
+	// The limits of cgroup v2 and v1 respectively, the unlimited ones are either 'max' or a huge number.
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			if limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
+				available = limit
+				break
+			}
+		}
+	}
+	if data, err := ioutil.ReadFile("/proc/meminfo"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 || fields[0] != "MemTotal:" {
+				continue
+			}
+			if kb, err := strconv.ParseUint(fields[1], 10, 64); err == nil && (available == 0 || kb<<10 < available) {
+				available = kb << 10
+			}
+			break
+		}
+	}
+	return available
+}
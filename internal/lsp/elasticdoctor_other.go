@@ -0,0 +1,13 @@
+// +build !darwin,!freebsd,!linux
+
+package lsp
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// freeDiskSpace isn't supported on this platform.
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("the free disk space is unknown on %s", runtime.GOOS)
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestDiagnoseProxy(t *testing.T) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer live.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	ctx := context.Background()
+	for goproxy, want := range map[string]protocol.DoctorStatus{
+		live.URL + ",direct":   protocol.DoctorPass,
+		broken.URL + "|direct": protocol.DoctorFail,
+		"off":                  protocol.DoctorFail,
+		"direct":               protocol.DoctorSkip,
+	} {
+		if got := diagnoseProxy(ctx, goproxy); got.Status != want {
+			t.Errorf("GOPROXY=%s: got %v, want %s", goproxy, got, want)
+		}
+	}
+}
+
+func TestDiagnoseModCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modCache := filepath.Join(dir, "pkg", "mod")
+	if got := diagnoseModCache(modCache); got.Status != protocol.DoctorPass {
+		t.Errorf("got %v", got)
+	}
+	if files, _ := ioutil.ReadDir(modCache); len(files) != 0 {
+		t.Errorf("the probe file is left in the module cache: %v", files)
+	}
+	// The module cache can't be created under a regular file.
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := diagnoseModCache(filepath.Join(dir, "file", "mod")); got.Status != protocol.DoctorFail {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestDiagnoseMemoryLimit(t *testing.T) {
+	for _, test := range []struct {
+		limit, available uint64
+		want             protocol.DoctorStatus
+	}{
+		{1 << 30, 0, protocol.DoctorSkip},
+		{0, 4 << 30, protocol.DoctorPass},
+		{1 << 30, 4 << 30, protocol.DoctorPass},
+		{8 << 30, 4 << 30, protocol.DoctorFail},
+	} {
+		if got := diagnoseMemoryLimit(test.limit, test.available); got.Status != test.want {
+			t.Errorf("limit %d, available %d: got %v, want %s", test.limit, test.available, got, test.want)
+		}
+	}
+}
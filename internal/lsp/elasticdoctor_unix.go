@@ -0,0 +1,14 @@
+// +build darwin freebsd linux
+
+package lsp
+
+import "syscall"
+
+// freeDiskSpace returns the disk space in bytes available to the unprivileged users on the file system containing path.
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
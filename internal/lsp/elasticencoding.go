@@ -0,0 +1,110 @@
+package lsp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// compressThreshold is the bytes of the encoded symbols and references from which the 'full' responses are compressed,
+// the smaller ones aren't worth the cost of the compression.
+const compressThreshold = 64 << 10
+
+// fullItem returns the i-th item of the response, the symbols are followed by the references as the pages are.
+func fullItem(resp protocol.FullResponse, i int) interface{} {
+	if i < len(resp.Symbols) {
+		return resp.Symbols[i]
+	}
+	return resp.References[i-len(resp.Symbols)]
+}
+
+// capFullPage returns the limit of the page of 'Full' from the offset, so that the encoded symbols and references of
+// the page take at most maxBytes. The items are measured one by one, rather than the response being marshaled as a
+// whole. The limit of the request is returned as is if the page is within the cap or maxBytes is zero, and a page
+// holds at least one item.
+func capFullPage(resp protocol.FullResponse, offset, limit int, maxBytes uint64) int {
+	if maxBytes == 0 {
+		return limit
+	}
+	total := len(resp.Symbols) + len(resp.References)
+	var size uint64
+	for n := 0; offset+n < total && (limit <= 0 || n < limit); n++ {
+		data, err := json.Marshal(fullItem(resp, offset+n))
+		if err != nil {
+			break
+		}
+		// The item is followed by a comma.
+		size += uint64(len(data)) + 1
+		if size > maxBytes && n > 0 {
+			return n
+		}
+	}
+	return limit
+}
+
+// encodeFull compresses the symbols and the references of the response by the content coding, once their encoding
+// exceeds compressThreshold. They're encoded item by item into the compressed data, so that the uncompressed encoding
+// is never held as a whole. The response is returned as is if the coding is empty.
+func encodeFull(resp protocol.FullResponse, coding string) (protocol.FullResponse, error) {
+	if coding != "gzip" {
+		return resp, nil
+	}
+	var plain, compressed bytes.Buffer
+	var zw *gzip.Writer
+	write := func(data []byte) error {
+		if zw != nil {
+			_, err := zw.Write(data)
+			return err
+		}
+		plain.Write(data)
+		if plain.Len() <= compressThreshold {
+			return nil
+		}
+		zw = gzip.NewWriter(&compressed)
+		_, err := zw.Write(plain.Bytes())
+		plain.Reset()
+		return err
+	}
+	total := len(resp.Symbols) + len(resp.References)
+	if err := write([]byte(`{"symbols":[`)); err != nil {
+		return resp, err
+	}
+	for i := 0; i < total; i++ {
+		switch {
+		case i == len(resp.Symbols):
+			if err := write([]byte(`],"references":[`)); err != nil {
+				return resp, err
+			}
+		case i > 0:
+			if err := write([]byte(`,`)); err != nil {
+				return resp, err
+			}
+		}
+		data, err := json.Marshal(fullItem(resp, i))
+		if err != nil {
+			return resp, err
+		}
+		if err := write(data); err != nil {
+			return resp, err
+		}
+	}
+	if len(resp.References) == 0 {
+		if err := write([]byte(`],"references":[`)); err != nil {
+			return resp, err
+		}
+	}
+	if err := write([]byte(`]}`)); err != nil {
+		return resp, err
+	}
+	if zw == nil {
+		return resp, nil
+	}
+	if err := zw.Close(); err != nil {
+		return resp, err
+	}
+	resp.Symbols, resp.References = []protocol.DetailSymbolInformation{}, []protocol.Reference{}
+	resp.Encoding, resp.Data = coding, compressed.Bytes()
+	return resp, nil
+}
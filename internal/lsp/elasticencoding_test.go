@@ -0,0 +1,133 @@
+package lsp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestCapFullPage(t *testing.T) {
+	var resp protocol.FullResponse
+	for i := 0; i < 10; i++ {
+		resp.Symbols = append(resp.Symbols, protocol.DetailSymbolInformation{Qname: fmt.Sprintf("p.S%d", i)})
+		resp.References = append(resp.References, protocol.Reference{Target: protocol.SymbolLocator{Qname: fmt.Sprintf("p.R%d", i)}})
+	}
+	data, err := json.Marshal(resp.Symbols[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The cap fits three symbols, the references are larger.
+	maxBytes := uint64(3 * (len(data) + 1))
+
+	var items []string
+	cursor := ""
+	for i := 0; i < 100; i++ {
+		offset, err := parseFullCursor(cursor, "v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := pageFull(resp, offset, capFullPage(resp, offset, 0, maxBytes), "v1")
+		if n := len(page.Symbols) + len(page.References); n == 0 || n > 3 {
+			t.Fatalf("got %d items of the page at %d, want at most 3", n, offset)
+		}
+		for _, sym := range page.Symbols {
+			items = append(items, sym.Qname)
+		}
+		for _, ref := range page.References {
+			items = append(items, ref.Target.Qname)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(items) != 20 || items[0] != "p.S0" || items[19] != "p.R9" {
+		t.Errorf("got the items %v of the pages, want all of them", items)
+	}
+
+	// The limits of the requests within the cap are kept.
+	if got := capFullPage(resp, 0, 2, maxBytes); got != 2 {
+		t.Errorf("got the limit %d, want 2 of the request", got)
+	}
+	if got := capFullPage(resp, 0, 0, 0); got != 0 {
+		t.Errorf("got the limit %d without the cap, want 0", got)
+	}
+	// A page holds at least one item.
+	if got := capFullPage(resp, 0, 0, 1); got != 1 {
+		t.Errorf("got the limit %d under the tiny cap, want 1", got)
+	}
+}
+
+func TestEncodeFull(t *testing.T) {
+	var resp protocol.FullResponse
+	for i := 0; i < 2000; i++ {
+		resp.Symbols = append(resp.Symbols, protocol.DetailSymbolInformation{Qname: fmt.Sprintf("p.S%d", i)})
+	}
+	resp.References = []protocol.Reference{{Target: protocol.SymbolLocator{Qname: "p.R"}}}
+	encoded, err := encodeFull(resp, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded.Encoding != "gzip" || len(encoded.Symbols) != 0 || len(encoded.References) != 0 {
+		t.Fatalf("got the encoding %q with %d symbols, want the symbols compressed by gzip", encoded.Encoding, len(encoded.Symbols))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(encoded.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Symbols    []protocol.DetailSymbolInformation `json:"symbols"`
+		References []protocol.Reference               `json:"references"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("got the malformed data %s: %v", data, err)
+	}
+	if !reflect.DeepEqual(decoded.Symbols, resp.Symbols) || !reflect.DeepEqual(decoded.References, resp.References) {
+		t.Errorf("got the decoded symbols and references different from the response")
+	}
+
+	// The small responses and the clients accepting no compression get the responses as is.
+	small := protocol.FullResponse{Symbols: resp.Symbols[:1], References: []protocol.Reference{}}
+	for _, test := range []struct {
+		resp   protocol.FullResponse
+		coding string
+	}{
+		{small, "gzip"},
+		{resp, ""},
+	} {
+		got, err := encodeFull(test.resp, test.coding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.resp) {
+			t.Errorf("got the response encoded by %q, want it as is", test.coding)
+		}
+	}
+}
+
+func TestCompressionCapability(t *testing.T) {
+	for _, test := range []struct {
+		accepted []string
+		want     string
+	}{
+		{nil, ""},
+		{[]string{"zstd"}, ""},
+		{[]string{"zstd", "gzip"}, "gzip"},
+	} {
+		caps := parseClientCapabilities(map[string]interface{}{
+			"elastic": map[string]interface{}{"compression": test.accepted},
+		})
+		if got := caps.compression(); got != test.want {
+			t.Errorf("%v: got the coding %q, want %q", test.accepted, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package lsp
+
+import (
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// excludeRule is a pattern in the syntax of '.gitignore', which applies to the paths under its base directory.
+type excludeRule struct {
+	// base is the directory declaring the rule, relative to the root of the scanning in the slash form, empty for the
+	// root itself.
+	base string
+	// elems are the elements of the pattern separated by '/', a '**' element matches any number of the elements.
+	elems []string
+	// anchored rules match the paths relative to the base, the others match the names at any depth.
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// parseExcludeRule parses a line of '.gitignore' declared in the base directory, the blank lines and the comments
+// are reported as not ok.
+func parseExcludeRule(base, line string) (excludeRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return excludeRule{}, false
+	}
+	rule := excludeRule{base: base}
+	if line[0] == '!' {
+		rule.negate, line = true, line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	// The patterns with a separator other than the trailing one are relative to the base.
+	if strings.Contains(line, "/") {
+		rule.anchored, line = true, strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return excludeRule{}, false
+	}
+	rule.elems = strings.Split(line, "/")
+	return rule, true
+}
+
+// match reports whether the rule matches the path relative to the root of the scanning in the slash form.
+func (r excludeRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if !r.anchored {
+		return matchElems(r.elems, []string{path.Base(rel)})
+	}
+	return matchElems(r.elems, strings.Split(rel, "/"))
+}
+
+// matchElems matches the elements of the path against the elements of the pattern.
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], elems[0]); err != nil || !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// scanFilter decides the paths skipped by the scanning of a workspace folder, by the exclusion patterns configured
+// and the '.gitignore' files of the directories scanned so far.
+type scanFilter struct {
+	root  string
+	rules []excludeRule
+}
+
+// newScanFilter returns the filter of the scanning of the root, the patterns are relative to the root. The
+// '.gitignore' files are read as the directories are entered.
+func newScanFilter(root string, patterns []string) *scanFilter {
+	f := &scanFilter{root: root}
+	for _, pattern := range patterns {
+		if rule, ok := parseExcludeRule("", pattern); ok {
+			f.rules = append(f.rules, rule)
+		}
+	}
+	return f
+}
+
+// enter returns the filter of the directory, with the rules of its '.gitignore' appended if there is any. The
+// filter of the parent directory is left unchanged.
+func (f *scanFilter) enter(dir string) *scanFilter {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return f
+	}
+	base := f.rel(dir)
+	rules := append([]excludeRule(nil), f.rules...)
+	for _, line := range strings.Split(string(data), "\n") {
+		if rule, ok := parseExcludeRule(base, line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return &scanFilter{root: f.root, rules: rules}
+}
+
+// rel returns the path relative to the root in the slash form, empty for the root itself.
+func (f *scanFilter) rel(p string) string {
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// excluded reports whether the path is skipped, the last rule matching the path decides like '.gitignore'.
+func (f *scanFilter) excluded(p string, isDir bool) bool {
+	rel := f.rel(p)
+	if rel == "" {
+		return false
+	}
+	excluded := false
+	for _, rule := range f.rules {
+		if rule.match(rel, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
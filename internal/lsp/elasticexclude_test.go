@@ -0,0 +1,107 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestExcludeRules(t *testing.T) {
+	for _, test := range []struct {
+		base, pattern string
+		rel           string
+		isDir         bool
+		want          bool
+	}{
+		{"", "node_modules", "web/node_modules", true, true},
+		{"", "node_modules/", "web/node_modules", false, false},
+		{"", "/out", "out", true, true},
+		{"", "/out", "sub/out", true, false},
+		{"", "bazel-*", "bazel-out", true, true},
+		{"", "gen/**/*.go", "gen/a/b/x.go", false, true},
+		{"", "gen/**/*.go", "gen/x.go", false, true},
+		{"", "**/testdata", "a/b/testdata", true, true},
+		{"", "*.pb.go", "api/api.pb.go", false, true},
+		{"sub", "build", "sub/build", true, true},
+		{"sub", "build", "build", true, false},
+		{"sub", "/build", "sub/x/build", true, false},
+	} {
+		rule, ok := parseExcludeRule(test.base, test.pattern)
+		if !ok {
+			t.Fatalf("failed to parse %q", test.pattern)
+		}
+		if got := rule.match(test.rel, test.isDir); got != test.want {
+			t.Errorf("got %v for %q matching %q in %q, want %v", got, test.pattern, test.rel, test.base, test.want)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if _, ok := parseExcludeRule("", line); ok {
+			t.Errorf("%q isn't expected to be a rule", line)
+		}
+	}
+}
+
+func TestScanExclusion(t *testing.T) {
+	dir := newTestDir(t, "exclude", map[string]string{
+		".gitignore":                    "bazel-*\n/out/\n*.gen.go\n",
+		"main.go":                       "package main\n",
+		"lib/lib.go":                    "package lib\n",
+		"lib/.gitignore":                "*/\n!keep/\n",
+		"lib/build/go.mod":              "module example.com/build\n",
+		"lib/keep/keep.go":              "package keep\n",
+		"only/only.gen.go":              "package only\n",
+		"bazel-out/gen/gen.go":          "package gen\n",
+		"out/go.mod":                    "module example.com/out\n",
+		"web/node_modules/pkg/pkg.go":   "package pkg\n",
+		"web/node_modules/pkg/go.mod":   "module example.com/pkg\n",
+		"tools/go.mod":                  "module example.com/tools\n",
+		"generated/api/api.go":          "package api\n",
+		"generated/api/keep/go.mod":     "module example.com/keep\n",
+		"generated/api/keep/keep.go":    "package keep\n",
+		"generated/api/service/main.go": "package main\n",
+	})
+	defer os.RemoveAll(dir)
+	filter := newScanFilter(dir, []string{"node_modules/", "generated/**"})
+
+	modules, err := collectModules(dir, dirVisitor{}, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(modules)
+	want := []string{filepath.Join(dir, "tools")}
+	if len(modules) != len(want) || modules[0] != want[0] {
+		t.Errorf("got modules %v, want %v", modules, want)
+	}
+
+	// The synthesized module isn't located in the excluded directories, and their sources aren't covered.
+	uncovered, needMod, err := collectUncoveredSrc(dir, dirVisitor{}, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(uncovered)
+	wantUncovered := []string{dir, filepath.Join(dir, "lib"), filepath.Join(dir, "lib", "keep")}
+	if len(needMod) != 0 || len(uncovered) != len(wantUncovered) {
+		t.Fatalf("got uncovered %v and needing modules %v, want uncovered %v", uncovered, needMod, wantUncovered)
+	}
+	for i := range wantUncovered {
+		if uncovered[i] != wantUncovered[i] {
+			t.Errorf("got uncovered %v, want %v", uncovered, wantUncovered)
+			break
+		}
+	}
+
+	depsMgr := DepsManager{excludePatterns: []string{"node_modules/", "generated/**"}, stats: newSessionStats()}
+	err, modules = depsMgr.collectMetadata(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(modules)
+	if want := []string{dir, filepath.Join(dir, "tools")}; len(modules) != 2 || modules[0] != want[0] || modules[1] != want[1] {
+		t.Errorf("got modules %v, want %v", modules, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		t.Errorf("the module covering the sources is expected to be synthesized: %v", err)
+	}
+}
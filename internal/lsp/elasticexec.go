@@ -0,0 +1,226 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// runGoCommand runs the go command with the given arguments in dir by the runner of the options and returns its
+// stdout. The stderr of the command will be attached to the returned error if the command fails.
+func runGoCommand(ctx context.Context, options source.Options, dir string, env []string, args ...string) (*bytes.Buffer, error) {
+	return runOptionsCommand(ctx, options, dir, env, "go", args...)
+}
+
+// runGitCommand runs the git command with the given arguments in dir under the environment of its folder.
+func runGitCommand(ctx context.Context, options source.Options, dir string, env []string, args ...string) (*bytes.Buffer, error) {
+	return runOptionsCommand(ctx, options, dir, env, "git", args...)
+}
+
+// runOptionsCommand runs the command by the runner of the options, i.e. in the sandbox of the servers of the session.
+// The commands of the options of no server, like the ones of the command line tools, run unconfined.
+func runOptionsCommand(ctx context.Context, options source.Options, dir string, env []string, name string, args ...string) (*bytes.Buffer, error) {
+	if options.RunCommand != nil {
+		return options.RunCommand(ctx, dir, env, name, args...)
+	}
+	return sandbox{}.run(ctx, dir, env, name, args...)
+}
+
+// commandOutputLimit bounds the bytes of the stdout and the stderr attached to the errors of the commands, the tails of
+// the outputs are kept since the causes of the failures are usually reported last.
+const commandOutputLimit = 4096
+
+// sandboxEnv are the variables kept by the restricted environment of the commands, besides the ones prefixed by
+// sandboxEnvPrefixes, i.e. the ones needed by the go command, the git command and the proxies of the network.
+var sandboxEnv = []string{
+	"PATH", "HOME", "USER", "TMPDIR", "LANG", "NETRC", "SSH_AUTH_SOCK",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"SYSTEMROOT", "TEMP", "TMP", "USERPROFILE", "LOCALAPPDATA", "APPDATA",
+}
+
+var sandboxEnvPrefixes = []string{"GO", "CGO_", "GIT_"}
+
+// sandbox confines the commands run by the servers, the zero sandbox runs the commands as is.
+type sandbox struct {
+	// The command prefixed to the commands, like 'docker exec' or 'chroot'.
+	wrapper []string
+	// Whether the environment of the commands only keeps the variables of sandboxEnv.
+	restrictEnv bool
+	// The commands running longer are killed, zero means no timeout.
+	timeout time.Duration
+}
+
+// restrict returns the variables of env kept by the sandbox.
+func (sb sandbox) restrict(env []string) []string {
+	if !sb.restrictEnv {
+		return append([]string{}, env...)
+	}
+	var kept []string
+	for _, kv := range env {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if sandboxed(name) {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// sandboxed tells whether the variable is kept by the restricted environment.
+func sandboxed(name string) bool {
+	for _, prefix := range sandboxEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, kept := range sandboxEnv {
+		if name == kept {
+			return true
+		}
+	}
+	return false
+}
+
+// subprocesses are the commands running in their process groups, which are killed once the process is interrupted or
+// terminated, and counted by the health checks.
+var subprocesses = struct {
+	sync.Mutex
+	running map[*os.Process]bool
+	// The number of the commands started, and of the ones whose process groups outlived them and were reaped.
+	started int
+	reaped  int
+}{running: make(map[*os.Process]bool)}
+
+// trackSubprocess records the process of the command started.
+func trackSubprocess(p *os.Process) {
+	subprocesses.Lock()
+	defer subprocesses.Unlock()
+	subprocesses.running[p] = true
+	subprocesses.started++
+}
+
+// untrackSubprocess forgets the process of the command exited, the processes left in its group, like the ones spawned
+// in the background, are killed so that they're never orphaned.
+func untrackSubprocess(p *os.Process) {
+	reaped := reapProcessGroup(p)
+	subprocesses.Lock()
+	defer subprocesses.Unlock()
+	delete(subprocesses.running, p)
+	if reaped {
+		subprocesses.reaped++
+	}
+}
+
+// killSubprocessesOnExit kills the process groups of the running commands once the process is interrupted or
+// terminated, before it exits by the signal, so that the long running commands like 'go mod download' aren't left
+// running by the servers exiting uncleanly.
+func killSubprocessesOnExit() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		killSubprocesses()
+		// The signal is raised once again by its default handler, so that the process exits as it would.
+		signal.Stop(c)
+		if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+			os.Exit(1)
+		}
+	}()
+}
+
+// killSubprocesses kills the process groups of the running commands.
+func killSubprocesses() {
+	subprocesses.Lock()
+	defer subprocesses.Unlock()
+	for p := range subprocesses.running {
+		killProcessGroup(p)
+	}
+}
+
+// run runs the command in the sandbox in its own process group, which is killed as a whole once the context is done, so
+// that the processes it spawns, like the git commands of 'go mod download', never outlive it. Both the stdout and the
+// stderr are attached to the error if it fails.
+func (sb sandbox) run(ctx context.Context, dir string, env []string, name string, args ...string) (*bytes.Buffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("'%s %s' canceled: %v", name, strings.Join(args, " "), err)
+	}
+	// The timeout of the sandbox is told from the cancellation of the caller by the context of the command.
+	parent := ctx
+	if sb.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sb.timeout)
+		defer cancel()
+	}
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd := exec.Command(name, args...)
+	if len(sb.wrapper) > 0 {
+		cmd = exec.Command(sb.wrapper[0], append(append(append([]string{}, sb.wrapper[1:]...), name), args...)...)
+	}
+	// Keep the PWD consistent with the working directory, see the comments of 'source.invokeGo'.
+	cmd.Env = append(sb.restrict(env), "PWD="+dir)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
+			return nil, fmt.Errorf("'%s' is required, but %s", cmd.Args[0], exec.ErrNotFound)
+		}
+		return nil, fmt.Errorf("'%s %s' failed: %v", name, strings.Join(args, " "), err)
+	}
+	trackSubprocess(cmd.Process)
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd.Process)
+		case <-exited:
+		}
+	}()
+	err := cmd.Wait()
+	close(exited)
+	untrackSubprocess(cmd.Process)
+	if err != nil {
+		if ctx.Err() != nil && parent.Err() == nil {
+			return nil, fmt.Errorf("'%s %s' timed out after %v: %s", name, strings.Join(args, " "), sb.timeout, commandOutput(stdout, stderr))
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("'%s %s' canceled: %v", name, strings.Join(args, " "), ctx.Err())
+		}
+		return nil, fmt.Errorf("'%s %s' failed: %v: %s", name, strings.Join(args, " "), err, commandOutput(stdout, stderr))
+	}
+	return stdout, nil
+}
+
+// commandOutput returns the outputs of the failed command attached to the error, i.e. the stderr followed by the
+// stdout if any, like the errors reported in JSON by 'go mod download -json'.
+func commandOutput(stdout, stderr *bytes.Buffer) string {
+	output := outputTail(strings.TrimSpace(stderr.String()))
+	if out := outputTail(strings.TrimSpace(stdout.String())); out != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += "stdout: " + out
+	}
+	return output
+}
+
+// outputTail returns the last commandOutputLimit bytes of the output.
+func outputTail(output string) string {
+	if len(output) <= commandOutputLimit {
+		return output
+	}
+	return "..." + output[len(output)-commandOutputLimit:]
+}
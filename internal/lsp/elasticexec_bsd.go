@@ -0,0 +1,8 @@
+// +build darwin freebsd
+
+package lsp
+
+import "syscall"
+
+// setParentDeathSignal is a no-op, the parent death signal is only supported on linux.
+func setParentDeathSignal(attr *syscall.SysProcAttr) {}
@@ -0,0 +1,11 @@
+// +build linux
+
+package lsp
+
+import "syscall"
+
+// setParentDeathSignal kills the command once the server dies, even by SIGKILL, so that it's never orphaned. The
+// processes spawned by the command are left to the command itself.
+func setParentDeathSignal(attr *syscall.SysProcAttr) {
+	attr.Pdeathsig = syscall.SIGKILL
+}
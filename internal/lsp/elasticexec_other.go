@@ -0,0 +1,21 @@
+// +build !darwin,!freebsd,!linux
+
+package lsp
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, the process groups are only supported on unix.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process alone, the processes it spawns are left running.
+func killProcessGroup(p *os.Process) {
+	p.Kill()
+}
+
+// reapProcessGroup does nothing, there is no process group left by the process.
+func reapProcessGroup(p *os.Process) bool {
+	return false
+}
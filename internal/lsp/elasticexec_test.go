@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/imports"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestSandboxCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands run by sh")
+	}
+	ctx := context.Background()
+	dir := os.TempDir()
+	run := func(options ServerOptions, env []string, script string) (string, error) {
+		t.Helper()
+		_, servers := NewServers(ctx, options)
+		stdout, err := servers.sandbox.run(ctx, dir, env, "sh", "-c", script)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(stdout.String()), nil
+	}
+
+	// The wrapper runs the commands as its trailing arguments.
+	if got, err := run(ServerOptions{ExecWrapper: []string{"env", "WRAPPED=yes"}}, os.Environ(), "echo $WRAPPED"); err != nil || got != "yes" {
+		t.Errorf("got the output %q, %v of the wrapped command, want yes", got, err)
+	}
+
+	// The restricted environment keeps the variables of the toolchain only.
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "SECRET_TOKEN=token")
+	if got, err := run(ServerOptions{ExecRestrictEnv: true}, env, `echo "$GOFLAGS:$SECRET_TOKEN"`); err != nil || got != "-mod=mod:" {
+		t.Errorf("got the output %q, %v of the restricted environment, want -mod=mod:", got, err)
+	}
+
+	// The commands running longer than the timeout are killed.
+	start := time.Now()
+	if _, err := run(ServerOptions{ExecTimeout: 100 * time.Millisecond}, os.Environ(), "echo started; sleep 60"); err == nil || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "started") {
+		t.Errorf("got the error %v, want the timeout with the stdout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("the command timed out after %v", elapsed)
+	}
+
+	// Both the stdout and the stderr are attached to the errors.
+	if _, err := run(ServerOptions{}, os.Environ(), "echo out; echo err >&2; exit 1"); err == nil || !strings.Contains(err.Error(), "err\nstdout: out") {
+		t.Errorf("got the error %v, want the stderr and the stdout", err)
+	}
+}
+
+func TestSandboxViewCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands run by sh")
+	}
+	dir := newTestDir(t, "sandboxview", map[string]string{"go.mod": "module example.com/m\n"})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.Env = nil
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOPATH=") {
+			options.Env = append(options.Env, kv)
+		}
+	}
+	// The wrapper answers 'go env GOPATH' in place of the go command.
+	_, servers := NewServers(ctx, ServerOptions{ExecWrapper: []string{"sh", "-c", "echo /sandboxed/gopath", "sh"}})
+	options.RunCommand = servers.sandbox.run
+	_, view := newTestServer(ctx, dir, "m", options)
+	var gopath string
+	if err := view.RunProcessEnvFunc(ctx, func(opts *imports.Options) error {
+		gopath = opts.Env.GOPATH
+		return nil
+	}, &imports.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if gopath != "/sandboxed/gopath" {
+		t.Errorf("got the GOPATH %q of the view, want the one told by the sandbox", gopath)
+	}
+}
+
+func TestReapProcessGroup(t *testing.T) {
+	if runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" && runtime.GOOS != "linux" {
+		t.Skip("the process groups are only supported on unix")
+	}
+	before := health()
+	// The command exits at once, leaving the process spawned in the background in its group.
+	stdout, err := sandbox{}.run(context.Background(), os.TempDir(), os.Environ(), "sh", "-c", "sleep 60 >/dev/null 2>&1 & echo $!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		p, err := os.FindProcess(pid)
+		if err != nil || p.Signal(syscall.Signal(0)) != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the process %d outlives the command", pid)
+		}
+	}
+	after := health()
+	if after.Subprocesses != 0 || after.SubprocessesStarted != before.SubprocessesStarted+1 || after.SubprocessesReaped != before.SubprocessesReaped+1 {
+		t.Errorf("got the subprocesses %+v, want one more started and reaped than %+v", after, before)
+	}
+}
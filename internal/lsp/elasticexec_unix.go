@@ -0,0 +1,32 @@
+// +build darwin freebsd linux
+
+package lsp
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command as the leader of a new process group, which is killed once the server dies if
+// supported by the platform.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	setParentDeathSignal(cmd.SysProcAttr)
+}
+
+// killProcessGroup kills the process group led by the process.
+func killProcessGroup(p *os.Process) {
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		p.Kill()
+	}
+}
+
+// reapProcessGroup kills the processes left in the group led by the exited process, and reports whether there were
+// any. The group is never reused while any process is left in it.
+func reapProcessGroup(p *os.Process) bool {
+	if err := syscall.Kill(-p.Pid, 0); err != nil {
+		return false
+	}
+	return syscall.Kill(-p.Pid, syscall.SIGKILL) == nil
+}
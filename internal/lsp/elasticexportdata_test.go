@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestExportDataDependencies(t *testing.T) {
+	dir := newTestDir(t, "exportdata", map[string]string{
+		"dep/go.mod": "module example.com/dep\n\ngo 1.11\n",
+		"dep/dep.go": "package dep\n\nimport \"strings\"\n\nfunc F() *strings.Builder { return nil }\n\ntype T struct{ X int }\n",
+		"m/go.mod":   "module example.com/m\n\ngo 1.11\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../dep\n",
+		"m/m.go":     "package m\n\nimport \"example.com/dep\"\n\nvar _ = dep.F().Len()\n\nvar _ = dep.T{}.X\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.ExportDataDependencies = true
+	folder := filepath.Join(dir, "m")
+	s, view := newTestServer(ctx, folder, "m", options)
+	uri := span.FileURI(filepath.Join(folder, "m.go"))
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := source.WidestCheckPackageHandle(cphs).Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := pkg.GetErrors(); len(errs) != 0 {
+		t.Fatalf("got the errors %v", errs)
+	}
+	dep, err := pkg.GetImport(ctx, "example.com/dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if syntax := dep.GetSyntax(ctx); len(syntax) != 0 {
+		t.Errorf("got the dependency parsed, want it imported from the export data")
+	}
+
+	depURI := span.FileURI(filepath.Join(dir, "dep", "dep.go"))
+	for _, test := range []struct {
+		pos   protocol.Position
+		qname string
+		want  protocol.Position
+	}{
+		{protocol.Position{Line: 4, Character: 12}, "dep.F", protocol.Position{Line: 4, Character: 5}},
+		{protocol.Position{Line: 6, Character: 16}, "dep.T.X", protocol.Position{Line: 6, Character: 15}},
+	} {
+		locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(uri)},
+				Position:     test.pos,
+			},
+		}})
+		if err != nil {
+			t.Errorf("%v: %v", test.pos, err)
+			continue
+		}
+		if len(locs) != 1 || locs[0].Qname != test.qname {
+			t.Errorf("%v: got the definitions %+v, want %s", test.pos, locs, test.qname)
+		}
+		// The declarations are located by the source, not only by the lines of the export data.
+		ident, err := source.IdentifierAt(ctx, view, view.Snapshot(), f, test.pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rng, err := ident.Declaration.Range()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ident.Declaration.URI() != depURI || rng.Start != test.want {
+			t.Errorf("%v: got the declaration at %s:%v, want %v", test.pos, ident.Declaration.URI(), rng.Start, test.want)
+		}
+	}
+
+	// The types of the indirect dependencies are imported from the export data as well.
+	locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(uri)},
+			Position:     protocol.Position{Line: 4, Character: 17},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 || locs[0].Qname != "strings.Builder.Len" {
+		t.Errorf("got the definitions %+v, want strings.Builder.Len", locs)
+	}
+}
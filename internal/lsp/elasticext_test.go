@@ -2,12 +2,15 @@ package lsp
 
 import (
 	"context"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/lsp/tests"
 	"golang.org/x/tools/internal/span"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -72,7 +75,7 @@ func testLSPExt(t *testing.T, exporter packagestest.Exporter) {
 		session:     session,
 		undelivered: make(map[span.URI][]source.Diagnostic),
 	}
-	es := &ElasticServer{*s, nil}
+	es := &ElasticServer{Server: *s}
 
 	expectedQNameKinds := make(QnameKindMap)
 	expectedPkgLocators := make(PkgMap)
@@ -140,12 +143,14 @@ type FullSymMap map[protocol.Location]DetailSymInfo
 
 func (qk QnameKindMap) test(t *testing.T, s *ElasticServer) {
 	for src, target := range qk {
-		params := &protocol.DefinitionParams{
-			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
-				TextDocument: protocol.TextDocumentIdentifier{
-					URI: src.URI,
+		params := &protocol.EDefinitionParams{
+			DefinitionParams: protocol.DefinitionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{
+						URI: src.URI,
+					},
+					Position: src.Range.Start,
 				},
-				Position: src.Range.Start,
 			},
 		}
 		var symLocators []protocol.SymbolLocator
@@ -179,12 +184,14 @@ func (qk QnameKindMap) collect(e *packagestest.Exported, fset *token.FileSet, sr
 
 func (ps PkgMap) test(t *testing.T, s *ElasticServer) {
 	for src, target := range ps {
-		params := &protocol.DefinitionParams{
-			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
-				TextDocument: protocol.TextDocumentIdentifier{
-					URI: src.URI,
+		params := &protocol.EDefinitionParams{
+			DefinitionParams: protocol.DefinitionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{
+						URI: src.URI,
+					},
+					Position: src.Range.Start,
 				},
-				Position: src.Range.Start,
 			},
 		}
 		var symLocators []protocol.SymbolLocator
@@ -311,3 +318,100 @@ func testLocation(e *packagestest.Exported, fset *token.FileSet, rng packagestes
 	}
 	return spn, m
 }
+
+// BenchmarkDeclFileAST compares reusing the AST held by the checked package with parsing the declaring file again,
+// which is what resolving a qualified name cost once the memoized AST had been collected.
+func BenchmarkDeclFileAST(b *testing.B) {
+	var src strings.Builder
+	src.WriteString("package qname\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&src, "\ntype T%d struct{ F int }\n\nfunc (t T%d) M() int { return t.F }\n", i, i)
+	}
+	dir := newTestDir(b, "qname", map[string]string{"go.mod": "module example.com/qname\n", "qname.go": src.String()})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	_, view := newTestServer(ctx, dir, "qname", source.DefaultOptions)
+	uri := span.FileURI(filepath.Join(dir, "qname.go"))
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pkg, err := source.WidestCheckPackageHandle(cphs).Check(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.ParseFile(token.NewFileSet(), uri.Filename(), src.String(), parser.ParseComments); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("PackageAST", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := declFileAST(ctx, view, pkg, uri); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+const closureQnameSrc = `package p
+
+var handler = func(x int) int { return x }
+
+type T struct{}
+
+func (T) M(arg int) {
+	local := 1
+	_ = func() {
+		inner := local
+		_ = func() { deepest := inner; _ = deepest }
+	}
+	_ = func() { second := arg; _ = second }
+}
+
+func Outer() {
+	var v = func() { y := 0; _ = y }
+	_ = v
+}
+`
+
+func TestGetQNameClosures(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", closureQnameSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	if _, err := (&types.Config{}).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	qnames := make(map[string]string)
+	for id, obj := range info.Defs {
+		if obj != nil && id.Name != "_" {
+			qnames[id.Name] = getQName(file, obj, getSymbolKind(obj), nil)
+		}
+	}
+	for name, want := range map[string]string{
+		"handler": "p.handler",
+		"x":       "p.handler.func1.x",
+		"M":       "p.T.M",
+		"arg":     "p.T.M.arg",
+		"local":   "p.T.M.local",
+		"inner":   "p.T.M.func1.inner",
+		"deepest": "p.T.M.func1.func1.deepest",
+		"second":  "p.T.M.func2.second",
+		"v":       "p.Outer.v",
+		"y":       "p.Outer.func1.y",
+	} {
+		if got := qnames[name]; got != want {
+			t.Errorf("qname of %s: got %q, want %q", name, got, want)
+		}
+	}
+}
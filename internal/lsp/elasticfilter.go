@@ -0,0 +1,50 @@
+package lsp
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// filterSymbols returns the symbols selected by the filter, along with whether they are capped by the filter. The
+// symbols are copied rather than filtered in place, since they are shared with the symbol index.
+func filterSymbols(syms []protocol.DetailSymbolInformation, filter protocol.SymbolFilter) ([]protocol.DetailSymbolInformation, bool) {
+	kinds := make(map[protocol.SymbolKind]bool)
+	for _, kind := range filter.Kinds {
+		kinds[kind] = true
+	}
+	selected := []protocol.DetailSymbolInformation{}
+	for _, sym := range syms {
+		if len(kinds) > 0 && !kinds[sym.Symbol.Kind] {
+			continue
+		}
+		if filter.ExcludeLocals && isLocalSymbol(sym) {
+			continue
+		}
+		if filter.MaxSymbols > 0 && len(selected) == filter.MaxSymbols {
+			return selected, true
+		}
+		selected = append(selected, sym)
+	}
+	return selected, false
+}
+
+// isLocalSymbol reports whether the symbol is declared in a function, the labels are the only such symbols of the
+// documents.
+func isLocalSymbol(sym protocol.DetailSymbolInformation) bool {
+	return sym.Symbol.Kind == protocol.Key
+}
+
+// isLocalObject reports whether the object is declared in a function, like the local variables and the labels. The
+// fields and the methods are never local, even if they belong to the local types.
+func isLocalObject(obj types.Object) bool {
+	if _, ok := obj.(*types.Label); ok {
+		return true
+	}
+	if obj.Pkg() == nil || obj.Parent() == nil {
+		return false
+	}
+	// The scopes of the files, which declare the imported package names, are nested in the package scope.
+	pkgScope := obj.Pkg().Scope()
+	return obj.Parent() != pkgScope && obj.Parent().Parent() != pkgScope
+}
@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestFilterSymbols(t *testing.T) {
+	sym := func(qname string, kind protocol.SymbolKind) protocol.DetailSymbolInformation {
+		return protocol.DetailSymbolInformation{Qname: qname, Symbol: protocol.SymbolInformation{Kind: kind}}
+	}
+	syms := []protocol.DetailSymbolInformation{
+		sym("p.T", protocol.Struct),
+		sym("p.T.F", protocol.Field),
+		sym("p.T.M", protocol.Method),
+		sym("p.F", protocol.Function),
+		sym("p.F.loop", protocol.Key),
+	}
+	for _, test := range []struct {
+		filter    protocol.SymbolFilter
+		want      []string
+		truncated bool
+	}{
+		{protocol.SymbolFilter{}, []string{"p.T", "p.T.F", "p.T.M", "p.F", "p.F.loop"}, false},
+		{protocol.SymbolFilter{ExcludeLocals: true}, []string{"p.T", "p.T.F", "p.T.M", "p.F"}, false},
+		{protocol.SymbolFilter{Kinds: []protocol.SymbolKind{protocol.Struct, protocol.Function}}, []string{"p.T", "p.F"}, false},
+		{protocol.SymbolFilter{MaxSymbols: 2}, []string{"p.T", "p.T.F"}, true},
+		{protocol.SymbolFilter{MaxSymbols: 4, ExcludeLocals: true}, []string{"p.T", "p.T.F", "p.T.M", "p.F"}, false},
+	} {
+		selected, truncated := filterSymbols(syms, test.filter)
+		got := []string{}
+		for _, sym := range selected {
+			got = append(got, sym.Qname)
+		}
+		if !reflect.DeepEqual(got, test.want) || truncated != test.truncated {
+			t.Errorf("%+v: got %v, truncated %v, want %v, truncated %v", test.filter, got, truncated, test.want, test.truncated)
+		}
+	}
+	if len(syms) != 5 || syms[4].Qname != "p.F.loop" {
+		t.Errorf("the symbols are modified by the filter: %v", syms)
+	}
+}
+
+func TestIsLocalObject(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+var V int
+
+type T struct{ F int }
+
+func (t T) M(a int) {
+	var l int
+loop:
+	for {
+		break loop
+	}
+	fmt.Println(l, a)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object), Implicits: make(map[ast.Node]types.Object)}
+	if _, err := (&types.Config{Importer: importer.Default()}).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for id, obj := range info.Defs {
+		if obj != nil {
+			got[id.Name] = isLocalObject(obj)
+		}
+	}
+	for _, obj := range info.Implicits {
+		got[obj.Name()] = isLocalObject(obj)
+	}
+	want := map[string]bool{"V": false, "T": false, "F": false, "M": false, "fmt": false, "t": true, "a": true, "l": true, "loop": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got locals %v, want %v", got, want)
+	}
+}
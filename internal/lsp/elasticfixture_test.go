@@ -0,0 +1,75 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// newTestDir returns a new temporary directory holding the files by their slash-separated paths, which is removed by
+// the caller.
+func newTestDir(t testing.TB, prefix string, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, dir, files)
+	return dir
+}
+
+// writeTestFiles writes the files by their slash-separated paths under dir, along with their directories.
+func writeTestFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestServer returns an elastic server of a new session by the options, whose view of the folder is named name.
+func newTestServer(ctx context.Context, folder, name string, options source.Options) (*ElasticServer, source.View) {
+	s := newTestSessionServer(ctx, options)
+	return s, s.session.NewView(ctx, name, span.FileURI(folder), options)
+}
+
+// newTestSessionServer returns an elastic server of a new session by the options, which has no view yet, like the
+// ones managing the folders by themselves.
+func newTestSessionServer(ctx context.Context, options source.Options) *ElasticServer {
+	session := cache.New().NewSession(ctx)
+	session.SetOptions(options)
+	return &ElasticServer{Server: Server{session: session, undelivered: make(map[span.URI][]source.Diagnostic)}, stats: newSessionStats()}
+}
+
+// newTestConn serves an elastic server of a new session among the servers on a pipe, whose view of the folder is named
+// name, and returns the connection of the client to it. The requests go through the handlers as they do from the
+// editors. The returned function closes the connection and waits until the server stops.
+func newTestConn(ctx context.Context, servers *Servers, folder, name string) (*ElasticServer, *jsonrpc2.Conn, func()) {
+	clientPipe, serverPipe := net.Pipe()
+	serverCtx, s := NewElasticServer(ctx, cache.New(), servers, jsonrpc2.NewHeaderStream(serverPipe, serverPipe))
+	s.session.NewView(ctx, name, span.FileURI(folder), s.session.Options())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.RunElasticServer(serverCtx)
+	}()
+	conn := jsonrpc2.NewConn(jsonrpc2.NewHeaderStream(clientPipe, clientPipe))
+	go conn.Run(ctx)
+	return s, conn, func() {
+		clientPipe.Close()
+		<-stopped
+	}
+}
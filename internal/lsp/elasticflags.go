@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// The feature flags gating the experimental subsystems, which can be overridden per workspace folder by the option
+// 'featureFlags', so that the new behaviors can be canaried on a subset of the repositories.
+const (
+	flagReferenceIndexing = "referenceIndexing"
+	flagVendorMode        = "vendorMode"
+	flagOffline           = "offline"
+)
+
+// applyFeatureFlags applies the feature flags of the workspace folder to the options. The flags of '*' apply to all
+// the folders, and are overridden by the flags keyed by the name of the folder, then by the URI of the folder. The
+// unknown flags are returned.
+func applyFeatureFlags(options *source.Options, uri span.URI, name string) []string {
+	flags := make(map[string]bool)
+	for _, key := range []string{"*", name, string(uri)} {
+		for flag, enabled := range options.FeatureFlags[key] {
+			flags[flag] = enabled
+		}
+	}
+	var unknown []string
+	for flag, enabled := range flags {
+		switch flag {
+		case flagReferenceIndexing:
+			options.DisableReferenceIndexing = !enabled
+		case flagVendorMode:
+			options.VendorMode = enabled
+		case flagOffline:
+			options.Offline = enabled
+		default:
+			unknown = append(unknown, flag)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// applyFolderEnv appends the environment of the workspace folder to the options, in the same precedence as the
+// feature flags, i.e. '*', then the name of the folder, then the URI of the folder.
+func applyFolderEnv(options *source.Options, uri span.URI, name string) {
+	env := make(map[string]string)
+	for _, key := range []string{"*", name, string(uri)} {
+		for k, v := range options.FolderEnv[key] {
+			env[k] = v
+		}
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// The later entries of the environment take precedence over the former ones.
+	options.Env = append([]string{}, options.Env...)
+	for _, k := range keys {
+		options.Env = append(options.Env, k+"="+env[k])
+	}
+}
+
+// offlineEnv returns the environment variables forbidding the go command from accessing the network.
+func offlineEnv() []string {
+	return []string{"GOPROXY=off", "GOSUMDB=off"}
+}
@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestApplyFeatureFlags(t *testing.T) {
+	options := source.Options{
+		FeatureFlags: map[string]map[string]bool{
+			"*":               {flagReferenceIndexing: false, flagOffline: true},
+			"canary":          {flagReferenceIndexing: true},
+			"file:///repo/cx": {flagVendorMode: true, "unknown": true},
+		},
+	}
+
+	canary := options
+	applyFeatureFlags(&canary, "file:///repo/canary", "canary")
+	if canary.DisableReferenceIndexing || !canary.Offline || canary.VendorMode {
+		t.Errorf("canary: got %+v", canary)
+	}
+
+	other := options
+	unknown := applyFeatureFlags(&other, "file:///repo/cx", "cx")
+	if !other.DisableReferenceIndexing || !other.Offline || !other.VendorMode {
+		t.Errorf("cx: got %+v", other)
+	}
+	if want := []string{"unknown"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("got unknown flags %v, want %v", unknown, want)
+	}
+}
+
+func TestFeatureFlagsOption(t *testing.T) {
+	var options source.Options
+	results := source.SetOptions(&options, map[string]interface{}{
+		"featureFlags": map[string]interface{}{
+			"*": map[string]interface{}{"offline": true},
+		},
+	})
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatal(r.Error)
+		}
+	}
+	if !options.FeatureFlags["*"]["offline"] {
+		t.Errorf("got feature flags %v", options.FeatureFlags)
+	}
+}
+
+func TestApplyFolderEnv(t *testing.T) {
+	var options source.Options
+	results := source.SetOptions(&options, map[string]interface{}{
+		"folderEnv": map[string]interface{}{
+			"*":                 map[string]interface{}{"GOFLAGS": "-mod=mod", "GONOSUMCHECK": 1},
+			"vendored":          map[string]interface{}{"GOFLAGS": "-mod=vendor"},
+			"file:///repo/priv": map[string]interface{}{"GOPRIVATE": "git.example.com"},
+		},
+	})
+	for _, r := range results {
+		if r.Error != nil {
+			t.Fatal(r.Error)
+		}
+	}
+	options.Env = []string{"HOME=/home/dev"}
+
+	vendored := options
+	applyFolderEnv(&vendored, "file:///repo/vendored", "vendored")
+	if want := []string{"HOME=/home/dev", "GOFLAGS=-mod=vendor", "GONOSUMCHECK=1"}; !reflect.DeepEqual(vendored.Env, want) {
+		t.Errorf("vendored: got env %v, want %v", vendored.Env, want)
+	}
+	priv := options
+	applyFolderEnv(&priv, "file:///repo/priv", "priv")
+	if want := []string{"HOME=/home/dev", "GOFLAGS=-mod=mod", "GONOSUMCHECK=1", "GOPRIVATE=git.example.com"}; !reflect.DeepEqual(priv.Env, want) {
+		t.Errorf("priv: got env %v, want %v", priv.Env, want)
+	}
+	if want := []string{"HOME=/home/dev"}; !reflect.DeepEqual(options.Env, want) {
+		t.Errorf("the environment of the options is expected to be kept, got %v", options.Env)
+	}
+
+	// The module folders under a root folder use the environment of the root folder.
+	depsMgr := DepsManager{
+		env: []string{"BASE=1"},
+		folderEnv: map[string][]string{
+			"/repo":        {"ROOT=1"},
+			"/repo/nested": {"NESTED=1"},
+		},
+	}
+	for dir, want := range map[string]string{
+		"/repo/mod":            "ROOT=1",
+		"/repo/nested/sub/mod": "NESTED=1",
+		"/repository":          "BASE=1",
+	} {
+		if got := depsMgr.envOf(dir); len(got) != 1 || got[0] != want {
+			t.Errorf("got env %v of %s, want [%s]", got, dir, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package lsp
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/tools/internal/xcontext"
+)
+
+// flightGroup deduplicates the concurrent calls with the same key, the callers arrived while a call is in flight wait
+// for it and share its result. The result is shared by the callers, so it must not be modified.
+//
+// The call runs under a context detached from the callers, which is canceled once every caller has left, e.g. by the
+// cancellation of its own context. A caller which leaves gets the error of its context, and the call abandoned by all
+// its callers is never joined by the later callers, like the retries of a canceled request, which start a new one.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	// The number of the callers waiting for the call.
+	callers int
+	val     interface{}
+	err     error
+}
+
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c, ok := g.calls[key]
+	if ok {
+		c.callers++
+	} else {
+		callCtx, cancel := context.WithCancel(xcontext.Detach(ctx))
+		c = &flightCall{done: make(chan struct{}), cancel: cancel, callers: 1}
+		g.calls[key] = c
+		go func() {
+			c.val, c.err = fn(callCtx)
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.callers--
+		if c.callers == 0 {
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			c.cancel()
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
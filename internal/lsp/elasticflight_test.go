@@ -0,0 +1,184 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// waitCallers waits until the call of the key in flight has n callers.
+func waitCallers(g *flightGroup, key string, n int) {
+	for {
+		g.mu.Lock()
+		c := g.calls[key]
+		joined := c != nil && c.callers == n
+		g.mu.Unlock()
+		if joined {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func(context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return "result", nil
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = g.do(ctx, "key", fn)
+	}()
+	<-started
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = g.do(ctx, "key", fn)
+		}(i)
+	}
+	// Wait for the followers to join the call in flight.
+	waitCallers(&g, "key", len(results))
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("got %d calls, expected the concurrent calls to be coalesced", calls)
+	}
+	for i, r := range results {
+		if r != "result" {
+			t.Errorf("caller %d got %v", i, r)
+		}
+	}
+	if _, err := g.do(ctx, "key", fn); err != nil || calls != 2 {
+		t.Errorf("got %d calls, expected a new call once the previous one is finished", calls)
+	}
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	var g flightGroup
+	var calls int32
+	release := make(chan struct{})
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+			return "result", nil
+		case <-ctx.Done():
+			close(canceled)
+			return nil, ctx.Err()
+		}
+	}
+
+	// The call goes on for the other callers once the first one is canceled.
+	first, cancelFirst := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := g.do(first, "key", fn)
+		errc <- err
+	}()
+	waitCallers(&g, "key", 1)
+	resultc := make(chan interface{}, 1)
+	go func() {
+		v, _ := g.do(context.Background(), "key", fn)
+		resultc <- v
+	}()
+	waitCallers(&g, "key", 2)
+	cancelFirst()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("got the error %v of the canceled caller, want %v", err, context.Canceled)
+	}
+	close(release)
+	if v := <-resultc; v != "result" {
+		t.Errorf("got %v of the remaining caller, want the result of the call", v)
+	}
+
+	// The call is canceled once all its callers leave, and the retries start a new call.
+	ctx, cancel := context.WithCancel(context.Background())
+	release = make(chan struct{})
+	go func() {
+		_, err := g.do(ctx, "abandoned", fn)
+		errc <- err
+	}()
+	waitCallers(&g, "abandoned", 1)
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("got the error %v of the canceled caller, want %v", err, context.Canceled)
+	}
+	<-canceled
+	close(release)
+	if v, err := g.do(context.Background(), "abandoned", fn); err != nil || v != "result" || calls != 3 {
+		t.Errorf("got %v, %v after %d calls, want the result of a new call", v, err, calls)
+	}
+}
+
+func TestFullCoalescedByHandler(t *testing.T) {
+	dir := newTestDir(t, "flight", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package a\n\ntype T struct{ F int }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	s, conn, stop := newTestConn(ctx, nil, dir, "m")
+	defer stop()
+	// The only slot of the type-checks is held, so that the computation of the first request waits until the second
+	// one arrives.
+	s.typeChecks = newAdmission(1, 1, 0)
+	hold, err := s.typeChecks.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))}}
+	resps := make([]protocol.FullResponse, 2)
+	errs := make(chan error, len(resps))
+	for i := range resps {
+		go func(i int) {
+			errs <- conn.Call(ctx, "textDocument/full", params, &resps[i])
+		}(i)
+	}
+	// Both requests are handled at the same time, and join the computation in flight.
+	for joined := false; !joined; time.Sleep(time.Millisecond) {
+		s.fulls.mu.Lock()
+		for _, c := range s.fulls.calls {
+			joined = joined || c.callers == len(resps)
+		}
+		s.fulls.mu.Unlock()
+		if ctx.Err() != nil {
+			t.Fatal("the requests are expected to share the computation in flight")
+		}
+	}
+	hold()
+	for range resps {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, resp := range resps {
+		if len(resp.Symbols) != 2 {
+			t.Errorf("got the symbols %+v of request %d, want T and T.F", resp.Symbols, i)
+		}
+	}
+}
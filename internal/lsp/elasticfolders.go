@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// folderSet collects the workspace folders in the order they're added. The folders are told apart by their cleaned
+// paths, so that the same folder sent twice, or the module folder which is already a workspace folder, is kept once.
+type folderSet struct {
+	dirs    []string
+	folders []protocol.WorkspaceFolder
+}
+
+// add adds the folder unless it's already in the set, and reports whether it's added.
+func (set *folderSet) add(folder protocol.WorkspaceFolder) bool {
+	dir := hostPaths.clean(span.NewURI(folder.URI).Filename())
+	if set.contains(dir) {
+		return false
+	}
+	set.dirs = append(set.dirs, dir)
+	set.folders = append(set.folders, folder)
+	return true
+}
+
+// contains reports whether the folder located at dir is in the set.
+func (set *folderSet) contains(dir string) bool {
+	for _, d := range set.dirs {
+		if hostPaths.equal(d, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleFolders converts the module folders under the root folder to the workspace folders, sorted by their paths so
+// that the order doesn't depend on how the modules are found. The root folder itself is left out.
+func moduleFolders(root string, modules []string) []protocol.WorkspaceFolder {
+	var dirs []string
+	for _, dir := range modules {
+		if !hostPaths.equal(dir, root) {
+			dirs = append(dirs, hostPaths.clean(dir))
+		}
+	}
+	sort.Strings(dirs)
+	var folders []protocol.WorkspaceFolder
+	for _, dir := range dirs {
+		folders = append(folders, protocol.WorkspaceFolder{URI: string(span.FileURI(dir)), Name: filepath.Base(dir)})
+	}
+	return folders
+}
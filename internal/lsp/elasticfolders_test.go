@@ -0,0 +1,49 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestManageDepsNestedModules(t *testing.T) {
+	root := newTestDir(t, "nested", map[string]string{
+		"go.mod":     "module example.com/\n",
+		"a/go.mod":   "module example.com/a\n",
+		"a/b/go.mod": "module example.com/a/b\n",
+		"c/go.mod":   "module example.com/c\n",
+	})
+	defer os.RemoveAll(root)
+	folder := func(dir string) protocol.WorkspaceFolder {
+		dir = filepath.Join(root, filepath.FromSlash(dir))
+		return protocol.WorkspaceFolder{URI: string(span.FileURI(dir)), Name: filepath.Base(dir)}
+	}
+	want := []protocol.WorkspaceFolder{folder("a/b"), folder("c")}
+
+	ctx := context.Background()
+	s := newTestSessionServer(ctx, source.DefaultOptions)
+	for _, folders := range [][]protocol.WorkspaceFolder{
+		// The nested module which is a workspace folder itself is never sent back.
+		{folder(""), folder("a")},
+		{folder("a"), folder("")},
+		// The same folder sent twice, spelled differently, is managed once.
+		{folder(""), {URI: string(span.FileURI(root)) + "/", Name: filepath.Base(root)}, folder("a")},
+	} {
+		got := s.ManageDeps(ctx, folders, nil)
+		if len(got) != len(want) {
+			t.Errorf("got the module folders %v of %v, want %v", got, folders, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got the module folders %v of %v, want %v", got, folders, want)
+				break
+			}
+		}
+	}
+}
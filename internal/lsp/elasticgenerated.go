@@ -0,0 +1,71 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// generatedHeaderRx matches the comment marking the generated files, like the ones generated by cgo, see
+// https://golang.org/s/generatedcode.
+var generatedHeaderRx = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGeneratedFile reports whether the file is generated, i.e. the generated comment precedes the package clause.
+func isGeneratedFile(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if generatedHeaderRx.MatchString(comment.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// originalPosition returns the position in the original file which the code at pos in a generated file is generated
+// from according to the line directives, like 'x.go' for the cgo generated 'x.cgo1.go'. It returns false if pos isn't
+// mapped to an existing file, like the declarations made up by cgo in '_cgo_gotypes.go'.
+func originalPosition(fset *token.FileSet, pos token.Pos) (token.Position, bool) {
+	if !pos.IsValid() {
+		return token.Position{}, false
+	}
+	adjusted := fset.PositionFor(pos, true)
+	if adjusted.Filename == fset.PositionFor(pos, false).Filename || !filepath.IsAbs(adjusted.Filename) {
+		return token.Position{}, false
+	}
+	if _, err := os.Stat(adjusted.Filename); err != nil {
+		return token.Position{}, false
+	}
+	return adjusted, true
+}
+
+// originalLocation returns the location of the name at the position of the original file, the content of the file is
+// read from fs so that the unsaved edits are honored. The column of the line directives is optional, the name is
+// located at the start of the line if it's unknown.
+func originalLocation(ctx context.Context, fs source.FileSystem, posn token.Position, name string) (protocol.Location, error) {
+	uri := span.FileURI(posn.Filename)
+	content, _, err := fs.GetFile(uri, source.Go).Read(ctx)
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	m := &protocol.ColumnMapper{
+		URI:       uri,
+		Converter: span.NewContentConverter(posn.Filename, content),
+		Content:   content,
+	}
+	col := posn.Column
+	if col == 0 {
+		col = 1
+	}
+	return m.Location(span.New(uri, span.NewPoint(posn.Line, col, -1), span.NewPoint(posn.Line, col+len(name), -1)))
+}
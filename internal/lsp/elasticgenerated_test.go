@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestOriginalLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	original := filepath.Join(dir, "x.go")
+	if err := ioutil.WriteFile(original, []byte("package x\n\nimport \"C\"\n\n/*世*/func Hello() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The file generated by cgo maps the declarations back to the original file by the line directives, and some of
+	// the declarations are made up.
+	generated := "// Code generated by cmd/cgo; DO NOT EDIT.\n\n" +
+		"//line " + original + ":1:1\npackage x\n\n" +
+		"//line " + original + ":5:8\nfunc Hello() {}\n\n" +
+		"//line :1\nfunc _Cfunc_puts() {}\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filepath.Join(dir, "x.cgo1.go"), generated, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isGeneratedFile(file) {
+		t.Errorf("x.cgo1.go is expected to be generated")
+	}
+	if plain, err := parser.ParseFile(fset, original, nil, parser.ParseComments); err != nil {
+		t.Fatal(err)
+	} else if isGeneratedFile(plain) {
+		t.Errorf("x.go isn't expected to be generated")
+	}
+
+	decls := file.Scope.Objects
+	posn, ok := originalPosition(fset, decls["Hello"].Pos())
+	if !ok {
+		t.Fatalf("Hello isn't mapped to the original file")
+	}
+	loc, err := originalLocation(context.Background(), cache.New(), posn, "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := protocol.Location{
+		URI: protocol.NewURI(span.FileURI(original)),
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 4, Character: 10},
+			End:   protocol.Position{Line: 4, Character: 15},
+		},
+	}
+	if loc != want {
+		t.Errorf("got location %v of Hello, want %v", loc, want)
+	}
+	if _, ok := originalPosition(fset, decls["_Cfunc_puts"].Pos()); ok {
+		t.Errorf("_Cfunc_puts is made up by cgo, it isn't expected to be mapped")
+	}
+}
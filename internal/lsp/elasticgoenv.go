@@ -0,0 +1,88 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"go/build"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// goEnvTimeout bounds the time of resolving the environment of the go command.
+const goEnvTimeout = 30 * time.Second
+
+// goEnv is the environment of the go command in a folder, which locates the standard library and the module cache.
+type goEnv struct {
+	GOROOT     string
+	GOPATH     string
+	GOMODCACHE string
+
+	// The variables reported by the 'elastic/effectiveConfig' request.
+	GOPROXY   string
+	GOFLAGS   string
+	GOPRIVATE string
+}
+
+// modCache returns the directory of the module cache, 'GOMODCACHE' is introduced in go1.15, the module cache is
+// located in the first GOPATH entry before. The GOPATH is a list separated by the separator of the platform, like
+// 'a:b' or 'a;b' on windows, the go command refuses to locate the module cache if the first entry is empty.
+func (e goEnv) modCache() string {
+	if e.GOMODCACHE != "" {
+		return e.GOMODCACHE
+	}
+	if list := filepath.SplitList(e.GOPATH); len(list) > 0 && list[0] != "" {
+		return filepath.Join(list[0], "pkg", "mod")
+	}
+	return ""
+}
+
+// modCacheRel returns the path of loc relative to the module cache, like 'example.com/foo@v1.2.3/foo.go', so that
+// the module versions are never confused with the '@' in the path of the module cache. loc is returned as is if it's
+// out of the module cache.
+func modCacheRel(modCache, loc string) string {
+	if modCache == "" {
+		return loc
+	}
+	if rel, err := filepath.Rel(modCache, loc); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return loc
+}
+
+// goEnvs caches the environments of the go command by the folders and the environment variables, so that the
+// folders using the different toolchains, and the environment changed by the configuration, are resolved separately.
+var goEnvs = struct {
+	sync.Mutex
+	m map[string]goEnv
+}{m: make(map[string]goEnv)}
+
+// resolveGoEnv returns the environment of the go command running in dir under env by the runner of the options. The
+// defaults of the process are used if the go command fails, and the failures are cached as well, since they would fail
+// once again.
+func resolveGoEnv(options source.Options, dir string, env []string) goEnv {
+	key := dir + "\x00" + strings.Join(env, "\x00")
+	goEnvs.Lock()
+	e, ok := goEnvs.m[key]
+	goEnvs.Unlock()
+	if ok {
+		return e
+	}
+	// The go command runs out of the lock, the concurrent resolutions of the same key get the same result anyway.
+	e = goEnv{GOROOT: build.Default.GOROOT, GOPATH: build.Default.GOPATH}
+	ctx, cancel := context.WithTimeout(context.Background(), goEnvTimeout)
+	defer cancel()
+	if stdout, err := runGoCommand(ctx, options, dir, env, "env", "-json", "GOROOT", "GOPATH", "GOMODCACHE", "GOPROXY", "GOFLAGS", "GOPRIVATE"); err == nil {
+		var resolved goEnv
+		if err := json.Unmarshal(stdout.Bytes(), &resolved); err == nil && resolved.GOROOT != "" {
+			e = resolved
+		}
+	}
+	goEnvs.Lock()
+	goEnvs.m[key] = e
+	goEnvs.Unlock()
+	return e
+}
@@ -0,0 +1,79 @@
+package lsp
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestResolveGoEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gopath := filepath.Join(dir, "gopath")
+	modCache := filepath.Join(dir, "modcache")
+	env := append(os.Environ(), "GOPATH="+gopath, "GOMODCACHE=")
+	folder := filepath.Join(dir, "folder")
+	if err := os.Mkdir(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := resolveGoEnv(source.DefaultOptions, folder, env)
+	if e.GOROOT == "" || e.GOPATH != gopath || e.modCache() != filepath.Join(gopath, "pkg", "mod") {
+		t.Errorf("got %+v with the module cache %s, want the GOPATH %s", e, e.modCache(), gopath)
+	}
+	// The changed environment is resolved again.
+	e = resolveGoEnv(source.DefaultOptions, folder, append(env, "GOMODCACHE="+modCache))
+	if e.modCache() != modCache {
+		t.Errorf("got the module cache %s, want %s", e.modCache(), modCache)
+	}
+
+	// The versions of the packages in the module cache of the folder are resolved, and the standard library is
+	// classified by the GOROOT of the folder.
+	options := source.DefaultOptions
+	options.Env = append(env, "GOMODCACHE="+modCache)
+	options.Offline = true
+	loc := filepath.Join(modCache, "example.com", "dep@v1.2.3", "dep.go")
+	if got := collectPkgMetadata(types.NewPackage("example.com/dep", "dep"), folder, loc, options); got.Version != "v1.2.3" {
+		t.Errorf("got version %q of %s, want v1.2.3", got.Version, loc)
+	}
+	loc = filepath.Join(e.GOROOT, "src", "fmt", "print.go")
+	if got := collectPkgMetadata(types.NewPackage("fmt", "fmt"), folder, loc, options); got.RepoURI != "fmt" {
+		t.Errorf("got repository %q of %s, want fmt", got.RepoURI, loc)
+	}
+}
+
+func TestModCache(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	a, b := filepath.Join(string(filepath.Separator), "a"), filepath.Join(string(filepath.Separator), "b")
+	for _, test := range []struct {
+		env  goEnv
+		want string
+	}{
+		{goEnv{GOPATH: a}, filepath.Join(a, "pkg", "mod")},
+		{goEnv{GOPATH: a + sep + b}, filepath.Join(a, "pkg", "mod")},
+		{goEnv{GOPATH: sep + b}, ""},
+		{goEnv{GOPATH: a + sep + b, GOMODCACHE: b}, b},
+		{goEnv{}, ""},
+	} {
+		if got := test.env.modCache(); got != test.want {
+			t.Errorf("got the module cache %q of %+v, want %q", got, test.env, test.want)
+		}
+	}
+
+	// The '@' in the path of the module cache isn't taken as a version.
+	modCache := filepath.Join(string(filepath.Separator), "home", "dev@v1.0.0", "go", "pkg", "mod")
+	loc := filepath.Join(modCache, "example.com", "dep@v1.2.3", "dep.go")
+	if got := getPkgVersionFast(modCacheRel(modCache, loc)); got != "v1.2.3" {
+		t.Errorf("got version %q of %s, want v1.2.3", got, loc)
+	}
+	if other := filepath.Join(string(filepath.Separator), "src", "dep.go"); modCacheRel(modCache, other) != other {
+		t.Errorf("the path out of the module cache is expected to be kept")
+	}
+}
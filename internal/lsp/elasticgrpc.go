@@ -0,0 +1,394 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// grpcService is the path prefix of the methods of the 'elastic.v1.Elastic' service, see 'elasticgrpc.proto'.
+const grpcService = "/elastic.v1.Elastic/"
+
+// The gRPC status codes.
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcUnknown           = 2
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcNotFound          = 5
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// grpcError is the error replied with the gRPC status code.
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string {
+	return e.message
+}
+
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// ServeGRPC serves the elastic operations as the unary methods of the gRPC service over the cleartext HTTP/2 on the
+// given address, it does nothing if the address is empty. The operations are served by the servers of the LSP
+// connections whose workspace folders contain the documents, so that they share the sessions, the caches and the
+// admission of the type-checks, which means the folders are opened by the LSP clients. The messages are neither
+// compressed nor streamed. The gRPC requests count as the activity of the servers.
+func ServeGRPC(ctx context.Context, servers *Servers, addr string) error {
+	if addr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Print(ctx, "gRPC serving", tag.Of("Address", ln.Addr().String()))
+	go func() {
+		if err := http.Serve(ln, h2c.NewHandler(grpcHandler(servers), &http2.Server{})); err != nil {
+			log.Error(ctx, "gRPC server failed", err)
+		}
+	}()
+	return nil
+}
+
+// grpcHandler serves the gRPC requests, each of which carries exactly one length-prefixed message. The status is
+// always replied by the trailers.
+func grpcHandler(servers *Servers) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "expected a gRPC request", http.StatusUnsupportedMediaType)
+			return
+		}
+		servers.touch()
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		resp, err := serveGRPCRequest(r)
+		w.WriteHeader(http.StatusOK)
+		code := grpcOK
+		if err == nil {
+			var prefix [5]byte
+			binary.BigEndian.PutUint32(prefix[1:], uint32(len(resp)))
+			w.Write(prefix[:])
+			w.Write(resp)
+		} else {
+			var message string
+			code, message = grpcStatus(err)
+			w.Header().Set("Grpc-Message", grpcEscape(message))
+		}
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	})
+}
+
+// serveGRPCRequest decodes the message of the request and serves the method under the timeout of the request.
+func serveGRPCRequest(r *http.Request) (protoMessage, error) {
+	method := strings.TrimPrefix(r.URL.Path, grpcService)
+	if method == r.URL.Path {
+		return nil, grpcErrorf(grpcUnimplemented, "unknown service of %s", r.URL.Path)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, grpcErrorf(grpcInternal, "failed to read the request: %v", err)
+	}
+	if len(body) < 5 || uint64(binary.BigEndian.Uint32(body[1:5])) != uint64(len(body)-5) {
+		return nil, grpcErrorf(grpcInternal, "expected exactly one message of the request")
+	}
+	if body[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "the compressed messages are unsupported")
+	}
+	req, err := parseProto(body[5:])
+	if err != nil {
+		return nil, grpcErrorf(grpcInternal, "malformed request: %v", err)
+	}
+	ctx := r.Context()
+	if timeout, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return serveGRPC(ctx, method, req)
+}
+
+// parseGRPCTimeout parses the 'grpc-timeout' header, like '100m' for 100 milliseconds.
+func parseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// serveGRPC serves the method of the gRPC service.
+func serveGRPC(ctx context.Context, method string, req protoFields) (protoMessage, error) {
+	var resp protoMessage
+	switch method {
+	case "EDefinition":
+		uri := span.NewURI(req.string(1))
+		pos, err := req.message(2)
+		if err != nil {
+			return nil, grpcErrorf(grpcInternal, "malformed position: %v", err)
+		}
+		s, _, err := grpcServerOf(uri)
+		if err != nil {
+			return nil, err
+		}
+		params := &protocol.EDefinitionParams{}
+		params.TextDocument.URI = protocol.NewURI(uri)
+		params.Position = protocol.Position{Line: float64(pos.uint(1)), Character: float64(pos.uint(2))}
+		// The messages are of the current version of the elastic protocol, rather than the one which the LSP connection
+		// of the server negotiated.
+		locators, err := s.serveEDefinition(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range locators {
+			resp.message(1, grpcSymbolLocator(loc))
+		}
+		resp.uint(2, protocol.IndexSchemaVersion)
+	case "Full":
+		uri := span.NewURI(req.string(1))
+		s, _, err := grpcServerOf(uri)
+		if err != nil {
+			return nil, err
+		}
+		// The response is neither shaped for the legacy clients nor compressed as negotiated by the LSP connection of
+		// the server, the pages capped by 'maxResponseSize' are fetched by the cursors.
+		full, err := s.serveFull(ctx, &protocol.FullParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(uri)},
+			Reference:    req.uint(2) != 0,
+			Limit:        int(req.uint(3)),
+			Cursor:       req.string(4),
+		})
+		if err != nil {
+			return nil, err
+		}
+		full.SchemaVersion = protocol.IndexSchemaVersion
+		for _, sym := range full.Symbols {
+			var msg protoMessage
+			msg.message(1, grpcSymbolInformation(sym.Symbol))
+			msg.string(2, sym.Qname)
+			msg.message(3, grpcPackageLocator(sym.Package))
+			resp.message(1, msg)
+		}
+		for _, ref := range full.References {
+			var msg protoMessage
+			msg.uint(1, uint64(ref.Category))
+			msg.string(2, string(ref.Kind))
+			msg.message(3, grpcLocation(ref.Loc))
+			msg.message(4, grpcSymbolInformation(ref.Symbol))
+			msg.message(5, grpcSymbolLocator(ref.Target))
+			resp.message(2, msg)
+		}
+		resp.uint(3, grpcBool(full.Partial))
+		for _, e := range full.Errors {
+			resp.bytes(4, []byte(e))
+		}
+		resp.uint(5, grpcBool(full.Truncated))
+		resp.uint(6, uint64(full.SchemaVersion))
+		resp.string(7, full.NextCursor)
+	case "ExportIndex":
+		folder := span.NewURI(req.string(1))
+		s, view, err := grpcServerOf(folder)
+		if err != nil {
+			return nil, err
+		}
+		if !hostPaths.equal(view.Folder().Filename(), folder.Filename()) {
+			return nil, grpcErrorf(grpcNotFound, "%s is not a workspace folder", folder.Filename())
+		}
+		release, err := s.admitTypeCheck(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		var index bytes.Buffer
+		if err := WriteSCIPIndex(ctx, view, &index); err != nil {
+			return nil, err
+		}
+		resp.bytes(1, index.Bytes())
+		resp.uint(2, protocol.IndexSchemaVersion)
+	case "DepsStatus":
+		for _, s := range servingServers() {
+			ready := s.ready()
+			cleanups := s.FolderNeedsCleanup.list()
+			for _, view := range s.session.Views() {
+				folder := view.Folder().Filename()
+				var msg protoMessage
+				msg.string(1, string(protocol.NewURI(view.Folder())))
+				msg.uint(2, grpcBool(ready))
+				msg.uint(3, grpcBool(s.depsRuns.managing(folder)))
+				for _, f := range cleanups {
+					if hostPaths.equal(f, folder) {
+						msg.uint(4, 1)
+						break
+					}
+				}
+				resp.message(1, msg)
+			}
+		}
+	default:
+		return nil, grpcErrorf(grpcUnimplemented, "unknown method %s", method)
+	}
+	return resp, nil
+}
+
+// servingServers returns the servers whose jsonrpc2 loops are running.
+func servingServers() []*ElasticServer {
+	serving.Lock()
+	defer serving.Unlock()
+	var servers []*ElasticServer
+	for s := range serving.servers {
+		servers = append(servers, s)
+	}
+	return servers
+}
+
+// grpcServerOf returns the server and the view of the innermost workspace folder containing the URI.
+func grpcServerOf(uri span.URI) (*ElasticServer, source.View, error) {
+	if uri == "" {
+		return nil, nil, grpcErrorf(grpcInvalidArgument, "expected a URI")
+	}
+	var server *ElasticServer
+	var found source.View
+	for _, s := range servingServers() {
+		for _, view := range s.session.Views() {
+			folder := view.Folder().Filename()
+			if hostPaths.hasPrefix(uri.Filename(), folder) && (found == nil || len(folder) > len(found.Folder().Filename())) {
+				server, found = s, view
+			}
+		}
+	}
+	if found == nil {
+		return nil, nil, grpcErrorf(grpcNotFound, "no workspace folder contains %s", uri.Filename())
+	}
+	return server, found, nil
+}
+
+// grpcStatus maps the error to the gRPC status code.
+func grpcStatus(err error) (int, string) {
+	switch err := err.(type) {
+	case *grpcError:
+		return err.code, err.message
+	case *jsonrpc2.Error:
+		switch err.Code {
+		case jsonrpc2.CodeInvalidParams, jsonrpc2.CodeInvalidRequest:
+			return grpcInvalidArgument, err.Message
+		case jsonrpc2.CodeServerOverloaded:
+			return grpcResourceExhausted, err.Message
+		case protocol.CodeRequestTimeout:
+			return grpcDeadlineExceeded, err.Message
+		}
+		return grpcUnknown, err.Message
+	}
+	switch err {
+	case context.Canceled:
+		return grpcCanceled, err.Error()
+	case context.DeadlineExceeded:
+		return grpcDeadlineExceeded, err.Error()
+	}
+	return grpcUnknown, err.Error()
+}
+
+// grpcEscape percent-encodes the status message as the 'grpc-message' header requires.
+func grpcEscape(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func grpcBool(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func grpcPosition(pos protocol.Position) protoMessage {
+	var msg protoMessage
+	msg.uint(1, uint64(pos.Line))
+	msg.uint(2, uint64(pos.Character))
+	return msg
+}
+
+func grpcLocation(loc protocol.Location) protoMessage {
+	var rng, msg protoMessage
+	rng.message(1, grpcPosition(loc.Range.Start))
+	rng.message(2, grpcPosition(loc.Range.End))
+	msg.string(1, string(loc.URI))
+	msg.message(2, rng)
+	return msg
+}
+
+func grpcPackageLocator(pkg protocol.PackageLocator) protoMessage {
+	var msg protoMessage
+	msg.string(1, pkg.Version)
+	msg.string(2, pkg.Name)
+	msg.string(3, pkg.RepoURI)
+	msg.string(4, pkg.Module)
+	return msg
+}
+
+func grpcSymbolLocator(loc protocol.SymbolLocator) protoMessage {
+	var msg protoMessage
+	msg.string(1, loc.Qname)
+	msg.uint(2, uint64(loc.Kind))
+	msg.string(3, loc.Path)
+	if loc.Loc != nil {
+		msg.message(4, grpcLocation(*loc.Loc))
+	}
+	msg.message(5, grpcPackageLocator(loc.Package))
+	msg.uint(6, grpcBool(loc.Generated))
+	msg.string(7, loc.Signature)
+	msg.string(8, loc.Snippet)
+	msg.uint(9, grpcBool(loc.Approximate))
+	return msg
+}
+
+func grpcSymbolInformation(sym protocol.SymbolInformation) protoMessage {
+	var msg protoMessage
+	msg.string(1, sym.Name)
+	msg.uint(2, uint64(sym.Kind))
+	msg.uint(3, grpcBool(sym.Deprecated))
+	msg.message(4, grpcLocation(sym.Location))
+	msg.string(5, sym.ContainerName)
+	return msg
+}
@@ -0,0 +1,161 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// callGRPC calls the method of the gRPC handler and returns the status code and the message of the response.
+func callGRPC(t *testing.T, method string, req protoMessage) (string, protoFields) {
+	body := make([]byte, 5, 5+len(req))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(req)))
+	r := httptest.NewRequest("POST", grpcService+method, bytes.NewReader(append(body, req...)))
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	grpcHandler(nil).ServeHTTP(w, r)
+	resp := w.Result()
+	code := resp.Trailer.Get("Grpc-Status")
+	data, _ := ioutil.ReadAll(resp.Body)
+	if code != "0" {
+		return code, nil
+	}
+	if len(data) < 5 {
+		t.Fatalf("got the response %q of %s, want one message", data, method)
+	}
+	fields, err := parseProto(data[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, fields
+}
+
+func TestGRPC(t *testing.T) {
+	dir := newTestDir(t, "grpc", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package a\n\ntype T struct{}\n\nvar V T\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	// The LSP connection of the server is a legacy one accepting the compression, which the gRPC messages don't follow.
+	s.clientCaps = parseClientCapabilities(map[string]interface{}{
+		"elastic": map[string]interface{}{"compression": []string{"gzip"}},
+	})
+	serving.Lock()
+	serving.servers[s] = true
+	serving.Unlock()
+	defer func() {
+		serving.Lock()
+		delete(serving.servers, s)
+		serving.Unlock()
+	}()
+	a := string(protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go"))))
+
+	var req protoMessage
+	req.string(1, a)
+	req.uint(2, 1)
+	code, full := callGRPC(t, "Full", req)
+	if code != "0" {
+		t.Fatalf("got the status %s of Full, want OK", code)
+	}
+	qnames := make(map[string]bool)
+	for _, sym := range full[1] {
+		fields, err := parseProto(sym.bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qnames[fields.string(2)] = true
+	}
+	if !qnames["a.T"] || !qnames["a.V"] || len(full[2]) == 0 {
+		t.Errorf("got the symbols %v and %d references, want a.T, a.V and the references", qnames, len(full[2]))
+	}
+
+	req = nil
+	req.string(1, a)
+	var pos protoMessage
+	pos.uint(1, 4)
+	pos.uint(2, 6)
+	req.message(2, pos)
+	code, def := callGRPC(t, "EDefinition", req)
+	if code != "0" || len(def[1]) != 1 {
+		t.Fatalf("got the status %s and the locators %v of EDefinition, want one locator", code, def)
+	}
+	if v := def.uint(2); v != protocol.IndexSchemaVersion {
+		t.Errorf("got the schema version %d of EDefinition, want %d", v, protocol.IndexSchemaVersion)
+	}
+	// The symbols of the workspace folder are located by the locations.
+	if loc, err := parseProto(def[1][0].bytes); err != nil {
+		t.Fatal(err)
+	} else if location, err := loc.message(4); err != nil || location.string(1) != a {
+		t.Errorf("got the locator %v, want the one located at a.go", loc)
+	} else if loc.string(7) == "" {
+		t.Errorf("got the locator %v without the signature, want the current shape", loc)
+	}
+
+	// The pages are fetched by the cursors.
+	var paged []string
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		req = nil
+		req.string(1, a)
+		req.uint(3, 1)
+		req.string(4, cursor)
+		code, page := callGRPC(t, "Full", req)
+		if code != "0" {
+			t.Fatalf("got the status %s of the page %q, want OK", code, cursor)
+		}
+		for _, sym := range page[1] {
+			fields, err := parseProto(sym.bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, fields.string(2))
+		}
+		if len(page[1]) > 1 {
+			t.Errorf("got %d symbols of the page %q, want at most 1", len(page[1]), cursor)
+		}
+		if cursor = page.string(7); cursor == "" {
+			break
+		}
+	}
+	if len(paged) != 2 {
+		t.Errorf("got the symbols %v of the pages, want a.T and a.V", paged)
+	}
+
+	code, status := callGRPC(t, "DepsStatus", nil)
+	if code != "0" || len(status[1]) != 1 {
+		t.Fatalf("got the status %s and the folders %v of DepsStatus, want one folder", code, status)
+	}
+	if folder, err := parseProto(status[1][0].bytes); err != nil || folder.string(1) != string(protocol.NewURI(span.FileURI(dir))) || folder.uint(3) != 0 {
+		t.Errorf("got the folder status %v, want the folder without the dependency management", folder)
+	}
+
+	req = nil
+	req.string(1, string(protocol.NewURI(span.FileURI(dir))))
+	code, index := callGRPC(t, "ExportIndex", req)
+	if code != "0" || len(index[1]) != 1 || len(index[1][0].bytes) == 0 {
+		t.Errorf("got the status %s of ExportIndex, want the index", code)
+	}
+	if v := index.uint(2); v != protocol.IndexSchemaVersion {
+		t.Errorf("got the schema version %d of ExportIndex, want %d", v, protocol.IndexSchemaVersion)
+	}
+
+	req = nil
+	req.string(1, "file:///none/a.go")
+	if code, _ := callGRPC(t, "Full", req); code != "5" {
+		t.Errorf("got the status %s of the document out of the folders, want NOT_FOUND", code)
+	}
+	if code, _ := callGRPC(t, "Hover", nil); code != "12" {
+		t.Errorf("got the status %s of the unknown method, want UNIMPLEMENTED", code)
+	}
+}
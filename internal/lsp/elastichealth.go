@@ -0,0 +1,111 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// serving keeps track of the listeners accepting the connections and the servers whose jsonrpc2 loop is running, so
+// that the health checks can tell whether the process still serves.
+var serving = struct {
+	sync.Mutex
+	listeners int
+	servers   map[*ElasticServer]bool
+}{servers: make(map[*ElasticServer]bool)}
+
+// healthReport is the body of the health checks.
+type healthReport struct {
+	// Running reports whether the process serves, i.e. it's listening for the connections or a jsonrpc2 loop is
+	// running.
+	Running bool `json:"running"`
+	// Ready reports whether every server running has been initialized and managed the dependencies of its folders.
+	Ready    bool `json:"ready"`
+	Sessions int  `json:"sessions"`
+	Views    int  `json:"views"`
+	DepsRuns int  `json:"depsRuns"`
+	// LoadQueue is the number of the package loads waiting for the parallelism limit.
+	LoadQueue int `json:"loadQueue"`
+	// Subprocesses are the go and git commands running, out of the ones started, and the commands whose process groups
+	// outlived them and were reaped.
+	Subprocesses        int `json:"subprocesses"`
+	SubprocessesStarted int `json:"subprocessesStarted"`
+	SubprocessesReaped  int `json:"subprocessesReaped"`
+}
+
+// ready reports whether the server has been initialized and no dependency management of its folders is running.
+func (s *ElasticServer) ready() bool {
+	s.stateMu.Lock()
+	state := s.state
+	s.stateMu.Unlock()
+	return state >= serverInitializing && state < serverShutDown && s.depsRuns.running() == 0
+}
+
+// health returns the health of the process.
+func health() healthReport {
+	serving.Lock()
+	defer serving.Unlock()
+	report := healthReport{
+		Running:  serving.listeners > 0 || len(serving.servers) > 0,
+		Sessions: len(serving.servers),
+	}
+	report.Ready = report.Running
+	subprocesses.Lock()
+	report.Subprocesses = len(subprocesses.running)
+	report.SubprocessesStarted, report.SubprocessesReaped = subprocesses.started, subprocesses.reaped
+	subprocesses.Unlock()
+	for s := range serving.servers {
+		report.Views += len(s.session.Views())
+		report.DepsRuns += s.depsRuns.running()
+		report.LoadQueue += s.stats.queuedLoads()
+		if !s.ready() {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// healthHandler serves '/healthz', which succeeds as long as the process serves, and '/readyz', which succeeds once
+// the servers are ready. Both of them reply the health report.
+func healthHandler() http.Handler {
+	reply := func(w http.ResponseWriter, ok bool, report healthReport) {
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := health()
+		reply(w, report.Running, report)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := health()
+		reply(w, report.Ready, report)
+	})
+	return mux
+}
+
+// ServeHealth serves the health checks on the given address, it does nothing if the address is empty.
+func ServeHealth(ctx context.Context, addr string) error {
+	if addr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Print(ctx, "Health serving", tag.Of("Address", ln.Addr().String()))
+	go func() {
+		if err := http.Serve(ln, healthHandler()); err != nil {
+			log.Error(ctx, "Health server failed", err)
+		}
+	}()
+	return nil
+}
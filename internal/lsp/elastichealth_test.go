@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestHealthChecks(t *testing.T) {
+	check := func(path string, wantCode int) healthReport {
+		t.Helper()
+		w := httptest.NewRecorder()
+		healthHandler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != wantCode {
+			t.Errorf("got the status %d of %s, want %d", w.Code, path, wantCode)
+		}
+		var report healthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	// Nothing serves yet.
+	check("/healthz", http.StatusServiceUnavailable)
+	check("/readyz", http.StatusServiceUnavailable)
+
+	ctx := context.Background()
+	s := newTestSessionServer(ctx, source.DefaultOptions)
+	serving.Lock()
+	serving.servers[s] = true
+	serving.Unlock()
+	defer func() {
+		serving.Lock()
+		delete(serving.servers, s)
+		serving.Unlock()
+	}()
+
+	// The server running isn't ready until it's initialized.
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusServiceUnavailable)
+	s.state = serverInitialized
+	if report := check("/readyz", http.StatusOK); !report.Running || !report.Ready || report.Sessions != 1 {
+		t.Errorf("got the report %+v, want the ready session", report)
+	}
+
+	// The server isn't ready while the dependencies of its folders are managed.
+	_, done := s.depsRuns.start(ctx, []string{"/repo"})
+	if report := check("/readyz", http.StatusServiceUnavailable); report.DepsRuns != 1 {
+		t.Errorf("got %d runs of the dependency management, want 1", report.DepsRuns)
+	}
+	done()
+	check("/readyz", http.StatusOK)
+
+	s.state = serverShutDown
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusServiceUnavailable)
+}
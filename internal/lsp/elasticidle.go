@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/telemetry/export"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// activity keeps track of the requests handled by the servers, so that they shut down once they've been idle for a
+// while. The connections accepted or closed count as the activity as well.
+type activity struct {
+	mu     sync.Mutex
+	active int
+	last   time.Time
+}
+
+// touch records the activity happened just now.
+func (a *activity) touch() {
+	a.mu.Lock()
+	a.last = time.Now()
+	a.mu.Unlock()
+}
+
+// idleFor returns how long the servers have been idle by now, it's zero while any request is active.
+func (a *activity) idleFor(now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active > 0 || now.Before(a.last) {
+		return 0
+	}
+	return now.Sub(a.last)
+}
+
+type idleRequestKey struct{}
+
+// idleHandler keeps track of the requests received, the request is active until it's fully processed.
+type idleHandler struct {
+	jsonrpc2.EmptyHandler
+	activity *activity
+}
+
+func (h idleHandler) Request(ctx context.Context, direction jsonrpc2.Direction, r *jsonrpc2.WireRequest) context.Context {
+	if direction != jsonrpc2.Receive {
+		// The requests sent while handling a request received mustn't end it once they're done.
+		return context.WithValue(ctx, idleRequestKey{}, nil)
+	}
+	h.activity.mu.Lock()
+	h.activity.active++
+	h.activity.last = time.Now()
+	h.activity.mu.Unlock()
+	return context.WithValue(ctx, idleRequestKey{}, true)
+}
+
+func (h idleHandler) Done(ctx context.Context, err error) {
+	if ctx.Value(idleRequestKey{}) == nil {
+		return
+	}
+	h.activity.mu.Lock()
+	h.activity.active--
+	h.activity.last = time.Now()
+	h.activity.mu.Unlock()
+}
+
+// shutdownWhenIdle returns the context which is canceled once no request of the servers has been active for the idle
+// timeout, the servers running are shut down gracefully before, i.e. the dependency management is aborted, the files
+// created manually are cleaned up and the telemetry is flushed. The zero timeout never shuts down.
+func (servers *Servers) shutdownWhenIdle(ctx context.Context) context.Context {
+	timeout := servers.options.IdleTimeout
+	if timeout <= 0 {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(timeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if servers.activity.idleFor(now) < timeout {
+					continue
+				}
+				log.Print(ctx, "shutting down the idle server", tag.Of("Timeout", timeout))
+				servers.shutdown(ctx)
+				export.Flush()
+				return
+			}
+		}
+	}()
+	return ctx
+}
+
+// shutdown shuts down the servers running like the 'shutdown' request does.
+func (servers *Servers) shutdown(ctx context.Context) {
+	serving.Lock()
+	var running []*ElasticServer
+	for s := range serving.servers {
+		if s.servers == servers {
+			running = append(running, s)
+		}
+	}
+	serving.Unlock()
+	for _, s := range running {
+		s.Cleanup()
+		if err := s.Shutdown(ctx); err != nil {
+			log.Error(ctx, "failed to shut down the idle server", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestIdleHandler(t *testing.T) {
+	h := idleHandler{activity: &activity{last: time.Now()}}
+	ctx := h.Request(context.Background(), jsonrpc2.Receive, &jsonrpc2.WireRequest{Method: "textDocument/full"})
+	// The requests sent by the server aren't the activity of the clients.
+	h.Done(h.Request(context.Background(), jsonrpc2.Send, &jsonrpc2.WireRequest{Method: "window/showMessage"}), nil)
+	h.Done(h.Request(ctx, jsonrpc2.Send, &jsonrpc2.WireRequest{Method: "workspace/configuration"}), nil)
+	if idle := h.activity.idleFor(time.Now().Add(time.Hour)); idle != 0 {
+		t.Errorf("got idle for %v while the request is active, want 0", idle)
+	}
+	h.Done(ctx, nil)
+	if idle := h.activity.idleFor(time.Now().Add(time.Hour)); idle < 59*time.Minute {
+		t.Errorf("got idle for %v an hour after the request, want about an hour", idle)
+	}
+}
+
+func TestShutdownWhenIdle(t *testing.T) {
+	ctx := context.Background()
+	if idleCtx, _ := NewServers(ctx, ServerOptions{}); idleCtx != ctx {
+		t.Errorf("the zero timeout is expected to never shut down")
+	}
+
+	idleCtx, servers := NewServers(ctx, ServerOptions{IdleTimeout: 50 * time.Millisecond})
+	s := newTestSessionServer(ctx, source.DefaultOptions)
+	s.state = serverInitialized
+	s.servers = servers
+	// The servers sharing other options aren't shut down.
+	other := newTestSessionServer(ctx, source.DefaultOptions)
+	other.state = serverInitialized
+	serving.Lock()
+	serving.servers[s] = true
+	serving.servers[other] = true
+	serving.Unlock()
+	defer func() {
+		serving.Lock()
+		delete(serving.servers, s)
+		delete(serving.servers, other)
+		serving.Unlock()
+	}()
+
+	select {
+	case <-idleCtx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the idle server is expected to shut down")
+	}
+	if s.state != serverShutDown {
+		t.Errorf("got the state %v of the idle server, want it shut down", s.state)
+	}
+	if other.state != serverInitialized {
+		t.Errorf("got the state %v of the server of the other options, want it still initialized", other.state)
+	}
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestEDefinitionImports(t *testing.T) {
+	dir := newTestDir(t, "imports", map[string]string{
+		"dep/go.mod": "module example.com/dep\n\ngo 1.11\n",
+		"dep/dep.go": "package dep\n\nfunc F() {}\n\ntype T struct{ X int }\n",
+		"m/go.mod":   "module example.com/m\n\ngo 1.11\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../dep\n",
+		"m/m.go": `package m
+
+import (
+	. "example.com/dep"
+	d "example.com/dep"
+)
+
+var _ = F
+
+var _ = d.F
+
+var _ = T{}.X
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	options := source.DefaultOptions
+	folder := filepath.Join(dir, "m")
+	s, _ := newTestServer(ctx, folder, "m", options)
+	for _, test := range []struct {
+		line, character float64
+		qname           string
+		kind            protocol.SymbolKind
+	}{
+		// The dot-imported identifiers.
+		{7, 8, "dep.F", protocol.Function},
+		{11, 8, "dep.T", protocol.Struct},
+		{11, 12, "dep.T.X", protocol.Field},
+		// The package names of the imports, both the qualifier and the names in the import specs.
+		{9, 8, "dep", protocol.Package},
+		{9, 10, "dep.F", protocol.Function},
+		{4, 1, "dep", protocol.Package},
+		{3, 1, "dep", protocol.Package},
+		// The import paths.
+		{3, 4, "dep", protocol.Package},
+		{4, 4, "dep", protocol.Package},
+	} {
+		locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(folder, "m.go")))},
+				Position:     protocol.Position{Line: test.line, Character: test.character},
+			},
+		}})
+		if err != nil {
+			t.Errorf("%v:%v: %v", test.line, test.character, err)
+			continue
+		}
+		if len(locs) != 1 || locs[0].Qname != test.qname || locs[0].Kind != test.kind || locs[0].Package.Name != "dep" || locs[0].Package.RepoURI != "example.com/dep" {
+			t.Errorf("%v:%v: got the definitions %+v, want %s of kind %v in example.com/dep", test.line, test.character, locs, test.qname, test.kind)
+		}
+	}
+
+	// Once the folder of the dependency is open as well, the jumps to it are located.
+	s.session.NewView(ctx, "dep", span.FileURI(filepath.Join(dir, "dep")), options)
+	locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(folder, "m.go")))},
+			Position:     protocol.Position{Line: 9, Character: 10},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 || locs[0].Loc == nil || locs[0].Loc.URI != protocol.NewURI(span.FileURI(filepath.Join(dir, "dep", "dep.go"))) || locs[0].Loc.Range.Start.Line != 2 {
+		t.Errorf("got the definitions %+v, want the location of F in the open folder of the dependency", locs)
+	}
+}
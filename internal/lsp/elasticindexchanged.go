@@ -0,0 +1,213 @@
+package lsp
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// IndexChanged re-emits the 'full' responses of the documents impacted by the changed files of the workspace folder,
+// so that a commit is indexed incrementally instead of indexing the whole folder again. The packages of the changed
+// Go files are impacted along with their importers in the folder, which are told by the imports of the files rather
+// than the type-checks. The changed files are invalidated in the session first unless they're open.
+func (s *ElasticServer) IndexChanged(ctx context.Context, params *protocol.IndexChangedParams) (protocol.IndexChangedResponse, error) {
+	resp := protocol.IndexChangedResponse{
+		SchemaVersion: s.clientCaps.schemaVersion(),
+		Documents:     []protocol.IndexedDocument{},
+		Removed:       []protocol.DocumentURI{},
+		Packages:      []string{},
+	}
+	folder := span.NewURI(params.Folder).Filename()
+	view := s.folderView(folder)
+	if view == nil {
+		return resp, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%s is not a workspace folder", folder)
+	}
+	changed, err := changedFiles(ctx, folder, view.Options(), params)
+	if err != nil {
+		return resp, err
+	}
+
+	var changedDirs []string
+	for _, path := range changed {
+		switch base := filepath.Base(path); {
+		case base == "go.mod" || base == "go.sum":
+			resp.All = true
+		case strings.HasSuffix(base, ".go"):
+			uri := span.FileURI(path)
+			changedDirs = append(changedDirs, filepath.Dir(path))
+			change := protocol.Changed
+			if _, err := os.Stat(path); err != nil {
+				change = protocol.Deleted
+				resp.Removed = append(resp.Removed, protocol.NewURI(uri))
+			} else if view.FindFile(ctx, uri) == nil {
+				change = protocol.Created
+			}
+			if !s.session.IsOpen(uri) {
+				s.session.DidChangeOutOfBand(ctx, uri, change)
+			}
+		}
+	}
+	if resp.All {
+		// The dependencies may change along with the module files, which can't be told without loading the packages.
+		if _, err := s.rebuildFolders(ctx, []protocol.WorkspaceFolder{{URI: protocol.NewURI(view.Folder()), Name: view.Name()}}); err != nil {
+			return resp, err
+		}
+	}
+
+	graph, err := importGraphOf(folder)
+	if err != nil {
+		return resp, err
+	}
+	impacted := make(map[string]bool)
+	if resp.All {
+		for dir := range graph.paths {
+			impacted[dir] = true
+		}
+	} else {
+		graph.importers(changedDirs, impacted)
+	}
+	var files []string
+	if err := walkGoSources(folder, func(path string) {
+		if resp.All || impacted[filepath.Dir(path)] {
+			files = append(files, path)
+		}
+	}); err != nil {
+		return resp, err
+	}
+	for dir := range impacted {
+		if path, ok := graph.paths[dir]; ok {
+			resp.Packages = append(resp.Packages, path)
+		}
+	}
+	sort.Strings(resp.Packages)
+	for _, path := range files {
+		uri := protocol.NewURI(span.FileURI(path))
+		full, err := s.Full(ctx, &protocol.FullParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Reference:    params.Reference,
+		})
+		if err != nil {
+			return resp, err
+		}
+		resp.Documents = append(resp.Documents, protocol.IndexedDocument{URI: uri, Full: full})
+	}
+	return resp, nil
+}
+
+// changedFiles returns the paths of the changed files, either the ones of the params or the ones differing between
+// the git revisions, which git tells under the environment of the folder. The files out of the folder are dropped.
+func changedFiles(ctx context.Context, folder string, options source.Options, params *protocol.IndexChangedParams) ([]string, error) {
+	var changed []string
+	if len(params.Files) > 0 {
+		for _, file := range params.Files {
+			changed = append(changed, span.NewURI(file).Filename())
+		}
+	} else {
+		if params.From == "" {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected the changed files or the git revisions")
+		}
+		args := []string{"diff", "--name-only", "--relative", "-z", params.From}
+		if params.To != "" {
+			args = append(args, params.To)
+		}
+		stdout, err := runGitCommand(ctx, options, folder, options.Env, append(args, "--")...)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(stdout.String(), "\x00") {
+			if name != "" {
+				changed = append(changed, filepath.Join(folder, filepath.FromSlash(name)))
+			}
+		}
+	}
+	var inside []string
+	for _, path := range changed {
+		if hostPaths.hasPrefix(path, folder) {
+			inside = append(inside, path)
+		}
+	}
+	return inside, nil
+}
+
+// importGraph relates the package directories of a folder by the imports of their Go files.
+type importGraph struct {
+	// The import paths of the directories.
+	paths map[string]string
+	// The directories importing the import paths.
+	importedBy map[string][]string
+}
+
+// importGraphOf parses the imports of the Go files under the folder. The import paths of the directories are resolved
+// by the innermost modules containing them, the directories out of any module have no import paths and are only
+// impacted by their own changes.
+func importGraphOf(folder string) (*importGraph, error) {
+	graph := &importGraph{
+		paths:      make(map[string]string),
+		importedBy: make(map[string][]string),
+	}
+	modules := make(map[string]string)
+	imported := make(map[string]map[string]bool)
+	fset := token.NewFileSet()
+	err := walkGoSources(folder, func(path string) {
+		dir := filepath.Dir(path)
+		if _, ok := imported[dir]; !ok {
+			imported[dir] = make(map[string]bool)
+			if modDir := moduleDir(folder, path); modDir != "" {
+				modPath, ok := modules[modDir]
+				if !ok {
+					if data, err := ioutil.ReadFile(filepath.Join(modDir, "go.mod")); err == nil {
+						if match := moduleDirectiveRx.FindSubmatch(data); match != nil {
+							modPath = string(match[1])
+						}
+					}
+					modules[modDir] = modPath
+				}
+				if rel, err := filepath.Rel(modDir, dir); err == nil && modPath != "" {
+					graph.paths[dir] = strings.TrimSuffix(modPath+"/"+filepath.ToSlash(rel), "/.")
+				}
+			}
+		}
+		// The imports are kept even if the rest of the file fails to be parsed.
+		file, _ := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if file == nil {
+			return
+		}
+		for _, spec := range file.Imports {
+			if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imported[dir][importPath] = true
+			}
+		}
+	})
+	for dir, paths := range imported {
+		for path := range paths {
+			graph.importedBy[path] = append(graph.importedBy[path], dir)
+		}
+	}
+	return graph, err
+}
+
+// importers adds the directories and their transitive importers to impacted.
+func (g *importGraph) importers(dirs []string, impacted map[string]bool) {
+	for len(dirs) > 0 {
+		dir := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		if impacted[dir] {
+			continue
+		}
+		impacted[dir] = true
+		if path, ok := g.paths[dir]; ok {
+			dirs = append(dirs, g.importedBy[path]...)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestIndexChanged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is unavailable")
+	}
+	dir := newTestDir(t, "indexchanged", nil)
+	defer os.RemoveAll(dir)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeTestFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.11\n",
+		"a/a.go": "package a\n\nfunc F() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nfunc G() { a.F() }\n",
+		"c/c.go": "package c\n\nfunc H() {}\n",
+	})
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	folder := protocol.NewURI(span.FileURI(dir))
+	uri := func(name string) protocol.DocumentURI {
+		return protocol.NewURI(span.FileURI(filepath.Join(dir, filepath.FromSlash(name))))
+	}
+	if _, err := s.Full(ctx, &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri("a/a.go")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, dir, map[string]string{"a/a.go": "package a\n\nfunc F() {}\n\nfunc E() {}\n"})
+	git("commit", "-q", "-a", "-m", "change")
+	resp, err := s.IndexChanged(ctx, &protocol.IndexChangedParams{Folder: folder, From: "HEAD~1", To: "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/m/a", "example.com/m/b"}; !reflect.DeepEqual(resp.Packages, want) {
+		t.Errorf("got the impacted packages %v, want %v", resp.Packages, want)
+	}
+	if len(resp.Documents) != 2 || resp.Documents[0].URI != uri("a/a.go") || resp.Documents[1].URI != uri("b/b.go") {
+		t.Fatalf("got the documents %+v, want a/a.go and b/b.go", resp.Documents)
+	}
+	if resp.SchemaVersion != protocol.IndexSchemaVersion || resp.Documents[0].Full.SchemaVersion != protocol.IndexSchemaVersion {
+		t.Errorf("got the schema versions %d and %d, want %d", resp.SchemaVersion, resp.Documents[0].Full.SchemaVersion, protocol.IndexSchemaVersion)
+	}
+	// The document changed is indexed by its new content.
+	var qnames []string
+	for _, sym := range resp.Documents[0].Full.Symbols {
+		qnames = append(qnames, sym.Qname)
+	}
+	if want := []string{"a.F", "a.E"}; !reflect.DeepEqual(qnames, want) {
+		t.Errorf("got the symbols %v, want %v", qnames, want)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "c", "c.go")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = s.IndexChanged(ctx, &protocol.IndexChangedParams{Folder: folder, Files: []protocol.DocumentURI{uri("c/c.go")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Documents) != 0 || !reflect.DeepEqual(resp.Removed, []protocol.DocumentURI{uri("c/c.go")}) {
+		t.Errorf("got the documents %+v and the removed %v, want c/c.go removed only", resp.Documents, resp.Removed)
+	}
+	if _, err := s.IndexChanged(ctx, &protocol.IndexChangedParams{Folder: folder}); err == nil {
+		t.Errorf("the request without the changed files and the revisions is expected to be rejected")
+	}
+}
@@ -0,0 +1,49 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// collectLabels collects the symbols of the labels declared in the file, which aren't covered by the document symbols
+// but are navigable by 'goto', 'break' and 'continue'. The labels are scoped by the enclosing functions, so they are
+// qualified by the functions like the local declarations, like 'pkg.Func.label'. The container of a label is the
+// function declaration enclosing it.
+func collectLabels(fset *token.FileSet, file *ast.File, m *protocol.ColumnMapper, info *types.Info, uri protocol.DocumentURI, ordinals declOrdinals) []protocol.DetailSymbolInformation {
+	var syms []protocol.DetailSymbolInformation
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			stmt, ok := n.(*ast.LabeledStmt)
+			if !ok {
+				return true
+			}
+			obj, ok := info.Defs[stmt.Label].(*types.Label)
+			if !ok {
+				return true
+			}
+			rng, err := toProtocolRange(fset, m, stmt.Label.Pos(), stmt.Label.End())
+			if err != nil {
+				return true
+			}
+			kind := getSymbolKind(obj)
+			syms = append(syms, protocol.DetailSymbolInformation{
+				Symbol: protocol.SymbolInformation{
+					Name:          obj.Name(),
+					Kind:          kind,
+					ContainerName: fn.Name.Name,
+					Location:      protocol.Location{URI: uri, Range: rng},
+				},
+				Qname: getQName(file, obj, kind, ordinals),
+			})
+			return true
+		})
+	}
+	return syms
+}
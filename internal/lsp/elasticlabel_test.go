@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+const labelSrc = `package p
+
+func F() {
+outer:
+	for {
+		goto done
+	}
+	func() {
+	loop:
+		for {
+			break loop
+		}
+	}()
+	func() {
+	loop:
+		for {
+			continue loop
+		}
+	}()
+done:
+	_ = 0
+	goto outer
+}
+
+type T struct{}
+
+func (T) M() {
+retry:
+	goto retry
+}
+`
+
+func TestCollectLabels(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "/p.go", labelSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object), Uses: make(map[*ast.Ident]types.Object)}
+	if _, err := (&types.Config{}).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	uri := span.FileURI("/p.go")
+	m := &protocol.ColumnMapper{
+		URI:       uri,
+		Converter: span.NewTokenConverter(fset, fset.File(file.Pos())),
+		Content:   []byte(labelSrc),
+	}
+	type label struct {
+		name, container, qname string
+		line                   float64
+	}
+	var got []label
+	for _, sym := range collectLabels(fset, file, m, info, protocol.NewURI(uri), nil) {
+		if sym.Symbol.Kind != protocol.Key {
+			t.Errorf("got kind %v of label %s, want %v", sym.Symbol.Kind, sym.Qname, protocol.Key)
+		}
+		got = append(got, label{sym.Symbol.Name, sym.Symbol.ContainerName, sym.Qname, sym.Symbol.Location.Range.Start.Line})
+	}
+	want := []label{
+		{"outer", "F", "p.F.outer", 3},
+		{"loop", "F", "p.F.func1.loop", 8},
+		{"loop", "F", "p.F.func2.loop", 14},
+		{"done", "F", "p.F.done", 19},
+		{"retry", "M", "p.T.M.retry", 27},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got labels %v, want %v", got, want)
+	}
+}
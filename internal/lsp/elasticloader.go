@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// packageLoader loads and type-checks the packages in parallel by a bounded number of the workers, like the warm-up of
+// the workspace, the indexing of the folders and the checks of the 'full' requests under the Go versions. The loads
+// beyond the limit wait in the queue, whose depth and the time of the loads are recorded by the stats.
+type packageLoader struct {
+	limit int
+	stats *sessionStats
+}
+
+// newPackageLoader returns the loader running up to parallelism loads at once, GOMAXPROCS is used if it's not
+// positive, since the type-checks are bound by the CPU.
+func newPackageLoader(parallelism int, stats *sessionStats) packageLoader {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	return packageLoader{limit: parallelism, stats: stats}
+}
+
+// run calls load for each of the n items, the items are started in order. The first error cancels the context of the
+// loads, the ones queued are never started, and it's returned once the running ones are done. The error of the
+// context is returned if it's done before all the items are started.
+func (l packageLoader) run(ctx context.Context, n int, load func(ctx context.Context, i int) error) error {
+	g, gctx := errgroup.WithContext(ctx)
+	slots := make(chan struct{}, l.limit)
+	l.stats.loadQueued(n)
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-gctx.Done():
+		}
+		// The slot may be free once the context is done as well.
+		if gctx.Err() != nil {
+			l.stats.loadQueued(i - n)
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+		l.stats.loadQueued(-1)
+		i := i
+		g.Go(func() error {
+			defer func() { <-slots }()
+			start := time.Now()
+			err := load(gctx, i)
+			l.stats.loaded(time.Since(start))
+			return err
+		})
+	}
+	return g.Wait()
+}
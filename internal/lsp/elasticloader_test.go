@@ -0,0 +1,79 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPackageLoader(t *testing.T) {
+	stats := newSessionStats()
+	loader := newPackageLoader(2, stats)
+	ctx := context.Background()
+
+	// The loads run in parallel up to the limit.
+	var mu sync.Mutex
+	running, peak := 0, 0
+	loaded := make([]bool, 8)
+	if err := loader.run(ctx, len(loaded), func(ctx context.Context, i int) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		loaded[i] = true
+		mu.Unlock()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if peak != 2 {
+		t.Errorf("got %d loads running at once, want 2", peak)
+	}
+	for i, ok := range loaded {
+		if !ok {
+			t.Errorf("got the item %d never loaded", i)
+		}
+	}
+	summary := stats.summary()
+	if summary.Loads != 8 || summary.LoadTime <= 0 || summary.SlowestLoad <= 0 || summary.PeakLoadQueue != 8 || stats.queuedLoads() != 0 {
+		t.Errorf("got the loads %d, time %v, slowest %v, peak queue %d and queue %d", summary.Loads, summary.LoadTime, summary.SlowestLoad, summary.PeakLoadQueue, stats.queuedLoads())
+	}
+
+	// The first error stops the loads queued.
+	failure := errors.New("failure")
+	started := 0
+	err := loader.run(ctx, 100, func(ctx context.Context, i int) error {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		if i == 0 {
+			return failure
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != failure || started > 3 {
+		t.Errorf("got the error %v after %d loads started, want the failure before the queued ones", err, started)
+	}
+	if stats.queuedLoads() != 0 {
+		t.Errorf("got %d loads left in the queue", stats.queuedLoads())
+	}
+
+	// The loads are never started under the canceled context.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	started = 0
+	if err := newPackageLoader(0, nil).run(canceled, 4, func(ctx context.Context, i int) error {
+		started++
+		return nil
+	}); err != context.Canceled || started != 0 {
+		t.Errorf("got the error %v after %d loads started, want the cancellation", err, started)
+	}
+}
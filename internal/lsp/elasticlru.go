@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"container/list"
+	"context"
+	"os"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// typeInfoFactor approximates the ratio of the memory held by a type-checked package, i.e. the ASTs and the type
+// information, to the size of its source files.
+const typeInfoFactor = 10
+
+// packageLRU bounds the type-checked packages retained by the views for the 'full' and 'edefinition' requests. The
+// views retain every package they have ever checked for the lifetime of the session, which is unaffordable when indexing
+// thousands of files sequentially. Once the number of the used packages or their estimated size exceeds the limits,
+// the least recently used packages are dropped from their views.
+//
+// The dependencies of the evicted packages are shared by the other packages commonly, they are left to the memory
+// watchdog.
+type packageLRU struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[lruKey]*list.Element
+	bytes uint64
+}
+
+type lruKey struct {
+	view string
+	id   string
+}
+
+type lruEntry struct {
+	key  lruKey
+	view source.View
+	size uint64
+}
+
+// use marks the package of the CheckPackageHandle as the most recently used one, and evicts the least recently used
+// packages if the limits of the view are exceeded.
+func (c *packageLRU) use(ctx context.Context, view source.View, cph source.CheckPackageHandle) {
+	if cph == nil {
+		return
+	}
+	opts := view.Options()
+	if opts.PackageCacheEntries == 0 && opts.PackageCacheBudget == 0 {
+		return
+	}
+	key := lruKey{view: view.Name(), id: cph.ID()}
+
+	c.mu.Lock()
+	if c.items == nil {
+		c.ll = list.New()
+		c.items = make(map[lruKey]*list.Element)
+	}
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		c.mu.Unlock()
+		return
+	}
+	entry := &lruEntry{key: key, view: view, size: estimatePackageSize(cph)}
+	c.items[key] = c.ll.PushFront(entry)
+	c.bytes += entry.size
+	var evicted []*lruEntry
+	for c.ll.Len() > 1 && c.exceeds(opts) {
+		e := c.ll.Back()
+		oldest := e.Value.(*lruEntry)
+		c.ll.Remove(e)
+		delete(c.items, oldest.key)
+		c.bytes -= oldest.size
+		evicted = append(evicted, oldest)
+	}
+	c.mu.Unlock()
+
+	// Evict the packages outside of the lock, which acquires the locks of the views.
+	for _, entry := range evicted {
+		entry.view.EvictPackage(ctx, entry.key.id)
+	}
+	if len(evicted) > 0 {
+		log.Print(ctx, "evicted least recently used packages", tag.Of("Count", len(evicted)))
+	}
+}
+
+// forget drops the entries of the view, like the one of a workspace folder removed, so that the view isn't retained.
+func (c *packageLRU) forget(view source.View) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.items {
+		if entry := e.Value.(*lruEntry); entry.view == view {
+			c.ll.Remove(e)
+			delete(c.items, key)
+			c.bytes -= entry.size
+		}
+	}
+}
+
+func (c *packageLRU) exceeds(opts source.Options) bool {
+	if opts.PackageCacheEntries > 0 && c.ll.Len() > opts.PackageCacheEntries {
+		return true
+	}
+	return opts.PackageCacheBudget > 0 && c.bytes > opts.PackageCacheBudget
+}
+
+// estimatePackageSize estimates the memory held by the type-checked package from the size of its source files.
+func estimatePackageSize(cph source.CheckPackageHandle) uint64 {
+	var size uint64
+	for _, ph := range cph.Files() {
+		if info, err := os.Stat(ph.File().Identity().URI.Filename()); err == nil {
+			size += uint64(info.Size())
+		}
+	}
+	return size * typeInfoFactor
+}
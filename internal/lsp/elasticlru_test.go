@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+type lruView struct {
+	source.View
+	options source.Options
+	evicted []string
+}
+
+func (v *lruView) Name() string            { return "lru" }
+func (v *lruView) Options() source.Options { return v.options }
+func (v *lruView) EvictPackage(ctx context.Context, id string) bool {
+	v.evicted = append(v.evicted, id)
+	return true
+}
+
+type lruCheckPackageHandle struct {
+	source.CheckPackageHandle
+	id    string
+	files []source.ParseGoHandle
+}
+
+func (cph *lruCheckPackageHandle) ID() string                    { return cph.id }
+func (cph *lruCheckPackageHandle) Files() []source.ParseGoHandle { return cph.files }
+
+type lruParseGoHandle struct {
+	source.ParseGoHandle
+	fh lruFileHandle
+}
+
+func (ph *lruParseGoHandle) File() source.FileHandle { return ph.fh }
+
+type lruFileHandle struct {
+	source.FileHandle
+	uri span.URI
+}
+
+func (fh lruFileHandle) Identity() source.FileIdentity { return source.FileIdentity{URI: fh.uri} }
+
+func TestPackageLRUEntries(t *testing.T) {
+	ctx := context.Background()
+	view := &lruView{options: source.Options{PackageCacheEntries: 2}}
+	var c packageLRU
+	for _, id := range []string{"a", "b", "a", "c", "d"} {
+		c.use(ctx, view, &lruCheckPackageHandle{id: id})
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(view.evicted, want) {
+		t.Errorf("got evicted %v, want %v", view.evicted, want)
+	}
+}
+
+func TestPackageLRUBudget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(file, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ph := &lruParseGoHandle{fh: lruFileHandle{uri: span.FileURI(file)}}
+	if size := estimatePackageSize(&lruCheckPackageHandle{files: []source.ParseGoHandle{ph}}); size != 100*typeInfoFactor {
+		t.Fatalf("got estimated size %d", size)
+	}
+
+	ctx := context.Background()
+	// The budget holds two packages.
+	view := &lruView{options: source.Options{PackageCacheBudget: 2 * 100 * typeInfoFactor}}
+	var c packageLRU
+	for _, id := range []string{"a", "b", "c"} {
+		c.use(ctx, view, &lruCheckPackageHandle{id: id, files: []source.ParseGoHandle{ph}})
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(view.evicted, want) {
+		t.Errorf("got evicted %v, want %v", view.evicted, want)
+	}
+	if c.bytes != 2*100*typeInfoFactor {
+		t.Errorf("got %d bytes retained", c.bytes)
+	}
+}
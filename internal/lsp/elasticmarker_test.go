@@ -0,0 +1,265 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/expect"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/txtar"
+)
+
+// TestElasticMarkers runs the marker tests of the elastic requests in testdata/elasticmarker. Each archive is a
+// workspace folder, the settings of the folder are read from 'settings.json' of the archive if any. The Go files are
+// annotated by the markers, whose first argument is the pattern locating the identifier on the line of the marker:
+//
+//	//@def(pattern, qname, kind)                'edefinition' at the identifier locates the qname of the kind
+//	//@defpkg(pattern, name, repoURI, version)  the package locator of the definition, the version is optional
+//	//@sym(pattern, qname, kind, container)     'full' reports the symbol declared at the identifier
+//	//@sympkg(pattern, name, repoURI, version)  the package locator of the symbol, the version is optional
+//
+// The kinds are the names of the symbol kinds, like Function and Struct.
+func TestElasticMarkers(t *testing.T) {
+	archives, err := filepath.Glob(filepath.Join("testdata", "elasticmarker", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) == 0 {
+		t.Fatal("no marker tests")
+	}
+	for _, archive := range archives {
+		archive := archive
+		t.Run(strings.TrimSuffix(filepath.Base(archive), ".txt"), func(t *testing.T) {
+			runMarkerArchive(t, archive)
+		})
+	}
+}
+
+// markerSymbolKinds are the symbol kinds by their names in the markers.
+var markerSymbolKinds = map[string]protocol.SymbolKind{
+	"File":          protocol.File,
+	"Module":        protocol.Module,
+	"Namespace":     protocol.Namespace,
+	"Package":       protocol.Package,
+	"Class":         protocol.Class,
+	"Method":        protocol.Method,
+	"Property":      protocol.Property,
+	"Field":         protocol.Field,
+	"Constructor":   protocol.Constructor,
+	"Enum":          protocol.Enum,
+	"Interface":     protocol.Interface,
+	"Function":      protocol.Function,
+	"Variable":      protocol.Variable,
+	"Constant":      protocol.Constant,
+	"String":        protocol.String,
+	"Number":        protocol.Number,
+	"Boolean":       protocol.Boolean,
+	"Array":         protocol.Array,
+	"Object":        protocol.Object,
+	"Key":           protocol.Key,
+	"Null":          protocol.Null,
+	"EnumMember":    protocol.EnumMember,
+	"Struct":        protocol.Struct,
+	"Event":         protocol.Event,
+	"Operator":      protocol.Operator,
+	"TypeParameter": protocol.TypeParameter,
+}
+
+// marker is a marker of a file, located at the identifier matched by its pattern.
+type marker struct {
+	note *expect.Note
+	uri  protocol.DocumentURI
+	pos  protocol.Position
+	// The position of the marker itself, for the messages.
+	posn token.Position
+}
+
+// str returns the i-th argument of the marker as a string, the absent arguments are empty.
+func (m marker) str(t *testing.T, i int) string {
+	t.Helper()
+	if i >= len(m.note.Args) {
+		return ""
+	}
+	s, ok := m.note.Args[i].(string)
+	if !ok {
+		t.Fatalf("%v: got the argument %v of %s, want a string", m.posn, m.note.Args[i], m.note.Name)
+	}
+	return s
+}
+
+// kind returns the i-th argument of the marker as a symbol kind.
+func (m marker) kind(t *testing.T, i int) protocol.SymbolKind {
+	t.Helper()
+	if i < len(m.note.Args) {
+		if id, ok := m.note.Args[i].(expect.Identifier); ok {
+			if kind, ok := markerSymbolKinds[string(id)]; ok {
+				return kind
+			}
+		}
+	}
+	t.Fatalf("%v: got no symbol kind of %s at %d", m.posn, m.note.Name, i)
+	return 0
+}
+
+func runMarkerArchive(t *testing.T, archive string) {
+	a, err := txtar.ParseFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "marker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	options := source.DefaultOptions
+	for _, f := range a.Files {
+		if f.Name == "settings.json" {
+			var settings map[string]interface{}
+			if err := json.Unmarshal(f.Data, &settings); err != nil {
+				t.Fatalf("malformed settings: %v", err)
+			}
+			for _, result := range source.SetOptions(&options, settings) {
+				if result.Error != nil {
+					t.Fatal(result.Error)
+				}
+			}
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, f.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The markers are collected before the requests, so that a malformed archive fails as a whole.
+	fset := token.NewFileSet()
+	var markers []marker
+	for _, f := range a.Files {
+		if !strings.HasSuffix(f.Name, ".go") {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		notes, err := expect.Parse(fset, path, f.Data)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		m := &protocol.ColumnMapper{
+			URI:       span.FileURI(path),
+			Converter: span.NewContentConverter(path, f.Data),
+			Content:   f.Data,
+		}
+		readFile := func(string) ([]byte, error) { return f.Data, nil }
+		for _, note := range notes {
+			mk := marker{note: note, uri: protocol.NewURI(m.URI), posn: fset.Position(note.Pos)}
+			if len(note.Args) == 0 {
+				t.Fatalf("%v: no pattern of %s", mk.posn, note.Name)
+			}
+			start, end, err := expect.MatchBefore(fset, readFile, note.Pos, note.Args[0])
+			if err != nil || start == token.NoPos {
+				t.Fatalf("%v: the pattern %v of %s isn't matched: %v", mk.posn, note.Args[0], note.Name, err)
+			}
+			rng, err := toProtocolRange(fset, m, start, end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mk.pos = rng.Start
+			markers = append(markers, mk)
+		}
+	}
+	if len(markers) == 0 {
+		t.Fatal("no markers in the archive")
+	}
+
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, filepath.Base(archive), options)
+	fulls := make(map[protocol.DocumentURI]protocol.FullResponse)
+	for _, mk := range markers {
+		switch mk.note.Name {
+		case "def", "defpkg":
+			locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: mk.uri},
+					Position:     mk.pos,
+				},
+			}})
+			if err != nil {
+				t.Errorf("%v: %v", mk.posn, err)
+				continue
+			}
+			if len(locs) != 1 {
+				t.Errorf("%v: got %d definitions, want 1", mk.posn, len(locs))
+				continue
+			}
+			if mk.note.Name == "def" {
+				checkMarkerSymbol(t, mk, locs[0].Qname, locs[0].Kind, "", false)
+			} else {
+				checkMarkerPackage(t, mk, locs[0].Package)
+			}
+		case "sym", "sympkg":
+			resp, ok := fulls[mk.uri]
+			if !ok {
+				resp, err = s.Full(ctx, &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: mk.uri}})
+				if err != nil {
+					t.Errorf("%v: %v", mk.posn, err)
+					continue
+				}
+				fulls[mk.uri] = resp
+			}
+			var found *protocol.DetailSymbolInformation
+			for i, sym := range resp.Symbols {
+				if sym.Symbol.Location.Range.Start == mk.pos {
+					found = &resp.Symbols[i]
+					break
+				}
+			}
+			if found == nil {
+				t.Errorf("%v: got no symbol declared at %v", mk.posn, mk.note.Args[0])
+				continue
+			}
+			if mk.note.Name == "sym" {
+				checkMarkerSymbol(t, mk, found.Qname, found.Symbol.Kind, found.Symbol.ContainerName, true)
+			} else {
+				checkMarkerPackage(t, mk, found.Package)
+			}
+		default:
+			t.Errorf("%v: unknown marker %s", mk.posn, mk.note.Name)
+		}
+	}
+}
+
+func checkMarkerSymbol(t *testing.T, mk marker, qname string, kind protocol.SymbolKind, container string, hasContainer bool) {
+	t.Helper()
+	if want := mk.str(t, 1); qname != want {
+		t.Errorf("%v: got the qname %s, want %s", mk.posn, qname, want)
+	}
+	if want := mk.kind(t, 2); kind != want {
+		t.Errorf("%v: got the kind %v of %s, want %v", mk.posn, kind, qname, want)
+	}
+	if want := mk.str(t, 3); hasContainer && container != want {
+		t.Errorf("%v: got the container %q of %s, want %q", mk.posn, container, qname, want)
+	}
+}
+
+func checkMarkerPackage(t *testing.T, mk marker, pkg protocol.PackageLocator) {
+	t.Helper()
+	if want := mk.str(t, 1); pkg.Name != want {
+		t.Errorf("%v: got the package name %s, want %s", mk.posn, pkg.Name, want)
+	}
+	if want := mk.str(t, 2); pkg.RepoURI != want {
+		t.Errorf("%v: got the repository %s, want %s", mk.posn, pkg.RepoURI, want)
+	}
+	if want := mk.str(t, 3); len(mk.note.Args) > 3 && pkg.Version != want {
+		t.Errorf("%v: got the version %s, want %s", mk.posn, pkg.Version, want)
+	}
+}
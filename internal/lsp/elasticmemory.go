@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	"golang.org/x/tools/internal/xcontext"
+)
+
+const (
+	// memoryCheckInterval is the interval the watchdog samples the memory usage at.
+	memoryCheckInterval = 5 * time.Second
+	// evictFraction is the fraction of the least recently used packages of each view dropped under the memory pressure.
+	evictFraction = 0.5
+)
+
+// memoryWatchdog samples the resident memory of the server periodically, once the memory usage exceeds the limit, the
+// least recently used type-checked packages and their parsed files are evicted from the views.
+type memoryWatchdog struct {
+	limit uint64
+	// pressure is 1 if the last sample exceeds the limit, it must be accessed atomically.
+	pressure int32
+
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+}
+
+// underPressure reports if the memory usage exceeds the limit at the last sample.
+func (w *memoryWatchdog) underPressure() bool {
+	return w != nil && atomic.LoadInt32(&w.pressure) == 1
+}
+
+func (w *memoryWatchdog) stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(w.cancel)
+}
+
+// startMemoryWatchdog starts the watchdog if the option 'memoryLimit' is set.
+func (s *ElasticServer) startMemoryWatchdog(ctx context.Context) {
+	limit := s.session.Options().MemoryLimit
+	if limit == 0 {
+		return
+	}
+	// The watchdog outlives the 'initialize' request.
+	ctx, cancel := context.WithCancel(xcontext.Detach(ctx))
+	w := &memoryWatchdog{limit: limit, cancel: cancel}
+	s.memory = w
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkMemory(ctx, w)
+			}
+		}
+	}()
+}
+
+// checkMemory sheds the caches of the views if the memory usage exceeds the limit.
+func (s *ElasticServer) checkMemory(ctx context.Context, w *memoryWatchdog) {
+	rss := currentRSS()
+	s.stats.memorySampled(rss)
+	if rss <= w.limit {
+		atomic.StoreInt32(&w.pressure, 0)
+		return
+	}
+	atomic.StoreInt32(&w.pressure, 1)
+	evicted := 0
+	for _, view := range s.session.Views() {
+		evicted += view.EvictPackages(ctx, evictFraction)
+	}
+	// Return the memory of the evicted packages to the OS, otherwise the RSS won't drop.
+	debug.FreeOSMemory()
+	log.Print(ctx, "memory limit exceeded, caches are evicted",
+		tag.Of("RSS", rss), tag.Of("Limit", w.limit), tag.Of("Evicted", evicted), tag.Of("After", currentRSS()))
+}
+
+// rejectUnderPressure returns a retryable error if the server is under the memory pressure and the option
+// 'rejectUnderMemoryPressure' is set.
+func (s *ElasticServer) rejectUnderPressure() error {
+	if !s.memory.underPressure() || !s.session.Options().RejectUnderMemoryPressure {
+		return nil
+	}
+	return jsonrpc2.NewErrorf(jsonrpc2.CodeServerOverloaded, "server is under memory pressure, retry later")
+}
+
+// currentRSS returns the resident set size of the process in bytes. It reads '/proc/self/statm' if possible, otherwise
+// the memory obtained from the OS by the Go runtime is used as an approximation.
+func currentRSS() uint64 {
+	if data, err := ioutil.ReadFile("/proc/self/statm"); err == nil {
+		if fields := bytes.Fields(data); len(fields) > 1 {
+			if pages, err := strconv.ParseUint(string(fields[1]), 10, 64); err == nil {
+				return pages * uint64(os.Getpagesize())
+			}
+		}
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// Initialize starts the memory watchdog, bounds the type-checks and restores the warm state once the options are
+// applied, and negotiates the elastic capabilities with the client.
+func (s *ElasticServer) Initialize(ctx context.Context, params *protocol.ParamInitia) (*protocol.InitializeResult, error) {
+	result, err := s.Server.Initialize(ctx, params)
+	if err == nil {
+		s.clientCaps = parseClientCapabilities(params.Capabilities.Experimental)
+		s.startMemoryWatchdog(ctx)
+		opts := s.session.Options()
+		s.typeChecks = newAdmission(opts.TypeCheckLimit, opts.TypeCheckQueue, opts.TypeCheckQueueTimeout)
+		s.restoreWarmState(ctx, warmFolders(params))
+		// The workspace symbols are searched by the elastic server.
+		result.Capabilities.WorkspaceSymbolProvider = true
+		// The declarations are annotated by the reference counts of the reference index.
+		result.Capabilities.CodeLensProvider = &protocol.CodeLensOptions{ResolveProvider: true}
+		if result.Capabilities.ExecuteCommandProvider != nil {
+			provider := result.Capabilities.ExecuteCommandProvider
+			provider.Commands = append(append([]string{}, provider.Commands...), elasticCommands...)
+		}
+		result.Capabilities.Experimental = map[string]interface{}{"elastic": elasticCapabilities()}
+	}
+	return result, err
+}
+
+// Shutdown stops the memory watchdog and the warm-up, emits the summary of the session and saves the warm state before
+// dropping the views.
+func (s *ElasticServer) Shutdown(ctx context.Context) error {
+	s.memory.stop()
+	s.warmUps.stop()
+	s.depsRuns.stop()
+	s.emitSummary(ctx)
+	s.saveWarmState(ctx)
+	return s.Server.Shutdown(ctx)
+}
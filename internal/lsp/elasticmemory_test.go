@@ -0,0 +1,40 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestCurrentRSS(t *testing.T) {
+	if rss := currentRSS(); rss == 0 {
+		t.Errorf("got zero resident memory")
+	}
+}
+
+func TestRejectUnderPressure(t *testing.T) {
+	s := newTestSessionServer(context.Background(), source.DefaultOptions)
+	if err := s.rejectUnderPressure(); err != nil {
+		t.Errorf("got unexpected error %v without the watchdog", err)
+	}
+
+	s.memory = &memoryWatchdog{limit: 1, pressure: 1}
+	if err := s.rejectUnderPressure(); err != nil {
+		t.Errorf("got unexpected error %v without the option 'rejectUnderMemoryPressure'", err)
+	}
+
+	options := s.session.Options()
+	options.RejectUnderMemoryPressure = true
+	s.session.SetOptions(options)
+	err := s.rejectUnderPressure()
+	if rpcErr, ok := err.(*jsonrpc2.Error); !ok || rpcErr.Code != jsonrpc2.CodeServerOverloaded {
+		t.Errorf("got %v, expected a retryable overloaded error", err)
+	}
+
+	s.memory.pressure = 0
+	if err := s.rejectUnderPressure(); err != nil {
+		t.Errorf("got unexpected error %v after the pressure is relieved", err)
+	}
+}
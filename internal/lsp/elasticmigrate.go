@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	errors "golang.org/x/xerrors"
+)
+
+// The kinds of the index documents, which are migrated apart.
+const (
+	fullDocument         = "full"
+	locatorDocument      = "locator"
+	indexChangedDocument = "indexChanged"
+)
+
+// schemaMigrations upgrade the decoded index documents of the schema versions to the next versions respectively, by
+// the kinds of the documents. A migration is added whenever the IndexSchemaVersion is bumped, the kinds left out are
+// of the same shapes in both versions, whose fields added since are optional.
+var schemaMigrations = map[int]map[string]func(doc map[string]interface{}){
+	1: {fullDocument: migrateLegacyFull},
+}
+
+// migrateLegacyFull upgrades the documents of the legacy clients, which may leave out the empty lists, and have no
+// snapshot. The fields added since are optional.
+func migrateLegacyFull(doc map[string]interface{}) {
+	for _, key := range []string{"symbols", "references"} {
+		if doc[key] == nil {
+			doc[key] = []interface{}{}
+		}
+	}
+	if _, ok := doc["snapshot"]; !ok {
+		doc["snapshot"] = json.Number("0")
+	}
+}
+
+// MigrateIndex upgrades the index documents read from r to the IndexSchemaVersion, and writes them to w. The input is
+// a stream of JSON values, like a single document or the JSON lines of the documents, each being a 'full' response,
+// an 'elastic/indexChanged' response carrying the 'full' responses, a symbol locator of the 'textDocument/edefinition'
+// responses, or an array of them. It returns the number of the documents upgraded, the documents of the current
+// version are written as they are. The SCIP indexes carry the version by the arguments of their tool info, and are
+// exported again rather than migrated.
+func MigrateIndex(r io.Reader, w io.Writer) (int, error) {
+	dec := json.NewDecoder(r)
+	// The numbers are kept as they are, like the snapshot IDs beyond the precision of float64.
+	dec.UseNumber()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	migrated := 0
+	for {
+		var value interface{}
+		if err := dec.Decode(&value); err == io.EOF {
+			return migrated, nil
+		} else if err != nil {
+			return migrated, err
+		}
+		n, err := migrateValue(value)
+		migrated += n
+		if err != nil {
+			return migrated, err
+		}
+		if err := enc.Encode(value); err != nil {
+			return migrated, err
+		}
+	}
+}
+
+// migrateValue upgrades the documents of the value in place.
+func migrateValue(value interface{}) (int, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		migrated := 0
+		for _, elem := range value {
+			n, err := migrateValue(elem)
+			migrated += n
+			if err != nil {
+				return migrated, err
+			}
+		}
+		return migrated, nil
+	case map[string]interface{}:
+		if docs, ok := value["documents"].([]interface{}); ok {
+			migrated, err := migrateDocument(indexChangedDocument, value)
+			if err != nil {
+				return migrated, err
+			}
+			for _, doc := range docs {
+				doc, ok := doc.(map[string]interface{})
+				if !ok {
+					return migrated, errors.New("expected the indexed documents to be objects")
+				}
+				if full, ok := doc["full"].(map[string]interface{}); ok {
+					n, err := migrateDocument(fullDocument, full)
+					migrated += n
+					if err != nil {
+						return migrated, err
+					}
+				}
+			}
+			return migrated, nil
+		}
+		_, hasSymbols := value["symbols"]
+		_, hasReferences := value["references"]
+		if hasSymbols || hasReferences {
+			return migrateDocument(fullDocument, value)
+		}
+		// The package locators are never left out of the symbol locators.
+		if pkg, ok := value["package"].(map[string]interface{}); ok {
+			migrated, err := migrateDocument(locatorDocument, value)
+			if err != nil {
+				return migrated, err
+			}
+			// The package locator takes the version of the symbol locator.
+			pkg["schemaVersion"] = protocol.IndexSchemaVersion
+			return migrated, nil
+		}
+	}
+	return 0, errors.New("expected the 'full' responses, the 'elastic/indexChanged' responses or the symbol locators")
+}
+
+// migrateDocument upgrades the document of the kind by the migrations from its version. It returns 1 if the document
+// is upgraded.
+func migrateDocument(kind string, doc map[string]interface{}) (int, error) {
+	version := protocol.LegacySchemaVersion
+	if v, ok := doc["schemaVersion"]; ok {
+		n, ok := v.(json.Number)
+		if !ok {
+			return 0, errors.Errorf("invalid schema version %v", v)
+		}
+		i, err := strconv.Atoi(string(n))
+		if err != nil || i < protocol.LegacySchemaVersion {
+			return 0, errors.Errorf("invalid schema version %v", v)
+		}
+		version = i
+	}
+	if version > protocol.IndexSchemaVersion {
+		return 0, errors.Errorf("the schema version %d is newer than the supported version %d", version, protocol.IndexSchemaVersion)
+	}
+	if version == protocol.IndexSchemaVersion {
+		return 0, nil
+	}
+	for ; version < protocol.IndexSchemaVersion; version++ {
+		if migrate := schemaMigrations[version][kind]; migrate != nil {
+			migrate(doc)
+		}
+	}
+	doc["schemaVersion"] = protocol.IndexSchemaVersion
+	return 1, nil
+}
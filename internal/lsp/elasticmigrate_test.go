@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestMigrateIndex(t *testing.T) {
+	current, err := json.Marshal(protocol.FullResponse{
+		SchemaVersion: protocol.IndexSchemaVersion,
+		Symbols:       []protocol.DetailSymbolInformation{{Qname: "a.F"}},
+		References:    []protocol.Reference{},
+		Snapshot:      18446744073709551615,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		`{"symbols":[{"qname":"a.F","symbolInformation":{"name":"F"}}],"references":null}`,
+		string(current),
+		`{"documents":[{"uri":"file:///a.go","full":{"symbols":[],"references":[]}}],"removed":[]}`,
+		`[{"symbols":[]},{"references":[]}]`,
+		`[{"qname":"a.F","kind":12,"package":{"version":"","name":"a","uri":"example.com/a"}}]`,
+	}, "\n")
+	var buf bytes.Buffer
+	migrated, err := MigrateIndex(strings.NewReader(input), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The indexChanged response is migrated along with its document.
+	if migrated != 6 {
+		t.Errorf("got %d documents migrated, want 6", migrated)
+	}
+	dec := json.NewDecoder(&buf)
+	var legacy protocol.FullResponse
+	if err := dec.Decode(&legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.SchemaVersion != protocol.IndexSchemaVersion || legacy.References == nil || len(legacy.Symbols) != 1 || legacy.Symbols[0].Symbol.Name != "F" {
+		t.Errorf("got the migrated document %+v", legacy)
+	}
+	var up protocol.FullResponse
+	if err := dec.Decode(&up); err != nil {
+		t.Fatal(err)
+	}
+	// The snapshot ID beyond the precision of float64 is kept.
+	if up.Snapshot != 18446744073709551615 {
+		t.Errorf("got the snapshot %d of the current document", up.Snapshot)
+	}
+	var changed protocol.IndexChangedResponse
+	if err := dec.Decode(&changed); err != nil {
+		t.Fatal(err)
+	}
+	if changed.SchemaVersion != protocol.IndexSchemaVersion || len(changed.Documents) != 1 || changed.Documents[0].Full.SchemaVersion != protocol.IndexSchemaVersion {
+		t.Errorf("got the migrated indexChanged response %+v", changed)
+	}
+	var fulls []protocol.FullResponse
+	if err := dec.Decode(&fulls); err != nil {
+		t.Fatal(err)
+	}
+	var locators []protocol.SymbolLocator
+	if err := dec.Decode(&locators); err != nil {
+		t.Fatal(err)
+	}
+	if len(locators) != 1 || locators[0].SchemaVersion != protocol.IndexSchemaVersion || locators[0].Package.SchemaVersion != protocol.IndexSchemaVersion || locators[0].Qname != "a.F" {
+		t.Errorf("got the migrated locators %+v", locators)
+	}
+
+	for _, bad := range []string{
+		`{"schemaVersion":99,"symbols":[]}`,
+		`{"schemaVersion":"2","symbols":[]}`,
+		`{"uri":"file:///a.go"}`,
+	} {
+		if _, err := MigrateIndex(strings.NewReader(bad), &bytes.Buffer{}); err == nil {
+			t.Errorf("got no error migrating %s", bad)
+		}
+	}
+}
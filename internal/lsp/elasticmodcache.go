@@ -0,0 +1,205 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// modCacheUsage records when the module versions in the module cache are used by the symbols, keyed by the directory
+// of the extracted module version, so that the least recently used ones are evicted once the quota is exceeded. The
+// module versions never used in the session fall back to the time they're extracted.
+var modCacheUsage = struct {
+	sync.Mutex
+	used map[string]time.Time
+	// evicting serializes the evictions of the module caches.
+	evicting sync.Mutex
+}{used: make(map[string]time.Time)}
+
+// touchModVersion records the use of the module version containing loc, if loc is located in the module cache.
+func touchModVersion(modCache, loc string, now time.Time) {
+	if dir := modVersionDir(modCache, loc); dir != "" {
+		modCacheUsage.Lock()
+		modCacheUsage.used[dir] = now
+		modCacheUsage.Unlock()
+	}
+}
+
+// modVersionDir returns the directory of the extracted module version containing loc, or "" if loc isn't located in
+// the module cache.
+func modVersionDir(modCache, loc string) string {
+	rel := modCacheRel(modCache, loc)
+	if rel == loc {
+		return ""
+	}
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	for i, elem := range elems {
+		if strings.Contains(elem, "@") {
+			return filepath.Join(modCache, filepath.FromSlash(strings.Join(elems[:i+1], "/")))
+		}
+	}
+	return ""
+}
+
+// liveModVersions returns the directories of the module versions in the module cache which hold the files of the
+// packages loaded by the views of the session, so that they aren't evicted from under the views.
+func liveModVersions(session source.Session, modCache string) map[string]bool {
+	live := make(map[string]bool)
+	if session == nil {
+		return live
+	}
+	for _, view := range session.Views() {
+		for _, uri := range view.Snapshot().MetadataFiles() {
+			if dir := modVersionDir(modCache, uri.Filename()); dir != "" {
+				live[dir] = true
+			}
+		}
+	}
+	return live
+}
+
+// modVersion is a module version in the module cache, i.e. the extracted directory like 'example.com/foo@v1.2.3' and
+// the files of the download cache like 'cache/download/example.com/foo/@v/v1.2.3.zip'.
+type modVersion struct {
+	dir   string
+	files []string
+	size  int64
+	used  time.Time
+}
+
+// scanModCache returns the module versions in the module cache and the total bytes of the module cache, including
+// the files of the download cache which don't belong to any extracted module version, like the lists of versions.
+func scanModCache(modCache string) ([]modVersion, int64, error) {
+	var versions []modVersion
+	var total int64
+	downloadCache := filepath.Join(modCache, "cache")
+	err := filepath.Walk(modCache, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == modCache {
+				return err
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			total += info.Size()
+			return nil
+		}
+		// The download cache is only counted, the '@v' directories in it aren't module versions.
+		if !strings.Contains(info.Name(), "@") || hostPaths.hasPrefix(path, downloadCache) {
+			return nil
+		}
+		v := modVersion{dir: path, used: info.ModTime()}
+		filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				v.size += info.Size()
+			}
+			return nil
+		})
+		total += v.size
+		v.files = downloadFiles(modCache, path)
+		for _, file := range v.files {
+			if info, err := os.Stat(file); err == nil {
+				v.size += info.Size()
+			}
+		}
+		versions = append(versions, v)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	modCacheUsage.Lock()
+	for i, v := range versions {
+		if used, ok := modCacheUsage.used[v.dir]; ok && used.After(v.used) {
+			versions[i].used = used
+		}
+	}
+	modCacheUsage.Unlock()
+	return versions, total, nil
+}
+
+// downloadFiles returns the files of the download cache of the extracted module version, the escaped module path and
+// the version are split by the last '@'.
+func downloadFiles(modCache, dir string) []string {
+	rel, err := filepath.Rel(modCache, dir)
+	if err != nil {
+		return nil
+	}
+	i := strings.LastIndex(rel, "@")
+	if i < 0 {
+		return nil
+	}
+	vdir := filepath.Join(modCache, "cache", "download", rel[:i], "@v")
+	infos, err := ioutil.ReadDir(vdir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), rel[i+1:]+".") {
+			files = append(files, filepath.Join(vdir, info.Name()))
+		}
+	}
+	return files
+}
+
+// evictModCache evicts the least recently used module versions until the module cache fits in the quota, the module
+// versions used since the protected time, like the ones just downloaded, and the live ones referenced by the loaded
+// packages are never evicted. It returns the module versions evicted and the bytes freed.
+func evictModCache(ctx context.Context, versions []modVersion, total int64, quota uint64, protected time.Time, live map[string]bool) ([]string, int64) {
+	modCacheUsage.evicting.Lock()
+	defer modCacheUsage.evicting.Unlock()
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].used.Before(versions[j].used) })
+	var evicted []string
+	var freed int64
+	for _, v := range versions {
+		if uint64(total-freed) <= quota {
+			break
+		}
+		if !v.used.Before(protected) {
+			log.Print(ctx, "the module cache exceeds the quota with the recently used module versions", tag.Of("Quota", quota))
+			break
+		}
+		if live[v.dir] {
+			continue
+		}
+		if err := removeModVersion(v); err != nil {
+			log.Error(ctx, "failed to evict the module version", err, tag.Of("Dir", v.dir))
+			continue
+		}
+		modCacheUsage.Lock()
+		delete(modCacheUsage.used, v.dir)
+		modCacheUsage.Unlock()
+		evicted = append(evicted, v.dir)
+		freed += v.size
+	}
+	return evicted, freed
+}
+
+// removeModVersion removes the module version from the module cache. The extracted files are read-only, they're made
+// writable first like 'go clean -modcache' does.
+func removeModVersion(v modVersion) error {
+	filepath.Walk(v.dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(path, 0777)
+		}
+		return nil
+	})
+	if err := os.RemoveAll(v.dir); err != nil {
+		return err
+	}
+	for _, file := range v.files {
+		os.Remove(file) // ignore the errors
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModCacheEviction(t *testing.T) {
+	modCache, err := ioutil.TempDir("", "modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		filepath.Walk(modCache, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				os.Chmod(path, 0777)
+			}
+			return nil
+		})
+		os.RemoveAll(modCache)
+	}()
+	now := time.Now()
+	versions := []struct {
+		path, version string
+		age           time.Duration
+	}{
+		{"example.com/old", "v1.0.0", 3 * time.Hour},
+		{"example.com/!upper", "v1.0.0", 2 * time.Hour},
+		{"example.com/used", "v0.1.0", time.Hour},
+		{"example.com/new", "v2.0.0", 0},
+	}
+	for _, v := range versions {
+		dir := filepath.Join(modCache, filepath.FromSlash(v.path+"@"+v.version))
+		for name, size := range map[string]int{"a.go": 100, "sub/b.go": 100} {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(file, []byte(strings.Repeat("x", size)), 0444); err != nil {
+				t.Fatal(err)
+			}
+		}
+		vdir := filepath.Join(modCache, "cache", "download", filepath.FromSlash(v.path), "@v")
+		if err := os.MkdirAll(vdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, ext := range []string{".zip", ".mod"} {
+			if err := ioutil.WriteFile(filepath.Join(vdir, v.version+ext), []byte(strings.Repeat("x", 50)), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(vdir, "list"), []byte(v.version+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// The extracted directories are read-only like the ones extracted by the go command.
+		os.Chmod(filepath.Join(dir, "sub"), 0555)
+		os.Chmod(dir, 0555)
+		if err := os.Chtimes(dir, now.Add(-v.age), now.Add(-v.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The use of a symbol makes the module version more recently used than the extracted time.
+	touchModVersion(modCache, filepath.Join(modCache, "example.com", "old@v1.0.0", "sub", "b.go"), now.Add(-30*time.Minute))
+
+	scanned, total, err := scanModCache(modCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != len(versions) {
+		t.Fatalf("got %d module versions, want %d", len(scanned), len(versions))
+	}
+	for _, v := range scanned {
+		if v.size != 300 || len(v.files) != 2 {
+			t.Errorf("got %d bytes and the download files %v of %s, want 300 bytes and 2 files", v.size, v.files, v.dir)
+		}
+	}
+	// The lists of the versions count as well.
+	if want := int64(len(versions) * (300 + len("v1.0.0\n"))); total != want {
+		t.Errorf("got %d bytes in total, want %d", total, want)
+	}
+
+	// The module versions are evicted in the order of the last use until the quota is met, the recent ones are kept.
+	evicted, freed := evictModCache(context.Background(), scanned, total, uint64(total-500), now.Add(-time.Minute), nil)
+	want := []string{
+		filepath.Join(modCache, "example.com", "!upper@v1.0.0"),
+		filepath.Join(modCache, "example.com", "used@v0.1.0"),
+	}
+	if len(evicted) != len(want) || evicted[0] != want[0] || evicted[1] != want[1] || freed != 600 {
+		t.Errorf("got the evicted %v freeing %d bytes, want %v freeing 600 bytes", evicted, freed, want)
+	}
+	for _, dir := range want {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s is expected to be removed", dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(modCache, "cache", "download", "example.com", "used", "@v", "v0.1.0.zip")); !os.IsNotExist(err) {
+		t.Errorf("the download files of the evicted module version are expected to be removed")
+	}
+
+	// The module versions of the loaded packages are kept, and the protected ones even if the quota can't be met.
+	old := modVersionDir(modCache, filepath.Join(modCache, "example.com", "old@v1.0.0", "sub", "b.go"))
+	if old != filepath.Join(modCache, "example.com", "old@v1.0.0") {
+		t.Errorf("got the module version %s of the file, want the old one", old)
+	}
+	scanned, total, _ = scanModCache(modCache)
+	if evicted, _ = evictModCache(context.Background(), scanned, total, 0, now.Add(-time.Minute), map[string]bool{old: true}); len(evicted) != 0 {
+		t.Errorf("got the evicted %v, want the live module version to be kept", evicted)
+	}
+	evicted, _ = evictModCache(context.Background(), scanned, total, 0, now.Add(-time.Minute), nil)
+	if len(evicted) != 1 || evicted[0] != filepath.Join(modCache, "example.com", "old@v1.0.0") {
+		t.Errorf("got the evicted %v, want only the old one", evicted)
+	}
+	if _, err := os.Stat(filepath.Join(modCache, "example.com", "new@v2.0.0")); err != nil {
+		t.Errorf("the recent module version is expected to be kept: %v", err)
+	}
+}
@@ -0,0 +1,406 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/module"
+	"golang.org/x/tools/internal/semver"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// The kinds of the anomalies reported for the module graph.
+const (
+	anomalyDuplicateMajor     = "duplicateMajor"
+	anomalyPseudoVersionDrift = "pseudoVersionDrift"
+	anomalyForkReplace        = "forkReplace"
+)
+
+// pseudoVersionRE matches the pseudo-versions, the pattern is copied from 'cmd/go/internal/modfetch/pseudo.go'.
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// isPseudoVersion reports whether v is a pseudo-version.
+func isPseudoVersion(v string) bool {
+	return strings.Count(v, "-") >= 2 && semver.IsValid(v) && pseudoVersionRE.MatchString(v)
+}
+
+// moduleInfo is the subset of the module information reported by 'go list -m -json'.
+type moduleInfo struct {
+	Path    string
+	Version string
+	Replace *moduleInfo
+	Main    bool
+	Dir     string
+	GoMod   string
+}
+
+// goModRequire is a requirement declared in the 'go.mod' file.
+type goModRequire struct {
+	Path    string
+	Version string
+	// The zero-based line of the requirement in the 'go.mod' file.
+	Line int
+}
+
+// loadModuleGraph resolves the module graph for the module located at dir.
+func loadModuleGraph(ctx context.Context, dir string, options source.Options) ([]moduleInfo, error) {
+	stdout, err := runGoCommand(ctx, options, dir, options.Env, "list", "-m", "-json", "all")
+	if err != nil {
+		return nil, err
+	}
+	var mods []moduleInfo
+	for dec := json.NewDecoder(stdout); ; {
+		var mod moduleInfo
+		if err := dec.Decode(&mod); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		mods = append(mods, mod)
+	}
+	return mods, nil
+}
+
+// parseGoModRequires collects the requirements declared in the content of a 'go.mod' file.
+func parseGoModRequires(data []byte) []goModRequire {
+	var requires []goModRequire
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 0; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			continue
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+			continue
+		case fields[0] == "require":
+			fields = fields[1:]
+		case !inBlock:
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		path := fields[0]
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		requires = append(requires, goModRequire{Path: path, Version: fields[1], Line: line})
+	}
+	return requires
+}
+
+// majorOf returns the major version of the module, like 'v2'. For the modules without the major version suffix, the
+// major version of the '+incompatible' versions is used.
+func majorOf(mod moduleInfo) (prefix, major string) {
+	prefix, pathMajor, ok := module.SplitPathVersion(mod.Path)
+	if !ok {
+		return mod.Path, ""
+	}
+	if pathMajor != "" {
+		return prefix, strings.TrimLeft(pathMajor, "/.")
+	}
+	if major := semver.Major(mod.Version); major != "" && major != "v0" {
+		return prefix, major
+	}
+	return prefix, "v1"
+}
+
+// detectModuleAnomalies reports the anomalies found in the resolved module graph:
+// - the same module is used with different major versions.
+// - the pseudo-version required by the 'go.mod' drifts from the version which is actually selected.
+// - the module is replaced by a different module, i.e. a fork.
+func detectModuleAnomalies(mods []moduleInfo, requires []goModRequire) []protocol.ModuleAnomaly {
+	anomalies := []protocol.ModuleAnomaly{}
+	majors := make(map[string]map[string]moduleInfo)
+	selected := make(map[string]moduleInfo)
+	for _, mod := range mods {
+		if mod.Main {
+			continue
+		}
+		selected[mod.Path] = mod
+		prefix, major := majorOf(mod)
+		if majors[prefix] == nil {
+			majors[prefix] = make(map[string]moduleInfo)
+		}
+		majors[prefix][major] = mod
+	}
+
+	var prefixes []string
+	for prefix := range majors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if len(majors[prefix]) < 2 {
+			continue
+		}
+		var dups []moduleInfo
+		for _, mod := range majors[prefix] {
+			dups = append(dups, mod)
+		}
+		sort.Slice(dups, func(i, j int) bool { return dups[i].Path < dups[j].Path })
+		var related []string
+		for _, mod := range dups {
+			related = append(related, mod.Path+"@"+mod.Version)
+		}
+		for _, mod := range dups {
+			anomalies = append(anomalies, protocol.ModuleAnomaly{
+				Kind:    anomalyDuplicateMajor,
+				Module:  mod.Path,
+				Version: mod.Version,
+				Message: fmt.Sprintf("multiple major versions of %s are used: %s", prefix, strings.Join(related, ", ")),
+				Related: related,
+			})
+		}
+	}
+
+	for _, req := range requires {
+		mod, ok := selected[req.Path]
+		if !ok || !isPseudoVersion(req.Version) || mod.Version == req.Version {
+			continue
+		}
+		anomalies = append(anomalies, protocol.ModuleAnomaly{
+			Kind:    anomalyPseudoVersionDrift,
+			Module:  req.Path,
+			Version: mod.Version,
+			Message: fmt.Sprintf("%s is required at pseudo-version %s, but %s is selected", req.Path, req.Version, mod.Version),
+		})
+	}
+
+	var paths []string
+	for path := range selected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		mod := selected[path]
+		// A replacement without a version is a local directory which is not considered as a fork.
+		if mod.Replace == nil || mod.Replace.Version == "" || mod.Replace.Path == mod.Path {
+			continue
+		}
+		anomalies = append(anomalies, protocol.ModuleAnomaly{
+			Kind:    anomalyForkReplace,
+			Module:  mod.Path,
+			Version: mod.Version,
+			Message: fmt.Sprintf("%s is replaced by the fork %s@%s", mod.Path, mod.Replace.Path, mod.Replace.Version),
+			Related: []string{mod.Replace.Path + "@" + mod.Replace.Version},
+		})
+	}
+	return anomalies
+}
+
+// ModuleAnomalies resolves the module graph of the specified folder, reports the anomalies found in the graph and
+// publishes them as the diagnostics of the corresponding 'go.mod'.
+func (s *ElasticServer) ModuleAnomalies(ctx context.Context, params *protocol.ModuleAnomaliesParams) (protocol.ModuleGraphReport, error) {
+	folder := span.NewURI(params.Folder)
+	dir := folder.Filename()
+	goModURI := span.FileURI(filepath.Join(dir, "go.mod"))
+	report := protocol.ModuleGraphReport{
+		Folder:    params.Folder,
+		GoMod:     protocol.NewURI(goModURI),
+		Anomalies: []protocol.ModuleAnomaly{},
+	}
+	data, err := ioutil.ReadFile(goModURI.Filename())
+	if err != nil {
+		return report, err
+	}
+	view := s.session.ViewOf(folder)
+	mods, err := loadModuleGraph(ctx, dir, view.Options())
+	if err != nil {
+		return report, err
+	}
+	requires := parseGoModRequires(data)
+	report.Anomalies = detectModuleAnomalies(mods, requires)
+
+	s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+		URI:         report.GoMod,
+		Diagnostics: anomalyDiagnostics(report.Anomalies, requires, data),
+	})
+	return report, nil
+}
+
+// anomalyDiagnostics converts the anomalies to the diagnostics located at the corresponding requirements in 'go.mod'.
+// The anomalies of the indirect modules which are not required by 'go.mod' are reported at the first line.
+func anomalyDiagnostics(anomalies []protocol.ModuleAnomaly, requires []goModRequire, data []byte) []protocol.Diagnostic {
+	lines := strings.Split(string(data), "\n")
+	diagnostics := []protocol.Diagnostic{}
+	for _, anomaly := range anomalies {
+		line := 0
+		for _, req := range requires {
+			if req.Path == anomaly.Module {
+				line = req.Line
+				break
+			}
+		}
+		var length int
+		if line < len(lines) {
+			length = len(strings.TrimRight(lines[line], "\r"))
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: float64(line)},
+				End:   protocol.Position{Line: float64(line), Character: float64(length)},
+			},
+			Severity: protocol.SeverityWarning,
+			Code:     anomaly.Kind,
+			Source:   "module graph",
+			Message:  anomaly.Message,
+		})
+	}
+	return diagnostics
+}
+
+// The kinds of the edges of the module graph.
+const (
+	moduleRequireEdge = "require"
+	moduleReplaceEdge = "replace"
+)
+
+// ModuleGraph merges the module graphs of the specified workspace folders, or all the workspace folders if none is
+// specified.
+func (s *ElasticServer) ModuleGraph(ctx context.Context, params *protocol.ModuleGraphParams) (protocol.ModuleGraph, error) {
+	views := s.session.Views()
+	if len(params.Folders) > 0 {
+		views = nil
+		for _, folder := range params.Folders {
+			view := s.folderView(span.NewURI(folder).Filename())
+			if view == nil {
+				return protocol.ModuleGraph{}, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%s is not a workspace folder", folder)
+			}
+			views = append(views, view)
+		}
+	}
+	return ModuleGraphOf(ctx, s.session.Views(), views), nil
+}
+
+// ModuleGraphOf merges the module graphs of the main modules at the folders of the views, the requirements are the
+// ones of the selected versions to the selected versions, the replacements relate the modules to the forks or the
+// directories. The modules are owned by the innermost workspace folders, of the views of the session, containing their
+// directories. The folders out of the module mode are skipped, and so are the ones failing to be resolved.
+func ModuleGraphOf(ctx context.Context, workspace, views []source.View) protocol.ModuleGraph {
+	graph := protocol.ModuleGraph{Modules: []protocol.ModuleNode{}, Edges: []protocol.ModuleEdge{}}
+	nodes := make(map[string]int)
+	edges := make(map[protocol.ModuleEdge]bool)
+	addNode := func(node protocol.ModuleNode) {
+		// The main module of a folder may be a directory replacement of another folder as well.
+		if i, ok := nodes[node.ID]; ok {
+			graph.Modules[i].Main = graph.Modules[i].Main || node.Main
+			return
+		}
+		nodes[node.ID] = len(graph.Modules)
+		graph.Modules = append(graph.Modules, node)
+	}
+	addEdge := func(edge protocol.ModuleEdge) {
+		if !edges[edge] {
+			edges[edge] = true
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	dirNode := func(path, dir string, main bool) protocol.ModuleNode {
+		uri := protocol.NewURI(span.FileURI(dir))
+		node := protocol.ModuleNode{ID: string(uri), Path: path, Main: main, Dir: uri}
+		var longest string
+		for _, view := range workspace {
+			if folder := view.Folder().Filename(); len(folder) > len(longest) && hostPaths.hasPrefix(dir, folder) {
+				longest = folder
+				node.Folder = protocol.NewURI(view.Folder())
+			}
+		}
+		return node
+	}
+
+	for _, view := range views {
+		dir := view.Folder().Filename()
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+			continue
+		}
+		mods, err := loadModuleGraph(ctx, dir, view.Options())
+		if err != nil {
+			log.Error(ctx, "failed to resolve the module graph", err, tag.Of("Folder", dir))
+			continue
+		}
+		stdout, err := runGoCommand(ctx, view.Options(), dir, view.Options().Env, "mod", "graph")
+		if err != nil {
+			log.Error(ctx, "failed to resolve the module graph", err, tag.Of("Folder", dir))
+			continue
+		}
+		// The IDs of the selected modules by their 'go mod graph' forms, i.e. the bare paths of the main modules and
+		// the 'path@version' of the others.
+		selected := make(map[string]string)
+		ids := make(map[string]string)
+		for _, mod := range mods {
+			if mod.Main {
+				node := dirNode(mod.Path, mod.Dir, true)
+				addNode(node)
+				selected[mod.Path], ids[mod.Path] = node.ID, node.ID
+				continue
+			}
+			id := mod.Path + "@" + mod.Version
+			addNode(protocol.ModuleNode{ID: id, Path: mod.Path, Version: mod.Version})
+			selected[mod.Path], ids[id] = id, id
+			if mod.Replace == nil {
+				continue
+			}
+			replacement := protocol.ModuleNode{ID: mod.Replace.Path + "@" + mod.Replace.Version, Path: mod.Replace.Path, Version: mod.Replace.Version}
+			if mod.Replace.Version == "" {
+				// The module in the directory keeps the path of the module replaced.
+				replacement = dirNode(mod.Path, mod.Replace.Dir, false)
+			}
+			addNode(replacement)
+			addEdge(protocol.ModuleEdge{From: id, To: replacement.ID, Kind: moduleReplaceEdge})
+		}
+		for _, line := range strings.Split(stdout.String(), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			// The requirements of the versions which aren't selected don't make the build list.
+			from, ok := ids[fields[0]]
+			if !ok {
+				continue
+			}
+			to, ok := selected[strings.SplitN(fields[1], "@", 2)[0]]
+			if !ok {
+				continue
+			}
+			addEdge(protocol.ModuleEdge{From: from, To: to, Kind: moduleRequireEdge})
+		}
+	}
+	sort.Slice(graph.Modules, func(i, j int) bool { return graph.Modules[i].ID < graph.Modules[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		if graph.Edges[i].To != graph.Edges[j].To {
+			return graph.Edges[i].To < graph.Edges[j].To
+		}
+		return graph.Edges[i].Kind < graph.Edges[j].Kind
+	})
+	return graph
+}
@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const anomalyGoMod = `module example.com/main
+
+go 1.12
+
+require (
+	github.com/foo/bar v1.2.0
+	github.com/foo/bar/v4 v4.0.1 // indirect
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
+)
+
+require github.com/baz/qux v1.0.0
+
+replace github.com/baz/qux => github.com/fork/qux v1.0.1
+`
+
+func TestParseGoModRequires(t *testing.T) {
+	got := parseGoModRequires([]byte(anomalyGoMod))
+	want := []goModRequire{
+		{Path: "github.com/foo/bar", Version: "v1.2.0", Line: 5},
+		{Path: "github.com/foo/bar/v4", Version: "v4.0.1", Line: 6},
+		{Path: "golang.org/x/net", Version: "v0.0.0-20190620200207-3b0461eec859", Line: 7},
+		{Path: "github.com/baz/qux", Version: "v1.0.0", Line: 10},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d requires, expected %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("require %d: got %v want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDetectModuleAnomalies(t *testing.T) {
+	mods := []moduleInfo{
+		{Path: "example.com/main", Main: true},
+		{Path: "github.com/foo/bar", Version: "v1.2.0"},
+		{Path: "github.com/foo/bar/v4", Version: "v4.0.1"},
+		{Path: "golang.org/x/net", Version: "v0.0.0-20191004110552-13f9640d40b9"},
+		{Path: "github.com/baz/qux", Version: "v1.0.0", Replace: &moduleInfo{Path: "github.com/fork/qux", Version: "v1.0.1"}},
+		{Path: "github.com/local/dep", Version: "v1.0.0", Replace: &moduleInfo{Path: "../dep"}},
+	}
+	anomalies := detectModuleAnomalies(mods, parseGoModRequires([]byte(anomalyGoMod)))
+	kinds := make(map[string][]string)
+	for _, a := range anomalies {
+		kinds[a.Kind] = append(kinds[a.Kind], a.Module)
+	}
+	if got := kinds[anomalyDuplicateMajor]; len(got) != 2 || got[0] != "github.com/foo/bar" || got[1] != "github.com/foo/bar/v4" {
+		t.Errorf("duplicate major: got %v", got)
+	}
+	if got := kinds[anomalyPseudoVersionDrift]; len(got) != 1 || got[0] != "golang.org/x/net" {
+		t.Errorf("pseudo-version drift: got %v", got)
+	}
+	if got := kinds[anomalyForkReplace]; len(got) != 1 || got[0] != "github.com/baz/qux" {
+		t.Errorf("fork replace: got %v", got)
+	}
+}
+
+func TestIsPseudoVersion(t *testing.T) {
+	for v, want := range map[string]bool{
+		"v0.0.0-20190620200207-3b0461eec859":              true,
+		"v1.2.4-0.20191109021931-daa7c04131f5":            true,
+		"v2.0.1-pre.0.20191109021931-daa7c04131f5":        true,
+		"v4.0.0-20190620200207-3b0461eec859+incompatible": true,
+		"v1.2.3-20190620200207":                           false,
+		"v1.2.3":                                          false,
+		"v1.2.3-beta1":                                    false,
+	} {
+		if got := isPseudoVersion(v); got != want {
+			t.Errorf("isPseudoVersion(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestModuleGraph(t *testing.T) {
+	dir := newTestDir(t, "modgraph", map[string]string{
+		"m/go.mod":   "module example.com/m\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../dep\n",
+		"m/m.go":     "package m\n\nimport _ \"example.com/dep\"\n",
+		"dep/go.mod": "module example.com/dep\n",
+		"dep/dep.go": "package dep\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.Env = append(append([]string{}, options.Env...), "GOPROXY=off")
+	s, _ := newTestServer(ctx, filepath.Join(dir, "m"), "m", options)
+	s.session.NewView(ctx, "dep", span.FileURI(filepath.Join(dir, "dep")), options)
+	m := string(protocol.NewURI(span.FileURI(filepath.Join(dir, "m"))))
+	dep := string(protocol.NewURI(span.FileURI(filepath.Join(dir, "dep"))))
+
+	graph, err := s.ModuleGraph(ctx, &protocol.ModuleGraphParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The directory replacement is the main module of the other folder.
+	wantModules := []protocol.ModuleNode{
+		{ID: "example.com/dep@v0.0.0", Path: "example.com/dep", Version: "v0.0.0"},
+		{ID: dep, Path: "example.com/dep", Main: true, Dir: dep, Folder: dep},
+		{ID: m, Path: "example.com/m", Main: true, Dir: m, Folder: m},
+	}
+	if !reflect.DeepEqual(graph.Modules, wantModules) {
+		t.Errorf("got the modules %+v, want %+v", graph.Modules, wantModules)
+	}
+	wantEdges := []protocol.ModuleEdge{
+		{From: "example.com/dep@v0.0.0", To: dep, Kind: moduleReplaceEdge},
+		{From: m, To: "example.com/dep@v0.0.0", Kind: moduleRequireEdge},
+	}
+	if !reflect.DeepEqual(graph.Edges, wantEdges) {
+		t.Errorf("got the edges %+v, want %+v", graph.Edges, wantEdges)
+	}
+
+	if _, err := s.ModuleGraph(ctx, &protocol.ModuleGraphParams{Folders: []protocol.DocumentURI{protocol.NewURI(span.FileURI(dir))}}); err == nil {
+		t.Errorf("the folder which isn't a workspace folder is expected to be rejected")
+	}
+}
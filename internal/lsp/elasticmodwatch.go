@@ -0,0 +1,67 @@
+package lsp
+
+import (
+	"context"
+	"path/filepath"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/telemetry"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/xcontext"
+)
+
+// modFileWatchers are the watchers of the module files registered besides the ones of the Go files. All the kinds of
+// the changes are watched, i.e. the sum of the kind bits, since the creation and the deletion of go.mod switch the
+// folder between the module mode and the GOPATH mode.
+var modFileWatchers = []protocol.FileSystemWatcher{
+	{GlobPattern: "**/go.mod", Kind: float64(protocol.WatchCreate + protocol.WatchChange + protocol.WatchDelete)},
+	{GlobPattern: "**/go.sum", Kind: float64(protocol.WatchCreate + protocol.WatchChange + protocol.WatchDelete)},
+}
+
+// DidChangeWatchedFiles handles the changes of the module files, i.e. go.mod and go.sum, besides the Go files handled
+// by the server. The metadata of the packages in the views affected is reloaded by the next requests, rather than
+// being kept until the restart, and the dependencies are downloaded again if the option 'downloadOnModChange' is set.
+func (s *ElasticServer) DidChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	var changes []protocol.FileEvent
+	for _, change := range params.Changes {
+		uri := span.NewURI(change.URI)
+		if kind := source.DetectLanguage("", uri.Filename()); kind != source.Mod && kind != source.Sum {
+			changes = append(changes, change)
+			continue
+		}
+		s.modFileChanged(ctx, uri, change.Type)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return s.Server.DidChangeWatchedFiles(ctx, &protocol.DidChangeWatchedFilesParams{Changes: changes})
+}
+
+// modFileChanged invalidates the views affected by the module file changed on the disk, i.e. the ones containing it
+// and the ones in its module, and downloads the dependencies of them once again.
+func (s *ElasticServer) modFileChanged(ctx context.Context, uri span.URI, changeType protocol.FileChangeType) {
+	// The content of the files open by the client remains the source of truth.
+	if s.session.IsOpen(uri) {
+		return
+	}
+	log.Print(ctx, "module file changed", telemetry.File.Of(uri))
+	s.session.DidChangeOutOfBand(ctx, uri, changeType)
+	if changeType == protocol.Deleted {
+		return
+	}
+	dir := filepath.Dir(uri.Filename())
+	var folders []protocol.WorkspaceFolder
+	for _, view := range s.session.Views() {
+		folder := view.Folder().Filename()
+		if !view.Options().DownloadOnModChange || !hostPaths.hasPrefix(uri.Filename(), folder) && !hostPaths.hasPrefix(folder, dir) {
+			continue
+		}
+		folders = append(folders, protocol.WorkspaceFolder{URI: string(view.Folder()), Name: view.Name()})
+	}
+	// The downloads outlive the notification, they're aborted once the folders are removed or the server shuts down.
+	if len(folders) > 0 {
+		go s.ManageDeps(xcontext.Detach(ctx), folders, protocol.ElasticOptions{InstallGoDependency: true})
+	}
+}
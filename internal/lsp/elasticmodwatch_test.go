@@ -0,0 +1,70 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestModFileChanged(t *testing.T) {
+	dir := newTestDir(t, "modwatch", nil)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		t.Helper()
+		writeTestFiles(t, dir, map[string]string{name: content})
+	}
+	write("v1/go.mod", "module example.com/dep\n\ngo 1.11\n")
+	write("v1/dep.go", "package dep\n\nfunc F() {}\n")
+	write("v2/go.mod", "module example.com/dep\n\ngo 1.11\n")
+	write("v2/dep.go", "package dep\n\nfunc G() {}\n")
+	goMod := func(version string) string {
+		return "module example.com/m\n\ngo 1.11\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../" + version + "\n"
+	}
+	write("m/go.mod", goMod("v1"))
+	write("m/m.go", "package m\n\nimport \"example.com/dep\"\n\nvar _ = dep.G\n")
+
+	ctx := context.Background()
+	options := source.DefaultOptions
+	folder := filepath.Join(dir, "m")
+	s, view := newTestServer(ctx, folder, "m", options)
+	errors := func() []string {
+		t.Helper()
+		f, err := view.GetFile(ctx, span.FileURI(filepath.Join(folder, "m.go")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, cphs, err := view.CheckPackageHandles(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, err := source.WidestCheckPackageHandle(cphs).Check(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msgs []string
+		for _, err := range pkg.GetErrors() {
+			msgs = append(msgs, err.Msg)
+		}
+		return msgs
+	}
+	if errs := errors(); len(errs) == 0 {
+		t.Fatal("got no errors of the undefined dep.G in v1")
+	}
+
+	// The upgraded dependency is loaded once the change of go.mod is notified.
+	write("m/go.mod", goMod("v2"))
+	if err := s.DidChangeWatchedFiles(ctx, &protocol.DidChangeWatchedFilesParams{Changes: []protocol.FileEvent{{
+		URI:  protocol.NewURI(span.FileURI(filepath.Join(folder, "go.mod"))),
+		Type: protocol.Changed,
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	if errs := errors(); len(errs) != 0 {
+		t.Errorf("got the errors %v of the stale dependency", errs)
+	}
+}
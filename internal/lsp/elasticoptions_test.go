@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// messageClient records the messages shown.
+type messageClient struct {
+	protocol.Client
+	mu       sync.Mutex
+	messages []string
+}
+
+func (c *messageClient) ShowMessage(ctx context.Context, params *protocol.ShowMessageParams) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, params.Message)
+	return nil
+}
+
+func TestElasticOptions(t *testing.T) {
+	var opts interface{}
+	if err := json.Unmarshal([]byte(`{
+		"installGoDependency": true,
+		"goProxies": ["https://proxy.example.com", "direct"],
+		"credentials": {"goPrivate": "git.example.com/*"},
+		"typeCheckQueueTimeout": 1.5,
+		"hoverKind": "SingleLine"
+	}`), &opts); err != nil {
+		t.Fatal(err)
+	}
+	elastic, err := protocol.DecodeElasticOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !elastic.InstallGoDependency || len(elastic.GoProxies) != 2 || elastic.Credentials == nil || elastic.Credentials.GoPrivate != "git.example.com/*" || elastic.TypeCheckQueueTimeout != 1.5 {
+		t.Errorf("got the elastic options %+v", elastic)
+	}
+	if _, err := protocol.DecodeElasticOptions(map[string]interface{}{"offline": "yes"}); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Errorf("got the error %v, want the invalid option 'offline'", err)
+	}
+
+	// The typed options are set like the ones decoded from JSON.
+	options := source.DefaultOptions
+	for _, result := range source.SetOptions(&options, elastic) {
+		if result.Error != nil || result.State != source.OptionHandled {
+			t.Errorf("got the result %+v of the option %s", result, result.Name)
+		}
+	}
+	if !options.InstallGoDependency || options.TypeCheckQueueTimeout != 1500*time.Millisecond || options.Credentials.GoPrivate != "git.example.com/*" {
+		t.Errorf("got the options %+v set by the elastic options", options)
+	}
+}
+
+func TestInitializeReportsOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	client := &messageClient{}
+	s := newTestSessionServer(ctx, source.DefaultOptions)
+	s.client = client
+	params := &protocol.ParamInitia{}
+	params.RootURI = string(span.FileURI(dir))
+	params.InitializationOptions = map[string]interface{}{"instalGoDependency": true, "offline": "yes", "vendorMode": true}
+	if _, err := s.initialize(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(client.messages, "\n")
+	if len(client.messages) != 2 || !strings.Contains(got, "instalGoDependency") || !strings.Contains(got, "offline") {
+		t.Errorf("got the messages %q, want the unknown option 'instalGoDependency' and the invalid option 'offline'", client.messages)
+	}
+	if !s.session.Options().VendorMode {
+		t.Error("got the valid option 'vendorMode' unset")
+	}
+}
@@ -0,0 +1,45 @@
+package lsp
+
+import (
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// sortSymbols sorts the symbols of a document by their positions, then by their qualified names, and by their kinds
+// at last. The order is stable for the identical documents, regardless of how the document symbols and the labels are
+// collected, so that the indexes of the same version of a document are identical across the runs.
+func sortSymbols(syms []protocol.DetailSymbolInformation) {
+	sort.SliceStable(syms, func(i, j int) bool {
+		a, b := syms[i], syms[j]
+		if c := protocol.CompareRange(a.Symbol.Location.Range, b.Symbol.Location.Range); c != 0 {
+			return c < 0
+		}
+		if a.Qname != b.Qname {
+			return a.Qname < b.Qname
+		}
+		return a.Symbol.Kind < b.Symbol.Kind
+	})
+}
+
+// sortReferences sorts the references of a document by their positions, then by the qualified names of their
+// targets. The references at the same position with the same target, like a field selected by a composite literal
+// key, are told apart by their kinds and categories, then by the declarations enclosing them.
+func sortReferences(refs []protocol.Reference) {
+	sort.SliceStable(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if c := protocol.CompareRange(a.Loc.Range, b.Loc.Range); c != 0 {
+			return c < 0
+		}
+		if a.Target.Qname != b.Target.Qname {
+			return a.Target.Qname < b.Target.Qname
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Symbol.Name < b.Symbol.Name
+	})
+}
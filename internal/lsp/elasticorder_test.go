@@ -0,0 +1,125 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestFullOrder(t *testing.T) {
+	dir := newTestDir(t, "order", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go": `package m
+
+type T struct {
+	A, B int
+	I
+}
+
+type I interface{ M() }
+
+func init() {}
+
+func (t T) M() {
+outer:
+	for {
+		t = T{A: 1, B: t.A}
+		break outer
+	}
+}
+
+func init() {}
+
+const (
+	X = iota
+	Y
+)
+`,
+	})
+	defer os.RemoveAll(dir)
+	full := func() []byte {
+		t.Helper()
+		ctx := context.Background()
+		s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+		resp, err := s.Full(ctx, &protocol.FullParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))},
+			Reference:    true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < len(resp.Symbols); i++ {
+			if a, b := resp.Symbols[i-1], resp.Symbols[i]; protocol.CompareRange(a.Symbol.Location.Range, b.Symbol.Location.Range) > 0 {
+				t.Errorf("got the symbol %s before %s", a.Qname, b.Qname)
+			}
+		}
+		for i := 1; i < len(resp.References); i++ {
+			if a, b := resp.References[i-1], resp.References[i]; protocol.CompareRange(a.Loc.Range, b.Loc.Range) > 0 {
+				t.Errorf("got the reference to %s at %v before the one to %s at %v", a.Target.Qname, a.Loc.Range, b.Target.Qname, b.Loc.Range)
+			}
+		}
+		// The snapshots are the only part told apart by the sessions.
+		resp.Snapshot = 0
+		data, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	first := full()
+	for i := 0; i < 3; i++ {
+		if got := full(); !bytes.Equal(got, first) {
+			t.Fatalf("got the response\n%s\nwant\n%s", got, first)
+		}
+	}
+}
+
+func TestSortReferences(t *testing.T) {
+	at := func(line, char float64) protocol.Location {
+		return protocol.Location{Range: protocol.Range{
+			Start: protocol.Position{Line: line, Character: char},
+			End:   protocol.Position{Line: line, Character: char + 1},
+		}}
+	}
+	refs := []protocol.Reference{
+		{Loc: at(2, 0), Target: protocol.SymbolLocator{Qname: "m.T"}},
+		{Loc: at(1, 4), Target: protocol.SymbolLocator{Qname: "m.T.A"}, Kind: protocol.WriteReference},
+		{Loc: at(1, 4), Target: protocol.SymbolLocator{Qname: "m.T.A"}, Kind: protocol.ReadReference},
+		{Loc: at(1, 4), Target: protocol.SymbolLocator{Qname: "m.A"}},
+		{Loc: at(1, 0), Target: protocol.SymbolLocator{Qname: "m.T"}},
+	}
+	reversed := make([]protocol.Reference, len(refs))
+	for i, ref := range refs {
+		reversed[len(refs)-1-i] = ref
+	}
+	sortReferences(refs)
+	sortReferences(reversed)
+	want := []string{"m.T@1:0", "m.A@1:4", "m.T.A@1:4 read", "m.T.A@1:4 write", "m.T@2:0"}
+	for _, sorted := range [][]protocol.Reference{refs, reversed} {
+		var got []string
+		for _, ref := range sorted {
+			s := fmt.Sprintf("%s@%v:%v", ref.Target.Qname, ref.Loc.Range.Start.Line, ref.Loc.Range.Start.Character)
+			if ref.Kind != "" {
+				s += " " + string(ref.Kind)
+			}
+			got = append(got, s)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got %v, want %v", got, want)
+				break
+			}
+		}
+	}
+}
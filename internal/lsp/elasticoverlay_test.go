@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestOverlayFullAndEDefinition(t *testing.T) {
+	dir := newTestDir(t, "overlay", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "package a\n\nfunc A() {}\n",
+		"b.go":   "package a\n\nfunc B() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "a", source.DefaultOptions)
+	uriOf := func(name string) protocol.DocumentURI {
+		return protocol.NewURI(span.FileURI(filepath.Join(dir, name)))
+	}
+	qnames := func(name string) []string {
+		t.Helper()
+		resp, err := s.Full(ctx, &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: uriOf(name)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var qnames []string
+		for _, sym := range resp.Symbols {
+			qnames = append(qnames, sym.Qname)
+		}
+		return qnames
+	}
+	definition := func(name string, line, character float64) protocol.Location {
+		t.Helper()
+		locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uriOf(name)},
+				Position:     protocol.Position{Line: line, Character: character},
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locs) != 1 || locs[0].Loc == nil {
+			t.Fatalf("got definitions %+v, want one location", locs)
+		}
+		return *locs[0].Loc
+	}
+	qnames("a.go")
+
+	// The unsaved edits of the files on disk.
+	s.session.DidOpen(ctx, span.NewURI(uriOf("a.go")), source.Go, []byte("package a\n\nfunc A() {}\n\nfunc C() { B() }\n"))
+	s.session.DidOpen(ctx, span.NewURI(uriOf("b.go")), source.Go, []byte("package a\n\n\n\nfunc B() {}\n"))
+	if got := qnames("a.go"); len(got) != 2 || got[1] != "a.C" {
+		t.Errorf("got qnames %v of the edited a.go, want a.A and a.C", got)
+	}
+	if got := definition("a.go", 4, 11); got.URI != uriOf("b.go") || got.Range.Start.Line != 4 {
+		t.Errorf("got definition %+v of B, want the line 4 of the edited b.go", got)
+	}
+
+	// The new files which only exist in the overlay.
+	s.session.DidOpen(ctx, span.NewURI(uriOf("c.go")), source.Go, []byte("package a\n\nfunc D() { C() }\n"))
+	if got := qnames("c.go"); len(got) != 1 || got[0] != "a.D" {
+		t.Errorf("got qnames %v of the new c.go, want a.D", got)
+	}
+	if got := definition("c.go", 2, 11); got.URI != uriOf("a.go") || got.Range.Start.Line != 4 {
+		t.Errorf("got definition %+v of C, want the line 4 of the edited a.go", got)
+	}
+}
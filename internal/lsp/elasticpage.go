@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// fullCursor returns the cursor of the page of 'Full' starting at the offset, the version of the document is echoed so
+// that the pages are never mixed up across the versions.
+func fullCursor(offset int, version string) string {
+	return strconv.Itoa(offset) + "@" + version
+}
+
+// parseFullCursor returns the offset of the page which the cursor points to, it fails if the cursor is issued for
+// another version of the document. The empty cursor points to the first page.
+func parseFullCursor(cursor string, version string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	i := strings.Index(cursor, "@")
+	if i < 0 {
+		return 0, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(cursor[:i])
+	if err != nil || offset < 0 {
+		return 0, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "invalid cursor %q", cursor)
+	}
+	if cursor[i+1:] != version {
+		return 0, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "cursor %q is issued for another version of the document", cursor)
+	}
+	return offset, nil
+}
+
+// pageFull returns the page of the response holding at most limit items from the offset, the symbols are followed by
+// the references. The cursor of the next page is set unless it's the last page. The other parts of the response, like
+// the qualified name collisions, are only returned by the first page. A non-positive limit takes all the rest.
+func pageFull(resp protocol.FullResponse, offset, limit int, version string) protocol.FullResponse {
+	if offset == 0 && limit <= 0 {
+		return resp
+	}
+	page := protocol.FullResponse{
+		Snapshot:  resp.Snapshot,
+		Partial:   resp.Partial,
+		Truncated: resp.Truncated,
+	}
+	if offset == 0 {
+		page.VersionDiffs = resp.VersionDiffs
+		page.QnameCollisions = resp.QnameCollisions
+		page.Errors = resp.Errors
+	}
+	total := len(resp.Symbols) + len(resp.References)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+		page.NextCursor = fullCursor(end, version)
+	}
+	split := len(resp.Symbols)
+	page.Symbols = resp.Symbols[clamp(offset, 0, split):clamp(end, 0, split)]
+	page.References = resp.References[clamp(offset-split, 0, total-split):clamp(end-split, 0, total-split)]
+	return page
+}
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
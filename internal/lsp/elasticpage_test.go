@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestPageFull(t *testing.T) {
+	resp := protocol.FullResponse{
+		Snapshot:        7,
+		QnameCollisions: []protocol.QnameCollision{{Qname: "p.init"}},
+	}
+	for _, qname := range []string{"p.A", "p.B", "p.C"} {
+		resp.Symbols = append(resp.Symbols, protocol.DetailSymbolInformation{Qname: qname})
+	}
+	for _, qname := range []string{"p.X", "p.Y"} {
+		resp.References = append(resp.References, protocol.Reference{Target: protocol.SymbolLocator{Qname: qname}})
+	}
+
+	// The pages are fetched by the cursors until the last page.
+	var pages [][]string
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		offset, err := parseFullCursor(cursor, "v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := pageFull(resp, offset, 2, "v1")
+		if page.Snapshot != 7 || (offset == 0) != (len(page.QnameCollisions) == 1) {
+			t.Errorf("got snapshot %d and collisions %v of the page at %d", page.Snapshot, page.QnameCollisions, offset)
+		}
+		var items []string
+		for _, sym := range page.Symbols {
+			items = append(items, sym.Qname)
+		}
+		for _, ref := range page.References {
+			items = append(items, ref.Target.Qname)
+		}
+		pages = append(pages, items)
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	want := [][]string{{"p.A", "p.B"}, {"p.C", "p.X"}, {"p.Y"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got pages %v, want %v", pages, want)
+	}
+
+	if got := pageFull(resp, 0, 0, "v1"); !reflect.DeepEqual(got, resp) {
+		t.Errorf("got %+v without the limit, want the whole response", got)
+	}
+	if _, err := parseFullCursor(fullCursor(2, "v1"), "v2"); err == nil {
+		t.Errorf("the cursor of another version is expected to be rejected")
+	}
+	if _, err := parseFullCursor("garbage", "v1"); err == nil {
+		t.Errorf("the invalid cursor is expected to be rejected")
+	}
+}
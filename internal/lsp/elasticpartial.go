@@ -0,0 +1,205 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// moduleDirectiveRx matches the module directive of 'go.mod'.
+var moduleDirectiveRx = regexp.MustCompile(`(?m)^module[ \t]+"?([^"\s]+)"?`)
+
+// partialFull collects the symbols of the file for 'Full' from the syntax alone, when the package of the file can't
+// be type checked, like the ones importing a package which fails to load. The qualified names are the best effort,
+// the package is guessed from the enclosing module and the ordinals only count the declarations of the file. The
+// response is marked as partial along with the errors, and the references are never collected without the types.
+func (s *ElasticServer) partialFull(ctx context.Context, view source.View, snapshot source.Snapshot, f source.File, fullParams *protocol.FullParams, checkErr error) (protocol.FullResponse, error) {
+	fullResponse := protocol.FullResponse{
+		Symbols:    []protocol.DetailSymbolInformation{},
+		References: []protocol.Reference{},
+		Snapshot:   snapshot.ID(),
+		Partial:    true,
+		Errors:     []string{checkErr.Error()},
+	}
+	fset := view.Session().Cache().FileSet()
+	ph := view.Session().Cache().ParseGoHandle(snapshot.Handle(ctx, f), source.ParseFull)
+	file, m, parseErr, err := ph.Parse(ctx)
+	if err != nil {
+		return fullResponse, err
+	}
+	if parseErr != nil {
+		fullResponse.Errors = append(fullResponse.Errors, parseErr.Error())
+	}
+	if file == nil || file.Name == nil {
+		return fullResponse, nil
+	}
+	loc := f.URI().Filename()
+	pkg := types.NewPackage(guessImportPath(view.Folder().Filename(), loc, file.Name.Name), file.Name.Name)
+	pkgLocator := collectPkgMetadata(pkg, view.Folder().Filename(), loc, view.Options())
+	style := view.Options().QnameStyle
+	syms := syntacticSymbols(fset, file, m, fullParams.TextDocument.URI)
+	for i := range syms {
+		syms[i].Qname = qualifyQname(truncateQname(syms[i].Qname, view.Options().MaxQnameDepth), pkg, pkgLocator.Version, style)
+		syms[i].Package = pkgLocator
+	}
+	sortSymbols(syms)
+	fullResponse.Symbols, fullResponse.Truncated = filterSymbols(syms, fullParams.SymbolFilter)
+	return fullResponse, nil
+}
+
+// guessImportPath guesses the import path of the package in the directory of the file, by the module enclosing the
+// directory within the folder. The package name is returned if there is no such module.
+func guessImportPath(folder, filename, name string) string {
+	dir := filepath.Dir(filename)
+	for d := dir; hostPaths.hasPrefix(d, folder); d = filepath.Dir(d) {
+		if data, err := ioutil.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			if match := moduleDirectiveRx.FindSubmatch(data); match != nil {
+				rel, err := filepath.Rel(d, dir)
+				if err != nil {
+					break
+				}
+				return path.Join(string(match[1]), filepath.ToSlash(rel))
+			}
+		}
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+	return name
+}
+
+// syntacticSymbols returns the symbols declared in the file with the short forms of the qualified names, i.e. the
+// package level declarations, the methods, the fields of the structs and the methods of the interfaces. The kinds of
+// the types are told by the type expressions.
+func syntacticSymbols(fset *token.FileSet, file *ast.File, m *protocol.ColumnMapper, uri protocol.DocumentURI) []protocol.DetailSymbolInformation {
+	var syms []protocol.DetailSymbolInformation
+	ordinals := packageDeclOrdinals(fset, []*ast.File{file})
+	add := func(id *ast.Ident, name string, kind protocol.SymbolKind, container string) {
+		rng, err := toProtocolRange(fset, m, id.Pos(), id.End())
+		if err != nil {
+			return
+		}
+		qname := file.Name.Name + "." + name
+		if container != "" {
+			qname = file.Name.Name + "." + container + "." + name
+		}
+		syms = append(syms, protocol.DetailSymbolInformation{
+			Symbol: protocol.SymbolInformation{
+				Name:          id.Name,
+				Kind:          kind,
+				ContainerName: container,
+				Location:      protocol.Location{URI: uri, Range: rng},
+			},
+			Qname: qname,
+		})
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) == 0 {
+				add(decl.Name, ordinals.name(decl.Name), protocol.Function, "")
+			} else if recv := receiverTypeName(decl.Recv.List[0].Type); recv != "" {
+				add(decl.Name, decl.Name.Name, protocol.Method, recv)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					add(spec.Name, ordinals.name(spec.Name), syntacticTypeKind(spec.Type), "")
+					switch typ := spec.Type.(type) {
+					case *ast.StructType:
+						for _, field := range typ.Fields.List {
+							for _, name := range field.Names {
+								add(name, name.Name, protocol.Field, spec.Name.Name)
+							}
+							if len(field.Names) == 0 {
+								if id := embeddedTypeName(field.Type); id != nil {
+									add(id, id.Name, protocol.Field, spec.Name.Name)
+								}
+							}
+						}
+					case *ast.InterfaceType:
+						for _, method := range typ.Methods.List {
+							for _, name := range method.Names {
+								add(name, name.Name, protocol.Method, spec.Name.Name)
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					kind := protocol.Variable
+					if decl.Tok == token.CONST {
+						kind = protocol.Constant
+					}
+					for _, name := range spec.Names {
+						add(name, ordinals.name(name), kind, "")
+					}
+				}
+			}
+		}
+	}
+	return syms
+}
+
+// receiverTypeName returns the name of the receiver type, like 'T' for '*T'.
+func receiverTypeName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// embeddedTypeName returns the identifier naming the embedded field, like 'T' for '*pkg.T'.
+func embeddedTypeName(expr ast.Expr) *ast.Ident {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr
+	case *ast.SelectorExpr:
+		return expr.Sel
+	}
+	return nil
+}
+
+// syntacticTypeKind returns the symbol kind of the type declared by the type expression, the named types other than
+// the predeclared ones can't be told without the types.
+func syntacticTypeKind(expr ast.Expr) protocol.SymbolKind {
+	switch expr := expr.(type) {
+	case *ast.StructType:
+		return protocol.Struct
+	case *ast.InterfaceType:
+		return protocol.Interface
+	case *ast.ArrayType:
+		return protocol.Array
+	case *ast.FuncType:
+		return protocol.Function
+	case *ast.ParenExpr:
+		return syntacticTypeKind(expr.X)
+	case *ast.Ident:
+		if obj, ok := types.Universe.Lookup(expr.Name).(*types.TypeName); ok {
+			b, _ := obj.Type().(*types.Basic)
+			switch {
+			case b == nil:
+			case b.Info()&types.IsNumeric != 0:
+				return protocol.Number
+			case b.Info()&types.IsBoolean != 0:
+				return protocol.Boolean
+			case b.Info()&types.IsString != 0:
+				return protocol.String
+			}
+		}
+	}
+	return protocol.Variable
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestPartialFull(t *testing.T) {
+	dir := newTestDir(t, "partial", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"sub/a.go": `package sub
+
+import "example.com/missing"
+
+const C = missing.X
+
+type T struct {
+	F int
+	*missing.E
+}
+
+func (t *T) M() {}
+
+type I interface{ N() }
+
+type S string
+
+func init() {}
+
+func init() {}
+`,
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.QnameStyle = source.ImportPathQname
+	s, _ := newTestServer(ctx, dir, "a", options)
+
+	resp, err := s.Full(ctx, &protocol.FullParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "sub", "a.go")))},
+		Reference:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Partial || len(resp.Errors) == 0 {
+		t.Errorf("got partial %v with errors %v, want a partial response with the errors", resp.Partial, resp.Errors)
+	}
+	type symbol struct {
+		qname string
+		kind  protocol.SymbolKind
+	}
+	var got []symbol
+	for _, sym := range resp.Symbols {
+		if sym.Package.Name != "sub" || sym.Package.RepoURI != "example.com/a/sub" {
+			t.Errorf("got package %+v of %s, want sub in example.com/a/sub", sym.Package, sym.Qname)
+		}
+		got = append(got, symbol{sym.Qname, sym.Symbol.Kind})
+	}
+	want := []symbol{
+		{"example.com/a/sub.C", protocol.Constant},
+		{"example.com/a/sub.T", protocol.Struct},
+		{"example.com/a/sub.T.F", protocol.Field},
+		{"example.com/a/sub.T.E", protocol.Field},
+		{"example.com/a/sub.T.M", protocol.Method},
+		{"example.com/a/sub.I", protocol.Interface},
+		{"example.com/a/sub.I.N", protocol.Method},
+		{"example.com/a/sub.S", protocol.String},
+		{"example.com/a/sub.init~1", protocol.Function},
+		{"example.com/a/sub.init~2", protocol.Function},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got symbols %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package lsp
+
+import (
+	"path"
+	"runtime"
+	"strings"
+)
+
+// pathFlavor abstracts the conventions of the file paths of a platform, so that the path manipulations of the
+// dependency management and the qualified names work on windows as well, and they can be tested for any platform on
+// any host. The windows paths lead with the volume names, like 'C:' or '\\host\share', they're separated by both '\'
+// and '/', and they're compared case-insensitively.
+type pathFlavor struct {
+	separator byte
+	windows   bool
+}
+
+var (
+	unixPaths    = pathFlavor{separator: '/'}
+	windowsPaths = pathFlavor{separator: '\\', windows: true}
+	// hostPaths is the flavor of the platform which the server runs on.
+	hostPaths = flavorOf(runtime.GOOS)
+)
+
+func flavorOf(goos string) pathFlavor {
+	if goos == "windows" {
+		return windowsPaths
+	}
+	return unixPaths
+}
+
+func (f pathFlavor) isSeparator(c byte) bool {
+	return c == f.separator || (f.windows && c == '/')
+}
+
+// volumeName returns the leading volume name of the windows path, like 'C:' for 'C:\foo' and '\\host\share' for
+// '\\host\share\foo'. It's always empty for the other platforms.
+func (f pathFlavor) volumeName(p string) string {
+	if !f.windows {
+		return ""
+	}
+	if len(p) >= 2 && p[1] == ':' && ('a' <= p[0] && p[0] <= 'z' || 'A' <= p[0] && p[0] <= 'Z') {
+		return p[:2]
+	}
+	// The UNC paths, like '\\host\share\foo'.
+	if len(p) > 2 && f.isSeparator(p[0]) && f.isSeparator(p[1]) && !f.isSeparator(p[2]) {
+		n := 0
+		for i := 2; i < len(p); i++ {
+			if f.isSeparator(p[i]) {
+				if n++; n == 2 {
+					return p[:i]
+				}
+			}
+		}
+		if n == 1 {
+			return p
+		}
+	}
+	return ""
+}
+
+// split splits the path into the volume name and the elements, the empty elements and '.' are dropped, and '..' are
+// resolved lexically. The path is either absolute or relative to the volume, the leading separator is dropped.
+func (f pathFlavor) split(p string) (string, []string) {
+	volume := f.volumeName(p)
+	rest := p[len(volume):]
+	if f.windows {
+		rest = strings.Replace(rest, "\\", "/", -1)
+	}
+	var elems []string
+	for _, elem := range strings.Split(path.Clean("/"+rest), "/") {
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return volume, elems
+}
+
+// join joins the elements into an absolute path on the volume.
+func (f pathFlavor) join(volume string, elems []string) string {
+	sep := string(f.separator)
+	return strings.Replace(volume, "/", sep, -1) + sep + strings.Join(elems, sep)
+}
+
+// clean returns the absolute path in the canonical form, i.e. the separators are normalized.
+func (f pathFlavor) clean(p string) string {
+	volume, elems := f.split(p)
+	return f.join(volume, elems)
+}
+
+func (f pathFlavor) sameElem(a, b string) bool {
+	if f.windows {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// hasPrefix reports whether the path is dir or it's located under dir. Unlike 'strings.HasPrefix', the paths are
+// compared by the elements, so that '/foobar' isn't under '/foo'.
+func (f pathFlavor) hasPrefix(p, dir string) bool {
+	pv, pe := f.split(p)
+	dv, de := f.split(dir)
+	if !f.sameElem(pv, dv) || len(pe) < len(de) {
+		return false
+	}
+	for i := range de {
+		if !f.sameElem(pe[i], de[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal reports whether the paths are the same.
+func (f pathFlavor) equal(a, b string) bool {
+	return f.hasPrefix(a, b) && f.hasPrefix(b, a)
+}
+
+// commonDir returns the longest common directory of the paths, it's the root of the volume if there is no common
+// directory other than that. The paths on the different volumes have no common directory, the empty string is
+// returned then.
+func (f pathFlavor) commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	volume, common := f.split(paths[0])
+	for _, p := range paths[1:] {
+		v, elems := f.split(p)
+		if !f.sameElem(v, volume) {
+			return ""
+		}
+		n := 0
+		for n < len(common) && n < len(elems) && f.sameElem(common[n], elems[n]) {
+			n++
+		}
+		common = common[:n]
+	}
+	return f.join(volume, common)
+}
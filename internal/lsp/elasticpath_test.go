@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"testing"
+)
+
+func TestPathFlavorSplit(t *testing.T) {
+	for _, test := range []struct {
+		flavor pathFlavor
+		path   string
+		volume string
+		clean  string
+	}{
+		{unixPaths, "/a/b/../c/", "", "/a/c"},
+		{unixPaths, "/", "", "/"},
+		{unixPaths, `/a\b`, "", `/a\b`},
+		{windowsPaths, `C:\a\b`, "C:", `C:\a\b`},
+		{windowsPaths, `c:/a\b/`, "c:", `c:\a\b`},
+		{windowsPaths, `C:\a\..\..\b`, "C:", `C:\b`},
+		{windowsPaths, `C:\`, "C:", `C:\`},
+		{windowsPaths, `\\host\share\a`, `\\host\share`, `\\host\share\a`},
+		{windowsPaths, `//host/share/a/b`, "//host/share", `\\host\share\a\b`},
+		{windowsPaths, `\\host\share`, `\\host\share`, `\\host\share\`},
+	} {
+		if volume := test.flavor.volumeName(test.path); volume != test.volume {
+			t.Errorf("got the volume %q of %q, want %q", volume, test.path, test.volume)
+		}
+		if clean := test.flavor.clean(test.path); clean != test.clean {
+			t.Errorf("got the clean path %q of %q, want %q", clean, test.path, test.clean)
+		}
+	}
+}
+
+func TestPathFlavorHasPrefix(t *testing.T) {
+	for _, test := range []struct {
+		flavor    pathFlavor
+		path, dir string
+		want      bool
+	}{
+		{unixPaths, "/foo/bar", "/foo", true},
+		{unixPaths, "/foo", "/foo/", true},
+		{unixPaths, "/foobar", "/foo", false},
+		{unixPaths, "/Foo/bar", "/foo", false},
+		{unixPaths, "/foo", "/foo/bar", false},
+		{windowsPaths, `C:\foo\bar`, `C:\foo`, true},
+		{windowsPaths, `c:/Foo/bar`, `C:\foo`, true},
+		{windowsPaths, `C:\foobar`, `C:\foo`, false},
+		{windowsPaths, `D:\foo\bar`, `C:\foo`, false},
+		{windowsPaths, `\\host\share\foo`, `\\HOST\share`, true},
+		{windowsPaths, `\\host\other\foo`, `\\host\share`, false},
+	} {
+		if got := test.flavor.hasPrefix(test.path, test.dir); got != test.want {
+			t.Errorf("got %v for %q under %q, want %v", got, test.path, test.dir, test.want)
+		}
+	}
+	if !windowsPaths.equal(`C:\Users\dev\repo`, `c:/users/dev/repo/`) {
+		t.Errorf("the windows paths are expected to be compared case-insensitively")
+	}
+	if unixPaths.equal("/home/dev/repo", "/home/dev/Repo") {
+		t.Errorf("the unix paths are expected to be compared case-sensitively")
+	}
+}
+
+func TestPathFlavorCommonDir(t *testing.T) {
+	for _, test := range []struct {
+		flavor pathFlavor
+		paths  []string
+		want   string
+	}{
+		{unixPaths, []string{"/a/b/c", "/a/b/d", "/a/b"}, "/a/b"},
+		{unixPaths, []string{"/a/bc", "/a/bd"}, "/a"},
+		{unixPaths, []string{"/a", "/b"}, "/"},
+		{unixPaths, []string{"/a/b/"}, "/a/b"},
+		{unixPaths, nil, ""},
+		{windowsPaths, []string{`C:\src\a\x`, `c:/src/A/y`}, `C:\src\a`},
+		{windowsPaths, []string{`C:\a`, `C:\b`}, `C:\`},
+		{windowsPaths, []string{`C:\src\a`, `D:\src\a`}, ""},
+		{windowsPaths, []string{`\\host\share\a\b`, `\\host\share\a\c`}, `\\host\share\a`},
+	} {
+		if got := test.flavor.commonDir(test.paths); got != test.want {
+			t.Errorf("got the common directory %q of %q, want %q", got, test.paths, test.want)
+		}
+	}
+}
+
+func TestModulePathOfLayout(t *testing.T) {
+	for _, test := range []struct {
+		flavor pathFlavor
+		folder string
+		want   string
+	}{
+		{unixPaths, "/data/github.com/owner/repo/__hash/master", "github.com/owner/repo"},
+		{unixPaths, "/data/github.com/owner/repo/__hash/master/sub/pkg", "github.com/owner/repo/sub/pkg"},
+		{unixPaths, "/data/github.com/owner/repo", "/data/github.com/owner/repo"},
+		{unixPaths, "/owner/repo/__hash/master", "/owner/repo/__hash/master"},
+		{unixPaths, "/data/github.com/owner/repo/__a/__b/sub", "/data/github.com/owner/repo/__a/__b/sub"},
+		{windowsPaths, `C:\data\github.com\owner\repo\__hash\master`, "github.com/owner/repo"},
+		{windowsPaths, `C:\data\github.com\owner\repo\__hash\master\sub`, "github.com/owner/repo/sub"},
+		{windowsPaths, `C:/data\github.com/owner\repo/__hash\master/sub`, "github.com/owner/repo/sub"},
+		{windowsPaths, `\\host\share\github.com\owner\repo\__hash\master`, "github.com/owner/repo"},
+		{windowsPaths, `C:\owner\repo\__hash\master`, `C:\owner\repo\__hash\master`},
+	} {
+		if got := modulePathOfLayout(test.flavor, test.folder); got != test.want {
+			t.Errorf("got the module path %q of %q, want %q", got, test.folder, test.want)
+		}
+	}
+}
+
+func TestPkgVersionFastMixedSeparators(t *testing.T) {
+	locs := []string{"/home/dev/go/pkg/mod/example.com/dep@v1.2.3/dep.go"}
+	// The backslashes are only the separators on windows.
+	if hostPaths.windows {
+		locs = append(locs, `C:\Users\dev\go\pkg\mod\example.com\dep@v1.2.3\dep.go`, `C:\Users\dev\go\pkg\mod/example.com/dep@v1.2.3\dep.go`)
+	}
+	for _, loc := range locs {
+		if got := getPkgVersionFast(loc); got != "v1.2.3" {
+			t.Errorf("got version %q of %s, want v1.2.3", got, loc)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/telemetry"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+)
+
+// defaultPrepareTimeout is the time box of a preparation if the client doesn't specify one.
+const defaultPrepareTimeout = 30 * time.Second
+
+// prepareTracker keeps track of the background preparations triggered by 'elastic/prepare'.
+type prepareTracker struct {
+	mu     sync.Mutex
+	nextID int64
+	// The average duration of the finished preparations, which is used to estimate the ETA of a new preparation.
+	avg   time.Duration
+	count int64
+}
+
+func (t *prepareTracker) start() (token string, eta time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	return "prepare-" + strconv.FormatInt(t.nextID, 10), t.avg
+}
+
+func (t *prepareTracker) finish(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count++
+	t.avg += (d - t.avg) / time.Duration(t.count)
+}
+
+// Prepare loads and type-checks the packages of the document in the background, so that the following requests for
+// the document can be served instantly. It returns immediately with a token, the client will be notified by
+// 'elastic/prepared' with the same token once the preparation is done or the time box is exceeded.
+func (s *ElasticServer) Prepare(ctx context.Context, params *protocol.PrepareParams) (protocol.PrepareResponse, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return protocol.PrepareResponse{}, err
+	}
+	timeout := defaultPrepareTimeout
+	if params.Timeout > 0 {
+		timeout = time.Duration(params.Timeout) * time.Millisecond
+	}
+	token, eta := s.prepares.start()
+
+	go func() {
+		// The preparation outlives the request, so it runs under the background context of the view.
+		ctx, cancel := context.WithTimeout(view.BackgroundContext(), timeout)
+		defer cancel()
+		ctx = telemetry.File.With(ctx, uri)
+
+		start := time.Now()
+		prepared := protocol.PreparedParams{
+			Token:        token,
+			TextDocument: params.TextDocument,
+		}
+		_, cphs, err := view.CheckPackageHandles(ctx, f)
+		for _, cph := range cphs {
+			if err != nil {
+				break
+			}
+			_, err = cph.Check(ctx)
+		}
+		if err != nil {
+			log.Error(ctx, "failed to prepare the packages", err)
+			prepared.Error = err.Error()
+		} else {
+			s.prepares.finish(time.Since(start))
+		}
+		prepared.Duration = float64(time.Since(start)) / float64(time.Millisecond)
+		if s.Conn == nil || !s.clientCaps.notifies("elastic/prepared") {
+			return
+		}
+		// The context of the preparation may be already exceeded.
+		if err := s.Conn.Notify(view.BackgroundContext(), "elastic/prepared", &prepared); err != nil {
+			log.Error(ctx, "failed to notify the preparation", err)
+		}
+	}()
+
+	return protocol.PrepareResponse{
+		Token: token,
+		ETA:   float64(eta) / float64(time.Millisecond),
+	}, nil
+}
@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestPrepare(t *testing.T) {
+	dir := newTestDir(t, "prepare", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package m\n\ntype T struct{ F int }\n\nfunc A(t T) int { return t.F }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	uri := span.FileURI(filepath.Join(dir, "a.go"))
+
+	// The preparation is left to the background, the packages are loaded by the go command well after the return.
+	resp, err := s.Prepare(ctx, &protocol.PrepareParams{TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(uri)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.packageCached(ctx, uri) {
+		t.Errorf("the package is expected to be type-checked in the background after the return")
+	}
+	if resp.Token != "prepare-1" || resp.ETA != 0 {
+		t.Errorf("got the token %q and the ETA %v, want prepare-1 without an estimate", resp.Token, resp.ETA)
+	}
+
+	// The package is type-checked once the preparation is done, which gives the estimate of the next one.
+	deadline := time.Now().Add(time.Minute)
+	for !s.packageCached(ctx, uri) {
+		if time.Now().After(deadline) {
+			t.Fatal("the package isn't type-checked by the preparation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for {
+		s.prepares.mu.Lock()
+		count := s.prepares.count
+		s.prepares.mu.Unlock()
+		if count == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the preparation isn't finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err = s.Prepare(ctx, &protocol.PrepareParams{TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(uri)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token != "prepare-2" || resp.ETA <= 0 {
+		t.Errorf("got the token %q and the ETA %v, want prepare-2 estimated by the previous preparation", resp.Token, resp.ETA)
+	}
+}
@@ -0,0 +1,78 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// snippetLines bounds the lines of the source excerpt of a declaration, the bodies of the long functions and types are
+// cut off.
+const snippetLines = 5
+
+// declSignature returns the one-line signature of the object, like 'func Foo(ctx context.Context) error'. The types
+// of the declaring package are unqualified, the others are qualified by the package names.
+func declSignature(obj types.Object) string {
+	if obj == nil {
+		return ""
+	}
+	return types.ObjectString(obj, func(pkg *types.Package) string {
+		if pkg == obj.Pkg() {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+// packageSignature returns the signature of the imported package, in the same form as the package names of the
+// imports, like 'package dep ("example.com/dep")'.
+func packageSignature(pkg *types.Package) string {
+	if pkg.Path() == pkg.Name() {
+		return "package " + pkg.Name()
+	}
+	return fmt.Sprintf("package %s (%q)", pkg.Name(), pkg.Path())
+}
+
+// declSnippet returns the first lines of the declaration at the position, i.e. the innermost function, spec or field
+// declaring the object there, or the line of the position for the others like the local variables. The file is parsed
+// again in full since the files of the dependencies are parsed without the function bodies. It returns an empty
+// string if the source is unavailable.
+func declSnippet(ctx context.Context, fs source.FileSystem, posn token.Position) string {
+	if posn.Filename == "" || posn.Line < 1 {
+		return ""
+	}
+	content, _, err := fs.GetFile(span.FileURI(posn.Filename), source.Go).Read(ctx)
+	if err != nil {
+		return ""
+	}
+	first, last := posn.Line, posn.Line
+	fset := token.NewFileSet()
+	if file, _ := parser.ParseFile(fset, posn.Filename, content, 0); file != nil && posn.Offset <= fset.File(file.Pos()).Size() {
+		pos := fset.File(file.Pos()).Pos(posn.Offset)
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	loop:
+		for _, n := range path {
+			switch n.(type) {
+			case *ast.FuncDecl, *ast.TypeSpec, *ast.ValueSpec, *ast.Field:
+				first, last = fset.Position(n.Pos()).Line, fset.Position(n.End()).Line
+				break loop
+			}
+		}
+	}
+	if last > first+snippetLines-1 {
+		last = first + snippetLines - 1
+	}
+	lines := strings.Split(string(content), "\n")
+	if last > len(lines) {
+		return ""
+	}
+	return strings.Join(lines[first-1:last], "\n")
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestEDefinitionPreview(t *testing.T) {
+	dir := newTestDir(t, "preview", map[string]string{
+		"dep/go.mod": "module example.com/dep\n\ngo 1.11\n",
+		"dep/dep.go": `package dep
+
+import "context"
+
+// F does nothing.
+func F(ctx context.Context, n int) error {
+	for i := 0; i < n; i++ {
+		_ = i
+	}
+	_ = ctx
+	return nil
+}
+
+type T struct {
+	X int
+}
+`,
+		"m/go.mod": "module example.com/m\n\ngo 1.11\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ../dep\n",
+		"m/m.go": `package m
+
+import "example.com/dep"
+
+var _ = dep.F(nil, 0)
+
+var _ = dep.T{}.X
+
+func local() {}
+
+var _ = local
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	options := source.DefaultOptions
+	folder := filepath.Join(dir, "m")
+	s, _ := newTestServer(ctx, folder, "m", options)
+	for _, test := range []struct {
+		line, character    float64
+		signature, snippet string
+	}{
+		// The functions longer than the excerpt are cut off.
+		{4, 12, "func F(ctx context.Context, n int) error", "func F(ctx context.Context, n int) error {\n\tfor i := 0; i < n; i++ {\n\t\t_ = i\n\t}\n\t_ = ctx"},
+		{6, 16, "field X int", "\tX int"},
+		{2, 9, `package dep ("example.com/dep")`, ""},
+		{10, 8, "func local()", "func local() {}"},
+	} {
+		locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(folder, "m.go")))},
+				Position:     protocol.Position{Line: test.line, Character: test.character},
+			},
+		}})
+		if err != nil {
+			t.Errorf("%v:%v: %v", test.line, test.character, err)
+			continue
+		}
+		if len(locs) != 1 || locs[0].Signature != test.signature || locs[0].Snippet != test.snippet {
+			t.Errorf("%v:%v: got the definitions %+v, want the signature %q and the snippet %q", test.line, test.character, locs, test.signature, test.snippet)
+		}
+	}
+
+	// The legacy clients don't know the previews.
+	if got := legacyLocator(protocol.SymbolLocator{Signature: "func F()", Snippet: "func F() {}"}); got.Signature != "" || got.Snippet != "" {
+		t.Errorf("got the legacy locator %+v with the preview", got)
+	}
+}
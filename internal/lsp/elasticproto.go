@@ -0,0 +1,123 @@
+package lsp
+
+import (
+	"encoding/binary"
+
+	errors "golang.org/x/xerrors"
+)
+
+// protoMessage is a message encoded by the protobuf wire format, the fields of the zero values are omitted like proto3.
+type protoMessage []byte
+
+func (m *protoMessage) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	*m = append(*m, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (m *protoMessage) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	m.varint(uint64(field)<<3 | 0)
+	m.varint(v)
+}
+
+func (m *protoMessage) bytes(field int, b []byte) {
+	m.varint(uint64(field)<<3 | 2)
+	m.varint(uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *protoMessage) string(field int, s string) {
+	if s != "" {
+		m.bytes(field, []byte(s))
+	}
+}
+
+// message embeds the message as the field, the empty messages are still present.
+func (m *protoMessage) message(field int, msg protoMessage) {
+	m.bytes(field, msg)
+}
+
+// packed encodes the repeated varints as a packed field.
+func (m *protoMessage) packed(field int, vs []uint64) {
+	var packed protoMessage
+	for _, v := range vs {
+		packed.varint(v)
+	}
+	m.bytes(field, packed)
+}
+
+// protoField is a field of a decoded message, the length-delimited fields keep their bytes and the others their
+// values.
+type protoField struct {
+	value uint64
+	bytes []byte
+}
+
+// protoFields is a decoded message, the fields are grouped by their numbers in the order of occurrence.
+type protoFields map[uint64][]protoField
+
+// parseProto decodes the message encoded by the protobuf wire format, the groups are unsupported.
+func parseProto(msg []byte) (protoFields, error) {
+	fields := make(protoFields)
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, errors.Errorf("malformed field key")
+		}
+		msg = msg[n:]
+		var field protoField
+		switch key & 7 {
+		case 0:
+			field.value, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return nil, errors.Errorf("malformed varint of field %d", key>>3)
+			}
+			msg = msg[n:]
+		case 1:
+			if len(msg) < 8 {
+				return nil, errors.Errorf("truncated fixed64 of field %d", key>>3)
+			}
+			field.value, msg = binary.LittleEndian.Uint64(msg), msg[8:]
+		case 2:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return nil, errors.Errorf("truncated bytes of field %d", key>>3)
+			}
+			field.bytes, msg = msg[n:n+int(size)], msg[n+int(size):]
+		case 5:
+			if len(msg) < 4 {
+				return nil, errors.Errorf("truncated fixed32 of field %d", key>>3)
+			}
+			field.value, msg = uint64(binary.LittleEndian.Uint32(msg)), msg[4:]
+		default:
+			return nil, errors.Errorf("unsupported wire type %d of field %d", key&7, key>>3)
+		}
+		fields[key>>3] = append(fields[key>>3], field)
+	}
+	return fields, nil
+}
+
+// uint returns the last value of the field, like proto3 does for the repeated scalars.
+func (f protoFields) uint(field uint64) uint64 {
+	if values := f[field]; len(values) > 0 {
+		return values[len(values)-1].value
+	}
+	return 0
+}
+
+func (f protoFields) string(field uint64) string {
+	if values := f[field]; len(values) > 0 {
+		return string(values[len(values)-1].bytes)
+	}
+	return ""
+}
+
+// message decodes the embedded message of the field, which is empty if the field is absent.
+func (f protoFields) message(field uint64) (protoFields, error) {
+	if values := f[field]; len(values) > 0 {
+		return parseProto(values[len(values)-1].bytes)
+	}
+	return protoFields{}, nil
+}
@@ -0,0 +1,150 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// proxyFailureThreshold is the number of the consecutive failures which demote a module proxy.
+const proxyFailureThreshold = 2
+
+// proxyFailures are the fragments of the errors of 'go mod download' caused by the module proxies rather than the
+// modules, like the network errors and the server errors of the proxies.
+var proxyFailures = []string{
+	"dial tcp",
+	"connection refused",
+	"connection reset",
+	"no such host",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"429 Too Many Requests",
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+}
+
+// proxyHealth tracks the health of the module proxies in the fallback chain, the proxies failing consecutively are
+// demoted for a cooldown period, so that an outage of one proxy doesn't fail the downloading of all the folders.
+type proxyHealth struct {
+	mu      sync.Mutex
+	proxies map[string]*proxyState
+}
+
+type proxyState struct {
+	failures     int
+	demotedUntil time.Time
+}
+
+// chain returns the proxies to try in order, the demoted proxies are skipped unless all of them are demoted.
+func (h *proxyHealth) chain(proxies []string, now time.Time) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var healthy []string
+	for _, proxy := range proxies {
+		if state, ok := h.proxies[proxy]; ok && now.Before(state.demotedUntil) {
+			continue
+		}
+		healthy = append(healthy, proxy)
+	}
+	if len(healthy) == 0 {
+		return proxies
+	}
+	return healthy
+}
+
+// report records whether the proxy is healthy, the proxy is demoted for the cooldown once it fails consecutively for
+// proxyFailureThreshold times.
+func (h *proxyHealth) report(ctx context.Context, proxy string, healthy bool, now time.Time, cooldown time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.proxies == nil {
+		h.proxies = make(map[string]*proxyState)
+	}
+	state, ok := h.proxies[proxy]
+	if !ok {
+		state = &proxyState{}
+		h.proxies[proxy] = state
+	}
+	if healthy {
+		state.failures = 0
+		state.demotedUntil = time.Time{}
+		return
+	}
+	state.failures++
+	if state.failures >= proxyFailureThreshold {
+		state.failures = 0
+		state.demotedUntil = now.Add(cooldown)
+		log.Print(ctx, "demoted the module proxy", tag.Of("Proxy", proxy), tag.Of("Cooldown", cooldown))
+	}
+}
+
+// reset forgets the failures of the proxies, so that the demoted proxies are tried again.
+func (h *proxyHealth) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.proxies = nil
+}
+
+// isProxyFailure reports whether the error of 'go mod download' is caused by the module proxy.
+func isProxyFailure(err error) bool {
+	msg := err.Error()
+	for _, failure := range proxyFailures {
+		if strings.Contains(msg, failure) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadModules downloads the dependencies of the module located at dir via the proxies in the fallback chain, the
+// next proxy is tried if the download fails, since the modules may be unavailable in some of the proxies, like the
+// internal proxy. Only the failures caused by the proxies count against their health.
+func downloadModules(ctx context.Context, options source.Options, dir string, env []string, proxies []string, health *proxyHealth, cooldown time.Duration) error {
+	if len(proxies) == 0 {
+		return fmt.Errorf("no module proxy is configured")
+	}
+	var err error
+	for _, proxy := range health.chain(proxies, time.Now()) {
+		var stdout *bytes.Buffer
+		if stdout, err = runGoCommand(ctx, options, dir, append(append([]string{}, env...), "GOPROXY="+proxy), "mod", "download", "-json"); err == nil {
+			health.report(ctx, proxy, true, time.Now(), cooldown)
+			touchDownloaded(stdout)
+			return nil
+		}
+		log.Error(ctx, "failed to download the dependencies", err, tag.Of("Proxy", proxy), tag.Of("Folder", dir))
+		if isProxyFailure(err) {
+			health.report(ctx, proxy, false, time.Now(), cooldown)
+		}
+	}
+	return err
+}
+
+// touchDownloaded records the use of the module versions downloaded, which are reported by 'go mod download -json',
+// so that the module versions required by the folders aren't evicted from the module cache in favor of the others.
+func touchDownloaded(stdout *bytes.Buffer) {
+	now := time.Now()
+	dec := json.NewDecoder(stdout)
+	for {
+		var mod struct{ Dir string }
+		if err := dec.Decode(&mod); err != nil {
+			return
+		}
+		if mod.Dir != "" {
+			modCacheUsage.Lock()
+			modCacheUsage.used[mod.Dir] = now
+			modCacheUsage.Unlock()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestProxyHealth(t *testing.T) {
+	ctx := context.Background()
+	proxies := []string{"https://internal", "https://proxy.golang.org", "direct"}
+	now := time.Now()
+	var health proxyHealth
+	health.report(ctx, "https://internal", false, now, time.Minute)
+	if got := health.chain(proxies, now); !reflect.DeepEqual(got, proxies) {
+		t.Errorf("got chain %v after one failure", got)
+	}
+	health.report(ctx, "https://internal", false, now, time.Minute)
+	if got, want := health.chain(proxies, now), proxies[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got chain %v, want %v", got, want)
+	}
+	// The demoted proxy is back once the cooldown expires.
+	if got := health.chain(proxies, now.Add(2*time.Minute)); !reflect.DeepEqual(got, proxies) {
+		t.Errorf("got chain %v after the cooldown", got)
+	}
+	// All the proxies are tried if all of them are demoted.
+	for _, proxy := range proxies[1:] {
+		health.report(ctx, proxy, false, now, time.Minute)
+		health.report(ctx, proxy, false, now, time.Minute)
+	}
+	if got := health.chain(proxies, now); !reflect.DeepEqual(got, proxies) {
+		t.Errorf("got chain %v while all the proxies are demoted", got)
+	}
+	health.report(ctx, "direct", true, now, time.Minute)
+	if got, want := health.chain(proxies, now), []string{"direct"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got chain %v, want %v", got, want)
+	}
+}
+
+func TestIsProxyFailure(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"example.com/m@v1.0.0: reading https://internal/example.com/m/@v/v1.0.0.mod: 503 Service Unavailable": true,
+		"dial tcp: lookup internal: no such host":                                                             true,
+		"example.com/m@v1.0.0: reading https://proxy.golang.org/example.com/m/@v/v1.0.0.mod: 404 Not Found":   false,
+	} {
+		if got := isProxyFailure(errors.New(msg)); got != want {
+			t.Errorf("isProxyFailure(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestDownloadModulesFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mod := "module example.com/proj\n\nrequire example.com/dep v1.0.0\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var outageHits, missingHits int32
+	outage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&outageHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer outage.Close()
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&missingHits, 1)
+		http.NotFound(w, r)
+	}))
+	defer missing.Close()
+
+	ctx := context.Background()
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOSUMDB=off", "GOMODCACHE="+filepath.Join(dir, "modcache"))
+	proxies := []string{outage.URL, missing.URL}
+	var health proxyHealth
+	for i := 0; i < proxyFailureThreshold; i++ {
+		if err := downloadModules(ctx, source.DefaultOptions, dir, env, proxies, &health, time.Minute); err == nil {
+			t.Fatal("expected the download to fail")
+		}
+	}
+	if atomic.LoadInt32(&outageHits) == 0 || atomic.LoadInt32(&missingHits) == 0 {
+		t.Errorf("got %d hits of the outage proxy and %d hits of the missing proxy", outageHits, missingHits)
+	}
+	// The proxy under outage is demoted, while the one missing the module isn't.
+	if got, want := health.chain(proxies, time.Now()), proxies[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got chain %v, want %v", got, want)
+	}
+}
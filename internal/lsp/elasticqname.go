@@ -0,0 +1,251 @@
+package lsp
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// truncateQname limits the depth of the qualified name, i.e. the number of the components following the package name.
+// The deeply nested anonymous structures produce absurdly long qualified names, the components beyond the depth are
+// replaced by the hash of them, so that the truncated names are still deterministic and distinguishable. A non-positive
+// depth means no limit.
+func truncateQname(qname string, maxDepth int) string {
+	if maxDepth <= 0 {
+		return qname
+	}
+	parts := strings.Split(qname, ".")
+	// The first component is the package name.
+	if len(parts)-1 <= maxDepth {
+		return qname
+	}
+	keep := parts[:maxDepth]
+	sum := sha1.Sum([]byte(strings.Join(parts[maxDepth:], ".")))
+	return strings.Join(keep, ".") + ".~" + hex.EncodeToString(sum[:4])
+}
+
+// qualifyQname replaces the package name leading the qualified name by the import path of the package under the
+// import path styles, the version of the module is appended to the import path as well if required. The truncation
+// and the disambiguation are applied before, since they work on the components of the short form.
+func qualifyQname(qname string, pkg *types.Package, version string, style source.QnameStyle) string {
+	if style == source.PackageNameQname || pkg == nil || (qname != pkg.Name() && !strings.HasPrefix(qname, pkg.Name()+".")) {
+		return qname
+	}
+	prefix := escapeImportPath(stripVendorPrefix(pkg.Path()))
+	if style == source.ImportPathVersionQname && version != "" {
+		prefix += "@" + version
+	}
+	return prefix + strings.TrimPrefix(qname, pkg.Name())
+}
+
+// escapeImportPath escapes the import path the same way as the linker does for the symbol names, i.e. the dots in the
+// last element of the path and the special characters are escaped, so that the first dot after the last slash of a
+// qualified name always separates the import path. The tilde is escaped as well since it leads the disambiguators.
+func escapeImportPath(path string) string {
+	slash := strings.LastIndex(path, "/")
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c <= ' ' || (c == '.' && i > slash) || c == '%' || c == '"' || c == '~' || c >= 0x7F {
+			fmt.Fprintf(&b, "%%%02x", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// declOrdinals maps the declarations sharing the same name in the same scope, which would produce the identical
+// qualified names, to their ordinals. The ordinals start from 1 in the source order.
+type declOrdinals map[token.Pos]int
+
+// name returns the name of the declared identifier, the ordinal is appended as a disambiguator if the name is shared
+// with the other declarations, like 'init~2'.
+func (o declOrdinals) name(id *ast.Ident) string {
+	if ordinal, ok := o[id.Pos()]; ok {
+		return id.Name + "~" + strconv.Itoa(ordinal)
+	}
+	return id.Name
+}
+
+func (o declOrdinals) add(decls map[string][]token.Pos) {
+	for _, poss := range decls {
+		if len(poss) < 2 {
+			continue
+		}
+		for i, pos := range poss {
+			o[pos] = i + 1
+		}
+	}
+}
+
+// packageDeclOrdinals collects the package level declarations which may be declared more than once in a package, i.e.
+// the 'init' functions and the blank identifiers. The files are ordered by the file names, so that the ordinals are
+// independent of the loading order.
+func packageDeclOrdinals(fset *token.FileSet, files []*ast.File) declOrdinals {
+	files = append([]*ast.File(nil), files...)
+	sort.Slice(files, func(i, j int) bool {
+		return fset.File(files[i].Pos()).Name() < fset.File(files[j].Pos()).Name()
+	})
+	decls := make(map[string][]token.Pos)
+	for _, file := range files {
+		for _, id := range repeatableDecls(file) {
+			decls[id.Name] = append(decls[id.Name], id.Pos())
+		}
+	}
+	ordinals := make(declOrdinals)
+	ordinals.add(decls)
+	return ordinals
+}
+
+// widestDeclOrdinals returns the ordinals of the package level declarations over the widest variant of the package
+// among the handles, i.e. the test variant recompiled with the in-package test files if there is one. The declarations
+// in the in-package test files belong to the package under test, like 'foo.TestX', so the declarations in the non-test
+// files are numbered together with them, no matter which variant is checked for the file. The external test files,
+// qualified by the test package like 'foo_test.TestX', make up a package on their own.
+func widestDeclOrdinals(ctx context.Context, fset *token.FileSet, cphs []source.CheckPackageHandle) declOrdinals {
+	if len(cphs) == 0 {
+		return nil
+	}
+	var files []*ast.File
+	for _, ph := range source.WidestCheckPackageHandle(cphs).Files() {
+		if file, _, _, err := ph.Parse(ctx); err == nil && file != nil {
+			files = append(files, file)
+		}
+	}
+	return packageDeclOrdinals(fset, files)
+}
+
+// repeatableDecls returns the identifiers of the package level declarations in the file which may be declared more
+// than once in a package, in the source order.
+func repeatableDecls(file *ast.File) []*ast.Ident {
+	var ids []*ast.Ident
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && decl.Name.Name == "init" {
+				ids = append(ids, decl.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name == "_" {
+						ids = append(ids, spec.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.Name == "_" {
+							ids = append(ids, name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// declPackageOrdinals returns the ordinals of the package level declarations of the package declaring the file, which
+// is either pkg or one of its dependencies. It returns nil if the file is out of the package.
+func declPackageOrdinals(ctx context.Context, fset *token.FileSet, pkg source.Package, uri span.URI) declOrdinals {
+	if pkg == nil {
+		return nil
+	}
+	// The builtin file is found without a package.
+	_, declPkg, err := pkg.FindFile(ctx, uri)
+	if err != nil || declPkg == nil {
+		return nil
+	}
+	return packageDeclOrdinals(fset, declPkg.GetSyntax(ctx))
+}
+
+// localDeclOrdinals collects the local declarations sharing the same name in the function, like the variables with the
+// same name declared in the different blocks. The declarations in the nested function literals are counted by those
+// literals, since the literals are named separately. The identifiers are resolved against the scopes under root, i.e.
+// the file scope, to tell the declarations from the uses.
+func localDeclOrdinals(fn ast.Node, root *types.Scope) declOrdinals {
+	ordinals := make(declOrdinals)
+	var visit func(owner ast.Node)
+	visit = func(owner ast.Node) {
+		decls := make(map[string][]token.Pos)
+		ast.Inspect(owner, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if n != owner {
+					visit(n)
+					return false
+				}
+			case *ast.Ident:
+				if n.Name == "_" {
+					return false
+				}
+				scope := root.Innermost(n.Pos())
+				if scope == nil {
+					scope = root
+				}
+				if obj := scope.Lookup(n.Name); obj != nil && obj.Pos() == n.Pos() {
+					decls[n.Name] = append(decls[n.Name], n.Pos())
+				}
+			}
+			return true
+		})
+		ordinals.add(decls)
+	}
+	visit(fn)
+	return ordinals
+}
+
+// rawQname strips the disambiguators of the qualified name.
+func rawQname(qname string) string {
+	parts := strings.Split(qname, ".")
+	for i, part := range parts {
+		// The hashed tails of the truncated names start with '~'.
+		if j := strings.Index(part, "~"); j > 0 {
+			parts[i] = part[:j]
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// findQnameCollisions reports the qualified names shared by more than one declaration in the package, which are
+// disambiguated in the symbols of the file, along with the raw locations of the symbols. The qualified names which
+// still collide, if any, are reported as well.
+func findQnameCollisions(symbols []protocol.DetailSymbolInformation) []protocol.QnameCollision {
+	var collisions []protocol.QnameCollision
+	index := make(map[string]int)
+	for _, sym := range symbols {
+		raw := rawQname(sym.Qname)
+		i, ok := index[raw]
+		if !ok {
+			i = len(collisions)
+			index[raw] = i
+			collisions = append(collisions, protocol.QnameCollision{Qname: raw})
+		}
+		collisions[i].Locations = append(collisions[i].Locations, sym.Symbol.Location)
+		collisions[i].Qnames = append(collisions[i].Qnames, sym.Qname)
+	}
+	n := 0
+	for _, c := range collisions {
+		if len(c.Locations) > 1 || c.Qnames[0] != c.Qname {
+			collisions[n] = c
+			n++
+		}
+	}
+	collisions = collisions[:n]
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Qname < collisions[j].Qname
+	})
+	return collisions
+}
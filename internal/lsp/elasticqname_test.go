@@ -0,0 +1,334 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestTruncateQname(t *testing.T) {
+	for _, test := range []struct {
+		qname string
+		depth int
+		want  string
+	}{
+		{"pkg.T.f", 0, "pkg.T.f"},
+		{"pkg.T.f", 2, "pkg.T.f"},
+		{"pkg.T.a.b.c", 2, "pkg.T.~"},
+		{"pkg.T.a.b.d", 2, "pkg.T.~"},
+	} {
+		got := truncateQname(test.qname, test.depth)
+		if !strings.HasPrefix(got, test.want) || (got == test.qname) != (test.want == test.qname) {
+			t.Errorf("truncateQname(%q, %d) = %q, want prefix %q", test.qname, test.depth, got, test.want)
+		}
+	}
+	if a, b := truncateQname("pkg.T.a.b.c", 2), truncateQname("pkg.T.a.b.d", 2); a == b {
+		t.Errorf("the truncated tails collide: %q", a)
+	}
+	if a, b := truncateQname("pkg.T.a.b.c", 2), truncateQname("pkg.T.a.b.c", 2); a != b {
+		t.Errorf("the truncation isn't deterministic: %q != %q", a, b)
+	}
+}
+
+func TestFindQnameCollisions(t *testing.T) {
+	sym := func(qname string, line float64) protocol.DetailSymbolInformation {
+		return protocol.DetailSymbolInformation{
+			Symbol: protocol.SymbolInformation{Location: protocol.Location{Range: protocol.Range{Start: protocol.Position{Line: line}}}},
+			Qname:  qname,
+		}
+	}
+	collisions := findQnameCollisions([]protocol.DetailSymbolInformation{
+		sym("pkg.init~1", 1), sym("pkg.T", 2), sym("pkg.init~3", 3), sym("pkg.T.f", 4), sym("pkg._~2", 5), sym("pkg.T.~0a1b2c3d", 6),
+	})
+	if len(collisions) != 2 {
+		t.Fatalf("got collisions %v", collisions)
+	}
+	if c := collisions[0]; c.Qname != "pkg._" || len(c.Locations) != 1 || c.Qnames[0] != "pkg._~2" {
+		t.Errorf("got collision %v", c)
+	}
+	if c := collisions[1]; c.Qname != "pkg.init" || len(c.Locations) != 2 || c.Qnames[1] != "pkg.init~3" {
+		t.Errorf("got collision %v", c)
+	}
+}
+
+const disambiguateSrcA = `package p
+
+var _ = 1
+
+func init() {
+	x := 1
+	_ = x
+}
+
+func F(v int) {
+	if v > 0 {
+		x := v
+		_ = x
+	} else {
+		x := -v
+		_ = x
+	}
+	y, err := 1, error(nil)
+	z, err := 2, error(nil)
+	_, _, _ = y, z, err
+}
+`
+
+const disambiguateSrcB = `package p
+
+var _ = 2
+
+func init() {
+	x := 2
+	_ = x
+}
+
+func init() {}
+`
+
+func TestDisambiguateQnames(t *testing.T) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	// Parse the files in the reverse order, the ordinals follow the file names anyway.
+	for _, src := range []struct{ name, content string }{{"b.go", disambiguateSrcB}, {"a.go", disambiguateSrcA}} {
+		file, err := parser.ParseFile(fset, src.name, src.content, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	if _, err := (&types.Config{}).Check("p", fset, files, info); err != nil {
+		t.Fatal(err)
+	}
+	ordinals := packageDeclOrdinals(fset, files)
+	var got []string
+	for id, obj := range info.Defs {
+		if obj == nil || id.Name == "_" {
+			continue
+		}
+		file := files[0]
+		if fset.File(id.Pos()).Name() == "a.go" {
+			file = files[1]
+		}
+		qname := getQName(file, obj, getSymbolKind(obj), ordinals)
+		if obj.Name() == "init" || obj.Name() == "x" || obj.Name() == "err" {
+			got = append(got, fset.Position(id.Pos()).String()+" "+qname)
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"a.go:12:3 p.F.x~1",
+		"a.go:15:3 p.F.x~2",
+		"a.go:18:5 p.F.err",
+		"a.go:5:6 p.init~1",
+		"a.go:6:2 p.init~1.x",
+		"b.go:10:6 p.init~3",
+		"b.go:5:6 p.init~2",
+		"b.go:6:2 p.init~2.x",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got qnames\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	var blanks []string
+	for _, file := range files {
+		for _, id := range repeatableDecls(file) {
+			blanks = append(blanks, ordinals.name(id))
+		}
+	}
+	sort.Strings(blanks)
+	if want := []string{"_~1", "_~2", "init~1", "init~2", "init~3"}; !reflect.DeepEqual(blanks, want) {
+		t.Errorf("got names %v, want %v", blanks, want)
+	}
+}
+
+const promotedSrc = `package p
+
+type Closer interface{ Close() error }
+
+type T struct {
+	X int
+	C interface{ Close() error }
+}
+
+func (*T) M() {}
+
+type Outer struct {
+	*T
+	Closer
+}
+
+var V interface{ Close() error }
+
+func f(o Outer) {
+	_ = o.Close()
+	o.M()
+	_ = o.X
+	_ = o.C.Close()
+	_ = V.Close()
+}
+`
+
+func TestPromotedQnames(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", promotedSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	if _, err := (&types.Config{}).Check("p", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			obj := info.Uses[sel.Sel]
+			got = append(got, fset.Position(sel.Sel.Pos()).String()+" "+getQName(file, obj, getSymbolKind(obj), nil))
+		}
+		return true
+	})
+	// The selected fields and methods are rooted at the types declaring them rather than at the embedding type.
+	want := []string{
+		"p.go:20:8 p.Closer.Close",
+		"p.go:21:4 p.T.M",
+		"p.go:22:8 p.T.X",
+		"p.go:23:10 p.T.C.Close",
+		"p.go:23:8 p.T.C",
+		"p.go:24:8 p.V.Close",
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got qnames\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestQualifyQname(t *testing.T) {
+	yaml := types.NewPackage("gopkg.in/yaml.v2", "yaml")
+	vendored := types.NewPackage("example.com/proj/vendor/github.com/foo/util", "util")
+	for _, test := range []struct {
+		qname   string
+		pkg     *types.Package
+		version string
+		style   source.QnameStyle
+		want    string
+	}{
+		{"yaml.T.M", yaml, "v2.2.8", source.PackageNameQname, "yaml.T.M"},
+		{"yaml.T.M", yaml, "v2.2.8", source.ImportPathQname, "gopkg.in/yaml%2ev2.T.M"},
+		{"yaml.T.M", yaml, "v2.2.8", source.ImportPathVersionQname, "gopkg.in/yaml%2ev2@v2.2.8.T.M"},
+		{"yaml", yaml, "", source.ImportPathVersionQname, "gopkg.in/yaml%2ev2"},
+		{"util.init~2", vendored, "", source.ImportPathQname, "github.com/foo/util.init~2"},
+		{"yamlx.T", yaml, "", source.ImportPathQname, "yamlx.T"},
+	} {
+		if got := qualifyQname(test.qname, test.pkg, test.version, test.style); got != test.want {
+			t.Errorf("qualifyQname(%q, %q, %d) = %q, want %q", test.qname, test.pkg.Path(), test.style, got, test.want)
+		}
+	}
+	if got, want := rawQname("example.com/%7euser/util.init~2"), "example.com/%7euser/util.init"; got != want {
+		t.Errorf("got raw qname %q, want %q", got, want)
+	}
+}
+
+func TestQnameStyleOption(t *testing.T) {
+	var options source.Options
+	for _, r := range source.SetOptions(&options, map[string]interface{}{"qnameStyle": "importPathVersion"}) {
+		if r.Error != nil {
+			t.Fatal(r.Error)
+		}
+	}
+	if options.QnameStyle != source.ImportPathVersionQname {
+		t.Errorf("got qname style %d", options.QnameStyle)
+	}
+	for _, r := range source.SetOptions(&options, map[string]interface{}{"qnameStyle": "fullPath"}) {
+		if r.Error == nil {
+			t.Errorf("expected an error for the unsupported style")
+		}
+	}
+}
+
+func TestTestPackageQnames(t *testing.T) {
+	dir := newTestDir(t, "testqname", map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"foo.go":          "package foo\n\nfunc init() {}\n\nfunc X() {}\n",
+		"foo_in_test.go":  "package foo\n\nimport \"testing\"\n\nfunc init() {}\n\nfunc helper() {}\n\nfunc TestIn(t *testing.T) { helper(); X() }\n",
+		"foo_ext_test.go": "package foo_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/foo\"\n)\n\nfunc helper() {}\n\nfunc TestExt(t *testing.T) { helper(); foo.X() }\n",
+	})
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		style source.QnameStyle
+		want  map[string][]string
+	}{
+		{source.PackageNameQname, map[string][]string{
+			"foo.go":          {"foo.init~1", "foo.X"},
+			"foo_in_test.go":  {"foo.init~2", "foo.helper", "foo.TestIn"},
+			"foo_ext_test.go": {"foo_test.helper", "foo_test.TestExt"},
+		}},
+		{source.ImportPathQname, map[string][]string{
+			"foo.go":          {"example.com/foo.init~1", "example.com/foo.X"},
+			"foo_in_test.go":  {"example.com/foo.init~2", "example.com/foo.helper", "example.com/foo.TestIn"},
+			"foo_ext_test.go": {"example.com/foo_test.helper", "example.com/foo_test.TestExt"},
+		}},
+	} {
+		ctx := context.Background()
+		options := source.DefaultOptions
+		options.QnameStyle = test.style
+		s, _ := newTestServer(ctx, dir, "testqname", options)
+
+		symbols := make(map[string]bool)
+		defined := make(map[string]bool)
+		var targets []string
+		for _, name := range []string{"foo.go", "foo_in_test.go", "foo_ext_test.go"} {
+			resp, err := s.Full(ctx, &protocol.FullParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, name)))},
+				Reference:    true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, sym := range resp.Symbols {
+				got = append(got, sym.Qname)
+				symbols[sym.Qname] = true
+				if sym.Package.RepoURI != "example.com/foo" {
+					t.Errorf("%v: got repository %q of %s, want example.com/foo", test.style, sym.Package.RepoURI, sym.Qname)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want[name]) {
+				t.Errorf("%v: got qnames %v of %s, want %v", test.style, got, name, test.want[name])
+			}
+			for _, ref := range resp.References {
+				if ref.Kind == protocol.DefinitionReference {
+					defined[ref.Target.Qname] = true
+				} else if ref.Target.Package.RepoURI == "example.com/foo" && ref.Target.Kind != protocol.Package {
+					targets = append(targets, ref.Target.Qname)
+				}
+			}
+		}
+		// The references to the test symbols are qualified the same as their declarations.
+		if len(targets) == 0 {
+			t.Errorf("%v: no reference to the symbols of the module", test.style)
+		}
+		for _, target := range targets {
+			if !symbols[target] {
+				t.Errorf("%v: got reference target %s, which isn't declared", test.style, target)
+			}
+		}
+		for symbol := range symbols {
+			if !defined[symbol] {
+				t.Errorf("%v: no definition reference to %s", test.style, symbol)
+			}
+		}
+	}
+}
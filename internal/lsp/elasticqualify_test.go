@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestQualifyLocalDefinitions(t *testing.T) {
+	dir := newTestDir(t, "qualify", map[string]string{
+		"go.mod": "module example.com/a\n\ngo 1.11\n",
+		"a.go":   "package a\n\nimport \"example.com/a/b\"\n\ntype M map[string]int\n\nvar _ M\n\nvar _ = b.F\n",
+		"b/b.go": "package b\n\nfunc F() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	definition := func(qualify bool, line, character float64) protocol.SymbolLocator {
+		t.Helper()
+		options := source.DefaultOptions
+		options.QualifyLocalDefinitions = qualify
+		s, _ := newTestServer(ctx, dir, "a", options)
+		locs, err := s.EDefinition(ctx, &protocol.EDefinitionParams{DefinitionParams: protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))},
+				Position:     protocol.Position{Line: line, Character: character},
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locs) != 1 || locs[0].Loc == nil {
+			t.Fatalf("got the definitions %+v, want one location", locs)
+		}
+		return locs[0]
+	}
+
+	if got := definition(false, 8, 10); got.Qname != "" || got.Kind != 0 || got.Package.Name != "" {
+		t.Errorf("got the local definition %+v, want the location only", got)
+	}
+	if got := definition(true, 8, 10); got.Qname != "b.F" || got.Kind != protocol.Function || got.Package.Name != "b" || got.Package.RepoURI != "example.com/a/b" {
+		t.Errorf("got the local definition %+v, want b.F of example.com/a/b", got)
+	}
+	if got := definition(true, 8, 8); got.Qname != "b" || got.Kind != protocol.Package || got.Package.RepoURI != "example.com/a/b" {
+		t.Errorf("got the local package %+v, want b of example.com/a/b", got)
+	}
+	// The symbols without a kind are located anyway.
+	if got := definition(true, 6, 6); got.Qname != "" || got.Kind != 0 {
+		t.Errorf("got the local definition %+v, want the location only", got)
+	}
+}
@@ -0,0 +1,398 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// referenceVisitor is called for each reference found in a file, enclosing is the name of the declaration which
+// encloses the reference, it's nil for the references at the top level.
+type referenceVisitor func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident)
+
+// walkReferences walks the file and classifies the uses of the symbols by their syntactic roles.
+func walkReferences(file *ast.File, info *types.Info, visit referenceVisitor) {
+	var stack []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			obj := info.Defs[n.Name]
+			if obj == nil {
+				obj = info.Implicits[n]
+			}
+			if obj != nil {
+				visit(n.Path, obj, protocol.ImportReference, protocol.READ, nil)
+			}
+			return false
+		case *ast.Ident:
+			// The embedded fields are both the definitions of the fields and the uses of the types.
+			if def := info.Defs[n]; def != nil && n.Name != "_" {
+				visit(n, def, protocol.DefinitionReference, protocol.UNCATEGORIZED, enclosingDecl(stack))
+			}
+			obj := info.Uses[n]
+			// The qualifiers of the imported symbols are covered by the import references.
+			if _, ok := obj.(*types.PkgName); obj == nil || ok {
+				return true
+			}
+			kind, category := classifyReference(stack, obj)
+			visit(n, obj, kind, category, enclosingDecl(stack))
+		}
+		return true
+	})
+}
+
+// classifyReference classifies the identifier at the top of the stack, which refers to obj.
+func classifyReference(stack []ast.Node, obj types.Object) (protocol.ReferenceKind, protocol.ReferenceCategory) {
+	// The node which the identifier stands for, the qualified identifiers and the selected fields or methods are
+	// the selector expressions as a whole.
+	i := len(stack) - 1
+	if sel, ok := stack[i-1].(*ast.SelectorExpr); ok && sel.Sel == stack[i] {
+		i--
+	}
+	expr := stack[i]
+	parent := stack[i-1]
+	switch parent := parent.(type) {
+	case *ast.CallExpr:
+		if _, ok := obj.(*types.Func); ok && parent.Fun == expr {
+			return protocol.CallReference, protocol.READ
+		}
+	case *ast.UnaryExpr:
+		if parent.Op == token.AND {
+			return protocol.AddressTakenReference, protocol.WRITE
+		}
+	case *ast.AssignStmt:
+		for _, lhs := range parent.Lhs {
+			if lhs == expr {
+				return protocol.WriteReference, protocol.WRITE
+			}
+		}
+	case *ast.IncDecStmt:
+		return protocol.WriteReference, protocol.WRITE
+	case *ast.RangeStmt:
+		if parent.Tok == token.ASSIGN && (parent.Key == expr || parent.Value == expr) {
+			return protocol.WriteReference, protocol.WRITE
+		}
+	}
+	if _, ok := obj.(*types.TypeName); ok {
+		// The embedded types, like 'struct { T }' and 'struct { *T }', are inherited.
+		p := i - 1
+		if star, ok := parent.(*ast.StarExpr); ok && star.X == expr {
+			p--
+		}
+		// The embedded field is located in 'StructType > FieldList > Field'.
+		if field, ok := stack[p].(*ast.Field); ok && len(field.Names) == 0 && p >= 2 {
+			switch stack[p-2].(type) {
+			case *ast.StructType, *ast.InterfaceType:
+				return protocol.TypeUseReference, protocol.INHERIT
+			}
+		}
+		return protocol.TypeUseReference, protocol.READ
+	}
+	return protocol.ReadReference, protocol.READ
+}
+
+// enclosingDecl returns the name of the innermost declaration in the stack.
+func enclosingDecl(stack []ast.Node) *ast.Ident {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl:
+			return n.Name
+		case *ast.TypeSpec:
+			return n.Name
+		case *ast.ValueSpec:
+			if len(n.Names) > 0 {
+				return n.Names[0]
+			}
+		}
+	}
+	return nil
+}
+
+// implementsWitness is a type declared in a file which satisfies an interface.
+type implementsWitness struct {
+	name  *ast.Ident
+	iface *types.TypeName
+}
+
+// findImplementsWitnesses finds the types declared in the file that satisfy the interfaces declared in the package or
+// referenced by the file, either by the values or by the pointers. The empty interfaces are ignored.
+func findImplementsWitnesses(file *ast.File, info *types.Info, pkg *types.Package) []implementsWitness {
+	seen := make(map[*types.TypeName]bool)
+	var ifaces []*types.TypeName
+	addIface := func(obj types.Object) {
+		tn, ok := obj.(*types.TypeName)
+		if !ok || seen[tn] {
+			return
+		}
+		if iface, ok := tn.Type().Underlying().(*types.Interface); ok && iface.NumMethods() > 0 && !isGeneric(tn) {
+			seen[tn] = true
+			ifaces = append(ifaces, tn)
+		}
+	}
+	for _, name := range pkg.Scope().Names() {
+		addIface(pkg.Scope().Lookup(name))
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			addIface(info.Uses[id])
+		}
+		return true
+	})
+
+	var witnesses []implementsWitness
+	ast.Inspect(file, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		tn, ok := info.Defs[ts.Name].(*types.TypeName)
+		if !ok || isGeneric(tn) {
+			return true
+		}
+		if _, ok := tn.Type().Underlying().(*types.Interface); ok {
+			return true
+		}
+		for _, iface := range ifaces {
+			it := iface.Type().Underlying().(*types.Interface)
+			if types.Implements(tn.Type(), it) || types.Implements(types.NewPointer(tn.Type()), it) {
+				witnesses = append(witnesses, implementsWitness{name: ts.Name, iface: iface})
+			}
+		}
+		return true
+	})
+	return witnesses
+}
+
+func isGeneric(tn *types.TypeName) bool {
+	named, ok := tn.Type().(*types.Named)
+	return ok && named.TypeParams().Len() > 0
+}
+
+// referenceCollector resolves the targets of the references, the targets are cached since the symbols are usually
+// referenced many times in a file.
+type referenceCollector struct {
+	ctx     context.Context
+	view    source.View
+	fset    *token.FileSet
+	uri     span.URI
+	m       *protocol.ColumnMapper
+	pkg     source.Package
+	info    *types.Info
+	targets map[types.Object]*protocol.SymbolLocator
+	pkgs    map[*types.Package]protocol.PackageLocator
+	// The ordinals of the package level declarations by the declaring packages.
+	pkgOrdinals map[*types.Package]declOrdinals
+}
+
+func newReferenceCollector(ctx context.Context, view source.View, pkg source.Package, uri span.URI, m *protocol.ColumnMapper) *referenceCollector {
+	return &referenceCollector{
+		ctx:         ctx,
+		view:        view,
+		fset:        view.Session().Cache().FileSet(),
+		uri:         uri,
+		m:           m,
+		pkg:         pkg,
+		info:        pkg.GetTypesInfo(),
+		targets:     make(map[types.Object]*protocol.SymbolLocator),
+		pkgs:        make(map[*types.Package]protocol.PackageLocator),
+		pkgOrdinals: make(map[*types.Package]declOrdinals),
+	}
+}
+
+// fileCollector returns the collector locating the objects referred to in the file.
+func (s *ElasticServer) fileCollector(ctx context.Context, view source.View, uri span.URI) (*referenceCollector, error) {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := source.NarrowestCheckPackageHandle(cphs).Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := pkg.File(uri)
+	if err != nil {
+		return nil, err
+	}
+	_, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newReferenceCollector(ctx, view, pkg, uri, m), nil
+}
+
+// target returns the symbol locator of the referenced object, it returns nil if the object can't be located, like the
+// builtin functions.
+func (c *referenceCollector) target(obj types.Object) *protocol.SymbolLocator {
+	if loc, ok := c.targets[obj]; ok {
+		return loc
+	}
+	var loc *protocol.SymbolLocator
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		imported := pkgName.Imported()
+		pkgLocator := c.pkgLocator(imported, packageFileOf(c.fset, imported))
+		loc = &protocol.SymbolLocator{
+			Qname:   qualifyQname(imported.Name(), imported, pkgLocator.Version, c.view.Options().QnameStyle),
+			Kind:    protocol.Package,
+			Package: pkgLocator,
+		}
+	} else if kind := getSymbolKind(obj); kind != 0 {
+		loc = &protocol.SymbolLocator{Qname: obj.Name(), Kind: kind}
+		if obj.Pkg() != nil && obj.Pos().IsValid() {
+			// The AST is looked up by the file declaring the object, while the package is located by the original file
+			// if the file is generated from one, like the cgo generated files.
+			declPath := c.fset.PositionFor(obj.Pos(), false).Filename
+			declURI := span.FileURI(declPath)
+			declAST, err := declFileAST(c.ctx, c.view, c.pkg, declURI)
+			if err == nil && isGeneratedFile(declAST) {
+				loc.Generated = true
+				if posn, ok := originalPosition(c.fset, obj.Pos()); ok {
+					declPath = posn.Filename
+				}
+			}
+			loc.Package = c.pkgLocator(obj.Pkg(), declPath)
+			if err == nil {
+				qname := getQName(declAST, obj, kind, c.ordinals(obj.Pkg(), declURI))
+				qname = truncateQname(qname, c.view.Options().MaxQnameDepth)
+				loc.Qname = qualifyQname(qname, obj.Pkg(), loc.Package.Version, c.view.Options().QnameStyle)
+			}
+		}
+	}
+	c.targets[obj] = loc
+	return loc
+}
+
+func (c *referenceCollector) ordinals(pkg *types.Package, uri span.URI) declOrdinals {
+	if ordinals, ok := c.pkgOrdinals[pkg]; ok {
+		return ordinals
+	}
+	ordinals := declPackageOrdinals(c.ctx, c.fset, c.pkg, uri)
+	c.pkgOrdinals[pkg] = ordinals
+	return ordinals
+}
+
+func (c *referenceCollector) pkgLocator(pkg *types.Package, loc string) protocol.PackageLocator {
+	if pkgLocator, ok := c.pkgs[pkg]; ok {
+		return pkgLocator
+	}
+	pkgLocator := collectPkgMetadata(pkg, c.view.Folder().Filename(), loc, c.view.Options())
+	c.pkgs[pkg] = pkgLocator
+	return pkgLocator
+}
+
+// reference converts the reference at the node to the protocol reference.
+func (c *referenceCollector) reference(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) (protocol.Reference, bool) {
+	target := c.target(obj)
+	if target == nil {
+		return protocol.Reference{}, false
+	}
+	rng, err := toProtocolRange(c.fset, c.m, n.Pos(), n.End())
+	if err != nil {
+		return protocol.Reference{}, false
+	}
+	ref := protocol.Reference{
+		Category: category,
+		Kind:     kind,
+		Loc:      protocol.Location{URI: protocol.NewURI(c.uri), Range: rng},
+		Target:   *target,
+	}
+	if enclosing != nil {
+		if rng, err := toProtocolRange(c.fset, c.m, enclosing.Pos(), enclosing.End()); err == nil {
+			ref.Symbol = protocol.SymbolInformation{
+				Name:     enclosing.Name,
+				Location: protocol.Location{URI: protocol.NewURI(c.uri), Range: rng},
+			}
+			if def := c.info.Defs[enclosing]; def != nil {
+				ref.Symbol.Kind = getSymbolKind(def)
+			}
+		}
+	}
+	return ref, true
+}
+
+// collectReferences collects the references in the document of the requested kinds, all the kinds are collected if
+// kinds is empty. The package level declarations of pkg are named by ordinals, the same as the symbols of the document.
+// The references to the local objects are excluded if excludeLocals is true. The references which the line directives
+// map to other files are reported once in the package, by the owner of the use-site, see useSiteOwners, and they're
+// excluded altogether if localOnly is true. The references are sorted by sortReferences.
+func collectReferences(ctx context.Context, view source.View, pkg source.Package, ordinals declOrdinals, uri span.URI, kinds []protocol.ReferenceKind, excludeLocals, localOnly bool) ([]protocol.Reference, error) {
+	ph, err := pkg.File(uri)
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[protocol.ReferenceKind]bool)
+	for _, kind := range kinds {
+		wanted[kind] = true
+		if kind == protocol.OtherReference {
+			wanted[protocol.ReadReference] = true
+			wanted[protocol.WriteReference] = true
+		}
+	}
+	want := func(kind protocol.ReferenceKind) bool {
+		return len(wanted) == 0 || wanted[kind]
+	}
+	c := newReferenceCollector(ctx, view, pkg, uri, m)
+	c.pkgOrdinals[pkg.GetTypes()] = ordinals
+	refs := []protocol.Reference{}
+	// The foreign use-sites of the references by their indexes in refs.
+	sites := make(map[int]useSite)
+	visitReferences(file, c.info, pkg.GetTypes(), func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) {
+		if !want(kind) || (excludeLocals && isLocalObject(obj)) {
+			return
+		}
+		site, foreign := foreignUseSite(c.fset, n, obj, kind)
+		if foreign && localOnly {
+			return
+		}
+		if ref, ok := c.reference(n, obj, kind, category, enclosing); ok {
+			if foreign {
+				sites[len(refs)] = site
+			}
+			refs = append(refs, ref)
+		}
+	})
+	if len(sites) > 0 {
+		owners := useSiteOwners(ctx, c.fset, pkg)
+		seen := make(map[useSite]bool)
+		owned := refs[:0]
+		for i, ref := range refs {
+			if site, ok := sites[i]; ok {
+				if owners[site] != uri || seen[site] {
+					continue
+				}
+				seen[site] = true
+			}
+			owned = append(owned, ref)
+		}
+		refs = owned
+	}
+	sortReferences(refs)
+	return refs, nil
+}
+
+// packageFileOf returns the path of a file of the package, which is used to resolve the version of the package.
+func packageFileOf(fset *token.FileSet, pkg *types.Package) string {
+	for _, name := range pkg.Scope().Names() {
+		if pos := pkg.Scope().Lookup(name).Pos(); pos.IsValid() {
+			return fset.Position(pos).Filename
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+const referencesSrc = `package p
+
+import "fmt"
+
+type Stringer interface{ String() string }
+
+type Base struct{}
+type Other struct{}
+type T struct {
+	Base
+	*Other
+	n int
+}
+
+func (t T) String() string { return fmt.Sprint(t.n) }
+
+func f() {
+	var t T
+	t.n = 1
+	p := &t.n
+	_ = p
+	t.String()
+	_ = Stringer(t)
+	for t.n = range []int{} {
+	}
+}
+`
+
+func checkReferencesSrc(t *testing.T) (*ast.File, *types.Info, *types.Package, *token.FileSet) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", referencesSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Implicits: make(map[ast.Node]types.Object),
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("p", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, info, pkg, fset
+}
+
+func TestWalkReferences(t *testing.T) {
+	file, info, _, fset := checkReferencesSrc(t)
+	type ref struct {
+		Line      int
+		Name      string
+		Kind      protocol.ReferenceKind
+		Category  protocol.ReferenceCategory
+		Enclosing string
+	}
+	var got []ref
+	walkReferences(file, info, func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) {
+		r := ref{Line: fset.Position(n.Pos()).Line, Name: obj.Name(), Kind: kind, Category: category}
+		if enclosing != nil {
+			r.Enclosing = enclosing.Name
+		}
+		got = append(got, r)
+	})
+	want := []ref{
+		{3, "fmt", protocol.ImportReference, protocol.READ, ""},
+		{5, "Stringer", protocol.DefinitionReference, protocol.UNCATEGORIZED, "Stringer"},
+		{5, "String", protocol.DefinitionReference, protocol.UNCATEGORIZED, "Stringer"},
+		{5, "string", protocol.TypeUseReference, protocol.READ, "Stringer"},
+		{7, "Base", protocol.DefinitionReference, protocol.UNCATEGORIZED, "Base"},
+		{8, "Other", protocol.DefinitionReference, protocol.UNCATEGORIZED, "Other"},
+		{9, "T", protocol.DefinitionReference, protocol.UNCATEGORIZED, "T"},
+		{10, "Base", protocol.DefinitionReference, protocol.UNCATEGORIZED, "T"},
+		{10, "Base", protocol.TypeUseReference, protocol.INHERIT, "T"},
+		{11, "Other", protocol.DefinitionReference, protocol.UNCATEGORIZED, "T"},
+		{11, "Other", protocol.TypeUseReference, protocol.INHERIT, "T"},
+		{12, "n", protocol.DefinitionReference, protocol.UNCATEGORIZED, "T"},
+		{12, "int", protocol.TypeUseReference, protocol.READ, "T"},
+		{15, "t", protocol.DefinitionReference, protocol.UNCATEGORIZED, "String"},
+		{15, "T", protocol.TypeUseReference, protocol.READ, "String"},
+		{15, "String", protocol.DefinitionReference, protocol.UNCATEGORIZED, "String"},
+		{15, "string", protocol.TypeUseReference, protocol.READ, "String"},
+		{15, "Sprint", protocol.CallReference, protocol.READ, "String"},
+		{15, "t", protocol.ReadReference, protocol.READ, "String"},
+		{15, "n", protocol.ReadReference, protocol.READ, "String"},
+		{17, "f", protocol.DefinitionReference, protocol.UNCATEGORIZED, "f"},
+		{18, "t", protocol.DefinitionReference, protocol.UNCATEGORIZED, "t"},
+		{18, "T", protocol.TypeUseReference, protocol.READ, "t"},
+		{19, "t", protocol.ReadReference, protocol.READ, "f"},
+		{19, "n", protocol.WriteReference, protocol.WRITE, "f"},
+		{20, "p", protocol.DefinitionReference, protocol.UNCATEGORIZED, "f"},
+		{20, "t", protocol.ReadReference, protocol.READ, "f"},
+		{20, "n", protocol.AddressTakenReference, protocol.WRITE, "f"},
+		{21, "p", protocol.ReadReference, protocol.READ, "f"},
+		{22, "t", protocol.ReadReference, protocol.READ, "f"},
+		{22, "String", protocol.CallReference, protocol.READ, "f"},
+		{23, "Stringer", protocol.TypeUseReference, protocol.READ, "f"},
+		{23, "t", protocol.ReadReference, protocol.READ, "f"},
+		{24, "t", protocol.ReadReference, protocol.READ, "f"},
+		{24, "n", protocol.WriteReference, protocol.WRITE, "f"},
+		{24, "int", protocol.TypeUseReference, protocol.READ, "f"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got references\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFindImplementsWitnesses(t *testing.T) {
+	file, info, pkg, _ := checkReferencesSrc(t)
+	var got []string
+	for _, w := range findImplementsWitnesses(file, info, pkg) {
+		got = append(got, w.name.Name+" "+w.iface.Name())
+	}
+	if want := []string{"T Stringer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got witnesses %v, want %v", got, want)
+	}
+}
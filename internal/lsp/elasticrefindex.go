@@ -0,0 +1,202 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// showReferencesCommand is the command of the resolved code lenses, its arguments are the locations of the
+// references.
+const showReferencesCommand = "elastic.references.show"
+
+// referenceIndex holds the references collected by the 'full' requests by the files they're found in, the references
+// of a file are replaced once the file is indexed again. It's persisted with the warm state, and the references of a
+// file are dropped once its content changes.
+type referenceIndex struct {
+	mu    sync.Mutex
+	files map[span.URI]indexedReferences
+}
+
+type indexedReferences struct {
+	// The hash of the content of the file, which validates the references restored from the warm state.
+	hash string
+	refs []indexedReference
+}
+
+type indexedReference struct {
+	Target string            `json:"target"`
+	Loc    protocol.Location `json:"location"`
+}
+
+// referenceKey identifies the symbol referred to across the repositories.
+func referenceKey(qname string, pkg protocol.PackageLocator) string {
+	return pkg.RepoURI + "\n" + pkg.Name + "\n" + qname
+}
+
+// update replaces the references of the file by the ones collected, the definitions aren't indexed.
+func (idx *referenceIndex) update(uri span.URI, hash string, refs []protocol.Reference) {
+	var indexed []indexedReference
+	for _, ref := range refs {
+		if ref.Kind == protocol.DefinitionReference || ref.Target.Qname == "" {
+			continue
+		}
+		indexed = append(indexed, indexedReference{Target: referenceKey(ref.Target.Qname, ref.Target.Package), Loc: ref.Loc})
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.files == nil {
+		idx.files = make(map[span.URI]indexedReferences)
+	}
+	idx.files[uri] = indexedReferences{hash: hash, refs: indexed}
+}
+
+// forget drops the references found in the files under the folder.
+func (idx *referenceIndex) forget(folder string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for uri := range idx.files {
+		if hostPaths.hasPrefix(uri.Filename(), folder) {
+			delete(idx.files, uri)
+		}
+	}
+}
+
+// locations returns the sorted locations of the references to the symbol of the key.
+func (idx *referenceIndex) locations(key string) []protocol.Location {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var locs []protocol.Location
+	for _, f := range idx.files {
+		for _, ref := range f.refs {
+			if ref.Target == key {
+				locs = append(locs, ref.Loc)
+			}
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		return protocol.CompareRange(locs[i].Range, locs[j].Range) < 0
+	})
+	return locs
+}
+
+// counts returns the number of the references to each symbol.
+func (idx *referenceIndex) counts() map[string]int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	counts := make(map[string]int)
+	for _, f := range idx.files {
+		for _, ref := range f.refs {
+			counts[ref.Target]++
+		}
+	}
+	return counts
+}
+
+// referenceLensData is the data of the code lenses, which locates the declaration annotated for the resolve step.
+type referenceLensData struct {
+	Qname   string                  `json:"qname"`
+	Package protocol.PackageLocator `json:"package"`
+}
+
+// CodeLens annotates the top-level declarations of the document with the number of the references to them. The
+// references are counted from the reference index, so they only cover the files indexed by the 'full' requests with
+// the references collected, or restored from the warm state.
+func (s *ElasticServer) CodeLens(ctx context.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	syms := s.indexedSymbols(ctx, view, uri)
+	if len(syms) == 0 {
+		return []protocol.CodeLens{}, nil
+	}
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	fh := view.Snapshot().Handle(ctx, f)
+	file, m, _, err := view.Session().Cache().ParseGoHandle(fh, source.ParseFull).Parse(ctx)
+	if file == nil {
+		return nil, err
+	}
+	fset := view.Session().Cache().FileSet()
+	// The symbols of the top-level declarations are located by the ranges of their names.
+	decls := make(map[protocol.Range]bool)
+	addName := func(id *ast.Ident) {
+		if id.Name == "_" {
+			return
+		}
+		if rng, err := toProtocolRange(fset, m, id.Pos(), id.End()); err == nil {
+			decls[rng] = true
+		}
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			addName(decl.Name)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					addName(spec.Name)
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						addName(name)
+					}
+				}
+			}
+		}
+	}
+	counts := s.references.counts()
+	lenses := []protocol.CodeLens{}
+	for _, sym := range syms {
+		if !decls[sym.Symbol.Location.Range] {
+			continue
+		}
+		n := counts[referenceKey(sym.Qname, sym.Package)]
+		lenses = append(lenses, protocol.CodeLens{
+			Range:   sym.Symbol.Location.Range,
+			Command: &protocol.Command{Title: referencesTitle(n)},
+			Data:    referenceLensData{Qname: sym.Qname, Package: sym.Package},
+		})
+	}
+	return lenses, nil
+}
+
+// ResolveCodeLens lists the locations of the references to the declaration annotated by the code lens as the
+// arguments of its command.
+func (s *ElasticServer) ResolveCodeLens(ctx context.Context, lens *protocol.CodeLens) (*protocol.CodeLens, error) {
+	// The data is decoded as a generic value by the protocol.
+	raw, err := json.Marshal(lens.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data referenceLensData
+	if err := json.Unmarshal(raw, &data); err != nil || data.Qname == "" {
+		return nil, fmt.Errorf("the code lens isn't annotated by the reference index")
+	}
+	locs := s.references.locations(referenceKey(data.Qname, data.Package))
+	resolved := *lens
+	resolved.Command = &protocol.Command{
+		Title:     referencesTitle(len(locs)),
+		Command:   showReferencesCommand,
+		Arguments: []interface{}{locs},
+	}
+	return &resolved, nil
+}
+
+func referencesTitle(n int) string {
+	if n == 1 {
+		return "1 reference"
+	}
+	return fmt.Sprintf("%d references", n)
+}
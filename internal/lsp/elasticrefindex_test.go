@@ -0,0 +1,75 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestReferenceCodeLens(t *testing.T) {
+	dir := newTestDir(t, "codelens", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\ntype T struct{ f int }\n\nfunc F() {\n\tG()\n\tG()\n}\n\nfunc G() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nvar V a.T\n\nfunc H() {\n\ta.F()\n\ta.G()\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a", "a.go")))
+	b := protocol.NewURI(span.FileURI(filepath.Join(dir, "b", "b.go")))
+	for _, uri := range []protocol.DocumentURI{a, b} {
+		if _, err := s.Full(ctx, &protocol.FullParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}, Reference: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The references collected partially aren't indexed.
+	if _, err := s.Full(ctx, &protocol.FullParams{
+		TextDocument:   protocol.TextDocumentIdentifier{URI: b},
+		Reference:      true,
+		ReferenceKinds: []protocol.ReferenceKind{protocol.CallReference},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	lenses, err := s.CodeLens(ctx, &protocol.CodeLensParams{TextDocument: protocol.TextDocumentIdentifier{URI: a}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := make(map[uint32]string)
+	for _, lens := range lenses {
+		titles[uint32(lens.Range.Start.Line)] = lens.Command.Title
+	}
+	// The fields aren't the top-level declarations.
+	want := map[uint32]string{2: "1 reference", 4: "1 reference", 9: "3 references"}
+	if len(titles) != len(want) {
+		t.Fatalf("got the code lenses %v, want %v", titles, want)
+	}
+	for line, title := range want {
+		if titles[line] != title {
+			t.Errorf("got %q at the line %d, want %q", titles[line], line, title)
+		}
+	}
+
+	for _, lens := range lenses {
+		if lens.Range.Start.Line != 9 {
+			continue
+		}
+		resolved, err := s.ResolveCodeLens(ctx, &lens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locs, ok := resolved.Command.Arguments[0].([]protocol.Location)
+		if resolved.Command.Command != showReferencesCommand || !ok || len(locs) != 3 || locs[0].URI != a || locs[2].URI != b {
+			t.Errorf("got the command %+v, want the 3 locations of the references to G", resolved.Command)
+		}
+	}
+	if _, err := s.ResolveCodeLens(ctx, &protocol.CodeLens{}); err == nil {
+		t.Errorf("the code lens without the data is expected to fail to be resolved")
+	}
+}
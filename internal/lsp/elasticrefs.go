@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	errors "golang.org/x/xerrors"
+)
+
+// WriteSCIPIndexesOfRefs writes the SCIP indexes of the git refs of the repository of the workspace folder in one
+// run, the index of each ref is written to the writer returned by create. The refs are checked out in turn into a
+// single detached worktree, which leaves the folder itself untouched, and the view of the worktree is kept across the
+// refs: only the files differing from the previous ref are rewritten by the checkout and invalidated in the session,
+// so that the type-checks of the unchanged packages and the module cache are shared by the refs. The view is created
+// again if the module files differ, like 'elastic/indexChanged' does. The indexes carry the folder as the project
+// root rather than the worktree.
+func WriteSCIPIndexesOfRefs(ctx context.Context, session source.Session, options source.Options, folder string, refs []string, create func(ref string) (io.WriteCloser, error)) error {
+	if len(refs) == 0 {
+		return errors.New("expected the git refs to index")
+	}
+	commits := make([]string, len(refs))
+	for i, ref := range refs {
+		stdout, err := runGitCommand(ctx, options, folder, options.Env, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+		if err != nil {
+			return errors.Errorf("%s is not a commit: %w", ref, err)
+		}
+		commits[i] = strings.TrimSpace(stdout.String())
+	}
+	stdout, err := runGitCommand(ctx, options, folder, options.Env, "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+	prefix := strings.TrimSpace(stdout.String())
+
+	worktree, err := ioutil.TempDir("", "gopls-refs")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(worktree)
+	if _, err := runGitCommand(ctx, options, folder, options.Env, "worktree", "add", "--detach", "-q", worktree, commits[0]); err != nil {
+		return err
+	}
+	defer func() {
+		// The worktree is pruned even if the context is done.
+		if _, err := runGitCommand(context.Background(), options, folder, options.Env, "worktree", "remove", "--force", worktree); err != nil {
+			log.Error(ctx, "failed to remove the worktree", err, tag.Of("Worktree", worktree))
+		}
+	}()
+
+	dir := filepath.Join(worktree, filepath.FromSlash(prefix))
+	name := filepath.Base(folder)
+	view := session.NewView(ctx, name, span.FileURI(dir), options)
+	defer func() { view.Shutdown(ctx) }()
+	for i, ref := range refs {
+		if i > 0 {
+			modChanged, err := checkoutRef(ctx, session, options, worktree, dir, commits[i-1], commits[i])
+			if err != nil {
+				return err
+			}
+			if modChanged {
+				// The dependencies may change along with the module files, which can't be told without loading the
+				// packages again.
+				view.Shutdown(ctx)
+				view = session.NewView(ctx, name, span.FileURI(dir), options)
+			}
+		}
+		w, err := create(ref)
+		if err != nil {
+			return err
+		}
+		if err := writeSCIPIndex(ctx, view, span.FileURI(folder), w); err != nil {
+			w.Close()
+			return errors.Errorf("failed to index %s: %w", ref, err)
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkoutRef checks out the commit in the worktree and invalidates the Go files of the directory differing from the
+// previous commit in the session, git runs under the environment of the folder. It reports whether the module files
+// of the directory differ.
+func checkoutRef(ctx context.Context, session source.Session, options source.Options, worktree, dir, from, to string) (bool, error) {
+	stdout, err := runGitCommand(ctx, options, worktree, options.Env, "diff", "--name-only", "--no-renames", "-z", from, to, "--")
+	if err != nil {
+		return false, err
+	}
+	existed := make(map[string]bool)
+	var changed []string
+	for _, name := range strings.Split(stdout.String(), "\x00") {
+		path := filepath.Join(worktree, filepath.FromSlash(name))
+		if name == "" || !hostPaths.hasPrefix(path, dir) {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			existed[path] = true
+		}
+		changed = append(changed, path)
+	}
+	if _, err := runGitCommand(ctx, options, worktree, options.Env, "checkout", "-q", "--detach", to); err != nil {
+		return false, err
+	}
+	modChanged := false
+	for _, path := range changed {
+		switch base := filepath.Base(path); {
+		case base == "go.mod" || base == "go.sum":
+			modChanged = true
+		case strings.HasSuffix(base, ".go"):
+			change := protocol.Changed
+			if _, err := os.Stat(path); err != nil {
+				change = protocol.Deleted
+			} else if !existed[path] {
+				change = protocol.Created
+			}
+			session.DidChangeOutOfBand(ctx, span.FileURI(path), change)
+		}
+	}
+	return modChanged, nil
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// closingBuffer is a buffer closed without effect.
+type closingBuffer struct{ bytes.Buffer }
+
+func (*closingBuffer) Close() error { return nil }
+
+func TestWriteSCIPIndexesOfRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is unavailable")
+	}
+	dir := newTestDir(t, "refs", nil)
+	defer os.RemoveAll(dir)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeTestFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.11\n",
+		"a/a.go": "package a\n\nfunc F() {}\n",
+	})
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	writeTestFiles(t, dir, map[string]string{
+		"a/a.go": "package a\n\nfunc F() { G() }\n",
+		"a/b.go": "package a\n\nfunc G() {}\n",
+	})
+	git("add", "-A")
+	git("commit", "-q", "-m", "v2")
+	// The uncommitted changes of the folder aren't indexed.
+	writeTestFiles(t, dir, map[string]string{"a/c.go": "package a\n"})
+
+	ctx := context.Background()
+	session := cache.New().NewSession(ctx)
+	options := source.DefaultOptions
+	session.SetOptions(options)
+	indexes := make(map[string]*closingBuffer)
+	if err := WriteSCIPIndexesOfRefs(ctx, session, options, dir, []string{"v1", "HEAD"}, func(ref string) (io.WriteCloser, error) {
+		indexes[ref] = &closingBuffer{}
+		return indexes[ref], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for ref, want := range map[string][]string{"v1": {"a/a.go"}, "HEAD": {"a/a.go", "a/b.go"}} {
+		index := decodeProto(t, indexes[ref].Bytes())
+		metadata := decodeProto(t, index[1][0])
+		if root := string(metadata[3][0]); root != string(protocol.NewURI(span.FileURI(dir))) {
+			t.Errorf("got the project root %s of %s, want %s", root, ref, dir)
+		}
+		var paths []string
+		for _, doc := range index[2] {
+			paths = append(paths, string(decodeProto(t, doc)[1][0]))
+		}
+		sort.Strings(paths)
+		if len(paths) != len(want) || paths[len(paths)-1] != want[len(want)-1] {
+			t.Errorf("got the documents %v of %s, want %v", paths, ref, want)
+		}
+	}
+	// The call of G is found once b.go is checked out along with the change of a.go.
+	if !bytes.Contains(indexes["HEAD"].Bytes(), []byte("a/G().")) {
+		t.Error("got no occurrence of a.G in the index of HEAD")
+	}
+	if len(session.Views()) != 0 {
+		t.Errorf("got %d views left, want none", len(session.Views()))
+	}
+}
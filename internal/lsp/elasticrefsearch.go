@@ -0,0 +1,114 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// References extends the references found by the embedded server, which only searches the package of the document,
+// by the references of all the workspace folders of the session. The references are matched by the qualified name
+// and the package of the symbol, so that the modules depending on different versions of the package are covered. The
+// reference index fills in the files out of the workspace folders, like the ones of the folders removed.
+func (s *ElasticServer) References(ctx context.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	locations, err := s.Server.References(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	obj, _, err := declAt(ctx, view, uri, params.Position)
+	// The unexported symbols can't be referred to by the other packages.
+	if err != nil || obj == nil || obj.Pkg() == nil || !obj.Exported() {
+		return locations, nil
+	}
+	c, err := s.fileCollector(ctx, view, uri)
+	if err != nil {
+		return locations, nil
+	}
+	target := c.target(obj)
+	if target == nil || target.Qname == "" {
+		return locations, nil
+	}
+	key := referenceKey(target.Qname, target.Package)
+
+	type location struct {
+		uri protocol.DocumentURI
+		rng protocol.Range
+	}
+	seen := make(map[location]bool)
+	for _, loc := range locations {
+		seen[location{loc.URI, loc.Range}] = true
+	}
+	var found []protocol.Location
+	add := func(loc protocol.Location) {
+		if !seen[location{loc.URI, loc.Range}] {
+			seen[location{loc.URI, loc.Range}] = true
+			found = append(found, loc)
+		}
+	}
+	searched := make(map[protocol.DocumentURI]bool)
+	for _, view := range s.session.Views() {
+		err := workspacePackages(ctx, view, func(pkg source.Package) {
+			for _, loc := range packageReferences(ctx, view, pkg, key, searched) {
+				add(loc)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The indexed references of the files searched above may be stale.
+	for _, loc := range s.references.locations(key) {
+		if !searched[loc.URI] {
+			add(loc)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].URI != found[j].URI {
+			return found[i].URI < found[j].URI
+		}
+		return protocol.CompareRange(found[i].Range, found[j].Range) < 0
+	})
+	return append(locations, found...), nil
+}
+
+// packageReferences returns the references of the package to the symbol of the key, the declarations are excluded.
+// The files of the package are marked as searched.
+func packageReferences(ctx context.Context, view source.View, pkg source.Package, key string, searched map[protocol.DocumentURI]bool) []protocol.Location {
+	var locations []protocol.Location
+	for _, ph := range pkg.Files() {
+		uri := ph.File().Identity().URI
+		file, m, _, err := ph.Cached(ctx)
+		if err != nil || file == nil {
+			continue
+		}
+		searched[protocol.NewURI(uri)] = true
+		c := newReferenceCollector(ctx, view, pkg, uri, m)
+		walkReferences(file, c.info, func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) {
+			if kind == protocol.DefinitionReference || !obj.Exported() {
+				return
+			}
+			target := c.target(obj)
+			if target == nil || referenceKey(target.Qname, target.Package) != key {
+				return
+			}
+			rng, err := toProtocolRange(c.fset, m, n.Pos(), n.End())
+			if err != nil {
+				return
+			}
+			locations = append(locations, protocol.Location{URI: protocol.NewURI(uri), Range: rng})
+		})
+	}
+	return locations
+}
@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCrossViewReferences(t *testing.T) {
+	dir := newTestDir(t, "refsearch", map[string]string{
+		"a/go.mod":  "module example.com/a\n",
+		"a/a.go":    "package a\n\nfunc F() {}\n\nfunc G() {\n\tF()\n}\n",
+		"b/go.mod":  "module example.com/b\n\nrequire example.com/a v0.0.0\n\nreplace example.com/a => ../a\n",
+		"b/b.go":    "package b\n\nimport \"example.com/a\"\n\nfunc H() {\n\ta.F()\n}\n",
+		"b/c/c.go":  "package c\n\nimport \"example.com/a\"\n\nvar V = a.F\n",
+		"b/d/d.go":  "package d\n\nfunc F() {}\n",
+		"b/d/d2.go": "package d\n\nfunc G() {\n\tF()\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	s, _ := newTestServer(ctx, filepath.Join(dir, "a"), "a", options)
+	s.session.NewView(ctx, "b", span.FileURI(filepath.Join(dir, "b")), options)
+
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a", "a.go")))
+	locations, err := s.References(ctx, &protocol.ReferenceParams{
+		Context: protocol.ReferenceContext{IncludeDeclaration: true},
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: a},
+			Position:     protocol.Position{Line: 2, Character: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The declaration and the use of the package itself come first, the unrelated d.F isn't matched.
+	want := []string{"a/a.go:2", "a/a.go:5", "b/b.go:5", "b/c/c.go:4"}
+	var got []string
+	for _, loc := range locations {
+		rel, err := filepath.Rel(dir, span.NewURI(loc.URI).Filename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%v", filepath.ToSlash(rel), loc.Range.Start.Line))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got the references %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got the reference %s, want %s", got[i], want[i])
+		}
+	}
+}
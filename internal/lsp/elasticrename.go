@@ -0,0 +1,300 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// Rename renames the symbol at the position across all the workspace folders of the session, the embedded server
+// checks the conflicts within the package of the document and renames the uses there. The symbols declared out of
+// the workspace folders, like the ones of the dependencies, are refused to be renamed. Renaming a method of an
+// interface renames the methods of the workspace implementing the interface as well, while renaming a method which
+// implements an interface of the workspace is refused, since the interface would no longer be implemented.
+func (s *ElasticServer) Rename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	obj, _, err := declAt(ctx, view, uri, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil && !s.inWorkspace(ctx, obj) {
+		return nil, errors.Errorf("%s is declared out of the workspace folders", obj.Name())
+	}
+	edit, err := s.Server.Rename(ctx, params)
+	// The symbols which can't be referred to by the other packages are covered by the embedded server.
+	if err != nil || obj == nil || obj.Pkg() == nil || !obj.Exported() {
+		return edit, err
+	}
+	c, err := s.fileCollector(ctx, view, uri)
+	if err != nil {
+		return nil, err
+	}
+	target := c.target(obj)
+	if target == nil || target.Qname == "" {
+		return edit, nil
+	}
+	keys := map[string]bool{referenceKey(target.Qname, target.Package): true}
+	if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+		if err := s.renamedMethods(ctx, fn, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make(map[string][]protocol.TextEdit)
+	if edit != nil && edit.Changes != nil {
+		for uri, edits := range *edit.Changes {
+			changes[uri] = append(changes[uri], edits...)
+		}
+	}
+	type location struct {
+		uri protocol.DocumentURI
+		rng protocol.Range
+	}
+	seen := make(map[location]bool)
+	for uri, edits := range changes {
+		for _, e := range edits {
+			seen[location{uri, e.Range}] = true
+		}
+	}
+	for _, view := range s.session.Views() {
+		var renameErr error
+		err := workspacePackages(ctx, view, func(pkg source.Package) {
+			for _, loc := range renamedReferences(ctx, view, pkg, keys) {
+				if !inFolder(span.NewURI(loc.URI).Filename(), view.Folder().Filename()) {
+					continue
+				}
+				// The references of the other packages can't refer to the unexported names.
+				if !ast.IsExported(params.NewName) && pkg.GetTypes().Path() != obj.Pkg().Path() {
+					renameErr = errors.Errorf("renaming %s to %s breaks the references of %s", obj.Name(), params.NewName, pkg.PkgPath())
+				}
+				if !seen[location{loc.URI, loc.Range}] {
+					seen[location{loc.URI, loc.Range}] = true
+					changes[loc.URI] = append(changes[loc.URI], protocol.TextEdit{Range: loc.Range, NewText: params.NewName})
+				}
+			}
+		})
+		if err == nil {
+			err = renameErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, edits := range changes {
+		sort.Slice(edits, func(i, j int) bool { return protocol.CompareRange(edits[i].Range, edits[j].Range) < 0 })
+	}
+	return &protocol.WorkspaceEdit{Changes: &changes}, nil
+}
+
+// inWorkspace reports whether the object is declared in one of the workspace folders.
+func (s *ElasticServer) inWorkspace(ctx context.Context, obj types.Object) bool {
+	fset := s.session.Cache().FileSet()
+	for _, view := range s.session.Views() {
+		if _, ok := workspaceLocation(ctx, view, fset, obj); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// renamedMethods adds the keys of the methods renamed along with the method, which are the methods of the workspace
+// implementing the interface if the method belongs to one. The types are checked by each view, so the types are
+// looked up again by the checks of the other views, and only the packages which are or import the package of the
+// method are searched.
+func (s *ElasticServer) renamedMethods(ctx context.Context, fn *types.Func, keys map[string]bool) error {
+	ifaceName := declaringInterface(fn)
+	recvName := receiverName(fn)
+	if ifaceName == nil && recvName == "" {
+		return nil
+	}
+	for _, view := range s.session.Views() {
+		var methodErr error
+		err := workspacePackages(ctx, view, func(pkg source.Package) {
+			if methodErr != nil {
+				return
+			}
+			declPkg := lookupPackage(pkg.GetTypes(), fn.Pkg().Path())
+			if declPkg == nil {
+				return
+			}
+			scope := pkg.GetTypes().Scope()
+			if ifaceName == nil {
+				// The method implementing an interface of the workspace is renamed by the interface.
+				recv := declPkg.Scope().Lookup(recvName)
+				if recv == nil {
+					return
+				}
+				for _, name := range scope.Names() {
+					tn, ok := scope.Lookup(name).(*types.TypeName)
+					if !ok || !types.IsInterface(tn.Type()) {
+						continue
+					}
+					iface := tn.Type().Underlying().(*types.Interface)
+					if hasMethod(iface, fn.Name()) && implementsInterface(recv.Type(), iface) {
+						methodErr = errors.Errorf("%s implements %s.%s, which is to be renamed instead", fn.Name(), pkg.PkgPath(), tn.Name())
+						return
+					}
+				}
+				return
+			}
+			tn, ok := declPkg.Scope().Lookup(ifaceName.Name()).(*types.TypeName)
+			if !ok || !types.IsInterface(tn.Type()) {
+				return
+			}
+			iface := tn.Type().Underlying().(*types.Interface)
+			for _, name := range scope.Names() {
+				impl, ok := scope.Lookup(name).(*types.TypeName)
+				if !ok || types.IsInterface(impl.Type()) || !implementsInterface(impl.Type(), iface) {
+					continue
+				}
+				obj, _, _ := types.LookupFieldOrMethod(impl.Type(), true, fn.Pkg(), fn.Name())
+				m, ok := obj.(*types.Func)
+				if !ok {
+					continue
+				}
+				if !s.inWorkspace(ctx, m) {
+					methodErr = errors.Errorf("%s of %s is declared out of the workspace folders", m.Name(), impl.Name())
+					return
+				}
+				if key, ok := methodKey(ctx, view, pkg, m); ok {
+					keys[key] = true
+				}
+			}
+		})
+		if err == nil {
+			err = methodErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// methodKey returns the key of the method as located by the package, which may be declared by the package or by one
+// of its imports.
+func methodKey(ctx context.Context, view source.View, pkg source.Package, m *types.Func) (string, bool) {
+	files := pkg.Files()
+	if len(files) == 0 {
+		return "", false
+	}
+	_, mapper, _, err := files[0].Cached(ctx)
+	if err != nil {
+		return "", false
+	}
+	c := newReferenceCollector(ctx, view, pkg, files[0].File().Identity().URI, mapper)
+	loc := c.target(m)
+	if loc == nil || loc.Qname == "" {
+		return "", false
+	}
+	return referenceKey(loc.Qname, loc.Package), true
+}
+
+// declaringInterface returns the named interface which declares the method, it's nil for the concrete methods.
+func declaringInterface(fn *types.Func) *types.TypeName {
+	if !types.IsInterface(fn.Type().(*types.Signature).Recv().Type()) {
+		return nil
+	}
+	scope := fn.Pkg().Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if iface, ok := tn.Type().Underlying().(*types.Interface); ok {
+			for i := 0; i < iface.NumExplicitMethods(); i++ {
+				if iface.ExplicitMethod(i) == fn {
+					return tn
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// receiverName returns the name of the named type of the receiver of the method, it's empty for the interface
+// methods.
+func receiverName(fn *types.Func) string {
+	t := fn.Type().(*types.Signature).Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok && !types.IsInterface(named) {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+// lookupPackage returns the package of the path among the package and its transitive imports.
+func lookupPackage(pkg *types.Package, path string) *types.Package {
+	seen := make(map[*types.Package]bool)
+	queue := []*types.Package{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p.Path() == path {
+			return p
+		}
+		for _, imp := range p.Imports() {
+			if !seen[imp] {
+				seen[imp] = true
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return nil
+}
+
+func hasMethod(iface *types.Interface, name string) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func implementsInterface(t types.Type, iface *types.Interface) bool {
+	return types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+// renamedReferences returns the locations of the declarations and the references of the package to the symbols of
+// the keys.
+func renamedReferences(ctx context.Context, view source.View, pkg source.Package, keys map[string]bool) []protocol.Location {
+	var locations []protocol.Location
+	for _, ph := range pkg.Files() {
+		uri := ph.File().Identity().URI
+		file, m, _, err := ph.Cached(ctx)
+		if err != nil || file == nil {
+			continue
+		}
+		c := newReferenceCollector(ctx, view, pkg, uri, m)
+		walkReferences(file, c.info, func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, category protocol.ReferenceCategory, enclosing *ast.Ident) {
+			if _, ok := n.(*ast.Ident); !ok || !obj.Exported() {
+				return
+			}
+			target := c.target(obj)
+			if target == nil || !keys[referenceKey(target.Qname, target.Package)] {
+				return
+			}
+			rng, err := toProtocolRange(c.fset, m, n.Pos(), n.End())
+			if err != nil {
+				return
+			}
+			locations = append(locations, protocol.Location{URI: protocol.NewURI(uri), Range: rng})
+		})
+	}
+	return locations
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestWorkspaceRename(t *testing.T) {
+	dir := newTestDir(t, "rename", map[string]string{
+		"a/go.mod": "module example.com/a\n",
+		"a/a.go":   "package a\n\ntype Namer interface {\n\tName() string\n}\n\nfunc Use(n Namer) string {\n\treturn n.Name()\n}\n",
+		"b/go.mod": "module example.com/b\n\nrequire example.com/a v0.0.0\n\nreplace example.com/a => ../a\n",
+		"b/b.go":   "package b\n\nimport (\n\t\"strings\"\n\n\t\"example.com/a\"\n)\n\ntype T struct{}\n\nfunc (T) Name() string { return strings.ToUpper(\"t\") }\n\nvar V = a.Use(T{})\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	s, _ := newTestServer(ctx, filepath.Join(dir, "a"), "a", options)
+	s.session.NewView(ctx, "b", span.FileURI(filepath.Join(dir, "b")), options)
+
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a", "a.go")))
+	b := protocol.NewURI(span.FileURI(filepath.Join(dir, "b", "b.go")))
+	rename := func(uri protocol.DocumentURI, line, character float64, newName string) (*protocol.WorkspaceEdit, error) {
+		return s.Rename(ctx, &protocol.RenameParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: line, Character: character},
+			NewName:      newName,
+		})
+	}
+
+	// The methods implementing the interface are renamed along with it.
+	edit, err := rename(a, 3, 1, "Title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for uri, edits := range *edit.Changes {
+		rel, err := filepath.Rel(dir, span.NewURI(uri).Filename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range edits {
+			got = append(got, fmt.Sprintf("%s:%v:%s", filepath.ToSlash(rel), e.Range.Start.Line, e.NewText))
+		}
+	}
+	sort.Strings(got)
+	want := []string{"a/a.go:3:Title", "a/a.go:7:Title", "b/b.go:10:Title"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got the edits %v, want %v", got, want)
+	}
+
+	for _, c := range []struct {
+		uri              protocol.DocumentURI
+		line, character  float64
+		newName, failure string
+	}{
+		{b, 10, 9, "Title", "Namer"},
+		{b, 10, 41, "Upper", "out of the workspace"},
+		{a, 6, 5, "use", "breaks the references of example.com/b"},
+	} {
+		if _, err := rename(c.uri, c.line, c.character, c.newName); err == nil || !strings.Contains(err.Error(), c.failure) {
+			t.Errorf("got the error %v renaming to %s, want the one containing %q", err, c.newName, c.failure)
+		}
+	}
+}
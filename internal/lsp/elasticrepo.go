@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// applyImportPathAlias rewrites the import path by the longest matching alias prefix. The prefix only matches the
+// complete path elements, i.e. the alias 'github.com/foo/bar' doesn't apply to 'github.com/foo/barbaz'.
+func applyImportPathAlias(path string, aliases map[string]string) string {
+	var longest string
+	for prefix := range aliases {
+		if len(prefix) <= len(longest) {
+			continue
+		}
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			longest = prefix
+		}
+	}
+	if longest == "" {
+		return path
+	}
+	return strings.TrimSuffix(aliases[longest], "/") + strings.TrimPrefix(path, strings.TrimSuffix(longest, "/"))
+}
+
+// redirectClient is used to detect the redirects of the repository URLs, the timeout prevents a slow code host from
+// blocking the requests.
+var redirectClient = &http.Client{Timeout: 5 * time.Second}
+
+// resolveRepoRedirect follows the HTTP redirects of the repository URL, like the repositories moved to another owner
+// or host, and returns the URL of the live repository. If the repository URL can't be resolved, it will be returned
+// as is.
+func resolveRepoRedirect(repoURL string) string {
+	if !strings.HasPrefix(repoURL, "https://") && !strings.HasPrefix(repoURL, "http://") {
+		return repoURL
+	}
+	// The repositories are unlikely to move during the lifetime of the server.
+	v, _ := sharedMetadata.load(nameKey(repoRedirectKind, repoURL), func() (interface{}, error) {
+		live := repoURL
+		if resp, err := redirectClient.Head(repoURL); err == nil {
+			resp.Body.Close()
+			if final := resp.Request.URL; resp.StatusCode < 400 && final != nil {
+				final.RawQuery = ""
+				final.Fragment = ""
+				if u := strings.TrimSuffix(final.String(), "/"); u != strings.TrimSuffix(repoURL, "/") {
+					live = u
+				}
+			}
+		}
+		return live, nil
+	})
+	return v.(string)
+}
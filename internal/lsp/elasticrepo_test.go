@@ -0,0 +1,44 @@
+package lsp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApplyImportPathAlias(t *testing.T) {
+	aliases := map[string]string{
+		"github.com/old/repo":       "github.com/new/repo",
+		"github.com/old/repo/sub":   "gitlab.com/sub/repo",
+		"example.com/vanity/":       "github.com/owner/vanity",
+		"github.com/old/repository": "github.com/new/repository",
+	}
+	for path, want := range map[string]string{
+		"github.com/old/repo":         "github.com/new/repo",
+		"github.com/old/repo/pkg":     "github.com/new/repo/pkg",
+		"github.com/old/repo/sub/pkg": "gitlab.com/sub/repo/pkg",
+		"github.com/old/repoX":        "github.com/old/repoX",
+		"example.com/vanity/x":        "github.com/owner/vanity/x",
+		"golang.org/x/tools":          "golang.org/x/tools",
+	} {
+		if got := applyImportPathAlias(path, aliases); got != want {
+			t.Errorf("applyImportPathAlias(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestResolveRepoRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old/repo" {
+			http.Redirect(w, r, "/new/repo", http.StatusMovedPermanently)
+		}
+	}))
+	defer srv.Close()
+
+	if got, want := resolveRepoRedirect(srv.URL+"/old/repo"), srv.URL+"/new/repo"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got, want := resolveRepoRedirect(srv.URL+"/live/repo"), srv.URL+"/live/repo"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
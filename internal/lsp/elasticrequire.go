@@ -0,0 +1,165 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// depsRequireCommand adds the requirements of the modules providing the packages imported by a file to the 'go.mod'
+// of its module, by 'go mod tidy -e', and reloads the view of the module. It takes exactly one file URI.
+const depsRequireCommand = "elastic.deps.require"
+
+// CodeAction offers the requirement of the missing modules as a quick fix once the document imports the packages
+// which no module of the 'go.mod' provides, along with the code actions of the embedded server.
+func (s *ElasticServer) CodeAction(ctx context.Context, params *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
+	// The missing modules are looked up first, since the go commands run by the embedded server may update the
+	// 'go.mod' on their own.
+	uri := span.NewURI(params.TextDocument.URI)
+	missing := s.missingRequires(ctx, uri)
+	actions, err := s.Server.CodeAction(ctx, params)
+	if len(missing) == 0 || !wantsQuickFix(params.Context.Only) {
+		return actions, err
+	}
+	// The embedded server fails to fix the imports of the packages which can't be loaded.
+	if err != nil {
+		log.Error(ctx, "failed to collect the code actions", err, tag.Of("File", uri))
+	}
+	var diagnostics []protocol.Diagnostic
+	for _, diag := range params.Context.Diagnostics {
+		for _, path := range missing {
+			if strings.Contains(diag.Message, path) {
+				diagnostics = append(diagnostics, diag)
+				break
+			}
+		}
+	}
+	title := fmt.Sprintf("Add the module requirements of %s", strings.Join(missing, ", "))
+	return append(actions, protocol.CodeAction{
+		Title:       title,
+		Kind:        protocol.QuickFix,
+		Diagnostics: diagnostics,
+		Command: &protocol.Command{
+			Title:     title,
+			Command:   depsRequireCommand,
+			Arguments: []interface{}{params.TextDocument.URI},
+		},
+	}), nil
+}
+
+func wantsQuickFix(only []protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, kind := range only {
+		if kind == protocol.QuickFix {
+			return true
+		}
+	}
+	return false
+}
+
+// missingRequires returns the import paths of the file which no module required by the 'go.mod' of the module of the
+// file provides, the standard library and the packages of the module itself are never missing.
+func (s *ElasticServer) missingRequires(ctx context.Context, uri span.URI) []string {
+	view := s.session.ViewOf(uri)
+	if view.Options().VendorMode {
+		return nil
+	}
+	modDir := moduleDir(view.Folder().Filename(), uri.Filename())
+	if modDir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(modDir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil
+	}
+	file, _, _, err := view.Session().Cache().ParseGoHandle(view.Snapshot().Handle(ctx, f), source.ParseHeader).Parse(ctx)
+	if file == nil {
+		return nil
+	}
+	var provided []string
+	if match := moduleDirectiveRx.FindSubmatch(data); match != nil {
+		provided = append(provided, string(match[1]))
+	}
+	for _, req := range parseGoModRequires(data) {
+		provided = append(provided, req.Path)
+	}
+	var missing []string
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		// The paths of the standard library have no dots in their first elements.
+		if err != nil || !strings.Contains(strings.SplitN(path, "/", 2)[0], ".") || providedBy(path, provided) {
+			continue
+		}
+		missing = append(missing, path)
+	}
+	return missing
+}
+
+// providedBy reports whether the package of the path belongs to one of the modules.
+func providedBy(path string, modules []string) bool {
+	for _, mod := range modules {
+		if mod != "" && (path == mod || strings.HasPrefix(path, mod+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleDir returns the innermost folder containing the 'go.mod' which the file belongs to, the folders out of the
+// workspace folder aren't looked up. It's empty if there is no such folder.
+func moduleDir(folder, path string) string {
+	for dir := filepath.Dir(path); hostPaths.hasPrefix(dir, folder); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	return ""
+}
+
+// requireModules runs 'go mod tidy -e' in the module of the file, which requires the modules providing the packages
+// imported, and reloads the view of the module.
+func (s *ElasticServer) requireModules(ctx context.Context, params *protocol.ExecuteCommandParams) (protocol.ElasticCommandResult, error) {
+	result := protocol.ElasticCommandResult{Folders: []protocol.DocumentURI{}}
+	if len(params.Arguments) != 1 {
+		return result, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected one file URI for %s, got %v", params.Command, params.Arguments)
+	}
+	arg, ok := params.Arguments[0].(string)
+	if !ok {
+		return result, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected one file URI for %s, got %v", params.Command, params.Arguments)
+	}
+	uri := span.NewURI(arg)
+	view := s.session.ViewOf(uri)
+	options := view.Options()
+	if options.Offline || options.VendorMode {
+		return result, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidRequest, "the folder of %s can't download the dependencies", uri.Filename())
+	}
+	modDir := moduleDir(view.Folder().Filename(), uri.Filename())
+	if modDir == "" {
+		return result, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%s belongs to no module of the workspace folders", uri.Filename())
+	}
+	env := append(append(append([]string{}, options.Env...), credentialEnv(options.Credentials)...), "GOFLAGS=-mod=mod")
+	if _, err := runGoCommand(ctx, options, modDir, env, "mod", "tidy", "-e"); err != nil {
+		return result, err
+	}
+	return s.rebuildFolders(ctx, []protocol.WorkspaceFolder{{URI: protocol.NewURI(view.Folder()), Name: view.Name()}})
+}
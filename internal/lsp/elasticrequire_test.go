@@ -0,0 +1,70 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestRequireModules(t *testing.T) {
+	dir := newTestDir(t, "require", map[string]string{
+		"go.mod":     "module example.com/m\n\nreplace example.com/dep => ./dep\n",
+		"a.go":       "package a\n\nimport (\n\t\"strings\"\n\n\t\"example.com/dep\"\n\t\"example.com/m/b\"\n)\n\nvar V = strings.ToUpper(dep.X + b.Y)\n",
+		"b/b.go":     "package b\n\nconst Y = \"y\"\n",
+		"dep/go.mod": "module example.com/dep\n",
+		"dep/dep.go": "package dep\n\nconst X = \"x\"\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.ConfigurationSupported = false
+	options.Env = append(append([]string{}, options.Env...), "GOPROXY=off")
+	s, _ := newTestServer(ctx, dir, "m", options)
+	s.state = serverInitialized
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))
+
+	actions, err := s.CodeAction(ctx, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: a},
+		Context: protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{
+			{Message: "could not import example.com/dep"},
+			{Message: "undeclared name: x"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var require *protocol.CodeAction
+	for i := range actions {
+		if actions[i].Command != nil && actions[i].Command.Command == depsRequireCommand {
+			require = &actions[i]
+		}
+	}
+	if require == nil || !strings.HasSuffix(require.Title, "example.com/dep") || len(require.Diagnostics) != 1 {
+		t.Fatalf("got the code actions %+v, want the one requiring example.com/dep only", actions)
+	}
+
+	if _, err := s.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: depsRequireCommand}); err == nil {
+		t.Errorf("the command without a file is expected to be rejected")
+	}
+	if _, err := s.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: require.Command.Command, Arguments: require.Command.Arguments}); err != nil {
+		t.Fatal(err)
+	}
+	s.warmUps.stop()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "example.com/dep v0.0.0") {
+		t.Errorf("got the go.mod %q, want example.com/dep required", data)
+	}
+	if missing := s.missingRequires(ctx, span.NewURI(a)); len(missing) != 0 {
+		t.Errorf("got the missing modules %v, want none", missing)
+	}
+}
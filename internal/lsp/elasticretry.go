@@ -0,0 +1,52 @@
+package lsp
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// maxDownloadBackoff caps the exponential backoff of the retries.
+const maxDownloadBackoff = time.Minute
+
+// retryPolicy decides how the failed downloads are retried. Only the transient failures, i.e. the ones caused by the
+// proxies, are retried with the exponential backoff, the permanent ones, like the missing modules, would fail again.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+// delay returns the backoff before the retry following the attempt, which starts from zero.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 0; i < attempt && d < maxDownloadBackoff; i++ {
+		d *= 2
+	}
+	if d > maxDownloadBackoff {
+		d = maxDownloadBackoff
+	}
+	return d
+}
+
+// downloadWithRetry downloads the dependencies of the module located at dir by downloadModules, and retries the
+// transient failures by the policy. It returns the number of the attempts made along with the last error.
+func downloadWithRetry(ctx context.Context, options source.Options, dir string, env []string, proxies []string, health *proxyHealth, cooldown time.Duration, policy retryPolicy) (int, error) {
+	for attempt := 0; ; attempt++ {
+		err := downloadModules(ctx, options, dir, env, proxies, health, cooldown)
+		if err == nil || !isProxyFailure(err) || attempt >= policy.retries {
+			return attempt + 1, err
+		}
+		delay := policy.delay(attempt)
+		log.Print(ctx, "retrying the download", tag.Of("Folder", dir), tag.Of("Attempt", attempt+1), tag.Of("Delay", delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt + 1, err
+		case <-timer.C:
+		}
+	}
+}
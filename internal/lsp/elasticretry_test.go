@@ -0,0 +1,65 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{retries: 10, backoff: time.Second}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if got := policy.delay(attempt); got != want {
+			t.Errorf("got the delay %v after the attempt %d, want %v", got, attempt, want)
+		}
+	}
+	if got := policy.delay(100); got != maxDownloadBackoff {
+		t.Errorf("got the delay %v, want the cap %v", got, maxDownloadBackoff)
+	}
+}
+
+func TestDownloadWithRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mod := "module example.com/proj\n\nrequire example.com/dep v1.0.0\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer outage.Close()
+	missing := httptest.NewServer(http.HandlerFunc(http.NotFound))
+	defer missing.Close()
+
+	ctx := context.Background()
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOSUMDB=off", "GOMODCACHE="+filepath.Join(dir, "modcache"))
+	policy := retryPolicy{retries: 2, backoff: time.Millisecond}
+	// The transient failures are retried until the retries are exhausted.
+	attempts, err := downloadWithRetry(ctx, source.DefaultOptions, dir, env, []string{outage.URL}, &proxyHealth{}, time.Minute, policy)
+	if err == nil || !isProxyFailure(err) || attempts != 3 {
+		t.Errorf("got %d attempts with %v, want 3 attempts failed transiently", attempts, err)
+	}
+	// The permanent failures are never retried.
+	attempts, err = downloadWithRetry(ctx, source.DefaultOptions, dir, env, []string{missing.URL}, &proxyHealth{}, time.Minute, policy)
+	if err == nil || isProxyFailure(err) || attempts != 1 {
+		t.Errorf("got %d attempts with %v, want 1 attempt failed permanently", attempts, err)
+	}
+	// The retries stop once the context is done.
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	attempts, _ = downloadWithRetry(ctx, source.DefaultOptions, dir, env, []string{outage.URL}, &proxyHealth{}, time.Minute, retryPolicy{retries: 5, backoff: time.Hour})
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want the retries to stop at the cancellation", attempts)
+	}
+}
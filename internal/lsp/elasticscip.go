@@ -0,0 +1,275 @@
+package lsp
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/debug"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// The roles of the SCIP occurrences, see 'SymbolRole' of 'scip.proto'.
+const (
+	scipDefinition  = 0x1
+	scipImport      = 0x2
+	scipWriteAccess = 0x4
+	scipReadAccess  = 0x8
+)
+
+// scipUTF16 is the 'TextEncoding' of the SCIP metadata, the ranges are measured in the UTF-16 code units like the LSP
+// positions.
+const scipUTF16 = 2
+
+// WriteSCIPIndex writes the SCIP index of the workspace folder of the view, i.e. the 'Index' message of 'scip.proto'
+// encoded by the protobuf wire format, which is usually saved as 'index.scip'. The documents are indexed by the same
+// symbols and references as the 'full' requests, the qualified names are mapped to the SCIP symbols and the package
+// locators to the SCIP packages. The files of the packages failing to be checked are skipped.
+func WriteSCIPIndex(ctx context.Context, view source.View, w io.Writer) error {
+	return writeSCIPIndex(ctx, view, view.Folder(), w)
+}
+
+// writeSCIPIndex writes the SCIP index of the view under the project root, which differs from the folder of the view
+// if the folder is a checkout of the project root.
+func writeSCIPIndex(ctx context.Context, view source.View, root span.URI, w io.Writer) error {
+	folder := view.Folder().Filename()
+	var files []string
+	if err := walkGoFiles(folder, func(path string) { files = append(files, path) }); err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var index protoMessage
+	var metadata, toolInfo protoMessage
+	toolInfo.string(1, "gopls")
+	toolInfo.string(2, debug.Version)
+	// The metadata has no room for the version of the shapes of the symbols, it's told by the arguments of the tool.
+	toolInfo.string(3, "-schema-version="+strconv.Itoa(protocol.IndexSchemaVersion))
+	metadata.message(2, toolInfo)
+	metadata.string(3, string(protocol.NewURI(root)))
+	metadata.uint(4, scipUTF16)
+	index.message(1, metadata)
+
+	// The files are indexed in parallel, each of them collects the symbols apart, and the documents are written in the
+	// order of the files.
+	docs := make([]protoMessage, len(files))
+	fileDefined := make([]map[string]bool, len(files))
+	fileReferred := make([]map[string]string, len(files))
+	if err := newPackageLoader(view.Options().LoadParallelism, nil).run(ctx, len(files), func(ctx context.Context, i int) error {
+		defined, referred := make(map[string]bool), make(map[string]string)
+		doc, err := scipDocument(ctx, view, folder, files[i], defined, referred)
+		if err != nil {
+			log.Error(ctx, "failed to index the file by SCIP", err, tag.Of("File", files[i]))
+			return nil
+		}
+		docs[i], fileDefined[i], fileReferred[i] = doc, defined, referred
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defined := make(map[string]bool)
+	external := make(map[string]string)
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		index.message(2, doc)
+		for symbol := range fileDefined[i] {
+			defined[symbol] = true
+		}
+		for symbol, name := range fileReferred[i] {
+			external[symbol] = name
+		}
+	}
+	// The symbols referred to but declared out of the workspace folder, like the ones of the dependencies.
+	var symbols []string
+	for symbol := range external {
+		if !defined[symbol] {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		index.message(3, scipSymbolInformation(symbol, external[symbol]))
+	}
+	_, err := w.Write(index)
+	return err
+}
+
+// scipDocument returns the 'Document' message of the file, the symbols defined by the file are added to defined, and
+// the symbols referred to are added to referred along with their display names.
+func scipDocument(ctx context.Context, view source.View, folder, path string, defined map[string]bool, referred map[string]string) (protoMessage, error) {
+	uri := span.FileURI(path)
+	pkg, ordinals, syms, err := fileSymbols(ctx, view, folder, path)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := collectReferences(ctx, view, pkg, ordinals, uri, nil, false, false)
+	if err != nil {
+		return nil, err
+	}
+	// The kinds of the enclosing declarations tell the descriptors of the components of the qualified names.
+	kinds := make(map[string]protocol.SymbolKind)
+	for _, sym := range syms {
+		kinds[sym.Qname] = sym.Symbol.Kind
+	}
+
+	type occurrence struct {
+		rng    protocol.Range
+		symbol string
+		roles  uint64
+	}
+	var occurrences []occurrence
+	var symbols protoMessage
+	for _, sym := range syms {
+		symbol := scipSymbol(sym.Qname, sym.Symbol.Kind, sym.Package, kinds)
+		defined[symbol] = true
+		occurrences = append(occurrences, occurrence{sym.Symbol.Location.Range, symbol, scipDefinition})
+		symbols.message(3, scipSymbolInformation(symbol, sym.Symbol.Name))
+	}
+	for _, ref := range refs {
+		// The builtins belong to no package.
+		if ref.Kind == protocol.DefinitionReference || ref.Target.Qname == "" || ref.Target.Package.Name == "" {
+			continue
+		}
+		symbol := scipSymbol(ref.Target.Qname, ref.Target.Kind, ref.Target.Package, kinds)
+		referred[symbol] = lastQnameComponent(ref.Target.Qname)
+		roles := uint64(scipReadAccess)
+		switch {
+		case ref.Kind == protocol.ImportReference:
+			roles = scipImport
+		case ref.Category == protocol.WRITE:
+			roles = scipWriteAccess
+		}
+		occurrences = append(occurrences, occurrence{ref.Loc.Range, symbol, roles})
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return protocol.CompareRange(occurrences[i].rng, occurrences[j].rng) < 0
+	})
+
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return nil, err
+	}
+	var doc protoMessage
+	doc.string(1, filepath.ToSlash(rel))
+	for _, o := range occurrences {
+		var msg protoMessage
+		msg.packed(1, scipRange(o.rng))
+		msg.string(2, o.symbol)
+		msg.uint(3, o.roles)
+		doc.message(2, msg)
+	}
+	doc = append(doc, symbols...)
+	doc.string(4, "go")
+	return doc, nil
+}
+
+// fileSymbols checks the narrowest package of the file in the workspace folder and returns the symbols of the file
+// like the 'full' requests.
+func fileSymbols(ctx context.Context, view source.View, folder, path string) (source.Package, declOrdinals, []protocol.DetailSymbolInformation, error) {
+	uri := span.FileURI(path)
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pkg, err := source.NarrowestCheckPackageHandle(cphs).Check(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pkgLocator := collectPkgMetadata(pkg.GetTypes(), folder, path, view.Options())
+	ordinals := widestDeclOrdinals(ctx, view.Session().Cache().FileSet(), cphs)
+	syms, err := constructDetailSymbol(ctx, view, pkg, ordinals, protocol.NewURI(uri), &pkgLocator)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pkg, ordinals, syms, nil
+}
+
+// scipSymbolInformation returns the 'SymbolInformation' message of the symbol.
+func scipSymbolInformation(symbol, displayName string) protoMessage {
+	var info protoMessage
+	info.string(1, symbol)
+	info.string(6, displayName)
+	return info
+}
+
+// scipRange returns the range in the SCIP form, i.e. the start line, the start character, the end line if it differs
+// from the start line, and the end character.
+func scipRange(rng protocol.Range) []uint64 {
+	if rng.Start.Line == rng.End.Line {
+		return []uint64{uint64(rng.Start.Line), uint64(rng.Start.Character), uint64(rng.End.Character)}
+	}
+	return []uint64{uint64(rng.Start.Line), uint64(rng.Start.Character), uint64(rng.End.Line), uint64(rng.End.Character)}
+}
+
+// scipSymbol maps the qualified name to the SCIP symbol, like 'scip-go gomod github.com/a/b v1.0.0 pkg/T#M().'. The
+// package name leads the descriptors as the namespace, and the components of the qualified name are suffixed by
+// their kinds, the components declared out of the document are taken as the types.
+func scipSymbol(qname string, kind protocol.SymbolKind, pkg protocol.PackageLocator, kinds map[string]protocol.SymbolKind) string {
+	// The import path leading the qualified names of the import path styles has the dots of its last element escaped.
+	start := strings.LastIndex(qname, "/") + 1
+	components := strings.Split(qname[start:], ".")
+	components[0] = qname[:start] + components[0]
+	var descriptors strings.Builder
+	descriptors.WriteString(scipEscape(pkg.Name) + "/")
+	for i := 1; i < len(components); i++ {
+		k, ok := kinds[strings.Join(components[:i+1], ".")]
+		if i == len(components)-1 {
+			k, ok = kind, true
+		}
+		if !ok {
+			k = protocol.Struct
+		}
+		descriptors.WriteString(scipEscape(components[i]) + scipSuffix(k))
+	}
+	return strings.Join([]string{"scip-go", "gomod", scipPackageField(pkg.RepoURI), scipPackageField(pkg.Version), descriptors.String()}, " ")
+}
+
+func scipSuffix(kind protocol.SymbolKind) string {
+	switch kind {
+	case protocol.Function, protocol.Method:
+		return "()."
+	case protocol.Struct, protocol.Interface, protocol.Class, protocol.TypeParameter:
+		return "#"
+	case protocol.Package, protocol.Module, protocol.Namespace:
+		return "/"
+	}
+	return "."
+}
+
+// scipPackageField escapes the spaces of the field of the SCIP package by doubling them, the empty field is a dot.
+func scipPackageField(s string) string {
+	if s == "" {
+		return "."
+	}
+	return strings.Replace(s, " ", "  ", -1)
+}
+
+// scipEscape quotes the name of the descriptor by the backticks unless it's a simple identifier.
+func scipEscape(name string) string {
+	for _, r := range name {
+		if !(r == '_' || r == '+' || r == '-' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return "`" + strings.Replace(name, "`", "``", -1) + "`"
+		}
+	}
+	return name
+}
+
+func lastQnameComponent(qname string) string {
+	return qname[strings.LastIndex(qname, ".")+1:]
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// decodeProto decodes the length-delimited fields of the message by the field numbers, the varint fields are skipped.
+func decodeProto(t *testing.T, msg []byte) map[uint64][][]byte {
+	fields := make(map[uint64][][]byte)
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(msg)
+			msg = msg[n:]
+		case 2:
+			size, n := binary.Uvarint(msg)
+			fields[key>>3] = append(fields[key>>3], msg[n:n+int(size)])
+			msg = msg[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func TestWriteSCIPIndex(t *testing.T) {
+	dir := newTestDir(t, "scip", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package a\n\nimport \"strings\"\n\ntype T struct{}\n\nfunc (T) M() {}\n\nfunc F() string {\n\tT{}.M()\n\treturn strings.ToUpper(\"f\")\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	_, view := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	var buf bytes.Buffer
+	if err := WriteSCIPIndex(ctx, view, &buf); err != nil {
+		t.Fatal(err)
+	}
+	index := decodeProto(t, buf.Bytes())
+	metadata := decodeProto(t, index[1][0])
+	if root := string(metadata[3][0]); root != string(protocol.NewURI(span.FileURI(dir))) {
+		t.Errorf("got the project root %s, want %s", root, dir)
+	}
+	toolInfo := decodeProto(t, metadata[2][0])
+	if args := toolInfo[3]; len(args) != 1 || string(args[0]) != fmt.Sprintf("-schema-version=%d", protocol.IndexSchemaVersion) {
+		t.Errorf("got the arguments %q of the tool, want the schema version", args)
+	}
+	if len(index[2]) != 1 {
+		t.Fatalf("got %d documents, want 1", len(index[2]))
+	}
+	doc := decodeProto(t, index[2][0])
+	if path := string(doc[1][0]); path != "a.go" {
+		t.Errorf("got the relative path %s, want a.go", path)
+	}
+	occurrences := make(map[string]int)
+	for _, occurrence := range doc[2] {
+		occurrences[string(decodeProto(t, occurrence)[2][0])]++
+	}
+	// Both the definition and the call of the method.
+	if method := "scip-go gomod example.com/m . a/T#M()."; occurrences[method] != 2 {
+		t.Errorf("got the occurrences %v, want 2 of %s", occurrences, method)
+	}
+	var external []string
+	for _, info := range index[3] {
+		external = append(external, string(decodeProto(t, info)[1][0]))
+	}
+	// The imported package and the function of the package, the builtin string type is left out.
+	if len(external) != 2 || !strings.HasSuffix(external[0], " strings/") || !strings.HasSuffix(external[1], " strings/ToUpper().") {
+		t.Errorf("got the external symbols %v, want strings and strings.ToUpper", external)
+	}
+}
@@ -0,0 +1,194 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// semanticTokenTypes is the legend of the types of the semantic tokens, which follow the standard token types of LSP.
+var semanticTokenTypes = []string{
+	"namespace", "type", "interface", "struct", "parameter", "variable", "property", "function", "method", "label",
+	"keyword", "comment", "string", "number", "operator",
+}
+
+const (
+	semanticNamespace = iota
+	semanticType
+	semanticInterface
+	semanticStruct
+	semanticParameter
+	semanticVariable
+	semanticProperty
+	semanticFunction
+	semanticMethod
+	semanticLabel
+	semanticKeyword
+	semanticComment
+	semanticString
+	semanticNumber
+	semanticOperator
+)
+
+// semanticTokenModifiers is the legend of the modifiers of the semantic tokens, the modifiers of a token are encoded
+// as the bit set of the indexes.
+var semanticTokenModifiers = []string{"declaration", "readonly", "defaultLibrary"}
+
+const (
+	semanticDeclaration = 1 << iota
+	semanticReadonly
+	semanticDefaultLibrary
+)
+
+// SemanticTokensFull classifies the tokens of the document by the resolved kinds of the identifiers, so that the
+// document can be highlighted without parsing it on the client side.
+func (s *ElasticServer) SemanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	return s.semanticTokens(ctx, span.NewURI(params.TextDocument.URI), nil)
+}
+
+// SemanticTokensRange classifies the tokens of the document within the range, see SemanticTokensFull.
+func (s *ElasticServer) SemanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	return s.semanticTokens(ctx, span.NewURI(params.TextDocument.URI), &params.Range)
+}
+
+// semanticTokens encodes the semantic tokens of the document within the range, all the tokens are encoded if the range
+// is nil. The identifiers which can't be resolved are skipped.
+func (s *ElasticServer) semanticTokens(ctx context.Context, uri span.URI, within *protocol.Range) (*protocol.SemanticTokens, error) {
+	resp := &protocol.SemanticTokens{Data: []uint32{}}
+	tf, err := s.checkTokenFile(ctx, s.session.ViewOf(uri), uri)
+	if err != nil {
+		return resp, err
+	}
+	info := tf.pkg.GetTypesInfo()
+	params := parameterObjects(tf.file, info)
+	decls := make(map[token.Pos]bool)
+	for id, obj := range info.Defs {
+		if obj != nil && tf.fset.File(id.Pos()) == tf.tok {
+			decls[id.Pos()] = true
+		}
+	}
+	var prev protocol.Position
+	for _, t := range scanTokens(tf.src) {
+		start := tf.tok.Pos(t.offset)
+		rng, err := toProtocolRange(tf.fset, tf.m, start, start+token.Pos(t.length))
+		if err != nil {
+			continue
+		}
+		if within != nil && (positionBefore(rng.Start, within.Start) || !positionBefore(rng.Start, within.End)) {
+			continue
+		}
+		typ, modifiers := -1, 0
+		switch t.typ {
+		case tokenKeyword:
+			typ = semanticKeyword
+		case tokenString:
+			typ = semanticString
+		case tokenComment:
+			typ = semanticComment
+		case tokenNumber:
+			typ = semanticNumber
+		case tokenOperator:
+			typ = semanticOperator
+		case tokenIdentifier:
+			if obj, ok := tf.objs[start]; ok {
+				typ, modifiers = classifyObject(obj, params)
+				if decls[start] {
+					modifiers |= semanticDeclaration
+				}
+			}
+		}
+		if typ < 0 {
+			continue
+		}
+		deltaStart := rng.Start.Character
+		if rng.Start.Line == prev.Line {
+			deltaStart -= prev.Character
+		}
+		resp.Data = append(resp.Data,
+			uint32(rng.Start.Line-prev.Line),
+			uint32(deltaStart),
+			uint32(rng.End.Character-rng.Start.Character),
+			uint32(typ),
+			uint32(modifiers),
+		)
+		prev = rng.Start
+	}
+	return resp, nil
+}
+
+// classifyObject returns the type and the modifiers of the semantic token of the identifier referring to the object.
+func classifyObject(obj types.Object, params map[types.Object]bool) (int, int) {
+	modifiers := 0
+	if obj.Pkg() == nil {
+		modifiers |= semanticDefaultLibrary
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return semanticNamespace, modifiers
+	case *types.TypeName:
+		switch obj.Type().Underlying().(type) {
+		case *types.Interface:
+			return semanticInterface, modifiers
+		case *types.Struct:
+			return semanticStruct, modifiers
+		}
+		return semanticType, modifiers
+	case *types.Var:
+		if obj.IsField() {
+			return semanticProperty, modifiers
+		}
+		if params[obj] {
+			return semanticParameter, modifiers
+		}
+		return semanticVariable, modifiers
+	case *types.Const, *types.Nil:
+		return semanticVariable, modifiers | semanticReadonly
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return semanticMethod, modifiers
+		}
+		return semanticFunction, modifiers
+	case *types.Builtin:
+		return semanticFunction, modifiers
+	case *types.Label:
+		return semanticLabel, modifiers
+	}
+	return semanticVariable, modifiers
+}
+
+// parameterObjects returns the receivers, the parameters and the results of the functions declared in the file.
+func parameterObjects(file *ast.File, info *types.Info) map[types.Object]bool {
+	params := make(map[types.Object]bool)
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				if obj := info.Defs[name]; obj != nil {
+					params[obj] = true
+				}
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			addFields(n.Recv)
+		case *ast.FuncType:
+			addFields(n.Params)
+			addFields(n.Results)
+		}
+		return true
+	})
+	return params
+}
+
+// positionBefore reports whether the position a is before b.
+func positionBefore(a, b protocol.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
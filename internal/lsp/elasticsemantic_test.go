@@ -0,0 +1,70 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	dir := newTestDir(t, "semantic", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "package a\n\nimport \"fmt\"\n\nconst N = 1\n\ntype T struct{ f int }\n\nfunc (t T) M(n int) string { return fmt.Sprint(n, t.f, N) }\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "a", source.DefaultOptions)
+	uri := protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))
+
+	// The tokens of the line of the method, decoded as the types, the modifiers by the starting characters.
+	resp, err := s.SemanticTokensRange(ctx, &protocol.SemanticTokensRangeParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        protocol.Range{Start: protocol.Position{Line: 8}, End: protocol.Position{Line: 9}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type semanticToken struct{ typ, modifiers uint32 }
+	got := make(map[uint32]semanticToken)
+	var line, char uint32
+	for i := 0; i+5 <= len(resp.Data); i += 5 {
+		if resp.Data[i] > 0 {
+			char = 0
+		}
+		line += resp.Data[i]
+		char += resp.Data[i+1]
+		if line != 8 {
+			t.Fatalf("got a token at the line %d, want only the line 8", line)
+		}
+		got[char] = semanticToken{resp.Data[i+3], resp.Data[i+4]}
+	}
+	for char, want := range map[uint32]semanticToken{
+		0:  {semanticKeyword, 0},                     // func
+		6:  {semanticParameter, semanticDeclaration}, // t
+		8:  {semanticStruct, 0},                      // T
+		11: {semanticMethod, semanticDeclaration},    // M
+		15: {semanticType, semanticDefaultLibrary},   // int
+		36: {semanticNamespace, 0},                   // fmt
+		40: {semanticFunction, 0},                    // Sprint
+		47: {semanticParameter, 0},                   // n
+		52: {semanticProperty, 0},                    // f
+		55: {semanticVariable, semanticReadonly},     // N
+	} {
+		if got[char] != want {
+			t.Errorf("got the token %+v at the character %d, want %+v", got[char], char, want)
+		}
+	}
+
+	full, err := s.SemanticTokensFull(ctx, &protocol.SemanticTokensParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Data) <= len(resp.Data) {
+		t.Errorf("got %d integers of the full tokens, want more than the %d of the range", len(full.Data), len(resp.Data))
+	}
+}
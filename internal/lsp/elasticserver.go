@@ -15,50 +15,121 @@ import (
 	"golang.org/x/tools/internal/semver"
 	"golang.org/x/tools/internal/span"
 	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	"golang.org/x/tools/internal/xcontext"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-)
-
-var (
-	pkgMod = filepath.Join(os.Getenv("GOPATH"), "pkg", "mod")
-	goRoot = os.Getenv("GOROOT")
+	"sync"
+	"time"
 )
 
 // NewElasticServer starts an LSP server on the supplied stream, and waits until the
-// stream is closed.
-func NewElasticServer(ctx context.Context, cache source.Cache, stream jsonrpc2.Stream) (context.Context, *ElasticServer) {
-	s := &ElasticServer{}
+// stream is closed. The server shares the server options with the other servers.
+func NewElasticServer(ctx context.Context, cache source.Cache, servers *Servers, stream jsonrpc2.Stream) (context.Context, *ElasticServer) {
+	s := &ElasticServer{stats: newSessionStats(), servers: servers}
 	ctx, s.Conn, s.client = protocol.NewElasticServer(ctx, stream, s)
+	s.Conn.AddHandler(&statsHandler{stats: s.stats})
+	if servers != nil {
+		s.Conn.AddHandler(idleHandler{activity: &servers.activity})
+	}
 	s.session = cache.NewSession(ctx)
+	// The go and git commands of the session run in the sandbox of the servers.
+	var sb sandbox
+	if servers != nil {
+		sb = servers.sandbox
+	}
+	options := s.session.Options()
+	options.RunCommand = sb.run
+	s.session.SetOptions(options)
 	return ctx, s
 }
 
 // RunElasticServerOnPort starts an LSP server on the given port and does not exit.
 // This function exists for debugging purposes.
-func RunElasticServerOnPort(ctx context.Context, cache source.Cache, port int, h func(ctx context.Context, s *ElasticServer)) error {
-	return RunElasticServerOnAddress(ctx, cache, fmt.Sprintf(":%v", port), h)
+func RunElasticServerOnPort(ctx context.Context, cache source.Cache, servers *Servers, port int, h func(ctx context.Context, s *ElasticServer)) error {
+	return RunElasticServerOnAddress(ctx, cache, servers, fmt.Sprintf(":%v", port), h)
 }
 
 // RunElasticServerOnAddress starts an LSP server on the given port and does not exit.
 // This function exists for debugging purposes.
-func RunElasticServerOnAddress(ctx context.Context, cache source.Cache, addr string, h func(ctx context.Context, s *ElasticServer)) error {
+func RunElasticServerOnAddress(ctx context.Context, cache source.Cache, servers *Servers, addr string, h func(ctx context.Context, s *ElasticServer)) error {
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
+	return serveElasticListener(ctx, cache, servers, ln, h)
+}
+
+// RunElasticServerOnSocket starts an LSP server on the unix domain socket located at the given path and does not exit.
+// A stale socket file left by the previous run will be removed before listening.
+func RunElasticServerOnSocket(ctx context.Context, cache source.Cache, servers *Servers, path string, h func(ctx context.Context, s *ElasticServer)) error {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	return serveElasticListener(ctx, cache, servers, ln, h)
+}
+
+// RunElasticServerOnStdio starts an LSP server communicating over the stdin and stdout, and waits until the stream is
+// closed. If trace is not nil, the rpc trace will be written into it.
+func RunElasticServerOnStdio(ctx context.Context, cache source.Cache, servers *Servers, trace io.Writer) error {
+	stream := jsonrpc2.NewHeaderStream(os.Stdin, os.Stdout)
+	if trace != nil {
+		stream = protocol.LoggingStream(stream, trace)
+	}
+	ctx, s := NewElasticServer(ctx, cache, servers, stream)
+	// The stdin isn't closed once the context is canceled, e.g. by the idle shutdown, so the server stops serving
+	// without waiting for it.
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.RunElasticServer(ctx)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// serveElasticListener accepts the connections from the listener and hands a new server for every connection to h.
+func serveElasticListener(ctx context.Context, cache source.Cache, servers *Servers, ln net.Listener, h func(ctx context.Context, s *ElasticServer)) error {
+	serving.Lock()
+	serving.listeners++
+	serving.Unlock()
+	defer func() {
+		serving.Lock()
+		serving.listeners--
+		serving.Unlock()
+	}()
+	// The listener stops accepting the connections once the context is canceled, e.g. by the idle shutdown.
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			return err
 		}
-		h(NewElasticServer(ctx, cache, jsonrpc2.NewHeaderStream(conn, conn)))
+		servers.touch()
+		h(NewElasticServer(ctx, cache, servers, jsonrpc2.NewHeaderStream(conn, conn)))
 	}
 }
 
@@ -66,137 +137,471 @@ func RunElasticServerOnAddress(ctx context.Context, cache source.Cache, addr str
 type ElasticServer struct {
 	Server
 	// The folders that need to be cleanup, like the folders contain the empty go.mod which is created manually.
-	FolderNeedsCleanup []string
+	FolderNeedsCleanup cleanupRegistry
+
+	prepares prepareTracker
+	memory   *memoryWatchdog
+	packages packageLRU
+	warmUps  warmUpTracker
+	fulls    flightGroup
+	symbols  symbolIndex
+	proxies  proxyHealth
+	depsRuns depsRuns
+	stats    *sessionStats
+	// references are the references collected by the 'full' requests, which are counted by the code lenses.
+	references referenceIndex
+	// servers are the servers sharing the server options with the server, it shares nothing if they are nil.
+	servers *Servers
+	// typeChecks bounds the concurrent type-checks of the full and edefinition requests of the connection, which are
+	// served in parallel, it's set once the server is initialized.
+	typeChecks *admission
+	// warmFolders identify the warm state of the session, they're set once the server is initialized.
+	warmFolders []string
+	// clientCaps are the elastic capabilities declared by the client at the initialization.
+	clientCaps clientCapabilities
+	checkDiags checkDiagnostics
+
+	// buildViewsMu guards the creation of the views for the build contexts.
+	buildViewsMu sync.Mutex
 }
 
 func (s *ElasticServer) RunElasticServer(ctx context.Context) error {
+	// The requests still running once the client disconnects, like the dependency downloads, are canceled.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The server is reported by the health checks as long as the jsonrpc2 loop is running.
+	serving.Lock()
+	serving.servers[s] = true
+	serving.Unlock()
+	defer func() {
+		serving.Lock()
+		delete(serving.servers, s)
+		serving.Unlock()
+		s.servers.touch()
+	}()
 	return s.Conn.Run(ctx)
 }
 
 // EDefinition has almost the same functionality with Definition except for the qualified name and symbol kind.
-func (s *ElasticServer) EDefinition(ctx context.Context, params *protocol.DefinitionParams) ([]protocol.SymbolLocator, error) {
+func (s *ElasticServer) EDefinition(ctx context.Context, params *protocol.EDefinitionParams) ([]protocol.SymbolLocator, error) {
+	locators, err := s.serveEDefinition(ctx, params)
+	if s.clientCaps.legacy() {
+		locators = legacyLocators(locators)
+	}
+	return locators, err
+}
+
+// serveEDefinition serves 'EDefinition' under the timeout of the request, the locators are in the shapes of the
+// current version of the elastic protocol and carry the IndexSchemaVersion.
+func (s *ElasticServer) serveEDefinition(ctx context.Context, params *protocol.EDefinitionParams) ([]protocol.SymbolLocator, error) {
+	ctx, cancel, timeout := withRequestTimeout(ctx, s.session.Options().RequestTimeouts, edefinitionTimeout)
+	defer cancel()
+	locators, err := s.eDefinition(ctx, params)
+	if timedOut(ctx, timeout, err) {
+		return nil, timeoutError(edefinitionTimeout, timeout, nil)
+	}
+	return versionLocators(locators, protocol.IndexSchemaVersion), err
+}
+
+// eDefinition serves 'EDefinition' under the timeout of the request.
+func (s *ElasticServer) eDefinition(ctx context.Context, params *protocol.EDefinitionParams) ([]protocol.SymbolLocator, error) {
 	uri := span.NewURI(params.TextDocument.URI)
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	view := s.session.ViewOf(uri)
+	snapshot, err := snapshotOf(view, params.Snapshot)
+	if err != nil {
+		return nil, err
+	}
 	f, err := view.GetFile(ctx, uri)
 	if err != nil {
 		return nil, err
 	}
-	ident, err := source.Identifier(ctx, view, f, params.Position)
+	ident, err := source.IdentifierAt(ctx, view, snapshot, f, params.Position)
 	if err != nil {
+		// The identifier may be missing since the package is broken, it's resolved from the syntax at best.
+		s.publishCheckErrors(ctx, snapshot, f)
+		if locators := s.approximateDefinition(ctx, view, snapshot, f, params.Position); len(locators) != 0 {
+			return locators, nil
+		}
 		return nil, err
 	}
+	// The package has been checked to find the identifier.
+	if cphs, err := snapshot.CheckPackageHandles(ctx, f); err == nil {
+		s.packages.use(ctx, view, source.NarrowestCheckPackageHandle(cphs))
+	}
 	declRange, err := ident.Declaration.Range()
 	if err != nil {
 		return nil, err
 	}
-	// Check whether the definition is in the current view, i.e. workspace folders. One repo may has several workspace folders.
-	if strings.HasPrefix(ident.Declaration.URI().Filename(), view.Folder().Filename()) {
-		// If it is the same-workspace folder jump, return early.
-		return []protocol.SymbolLocator{{
-			Loc: &protocol.Location{
-				URI:   protocol.NewURI(ident.Declaration.URI()),
-				Range: declRange,
-			},
-			Package: protocol.PackageLocator{},
-		}}, nil
-	}
-	// If it is the cross-view jump, only return the qname, symbol kind and package locator.
 	declObj := ident.GetDeclObject()
 	declURI := ident.Declaration.URI()
-	declFile, err := view.GetFile(ctx, declURI)
-	if err != nil {
-		return nil, err
+	declPkg := ident.GetDeclPackage()
+	declLoc := protocol.Location{URI: protocol.NewURI(declURI), Range: declRange}
+	declPath := declURI.Filename()
+	// The declarations in the generated files, like the cgo generated ones located in the build cache, are mapped back
+	// to the original files by the line directives. The AST is the one of the package the identifier is resolved in,
+	// since the positions of the declaration are the ones of that package.
+	declAST, astErr := declFileAST(ctx, view, declPkg, declURI)
+	generated := astErr == nil && isGeneratedFile(declAST)
+	if generated && declObj != nil {
+		if posn, ok := originalPosition(view.Session().Cache().FileSet(), declObj.Pos()); ok {
+			if loc, err := originalLocation(ctx, view.Session(), posn, declObj.Name()); err == nil {
+				declLoc, declPath = loc, posn.Filename
+			}
+		}
+	}
+	// The package names, like the aliases of the imports and the dots of the dot-imports, and the import paths stand for
+	// the imported packages, which are located by the package files rather than by the import specs.
+	imported := ident.GetImportedPackage()
+	if imported != nil {
+		if loc := packageFileOf(view.Session().Cache().FileSet(), imported); loc != "" {
+			declPath = loc
+		}
+	}
+	// The definition is previewed by the signature and the source excerpt of the declaration, the excerpts of the
+	// generated files are left out since they aren't the sources which the locations are mapped back to.
+	signature, snippet := declSignature(declObj), ""
+	if imported != nil {
+		signature = packageSignature(imported)
+	} else if declObj != nil && declObj.Pos().IsValid() && !generated {
+		// The positions of the file itself are used rather than the ones adjusted by the line directives.
+		snippet = declSnippet(ctx, view.Session(), view.Session().Cache().FileSet().PositionFor(declObj.Pos(), false))
+	}
+	// Check whether the definition is in the workspace folders, i.e. the current view or the other views of the session.
+	// One repo may has several workspace folders. Under the vendor mode, the vendored packages are considered as the
+	// dependencies rather than the workspace code.
+	declInVendor := view.Options().VendorMode && strings.Contains(declPath, folderSkip)
+	local := s.inOpenFolder(view, declPath) && !declInVendor
+	locator := protocol.SymbolLocator{Generated: generated, Signature: signature, Snippet: snippet}
+	if local {
+		locator.Loc = &declLoc
+		// If it is the same-workspace folder jump, return early unless the qualified names are always wanted.
+		if !view.Options().QualifyLocalDefinitions {
+			return []protocol.SymbolLocator{locator}, nil
+		}
+	}
+	// If it is the cross-view jump, only return the qname, symbol kind and package locator.
+	if imported != nil {
+		locator.Package = collectPkgMetadata(imported, view.Folder().Filename(), declPath, view.Options())
+		locator.Qname = qualifyQname(imported.Name(), imported, locator.Package.Version, view.Options().QnameStyle)
+		locator.Kind = protocol.Package
+		return []protocol.SymbolLocator{locator}, nil
 	}
 	kind := getSymbolKind(declObj)
 	if kind == 0 {
+		// The local definitions are still located.
+		if local {
+			return []protocol.SymbolLocator{locator}, nil
+		}
 		return nil, fmt.Errorf("no corresponding symbol kind for '" + ident.Name + "'")
 	}
-	qname := getQName(ctx, view, declFile, declObj, kind)
-	declPath := declURI.Filename()
-	pkgLocator := collectPkgMetadata(declObj.Pkg(), view.Folder().Filename(), declPath)
-	return []protocol.SymbolLocator{{Qname: qname, Kind: kind, Package: pkgLocator}}, nil
+	locator.Kind = kind
+	locator.Package = collectPkgMetadata(declObj.Pkg(), view.Folder().Filename(), declPath, view.Options())
+	if astErr == nil {
+		ordinals := declPackageOrdinals(ctx, view.Session().Cache().FileSet(), declPkg, declURI)
+		qname := truncateQname(getQName(declAST, declObj, kind, ordinals), view.Options().MaxQnameDepth)
+		locator.Qname = qualifyQname(qname, declObj.Pkg(), locator.Package.Version, view.Options().QnameStyle)
+	}
+	return []protocol.SymbolLocator{locator}, nil
+}
+
+// inOpenFolder reports whether the file is in the folder of the view or in any other folder open in the session, so
+// that the jumps across the workspace folders are located concretely.
+func (s *ElasticServer) inOpenFolder(view source.View, path string) bool {
+	if inFolder(path, view.Folder().Filename()) {
+		return true
+	}
+	for _, v := range s.session.Views() {
+		if inFolder(path, v.Folder().Filename()) {
+			return true
+		}
+	}
+	return false
 }
 
 const (
 	folderSkip = string(filepath.Separator) + "vendor" + string(filepath.Separator)
 )
 
-// Full collects the symbols defined in the current file and the references.
+// Full collects the symbols defined in the current file and the references, in the shapes of the version of the
+// elastic protocol negotiated with the client. The large responses are compressed if the client accepts it.
 func (s *ElasticServer) Full(ctx context.Context, fullParams *protocol.FullParams) (protocol.FullResponse, error) {
-	params := protocol.DocumentSymbolParams{TextDocument: fullParams.TextDocument}
+	resp, err := s.serveFull(ctx, fullParams)
+	resp.SchemaVersion = protocol.IndexSchemaVersion
+	if s.clientCaps.legacy() {
+		resp = legacyFull(resp)
+	}
+	if err != nil {
+		return resp, err
+	}
+	return encodeFull(resp, s.clientCaps.compression())
+}
+
+// serveFull serves 'Full' under the timeout of the request.
+func (s *ElasticServer) serveFull(ctx context.Context, fullParams *protocol.FullParams) (protocol.FullResponse, error) {
 	fullResponse := protocol.FullResponse{
 		Symbols:    []protocol.DetailSymbolInformation{},
 		References: []protocol.Reference{},
 	}
+	if err := s.rejectUnderPressure(); err != nil {
+		return fullResponse, err
+	}
+	ctx, cancel, timeout := withRequestTimeout(ctx, s.session.Options().RequestTimeouts, fullTimeout)
+	defer cancel()
+	// The references aren't collected for the clients which don't handle them.
+	if !s.clientCaps.references() {
+		fullParams.Reference = false
+	}
 	uri := span.NewURI(fullParams.TextDocument.URI)
-	// Intercept the 'full' request for 'vendor' folder.
-	// TODO(henrywong) Support the code intelligence for 'vendor' folder
-	if ok := strings.Contains(uri.Filename(), folderSkip); ok {
+	if fullParams.SymbolFilter.ExcludeTests && strings.HasSuffix(uri.Filename(), "_test.go") {
 		return fullResponse, nil
 	}
 	view := s.session.ViewOf(uri)
+	// Intercept the 'full' request for 'vendor' folder unless the vendored packages are indexed under the vendor mode.
+	if ok := strings.Contains(uri.Filename(), folderSkip); ok && !view.Options().VendorMode {
+		return fullResponse, nil
+	}
+	view, err := s.buildContextView(ctx, view, fullParams.BuildContext)
+	if err != nil {
+		return fullResponse, err
+	}
+	snapshot, err := snapshotOf(view, fullParams.Snapshot)
+	if err != nil {
+		return fullResponse, err
+	}
 	f, err := view.GetFile(ctx, uri)
 	if err != nil {
 		return fullResponse, err
 	}
-	path := f.URI().Filename()
-	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if fullParams.Limit < 0 {
+		return fullResponse, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "invalid limit %d", fullParams.Limit)
+	}
+	version := snapshot.Handle(ctx, f).Identity().Version
+	offset, err := parseFullCursor(fullParams.Cursor, version)
+	if err != nil {
+		return fullResponse, err
+	}
+	// The retried requests for the same version of the document share one computation, as well as the pages.
+	keyParams := *fullParams
+	keyParams.Limit, keyParams.Cursor = 0, ""
+	key := fmt.Sprintf("%s@%s %v", uri, version, keyParams)
+	// The shared computation takes one slot of the type-checks, it runs on until all the requests sharing it leave.
+	v, err := s.fulls.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		release, err := s.admitTypeCheck(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return s.full(ctx, view, snapshot, f, fullParams)
+	})
+	if timedOut(ctx, timeout, err) {
+		// The symbols collected by the syntax are still returned, the parsing doesn't wait for the type-checking.
+		partial, err := s.partialFull(xcontext.Detach(ctx), view, snapshot, f, fullParams, err)
+		if err != nil {
+			return fullResponse, timeoutError(fullTimeout, timeout, nil)
+		}
+		limit := capFullPage(partial, offset, fullParams.Limit, view.Options().MaxResponseSize)
+		return fullResponse, timeoutError(fullTimeout, timeout, pageFull(partial, offset, limit, version))
+	}
 	if err != nil {
 		return fullResponse, err
 	}
+	// The responses beyond the cap are paginated, rather than marshaled as a whole.
+	resp := v.(protocol.FullResponse)
+	return pageFull(resp, offset, capFullPage(resp, offset, fullParams.Limit, view.Options().MaxResponseSize), version), nil
+}
+
+// full collects the symbols and the references of the file for 'Full'.
+func (s *ElasticServer) full(ctx context.Context, view source.View, snapshot source.Snapshot, f source.File, fullParams *protocol.FullParams) (protocol.FullResponse, error) {
+	fullResponse := protocol.FullResponse{
+		Symbols:    []protocol.DetailSymbolInformation{},
+		References: []protocol.Reference{},
+		Snapshot:   snapshot.ID(),
+	}
+	uri := f.URI()
+	path := uri.Filename()
+	// The files of the packages which fail to be checked, like the ones importing a broken package, are still indexed
+	// by their syntax.
+	cphs, err := snapshot.CheckPackageHandles(ctx, f)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fullResponse, err
+		}
+		s.publishPackageErrors(ctx, uri, nil, err)
+		return s.partialFull(ctx, view, snapshot, f, fullParams, err)
+	}
 	cph := source.NarrowestCheckPackageHandle(cphs)
 	pkg, err := cph.Check(ctx)
 	if err != nil {
-		return fullResponse, err
+		if ctx.Err() != nil {
+			return fullResponse, err
+		}
+		s.publishPackageErrors(ctx, uri, nil, err)
+		return s.partialFull(ctx, view, snapshot, f, fullParams, err)
 	}
-	pkgLocator := collectPkgMetadata(pkg.GetTypes(), view.Folder().Filename(), path)
+	s.publishPackageErrors(ctx, uri, pkg, nil)
+	s.packages.use(ctx, view, cph)
+	pkgLocator := collectPkgMetadata(pkg.GetTypes(), view.Folder().Filename(), path, view.Options())
 
-	detailSyms, err := constructDetailSymbol(s, ctx, &params, &pkgLocator)
+	// Construct the symbols from the package checked above, rather than resolving and checking the file again.
+	ordinals := widestDeclOrdinals(ctx, view.Session().Cache().FileSet(), cphs)
+	detailSyms, err := constructDetailSymbol(ctx, view, pkg, ordinals, fullParams.TextDocument.URI, &pkgLocator)
 	if err != nil {
 		return fullResponse, err
 	}
-	fullResponse.Symbols = detailSyms
+	fullResponse.Symbols, fullResponse.Truncated = filterSymbols(detailSyms, fullParams.SymbolFilter)
+	fullResponse.QnameCollisions = findQnameCollisions(detailSyms)
+	fh := snapshot.Handle(ctx, f)
+	s.symbols.update(uri, fh.Identity().Version, contentHash(ctx, fh), detailSyms)
 
-	// TODO(henrywong) We won't collect the references for now because of the performance issue. Once the 'References'
-	//  option is true, we will implement the references collecting feature.
-	if !fullParams.Reference {
+	if len(fullParams.GoVersions) > 0 {
+		diffs, err := collectVersionDiffs(ctx, newPackageLoader(view.Options().LoadParallelism, s.stats), view, pkg, uri, fullParams.GoVersions, detailSyms)
+		if err != nil {
+			return fullResponse, err
+		}
+		fullResponse.VersionDiffs = diffs
+	}
+
+	// The references are only collected on demand because of the performance issue, the client can narrow down the
+	// cost further by selecting the kinds of the references.
+	if !fullParams.Reference || view.Options().DisableReferenceIndexing {
 		return fullResponse, nil
 	}
+	refs, err := collectReferences(ctx, view, pkg, ordinals, uri, fullParams.ReferenceKinds, fullParams.SymbolFilter.ExcludeLocals, fullParams.LocalReferences)
+	if err != nil {
+		return fullResponse, err
+	}
+	if limit := fullParams.SymbolFilter.MaxReferences; limit > 0 && len(refs) > limit {
+		refs, fullResponse.Truncated = refs[:limit], true
+	}
+	// Only the complete references of the file are indexed, so that the counts of the code lenses aren't skewed.
+	if len(fullParams.ReferenceKinds) == 0 && !fullParams.LocalReferences && !fullResponse.Truncated {
+		s.references.update(uri, contentHash(ctx, fh), refs)
+	}
+	fullResponse.References = refs
 	return fullResponse, nil
 }
 
 // ManageDeps will explore the workspace folders sent from the client and manages the corresponding dependencies.
-func (s *ElasticServer) ManageDeps(ctx context.Context, folders *[]protocol.WorkspaceFolder, options interface{}) {
+// It returns the module folders discovered under the workspace folders, in the order of the workspace folders and
+// sorted by the paths under each of them, without the ones which are workspace folders already. It's aborted once the
+// server shuts down or the folders are removed, the module folders discovered so far are returned.
+func (s *ElasticServer) ManageDeps(ctx context.Context, folders []protocol.WorkspaceFolder, options interface{}) []protocol.WorkspaceFolder {
+	// The folders sent more than once are managed once.
+	var roots folderSet
+	for _, folder := range folders {
+		roots.add(folder)
+	}
+	ctx, done := s.depsRuns.start(ctx, roots.dirs)
+	defer done()
 	installGoDeps := s.session.Options().InstallGoDependency
-	if !installGoDeps {
-		// Peek the value of the option 'installGoDependency' to guide the dependency management.
-		if opts, ok := options.(map[string]interface{}); ok {
-			if opt, ok := opts["installGoDependency"].(bool); ok && opt {
-				installGoDeps = true
-			}
+	vendorMode := s.session.Options().VendorMode
+	// The options 'installGoDependency' and 'vendorMode' guide the dependency management, the invalid options are
+	// reported once the server is initialized.
+	elastic, err := protocol.DecodeElasticOptions(options)
+	if err != nil {
+		log.Error(ctx, "invalid elastic options", err)
+	}
+	installGoDeps = installGoDeps || elastic.InstallGoDependency
+	vendorMode = vendorMode || elastic.VendorMode
+	// Under the vendor mode, all the dependencies are loaded from the vendor folders, there is nothing to download.
+	if vendorMode {
+		installGoDeps = false
+	}
+	// The folders under the vendor mode or offline by their feature flags don't download the dependencies.
+	flagged := s.session.Options()
+	source.SetOptions(&flagged, options)
+	depsMgr := DepsManager{
+		installGoDeps:      installGoDeps,
+		FolderNeedsCleanup: &s.FolderNeedsCleanup,
+		noDownload:         make(map[string]bool),
+		folderEnv:          make(map[string][]string),
+		excludePatterns:    flagged.ExcludePatterns,
+		env:                append(append([]string{}, flagged.Env...), credentialEnv(flagged.Credentials)...),
+		proxies:            flagged.GoProxies,
+		cooldown:           flagged.ProxyCooldown,
+		health:             &s.proxies,
+		retry:              retryPolicy{retries: flagged.DownloadRetries, backoff: flagged.DownloadBackoff},
+		modCacheQuota:      flagged.ModCacheQuota,
+		session:            s.session,
+		options:            flagged,
+		stats:              s.stats,
+	}
+	ctx, cancel, timeout := withRequestTimeout(ctx, flagged.RequestTimeouts, manageDepsTimeout)
+	defer cancel()
+	defer func() {
+		if timedOut(ctx, timeout, ctx.Err()) {
+			log.Error(ctx, "the dependency management exceeds the timeout", ctx.Err(), tag.Of("Timeout", timeout))
+		}
+	}()
+	completion := protocol.CompletionEvent{Event: protocol.CompletionManageDeps, Folders: folderURIs(roots.folders)}
+	start := time.Now()
+	defer func() {
+		completion.Duration = time.Since(start).Seconds()
+		completion.Canceled = ctx.Err() != nil
+		s.notifyCompletion(ctx, completion)
+	}()
+	// The environment of the folders is resolved like the views, the configuration of the folders is only available
+	// once the server is initialized, i.e. for the folders added later.
+	s.stateMu.Lock()
+	initialized := s.state >= serverInitialized
+	s.stateMu.Unlock()
+	untrusted := make(map[string]bool)
+	for _, folder := range roots.folders {
+		s.stats.folderManaged(span.NewURI(folder.URI).Filename())
+		folderOpts := flagged
+		folderOpts.Env = append([]string{}, flagged.Env...)
+		if initialized {
+			s.fetchConfig(ctx, folder.Name, span.NewURI(folder.URI), &folderOpts)
+		}
+		applyFeatureFlags(&folderOpts, span.NewURI(folder.URI), folder.Name)
+		applyFolderEnv(&folderOpts, span.NewURI(folder.URI), folder.Name)
+		depsMgr.folderEnv[span.NewURI(folder.URI).Filename()] = append(folderOpts.Env, credentialEnv(folderOpts.Credentials)...)
+		if folderOpts.VendorMode || folderOpts.Offline {
+			depsMgr.noDownload[span.NewURI(folder.URI).Filename()] = true
+		}
+		// The untrusted folders are neither scanned, nor have the go.mod initialized, nor download the dependencies.
+		if !s.trusted(ctx, folderOpts, span.NewURI(folder.URI).Filename()) {
+			untrusted[folder.URI] = true
+			depsMgr.noDownload[span.NewURI(folder.URI).Filename()] = true
 		}
 	}
-	depsMgr := DepsManager{installGoDeps: installGoDeps}
-	for _, folder := range *folders {
-		if err := depsMgr.run(ctx, folder); err != nil {
+	// The module folders are collected aside, so that the nested modules found by more than one workspace folder, or
+	// the ones which are workspace folders themselves, are kept once.
+	all := folderSet{dirs: append([]string{}, roots.dirs...), folders: append([]protocol.WorkspaceFolder{}, roots.folders...)}
+	var discovered []protocol.WorkspaceFolder
+	for _, folder := range roots.folders {
+		if ctx.Err() != nil {
+			log.Print(ctx, "aborted the dependency management", tag.Of("Folder", folder.URI))
+			return discovered
+		}
+		if untrusted[folder.URI] {
+			log.Print(ctx, "skipped the dependency management of the untrusted folder", tag.Of("Folder", folder.URI))
+			completion.Errors = append(completion.Errors, span.NewURI(folder.URI).Filename()+": the folder is untrusted")
+			continue
+		}
+		modules, err := depsMgr.run(ctx, folder)
+		if err != nil {
 			log.Error(ctx, "", err)
+			completion.Errors = append(completion.Errors, err.Error())
+		}
+		for _, module := range modules {
+			if all.add(module) {
+				discovered = append(discovered, module)
+			}
 		}
-		*folders = append(*folders, depsMgr.moduleFolders...)
 	}
-	depsMgr.downloadDeps(ctx, folders)
+	completion.Errors = append(completion.Errors, depsMgr.downloadDeps(ctx, all.folders)...)
+	return discovered
 }
 
-func (s ElasticServer) Cleanup() {
-	for _, folder := range s.FolderNeedsCleanup {
-		goMod := filepath.Join(folder, "go.mod")
-		goSum := filepath.Join(folder, "go.sum")
-		if _, err := os.Stat(goMod); err == nil {
-			os.Remove(goMod) // ignore the errors
-		}
-		if _, err := os.Stat(goSum); err == nil {
-			os.Remove(goSum) // ignore the errors
-		}
-	}
+// Cleanup removes the files created manually in the folders, like the go.mod created by the dependency management.
+func (s *ElasticServer) Cleanup() {
+	s.FolderNeedsCleanup.cleanup()
 }
 
 // getSymbolKind get the symbol kind for a single position.
@@ -214,6 +619,10 @@ func getSymbolKind(declObj types.Object) protocol.SymbolKind {
 		return protocol.Null
 	case *types.PkgName:
 		return protocol.Package
+	case *types.Label:
+		// There is no symbol kind for the labels in the protocol, the labels name the positions in the functions like
+		// the keys.
+		return protocol.Key
 	case *types.Func:
 		s, _ := declObj.Type().(*types.Signature)
 		if s.Recv() == nil {
@@ -251,31 +660,53 @@ func getSymbolKind(declObj types.Object) protocol.SymbolKind {
 // search and code intelligence. The qualified name pattern as bellow:
 //  qname = package.name + struct.name* + function.name* | (struct.name + method.name)* + struct.name* + symbol.name
 //
+// The names shared by more than one declaration in the same scope are disambiguated by the ordinals of the
+// declarations, like 'pkg.init~2'. The ordinals of the package level declarations are given by pkgOrdinals, which may
+// be nil if the package files are unavailable.
+//
 // TODO(henrywong) It's better to use the scope chain to give a qualified name for the symbols, however there is no
 // APIs can achieve this goals, just traverse the ast node path for now.
-func getQName(ctx context.Context, view source.View, f source.File, declObj types.Object, kind protocol.SymbolKind) string {
-	qname := declObj.Name()
+func getQName(fAST *ast.File, declObj types.Object, kind protocol.SymbolKind, pkgOrdinals declOrdinals) string {
 	if kind == protocol.Package {
-		return qname
-	}
-	s := view.Snapshot()
-	fh := s.Handle(ctx, f)
-	fAST, _, _, err := view.Session().Cache().ParseGoHandle(fh, source.ParseExported).Parse(ctx)
-	if err != nil {
-		return ""
+		// The package names declared by the imports may be the aliases, the qualified name is the real name of the
+		// imported package.
+		if pkgName, ok := declObj.(*types.PkgName); ok {
+			return pkgName.Imported().Name()
+		}
+		return declObj.Name()
 	}
 	pos := declObj.Pos()
 	astPath, _ := astutil.PathEnclosingInterval(fAST, pos, pos)
+	ordinals := pkgOrdinals
+	if declObj.Pkg() != nil && declObj.Parent() != nil && declObj.Parent() != declObj.Pkg().Scope() {
+		// The local declarations are disambiguated within the outermost function.
+		if fn, root := outermostFunc(astPath), fileScopeOf(declObj); fn != nil && root != nil {
+			ordinals = localDeclOrdinals(fn, root)
+		}
+	}
+	name := func(id *ast.Ident) string {
+		if _, ok := ordinals[id.Pos()]; ok {
+			return ordinals.name(id)
+		}
+		return pkgOrdinals.name(id)
+	}
+	qname := declObj.Name()
+	if id, ok := astPath[0].(*ast.Ident); ok && id.Pos() == pos {
+		qname = name(id)
+	}
 	// TODO(henrywong) Should we put a check here for the case of only one node?
 	for id, n := range astPath[1:] {
 		switch n.(type) {
-		case *ast.StructType:
-			// Check its father to decide whether the ast.StructType is a named type or an anonymous type.
+		case *ast.StructType, *ast.InterfaceType:
+			// Check its father to decide whether the struct or the interface is a named type or an anonymous type. The
+			// methods of the anonymous interfaces are named after the fields and the variables alike, so that the methods
+			// selected through the embedded fields, like 'o.C.Close' with 'T' embedded in 'o', are rooted at 'T.C'
+			// rather than at the outer type.
 			switch astPath[id+2].(type) {
 			case *ast.TypeSpec:
 				// ident is located in a named struct declaration, add the type name into the qualified name.
 				ts, _ := astPath[id+2].(*ast.TypeSpec)
-				qname = ts.Name.Name + "." + qname
+				qname = name(ts.Name) + "." + qname
 			case *ast.Field:
 				// ident is located in a anonymous struct declaration which used to define a field, like struct fields,
 				// function parameters, function named return parameters, add the field name into the qualified name.
@@ -283,7 +714,7 @@ func getQName(ctx context.Context, view source.View, f source.File, declObj type
 				if len(field.Names) != 0 {
 					// If there is a bunch of fields declared with same anonymous struct type, just consider the first field's
 					// name.
-					qname = field.Names[0].Name + "." + qname
+					qname = name(field.Names[0]) + "." + qname
 				}
 
 			case *ast.ValueSpec:
@@ -293,19 +724,21 @@ func getQName(ctx context.Context, view source.View, f source.File, declObj type
 				if len(vs.Names) != 0 {
 					// If there is a bunch of variables declared with same anonymous struct type, just consider the first
 					// variable's name.
-					qname = vs.Names[0].Name + "." + qname
+					qname = name(vs.Names[0]) + "." + qname
 				}
 			}
-		case *ast.InterfaceType:
-			// Check its father to get the interface name.
-			switch astPath[id+2].(type) {
-			case *ast.TypeSpec:
-				ts, _ := astPath[id+2].(*ast.TypeSpec)
-				qname = ts.Name.Name + "." + qname
-			}
-
+		case *ast.FuncLit:
+			// ident is located in a function literal, name the literal by its ordinal in the enclosing function, like
+			// 'Outer.func1'. The literals in the package level variables are named after the variables, like 'v.func1'.
+			lit, _ := n.(*ast.FuncLit)
+			qname = funcLitName(lit, astPath[id+2:], name) + "." + qname
 		case *ast.FuncDecl:
 			f, _ := n.(*ast.FuncDecl)
+			// ident is declared inside the function, like the local variables and the parameters, add the function name
+			// as a prefix.
+			if f.Name.Pos() != pos {
+				qname = name(f.Name) + "." + qname
+			}
 			// If n is method, add the struct name as a prefix.
 			if f.Recv != nil {
 				var typeName string
@@ -325,38 +758,175 @@ func getQName(ctx context.Context, view source.View, f source.File, declObj type
 	return declObj.Pkg().Name() + "." + qname
 }
 
+// funcLitName names the function literal by its ordinal among the function literals directly nested in the enclosing
+// function, which is the first function in outer, the path from the parent of the literal to the root. The ordinals
+// start from 1 in the source order, the literals nested in other literals are counted by those literals. The variables
+// are named by name.
+func funcLitName(lit *ast.FuncLit, outer []ast.Node, name func(*ast.Ident) string) string {
+	var scope ast.Node
+	var prefix string
+loop:
+	for _, n := range outer {
+		switch n := n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			scope, prefix = n, ""
+			break loop
+		case *ast.ValueSpec:
+			// The literal is named after the variable if it turns out to be a package level variable.
+			if len(n.Names) > 0 && scope == nil {
+				scope, prefix = n, name(n.Names[0])+"."
+			}
+		}
+	}
+	if scope == nil {
+		return "func"
+	}
+	ordinal, found := 0, false
+	ast.Inspect(scope, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		if l, ok := n.(*ast.FuncLit); ok && n != scope {
+			ordinal++
+			found = l == lit
+			return false
+		}
+		return true
+	})
+	return prefix + "func" + strconv.Itoa(ordinal)
+}
+
+// outermostFunc returns the outermost function in the path, i.e. the function declaration or the function literal
+// assigned to a package level variable.
+func outermostFunc(astPath []ast.Node) ast.Node {
+	for i := len(astPath) - 1; i >= 0; i-- {
+		switch n := astPath[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return n
+		}
+	}
+	return nil
+}
+
+// fileScopeOf returns the file scope enclosing the local object.
+func fileScopeOf(obj types.Object) *types.Scope {
+	pkgScope := obj.Pkg().Scope()
+	for scope := obj.Parent(); scope != nil; scope = scope.Parent() {
+		if scope.Parent() == pkgScope {
+			return scope
+		}
+	}
+	return nil
+}
+
+// declFileAST returns the AST of the file declaring a symbol. The file is either in the type-checked package or in
+// one of its dependencies, so the AST held by the package is reused instead of parsing the file again. The file is
+// parsed only if it's out of the package, like the ignored files.
+func declFileAST(ctx context.Context, view source.View, pkg source.Package, uri span.URI) (*ast.File, error) {
+	if pkg != nil {
+		if ph, _, err := pkg.FindFile(ctx, uri); err == nil {
+			if fAST, _, _, err := ph.Cached(ctx); err == nil && fAST != nil {
+				return fAST, nil
+			}
+			if fAST, _, _, err := ph.Parse(ctx); err == nil && fAST != nil {
+				return fAST, nil
+			}
+		}
+	}
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	fh := view.Snapshot().Handle(ctx, f)
+	fAST, _, _, err := view.Session().Cache().ParseGoHandle(fh, source.ParseExported).Parse(ctx)
+	return fAST, err
+}
+
 // collectPackageMetadata collects metadata for the packages where the specified symbols located and the scheme, i.e.
 // URL prefix, of the repository which the packages belong to.
-func collectPkgMetadata(pkg *types.Package, dir string, loc string) protocol.PackageLocator {
+func collectPkgMetadata(pkg *types.Package, dir string, loc string, opts source.Options) protocol.PackageLocator {
 	if pkg == nil {
 		return protocol.PackageLocator{}
 	}
+	// The moved repositories are still imported by the old import paths, apply the aliases so that the repository URI
+	// points to the live repository.
+	pkgPath := applyImportPathAlias(pkg.Path(), opts.ImportPathAliases)
+	// The import path of the external test package, like 'example.com/foo_test', can't be resolved, it's located in the
+	// same repository as the package under test.
+	if isExternalTest(pkg, loc) {
+		pkgPath = strings.TrimSuffix(pkgPath, "_test")
+	}
 	pkgLocator := protocol.PackageLocator{
 		Name:    pkg.Name(),
-		RepoURI: pkg.Path(),
+		RepoURI: pkgPath,
 	}
-	// If the package is located in the standard library, there is no need to resolve the revision.
-	if strings.HasPrefix(loc, dir) || strings.HasPrefix(loc, goRoot) {
+	// The module and the version of the vendored packages are recorded in 'vendor/modules.txt', no matter whether the
+	// vendored packages are loaded under the vendor mode.
+	if mod, vendoredPath := vendoredModuleOf(loc, pkgPath); mod != nil {
+		pkgLocator.Module = mod.Path
+		pkgLocator.Version = normalizeRevision(mod.revision())
+		resolveRepoURI(&pkgLocator, applyImportPathAlias(mod.sourcePath(vendoredPath), opts.ImportPathAliases), opts)
 		return pkgLocator
 	}
-	getPkgVersion(dir, &pkgLocator, loc)
-	repoRoot, err := vcs.RepoRootForImportPath(pkg.Path(), false)
-	if err == nil {
-		pkgLocator.RepoURI = repoRoot.Repo
+	// If the package is located in the standard library, there is no need to resolve the revision. The toolchain of
+	// the folder is resolved by its environment, which may differ from the one of the process.
+	env := resolveGoEnv(opts, dir, opts.Env)
+	if inFolder(loc, dir) || (env.GOROOT != "" && inFolder(loc, env.GOROOT)) {
 		return pkgLocator
 	}
+	getPkgVersion(env.modCache(), &pkgLocator, loc)
+	resolveRepoURI(&pkgLocator, pkgPath, opts)
 	return pkgLocator
 }
 
+// isExternalTest reports whether the package declared in the file at loc is an external test package, i.e. the
+// package with the '_test' suffix declared by the test files of the package under test.
+func isExternalTest(pkg *types.Package, loc string) bool {
+	return strings.HasSuffix(pkg.Name(), "_test") && strings.HasSuffix(pkg.Path(), "_test") && strings.HasSuffix(loc, "_test.go")
+}
+
+// resolveRepoURI resolves the URI of the repository which the package belongs to.
+func resolveRepoURI(pkgLocator *protocol.PackageLocator, pkgPath string, opts source.Options) {
+	resolve := vcs.RepoRootForImportPath
+	if opts.Offline {
+		// Only the well-known code hosts can be resolved without the network access.
+		resolve = func(importPath string, _ bool) (*vcs.RepoRoot, error) {
+			return vcs.RepoRootForImportPathStatic(importPath, "")
+		}
+	}
+	// The resolving may take a round trip to the code host, the repositories resolved are shared by the sessions.
+	v, err := sharedMetadata.load(nameKey(repoRootKind, pkgPath), func() (interface{}, error) {
+		repoRoot, err := resolve(pkgPath, false)
+		if err != nil {
+			return nil, err
+		}
+		return repoRoot.Repo, nil
+	})
+	if err != nil {
+		return
+	}
+	repo := v.(string)
+	pkgLocator.RepoURI = repo
+	if opts.DetectRepoRedirects && !opts.Offline {
+		pkgLocator.RepoURI = resolveRepoRedirect(repo)
+	}
+}
+
 // getPkgVersion collects the version information for a specified package, the version information will be one of the
 // two forms semver format and prefix of a commit hash.
-func getPkgVersion(dir string, pkgLoc *protocol.PackageLocator, loc string) {
-	rev := getPkgVersionFast(strings.TrimPrefix(loc, filepath.Join(pkgMod, dir)))
+func getPkgVersion(modCache string, pkgLoc *protocol.PackageLocator, loc string) {
+	touchModVersion(modCache, loc, time.Now())
+	rev := getPkgVersionFast(modCacheRel(modCache, loc))
 	if rev == "" {
 		if err := getPkgVersionSlow(); err != nil {
 			return
 		}
 	}
+	pkgLoc.Version = normalizeRevision(rev)
+}
+
+// normalizeRevision converts the module version to the revision used by the package locator.
+func normalizeRevision(rev string) string {
 	// In general, the module version is in semver format and it's bound to be accompanied by a semver tag. But
 	// sometimes, like when there is no tag or try to get the latest commit, the module version is in pseudo-version
 	// pseudo-version format. Strip off the prefix to get the commit hash part which is a prefix of the full commit
@@ -366,7 +936,7 @@ func getPkgVersion(dir string, pkgLoc *protocol.PackageLocator, loc string) {
 		i := strings.LastIndex(rev, "-")
 		rev = rev[i+1:]
 	}
-	pkgLoc.Version = rev
+	return rev
 }
 
 // getPkgVersionSlow get the pkg revision with a more accurate approach, call 'go list' again is an option, but it not
@@ -380,10 +950,11 @@ func getPkgVersionSlow() error {
 // revision, we can extract it from the path, like '.../modulename@v1.2.3/...', this approach can avoid call 'go list'
 // multiple times. If there are multiple valid version substrings, give up.
 func getPkgVersionFast(loc string) string {
-	strs := strings.SplitAfter(loc, "@")
+	// The path may be mixed with the slashes on windows.
+	strs := strings.SplitAfter(filepath.ToSlash(loc), "@")
 	var validVersion []string
 	for i := 1; i < len(strs); i++ {
-		substrs := strings.Split(strs[i], string(filepath.Separator))
+		substrs := strings.Split(strs[i], "/")
 		if semver.IsValid(substrs[0]) {
 			validVersion = append(validVersion, substrs[0])
 		}
@@ -415,60 +986,140 @@ var (
 // - Recognize the potential multi-module cases.
 // - Download the dependencies.
 type DepsManager struct {
-	installGoDeps      bool
-	moduleFolders      []protocol.WorkspaceFolder
-	FolderNeedsCleanup []string
+	installGoDeps bool
+	// The registry of the server recording the folders which need cleanup, nil means no cleanup.
+	FolderNeedsCleanup *cleanupRegistry
+
+	// The root folders which mustn't download the dependencies, the module folders under them are skipped as well.
+	noDownload map[string]bool
+
+	// The patterns of the paths skipped by the scanning of the workspace folders, besides the '.gitignore' files.
+	excludePatterns []string
+
+	// The environment and the fallback chain of the module proxies to download the dependencies, the environment
+	// carries the credentials of the private modules. The environment of the root folders, overridden by the folders,
+	// applies to the module folders under them as well.
+	env       []string
+	folderEnv map[string][]string
+	proxies   []string
+	cooldown  time.Duration
+	health    *proxyHealth
+	retry     retryPolicy
+	// The quota of the module cache in bytes, zero means no limit.
+	modCacheQuota uint64
+	// The session whose views hold the module versions which mustn't be evicted from the module cache.
+	session source.Session
+	// The options of the folders, whose runner runs the go and git commands of the dependency management.
+	options source.Options
+
+	stats *sessionStats
 }
 
-// run will be called for every 'protocol.WorkspaceFolder' to collect module folders. Besides that specify which folders
-// need cleanup when language server shutdown.
-func (depsMgr *DepsManager) run(ctx context.Context, root protocol.WorkspaceFolder) error {
+// run will be called for every 'protocol.WorkspaceFolder' to collect module folders, the root folder itself is left
+// out. Besides that specify which folders need cleanup when language server shutdown.
+func (depsMgr *DepsManager) run(ctx context.Context, root protocol.WorkspaceFolder) ([]protocol.WorkspaceFolder, error) {
 	// In order to handle the modules separately, we consider different modules as different workspace folders, so we
 	// can manage the dependency of different modules separately.
-	err, modules := depsMgr.collectMetadata(ctx, span.NewURI(root.URI).Filename())
-	// depsMgr.moduleFolders = append(depsMgr.moduleFolders, root)
+	dir := span.NewURI(root.URI).Filename()
+	err, modules := depsMgr.collectMetadata(ctx, dir)
 	if err != nil {
-		return err
-	}
-	// Convert the module folders to the workspace folders.
-	for _, folder := range modules {
-		uri := span.NewURI(folder)
-		if filepath.Clean(string(uri)) == filepath.Clean(root.URI) {
-			continue
-		}
-		depsMgr.moduleFolders = append(depsMgr.moduleFolders, protocol.WorkspaceFolder{URI: string(uri), Name: filepath.Base(folder)})
+		return nil, err
 	}
-	return nil
+	return moduleFolders(dir, modules), nil
 }
 
-func (depsMgr DepsManager) downloadDeps(ctx context.Context, folders *[]protocol.WorkspaceFolder) {
+// downloadDeps downloads the dependencies of the module folders, and returns the errors of the failed downloads.
+func (depsMgr DepsManager) downloadDeps(ctx context.Context, folders []protocol.WorkspaceFolder) []string {
 	if !depsMgr.installGoDeps {
-		return
+		return nil
 	}
-	for _, folder := range *folders {
+	var failures []string
+	// The sizes of the module caches before the downloads, so that the bytes added by the downloads are tracked.
+	start := time.Now()
+	modCaches := make(map[string]int64)
+	for _, folder := range folders {
 		dir := span.NewURI(folder.URI).Filename()
-		if checkVendorFolder(dir) >= 0 {
+		if checkVendorFolder(dir) >= 0 || depsMgr.skipDownload(dir) {
 			continue
 		}
-		cmd := exec.Command("go", "mod", "download")
-		cmd.Env = append(append([]string{}, os.Environ()...), "GOPROXY=https://proxy.golang.org")
-		cmd.Dir = dir
-		if err := cmd.Run(); err != nil {
-			log.Error(ctx, "failed to download the dependencies", err)
-			// If dependencies downloading fails, put the folder under the vendor mode.
+		env := depsMgr.envOf(dir)
+		if modCache := resolveGoEnv(depsMgr.options, dir, env).modCache(); modCache != "" {
+			if _, ok := modCaches[modCache]; !ok {
+				_, modCaches[modCache], _ = scanModCache(modCache)
+			}
+		}
+		attempts, err := downloadWithRetry(ctx, depsMgr.options, dir, env, depsMgr.proxies, depsMgr.health, depsMgr.cooldown, depsMgr.retry)
+		// The aborted downloads say nothing about the folder, which mustn't be put under the vendor mode.
+		if ctx.Err() != nil {
+			log.Print(ctx, "aborted the dependency downloading", tag.Of("Folder", dir))
+			return failures
+		}
+		depsMgr.stats.depsDownload(err == nil)
+		if err != nil {
+			depsMgr.stats.depsFailure(protocol.DepsFailure{Folder: dir, Attempts: attempts, Transient: isProxyFailure(err), Error: err.Error()})
+			failures = append(failures, dir+": "+err.Error())
+			// If dependencies downloading fails via all the proxies even after the retries, put the folder under the
+			// vendor mode.
 			storeVendorFolder(dir)
 		}
 	}
+	for modCache, before := range modCaches {
+		depsMgr.manageModCache(ctx, modCache, before, start)
+	}
+	return failures
+}
+
+// manageModCache tracks the bytes added to the module cache by the downloads, and evicts the least recently used
+// module versions if the module cache exceeds the quota. The module versions used since the start of the downloads
+// and the ones of the packages loaded by the views are kept.
+func (depsMgr DepsManager) manageModCache(ctx context.Context, modCache string, before int64, start time.Time) {
+	versions, total, err := scanModCache(modCache)
+	if err != nil {
+		log.Error(ctx, "failed to scan the module cache", err, tag.Of("Dir", modCache))
+		return
+	}
+	if total > before {
+		depsMgr.stats.modCacheAdded(total - before)
+	}
+	if depsMgr.modCacheQuota == 0 || uint64(total) <= depsMgr.modCacheQuota {
+		return
+	}
+	evicted, freed := evictModCache(ctx, versions, total, depsMgr.modCacheQuota, start, liveModVersions(depsMgr.session, modCache))
+	depsMgr.stats.modCacheEvicted(len(evicted))
+	log.Print(ctx, "evicted the module cache", tag.Of("Dir", modCache), tag.Of("Versions", len(evicted)), tag.Of("Freed", freed))
+}
+
+// envOf returns the environment of the go commands running in dir, i.e. the one of the innermost root folder
+// containing dir.
+func (depsMgr DepsManager) envOf(dir string) []string {
+	env, longest := depsMgr.env, -1
+	for root, rootEnv := range depsMgr.folderEnv {
+		if _, elems := hostPaths.split(root); len(elems) > longest && hostPaths.hasPrefix(dir, root) {
+			env, longest = rootEnv, len(elems)
+		}
+	}
+	return env
 }
 
-func (depsMgr *DepsManager) goModInit(folder string) error {
-	modulePath := getModulePath(folder)
+// skipDownload reports whether the folder is under a root folder which mustn't download the dependencies.
+func (depsMgr DepsManager) skipDownload(dir string) bool {
+	for root := range depsMgr.noDownload {
+		if hostPaths.hasPrefix(dir, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (depsMgr *DepsManager) goModInit(ctx context.Context, folder string) error {
+	modulePath := getModulePath(depsMgr.options, folder, depsMgr.envOf(folder))
 	if depsMgr.installGoDeps {
-		cmd := exec.Command("go", "mod", "init", modulePath)
-		cmd.Dir = folder
-		return cmd.Run()
+		_, err := runGoCommand(ctx, depsMgr.options, folder, depsMgr.envOf(folder), "mod", "init", modulePath)
+		return err
 	} else {
-		depsMgr.FolderNeedsCleanup = append(depsMgr.FolderNeedsCleanup, folder)
+		if depsMgr.FolderNeedsCleanup != nil {
+			depsMgr.FolderNeedsCleanup.add(folder)
+		}
 		return constructGoModManually(folder, modulePath)
 	}
 }
@@ -476,60 +1127,74 @@ func (depsMgr *DepsManager) goModInit(folder string) error {
 // collectMetadata explores the workspace folder to collects the meta information of the folder. And
 // create a new 'go.mod' if necessary to cover all the source files.
 func (depsMgr *DepsManager) collectMetadata(ctx context.Context, folder string) (error, []string) {
-	var module []string
 	// Collect 'go.mod' and record them as workspace folders.
-	if err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
-		base := filepath.Base(path)
-		if (base[0] == '.' || base == "vendor") && info.IsDir() {
-			return filepath.SkipDir
-		} else if info != nil && info.Name() == "go.mod" {
-			dir := filepath.Dir(path)
-			module = append(module, dir)
-		}
-		return nil
-	}); err != nil {
+	// The paths ignored by git, or excluded by the configuration, are never scanned.
+	filter := newScanFilter(folder, depsMgr.excludePatterns)
+	module, err := collectModules(folder, dirVisitor{}, filter)
+	if err != nil {
 		return err, module
 	}
-	folderUncovered, folderNeedMod, err := collectUncoveredSrc(folder)
+	folderUncovered, folderNeedMod, err := collectUncoveredSrc(folder, dirVisitor{}, filter)
 	if err != nil {
 		return nil, module
 	}
 	// If folders need to be covered exist, a new 'go.mod' will be created manually.
-	if len(folderUncovered) > 0 {
-		longestPrefix := string(filepath.Separator)
-		// Compute the longest common prefix of the folders which need to be covered by 'go.mod'.
-	DONE:
-		for i, name := range folderUncovered[0] {
-			same := true
-			for _, folder := range folderUncovered[1:] {
-				if len(folder) <= i || folder[i] != name {
-					same = false
-					break DONE
-				}
-			}
-			if same {
-				longestPrefix = filepath.Join(longestPrefix, name)
-			}
-		}
-		folderNeedMod = append(folderNeedMod, filepath.Clean(longestPrefix))
+	// The folders which need to be covered share the 'go.mod' in their longest common directory.
+	if dir := hostPaths.commonDir(folderUncovered); dir != "" {
+		folderNeedMod = append(folderNeedMod, dir)
 	}
 
 	for _, folder := range folderNeedMod {
-		if err := depsMgr.goModInit(folder); err != nil {
+		if err := depsMgr.goModInit(ctx, folder); err != nil {
 			log.Error(ctx, "error when initializing module", err, telemetry.File)
 			continue
 		}
+		depsMgr.stats.moduleSynthesized(folder)
 		module = append(module, folder)
 	}
 	return nil, module
 }
 
+// collectModules explores the folder recursively to collect the folders of 'go.mod', the hidden and the vendor
+// directories are skipped, as well as the ones excluded by the filter. The symlinked directories are followed once by
+// the visitor.
+func collectModules(folder string, visitor dirVisitor, filter *scanFilter) ([]string, error) {
+	if !visitor.visit(folder) {
+		return nil, nil
+	}
+	fileInfo, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	filter = filter.enter(folder)
+	var module []string
+	for _, info := range fileInfo {
+		if info.Name() == "go.mod" && !info.IsDir() {
+			module = append(module, folder)
+		}
+	}
+	for _, info := range fileInfo {
+		subdir := filepath.Join(folder, info.Name())
+		if name := info.Name(); name[0] != '.' && name != "vendor" && isDir(folder, info) && !filter.excluded(subdir, true) {
+			// The unreadable sub directories are skipped.
+			sub, _ := collectModules(subdir, visitor, filter)
+			module = append(module, sub...)
+		}
+	}
+	return module, nil
+}
+
 // collectUncoveredSrc explores the rootPath recursively, collects
 //  - folders need to be covered, which we will create a module to cover all these folders.
 //  - folders need to create a module.
-func collectUncoveredSrc(path string) ([][]string, []string, error) {
-	var folderUncovered [][]string
+// The directories and the files excluded by the filter are skipped, and the symlinked directories are followed once by
+// the visitor.
+func collectUncoveredSrc(path string, visitor dirVisitor, filter *scanFilter) ([]string, []string, error) {
+	var folderUncovered []string
 	var folderNeedMod []string
+	if !visitor.visit(path) {
+		return nil, nil, nil
+	}
 	if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
 		return nil, nil, nil
 	}
@@ -559,25 +1224,49 @@ func collectUncoveredSrc(path string) ([][]string, []string, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	filter = filter.enter(path)
 	for _, info := range fileInfo {
+		sub := filepath.Join(path, info.Name())
+		dir := isDir(path, info)
+		if filter.excluded(sub, dir) {
+			continue
+		}
 		if !shouldBeCovered && filepath.Ext(info.Name()) == ".go" && !strings.HasSuffix(info.Name(), "_test.go") {
 			shouldBeCovered = true
 		}
-		if info.IsDir() && info.Name()[0] != '.' {
-			uncovered, mod, e := collectUncoveredSrc(filepath.Join(path, info.Name()))
+		if info.Name()[0] != '.' && dir {
+			uncovered, mod, e := collectUncoveredSrc(sub, visitor, filter)
 			folderNeedMod = append(folderNeedMod, mod...)
 			folderUncovered = append(folderUncovered, uncovered...)
 			err = e
 		}
 	}
 	if shouldBeCovered {
-		folderUncovered = append(folderUncovered, strings.Split(path, string(filepath.Separator)))
+		folderUncovered = append(folderUncovered, path)
 	}
 	return folderUncovered, folderNeedMod, err
 }
 
-func constructDetailSymbol(s *ElasticServer, ctx context.Context, params *protocol.DocumentSymbolParams, pkgLocator *protocol.PackageLocator) (detailSyms []protocol.DetailSymbolInformation, err error) {
-	docSyms, err := (*Server).DocumentSymbol(&s.Server, ctx, params)
+// constructDetailSymbol collects the symbols defined in the document, along with the labels, sorted by sortSymbols.
+func constructDetailSymbol(ctx context.Context, view source.View, pkg source.Package, ordinals declOrdinals, uri protocol.DocumentURI, pkgLocator *protocol.PackageLocator) (detailSyms []protocol.DetailSymbolInformation, err error) {
+	docSyms, err := source.PackageDocumentSymbols(ctx, view, pkg, span.NewURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	ph, err := pkg.File(span.NewURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The document symbols are in the source order, so the repeated package level declarations of the file, like the
+	// 'init' functions, are named in the same order.
+	repeated := make(map[string][]string)
+	for _, id := range repeatableDecls(file) {
+		repeated[id.Name] = append(repeated[id.Name], ordinals.name(id))
+	}
 
 	var flattenDocumentSymbol func(*[]protocol.DocumentSymbol, string, string)
 	// Note: The reason why we construct the qname during the flatten process is that we can't construct the qname
@@ -590,19 +1279,21 @@ func constructDetailSymbol(s *ElasticServer, ctx context.Context, params *protoc
 				Deprecated:    symbol.Deprecated,
 				ContainerName: container,
 				Location: protocol.Location{
-					URI:   params.TextDocument.URI,
+					URI:   uri,
 					Range: symbol.SelectionRange,
 				},
 			}
 			var qnamePrefix string
 			if prefix != "" {
 				qnamePrefix = prefix + "." + symbol.Name
+			} else if names := repeated[symbol.Name]; len(names) > 0 {
+				qnamePrefix, repeated[symbol.Name] = names[0], names[1:]
 			} else {
 				qnamePrefix = symbol.Name
 			}
 			detailSyms = append(detailSyms, protocol.DetailSymbolInformation{
 				Symbol:  sym,
-				Qname:   pkgLocator.Name + "." + qnamePrefix,
+				Qname:   truncateQname(pkgLocator.Name+"."+qnamePrefix, view.Options().MaxQnameDepth),
 				Package: *pkgLocator,
 			})
 			if len(symbol.Children) > 0 {
@@ -612,10 +1303,35 @@ func constructDetailSymbol(s *ElasticServer, ctx context.Context, params *protoc
 	}
 
 	flattenDocumentSymbol(&docSyms, "", "")
+	for _, label := range collectLabels(view.Session().Cache().FileSet(), file, m, pkg.GetTypesInfo(), uri, ordinals) {
+		label.Qname = truncateQname(label.Qname, view.Options().MaxQnameDepth)
+		label.Package = *pkgLocator
+		detailSyms = append(detailSyms, label)
+	}
+
+	// Attach the enum-like groups to the constants.
+	style := view.Options().QnameStyle
+	versions := map[*types.Package]string{pkg.GetTypes(): pkgLocator.Version}
+	qualify := func(qname string, p *types.Package) string {
+		if _, ok := versions[p]; !ok && style == source.ImportPathVersionQname {
+			loc := packageFileOf(view.Session().Cache().FileSet(), p)
+			versions[p] = collectPkgMetadata(p, view.Folder().Filename(), loc, view.Options()).Version
+		}
+		return qualifyQname(qname, p, versions[p], style)
+	}
+	groups := collectConstGroups(file, pkg.GetTypesInfo(), pkg.GetTypes(), qualify)
+	for i := range detailSyms {
+		if group, ok := groups[detailSyms[i].Qname]; ok && detailSyms[i].Symbol.Kind == protocol.Constant {
+			detailSyms[i].ConstGroup = &group
+		}
+		// The qualified names are styled at last, since the groups are keyed by the short forms.
+		detailSyms[i].Qname = qualify(detailSyms[i].Qname, pkg.GetTypes())
+	}
+	sortSymbols(detailSyms)
 	return
 }
 
-func getModulePath(folder string) string {
+func getModulePath(options source.Options, folder string, env []string) string {
 	// findModulePath is copied from 'go/src/cmd/go/internal/modload/init.go'.
 	// TODO(henrywong) The best approach to guess the module path is `go mod init`, see
 	//  https://github.com/golang/go/blob/release-branch.go1.12/src/cmd/go/alldocs.go#L1040. However in order to get rid
@@ -684,25 +1400,41 @@ func getModulePath(folder string) string {
 		return "", fmt.Errorf(msg, folder)
 	}
 	modulePath, err := findModulePath()
-	if err != nil {
-		list := strings.Split(folder, string(filepath.Separator)+"__")
-		if len(list) != 2 {
-			return folder
-		}
-		prefixList := strings.Split(list[0], string(filepath.Separator))
-		suffixList := strings.Split(list[1], string(filepath.Separator))
-		if len(prefixList) < 4 {
-			return folder
-		}
-		// concatenate 'code host/owner/repo'
-		modulePath = strings.Join(prefixList[len(prefixList)-3:], "/")
-		if len(suffixList) < 3 {
-			return modulePath
+	if err == nil {
+		return modulePath
+	}
+	// Without the import comments or the manifests, the remote of the repository is the most reliable hint, and the
+	// layout of the folder is the last resort.
+	if modulePath, ok := modulePathOfRemote(options, folder, env); ok {
+		return modulePath
+	}
+	return modulePathOfLayout(hostPaths, folder)
+}
+
+// modulePathOfLayout guesses the module path by the layout of the folder cloned by the code search, like
+// '.../github.com/owner/repo/__<hash>/<branch>/sub' for the module 'github.com/owner/repo/sub'. The folder is
+// returned if it isn't in the layout.
+func modulePathOfLayout(f pathFlavor, folder string) string {
+	_, elems := f.split(folder)
+	marker := -1
+	for i, elem := range elems {
+		if strings.HasPrefix(elem, "__") {
+			if marker >= 0 {
+				return folder
+			}
+			marker = i
 		}
-		// Skip the dummy hash folder and branch name, concatenates remain elements for the submodule cases.
-		modulePath = strings.Join(append([]string{modulePath}, suffixList[2:]...), "/")
 	}
-	return modulePath
+	if marker < 3 {
+		return folder
+	}
+	// concatenate 'code host/owner/repo'
+	modulePath := strings.Join(elems[marker-3:marker], "/")
+	if len(elems)-marker < 3 {
+		return modulePath
+	}
+	// Skip the dummy hash folder and branch name, concatenates remain elements for the submodule cases.
+	return strings.Join(append([]string{modulePath}, elems[marker+2:]...), "/")
 }
 
 func constructGoModManually(folder string, modulePath string) error {
@@ -723,19 +1455,25 @@ func constructGoModManually(folder string, modulePath string) error {
 }
 
 var (
-	storeVendorFolder, checkVendorFolder, clearVendorFolder = vendorModeHelper()
+	storeVendorFolder, checkVendorFolder, clearVendorFolder, forgetVendorFolders = vendorModeHelper()
 )
 
 // vendorModeHelper are only used to transport the vendor mode related information from 'ManageDeps()' to the 'view'
-// creation. It will return three helpers.
+// creation. It will return four helpers.
 // - one for recording the folders which should be under vendor mode
 // - one for checking whether the folder is under vendor mode
 // - one for clearing the folder when language server jump into new workspace
-func vendorModeHelper() (func(string), func(string) int, func(int)) {
+// - one for forgetting the folders under a workspace folder removed
+func vendorModeHelper() (func(string), func(string) int, func(int), func(string)) {
+	var mu sync.Mutex
 	var folderUnderVendorMode []string
 	return func(folder string) {
+			mu.Lock()
+			defer mu.Unlock()
 			folderUnderVendorMode = append(folderUnderVendorMode, folder)
 		}, func(folder string) int {
+			mu.Lock()
+			defer mu.Unlock()
 			for index, dir := range folderUnderVendorMode {
 				if folder == dir {
 					return index
@@ -755,11 +1493,23 @@ func vendorModeHelper() (func(string), func(string) int, func(int)) {
 			}
 			return -1
 		}, func(index int) {
+			mu.Lock()
+			defer mu.Unlock()
 			length := len(folderUnderVendorMode)
 			if index < 0 || index >= length {
 				return
 			}
 			folderUnderVendorMode[index] = folderUnderVendorMode[length-1]
 			folderUnderVendorMode = folderUnderVendorMode[:length-1]
+		}, func(root string) {
+			mu.Lock()
+			defer mu.Unlock()
+			kept := folderUnderVendorMode[:0]
+			for _, dir := range folderUnderVendorMode {
+				if !hostPaths.hasPrefix(dir, root) {
+					kept = append(kept, dir)
+				}
+			}
+			folderUnderVendorMode = kept
 		}
 }
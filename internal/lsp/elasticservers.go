@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"context"
+	"time"
+)
+
+// ServerOptions are the settings of the servers given by the operator of the process, like the flags of 'gopls serve',
+// unlike the options of the sessions given by the clients.
+type ServerOptions struct {
+	// IdleTimeout shuts down the servers gracefully once none of their requests has been active for the duration, zero
+	// means never.
+	IdleTimeout time.Duration
+	// TypeCheckLimit bounds the concurrent type-checks of all the connections, the type-checks beyond the limit wait in
+	// the queue of TypeCheckQueue for up to TypeCheckTimeout. The zero limit means no limit and the zero timeout means
+	// no timeout.
+	TypeCheckLimit   int
+	TypeCheckQueue   int
+	TypeCheckTimeout time.Duration
+	// ExecWrapper runs the go and git commands given as its trailing arguments, like a container or a chroot configured
+	// by the operator. If ExecRestrictEnv is set, only the variables needed by the toolchain are passed to the commands,
+	// and the commands running longer than ExecTimeout are killed, the zero timeout means no timeout.
+	ExecWrapper     []string
+	ExecRestrictEnv bool
+	ExecTimeout     time.Duration
+	// KillSubprocessesOnExit kills the process groups of the running commands once the process is interrupted or
+	// terminated, it's meant for the process serving until it exits by the signal.
+	KillSubprocessesOnExit bool
+}
+
+// Servers are the servers sharing the server options, like the ones of the connections accepted by one listener. The
+// idle timeout applies to their activity as a whole, the limit of the type-checks to all their connections, and their
+// commands run in the same sandbox. The nil servers share nothing and never shut down.
+type Servers struct {
+	options    ServerOptions
+	activity   activity
+	typeChecks *admission
+	sandbox    sandbox
+}
+
+// NewServers returns the servers sharing the options, and the context which is canceled once they've been shut down
+// by the idle timeout. The servers stop serving once the context is canceled.
+func NewServers(ctx context.Context, options ServerOptions) (context.Context, *Servers) {
+	servers := &Servers{
+		options:    options,
+		typeChecks: newAdmission(options.TypeCheckLimit, options.TypeCheckQueue, options.TypeCheckTimeout),
+		sandbox: sandbox{
+			wrapper:     append([]string{}, options.ExecWrapper...),
+			restrictEnv: options.ExecRestrictEnv,
+			timeout:     options.ExecTimeout,
+		},
+	}
+	servers.activity.last = time.Now()
+	if options.KillSubprocessesOnExit {
+		killSubprocessesOnExit()
+	}
+	return servers.shutdownWhenIdle(ctx), servers
+}
+
+// touch records the activity of the servers happened just now.
+func (servers *Servers) touch() {
+	if servers == nil {
+		return
+	}
+	servers.activity.touch()
+}
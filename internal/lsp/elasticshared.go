@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// sharedMetadata is the process-wide cache of the metadata shared by the sessions, like the ones indexing the
+// different branches of the same repository, so that the metadata is resolved once per process rather than once per
+// session.
+var sharedMetadata sharedCache
+
+// The kinds of the entries of the shared metadata.
+const (
+	// The repository URL resolved by the import path.
+	repoRootKind = "repoRoot"
+	// The live repository URL resolved by following the redirects of the repository URL.
+	repoRedirectKind = "repoRedirect"
+	// The parsed 'vendor/modules.txt' of the content.
+	vendorManifestKind = "vendorManifest"
+)
+
+// sharedCache holds the entries addressed by the content they're derived from, like the hash of a file or the import
+// path resolved, so that the entries never go stale and are safe to share by the sessions. The concurrent
+// computations of the same entry are done once, the failed ones aren't cached.
+type sharedCache struct {
+	mu      sync.Mutex
+	entries map[string]interface{}
+	calls   flightGroup
+}
+
+// contentKey returns the key of the entry of the kind derived from the content.
+func contentKey(kind string, content []byte) string {
+	return fmt.Sprintf("%s:%x", kind, sha1.Sum(content))
+}
+
+// nameKey returns the key of the entry of the kind derived from the name, like an import path.
+func nameKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+func (c *sharedCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[key]
+	return v, ok
+}
+
+// put stores the entry, the existing entry is replaced only if replace is set.
+func (c *sharedCache) put(key string, v interface{}, replace bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]interface{})
+	}
+	if _, ok := c.entries[key]; ok && !replace {
+		return
+	}
+	c.entries[key] = v
+}
+
+func (c *sharedCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// load returns the entry of the key, which is computed by fn if it's missing.
+func (c *sharedCache) load(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if v, ok := c.get(key); ok {
+		return v, nil
+	}
+	return c.calls.do(context.Background(), key, func(context.Context) (interface{}, error) {
+		if v, ok := c.get(key); ok {
+			return v, nil
+		}
+		v, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, v, false)
+		return v, nil
+	})
+}
+
+// strings returns the string entries of the kind keyed by the names they're derived from.
+func (c *sharedCache) strings(kind string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]string)
+	for key, v := range c.entries {
+		if s, ok := v.(string); ok && strings.HasPrefix(key, kind+":") {
+			m[strings.TrimPrefix(key, kind+":")] = s
+		}
+	}
+	return m
+}
@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSharedCache(t *testing.T) {
+	var c sharedCache
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.load(nameKey(repoRootKind, "example.com/a"), func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return "https://example.com/a", nil
+			})
+			if err != nil || v != "https://example.com/a" {
+				t.Errorf("got %v, %v, want the repository", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("got %d computations, want 1", calls)
+	}
+
+	// The failures are retried.
+	fail := errors.New("unreachable")
+	if _, err := c.load(nameKey(repoRootKind, "example.com/b"), func() (interface{}, error) { return nil, fail }); err != fail {
+		t.Errorf("got %v, want %v", err, fail)
+	}
+	if _, ok := c.get(nameKey(repoRootKind, "example.com/b")); ok {
+		t.Errorf("the failure is expected not to be cached")
+	}
+
+	c.put(nameKey(repoRedirectKind, "https://example.com/a"), "https://example.com/moved", false)
+	c.put(nameKey(repoRedirectKind, "https://example.com/a"), "https://example.com/other", false)
+	if got := c.strings(repoRedirectKind); len(got) != 1 || got["https://example.com/a"] != "https://example.com/moved" {
+		t.Errorf("got the redirects %v, want the first one kept", got)
+	}
+}
+
+func TestSharedVendorManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manifest := "# example.com/dep v1.2.3\nexample.com/dep\n"
+	// The checkouts of the different branches vendor the same modules.
+	var vendorDirs []string
+	for _, branch := range []string{"main", "release"} {
+		vendorDir := filepath.Join(dir, branch, "vendor")
+		if err := os.MkdirAll(vendorDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(vendorDir, "modules.txt"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		vendorDirs = append(vendorDirs, vendorDir)
+	}
+	main, err := loadVendorManifest(vendorDirs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := loadVendorManifest(vendorDirs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main != release {
+		t.Errorf("the manifests of the same content are expected to be shared")
+	}
+	if mod := main.lookup("example.com/dep"); mod == nil || mod.Version != "v1.2.3" {
+		t.Errorf("got the module %+v, want example.com/dep v1.2.3", mod)
+	}
+}
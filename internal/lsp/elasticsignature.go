@@ -0,0 +1,22 @@
+package lsp
+
+import (
+	"context"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// SignatureHelp serves the signature help of the embedded server, which derives the parameters and the active
+// parameter from the type information, under the bounds of the type-checks of the elastic server, since the light
+// editing of the client may request it at every keystroke.
+func (s *ElasticServer) SignatureHelp(ctx context.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
+	if err := s.rejectUnderPressure(); err != nil {
+		return nil, err
+	}
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return s.Server.SignatureHelp(ctx, params)
+}
@@ -0,0 +1,48 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	dir := newTestDir(t, "signature", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "package a\n\nimport \"strings\"\n\nvar _ = strings.Repeat(\"a\", 2)\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "a", source.DefaultOptions)
+	params := &protocol.SignatureHelpParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))},
+		Position:     protocol.Position{Line: 4, Character: 28},
+	}}
+
+	help, err := s.SignatureHelp(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if help == nil || len(help.Signatures) != 1 || help.Signatures[0].Label != "Repeat(s string, count int) string" || help.ActiveParameter != 1 {
+		t.Errorf("got the signature help %+v, want Repeat with the count active", help)
+	}
+
+	// The signature help waits for the type-checks like the other requests.
+	s.typeChecks = newAdmission(1, 0, time.Millisecond)
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	_, err = s.SignatureHelp(ctx, params)
+	if rpcErr, ok := err.(*jsonrpc2.Error); !ok || rpcErr.Code != jsonrpc2.CodeServerOverloaded {
+		t.Errorf("got %v, want the overloaded error", err)
+	}
+}
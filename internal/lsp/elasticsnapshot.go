@@ -0,0 +1,19 @@
+package lsp
+
+import (
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// snapshotOf returns the snapshot of the view which a request is served as of, the zero ID denotes the current
+// snapshot. The replaced snapshots are only available if they are still retained by the view, see the option
+// 'snapshotHistory'.
+func snapshotOf(view source.View, id uint64) (source.Snapshot, error) {
+	if id == 0 {
+		return view.Snapshot(), nil
+	}
+	if snapshot, ok := view.SnapshotAt(id); ok {
+		return snapshot, nil
+	}
+	return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "snapshot %d is no longer retained", id)
+}
@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestSnapshotHistory(t *testing.T) {
+	dir := newTestDir(t, "history", map[string]string{"a.go": "package a\n"})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.go")
+
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.SnapshotHistory = 2
+	_, view := newTestServer(ctx, dir, "history", options)
+	uri := span.FileURI(path)
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentAt := func(snapshot source.Snapshot) string {
+		data, _, err := snapshot.Handle(ctx, f).Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	var ids []uint64
+	for _, content := range []string{"package a // 1\n", "package a // 2\n", "package a // 3\n"} {
+		if _, err := view.SetContent(ctx, uri, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		snapshot := view.Snapshot()
+		if got := contentAt(snapshot); got != content {
+			t.Fatalf("got content %q, want %q", got, content)
+		}
+		ids = append(ids, snapshot.ID())
+	}
+	if snapshot, err := snapshotOf(view, 0); err != nil || snapshot.ID() != ids[2] {
+		t.Errorf("got snapshot %v (%v), want the current one", snapshot, err)
+	}
+	// The snapshots replaced by the last two edits are retained.
+	if snapshot, err := snapshotOf(view, ids[1]); err != nil {
+		t.Error(err)
+	} else if got := contentAt(snapshot); got != "package a // 2\n" {
+		t.Errorf("got content %q of snapshot %d", got, ids[1])
+	}
+	if _, err := snapshotOf(view, ids[0]-1); err == nil {
+		t.Errorf("snapshot %d is expected to be dropped", ids[0]-1)
+	}
+	view.EvictPackages(ctx, 1)
+	if _, err := snapshotOf(view, ids[1]); err == nil {
+		t.Errorf("the retained snapshots are expected to be dropped under the eviction")
+	}
+}
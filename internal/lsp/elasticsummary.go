@@ -0,0 +1,273 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	"golang.org/x/tools/internal/xcontext"
+)
+
+// sessionStats accumulates the statistics of the session, which are summarized at the shutdown so that the
+// orchestrator can spot the regressions and the problematic repositories. The nil stats discard everything.
+type sessionStats struct {
+	mu                 sync.Mutex
+	start              time.Time
+	folders            []string
+	modulesSynthesized []string
+	depsDownloaded     int
+	depsFailed         int
+	depsFailures       []protocol.DepsFailure
+	modCacheBytes      int64
+	modCacheEvictions  int
+	requests           map[string]int
+	errors             map[string]int
+	peakMemory         uint64
+
+	// The loads of the packages by the packageLoader, their total and longest time, and the current and the peak depth
+	// of the queue.
+	loads         int
+	loadTime      time.Duration
+	slowestLoad   time.Duration
+	loadQueue     int
+	peakLoadQueue int
+}
+
+func newSessionStats() *sessionStats {
+	return &sessionStats{
+		start:    time.Now(),
+		requests: make(map[string]int),
+		errors:   make(map[string]int),
+	}
+}
+
+func (st *sessionStats) folderManaged(folder string) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.folders = append(st.folders, folder)
+}
+
+func (st *sessionStats) moduleSynthesized(folder string) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.modulesSynthesized = append(st.modulesSynthesized, folder)
+}
+
+func (st *sessionStats) depsDownload(ok bool) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if ok {
+		st.depsDownloaded++
+	} else {
+		st.depsFailed++
+	}
+}
+
+// depsFailure retains the details of the failed download, the latest one replaces the former of the same folder.
+func (st *sessionStats) depsFailure(failure protocol.DepsFailure) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i := range st.depsFailures {
+		if st.depsFailures[i].Folder == failure.Folder {
+			st.depsFailures[i] = failure
+			return
+		}
+	}
+	st.depsFailures = append(st.depsFailures, failure)
+}
+
+func (st *sessionStats) modCacheAdded(bytes int64) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.modCacheBytes += bytes
+}
+
+func (st *sessionStats) modCacheEvicted(versions int) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.modCacheEvictions += versions
+}
+
+// memorySampled records the resident memory sampled, the peak is tracked in case the OS doesn't report it.
+func (st *sessionStats) memorySampled(rss uint64) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if rss > st.peakMemory {
+		st.peakMemory = rss
+	}
+}
+
+// loadQueued adds delta to the depth of the queue of the loads, which are queued at once and leave the queue one by
+// one once started.
+func (st *sessionStats) loadQueued(delta int) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.loadQueue += delta
+	if st.loadQueue > st.peakLoadQueue {
+		st.peakLoadQueue = st.loadQueue
+	}
+}
+
+// queuedLoads returns the number of the loads waiting in the queue.
+func (st *sessionStats) queuedLoads() int {
+	if st == nil {
+		return 0
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.loadQueue
+}
+
+func (st *sessionStats) loaded(d time.Duration) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.loads++
+	st.loadTime += d
+	if d > st.slowestLoad {
+		st.slowestLoad = d
+	}
+}
+
+// summary returns the summary of the session so far.
+func (st *sessionStats) summary() protocol.SessionSummary {
+	st.memorySampled(currentRSS())
+	st.memorySampled(peakRSS())
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	summary := protocol.SessionSummary{
+		Folders:            append([]string{}, st.folders...),
+		ModulesSynthesized: append([]string{}, st.modulesSynthesized...),
+		DepsDownloaded:     st.depsDownloaded,
+		DepsFailed:         st.depsFailed,
+		ModCacheAdded:      st.modCacheBytes,
+		ModCacheEvicted:    st.modCacheEvictions,
+		Requests:           make(map[string]int, len(st.requests)),
+		Errors:             make(map[string]int, len(st.errors)),
+		PeakMemory:         st.peakMemory,
+		Uptime:             time.Since(st.start).Seconds(),
+		Loads:              st.loads,
+		LoadTime:           st.loadTime.Seconds(),
+		SlowestLoad:        st.slowestLoad.Seconds(),
+		PeakLoadQueue:      st.peakLoadQueue,
+	}
+	if len(st.depsFailures) > 0 {
+		summary.DepsFailures = append([]protocol.DepsFailure{}, st.depsFailures...)
+	}
+	for method, n := range st.requests {
+		summary.Requests[method] = n
+	}
+	for method, n := range st.errors {
+		summary.Errors[method] = n
+	}
+	return summary
+}
+
+// peakRSS returns the peak resident set size of the process in bytes reported by '/proc/self/status', it returns 0
+// if it's unavailable.
+func peakRSS() uint64 {
+	data, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "VmHWM:" {
+			continue
+		}
+		if kb, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			return kb << 10
+		}
+	}
+	return 0
+}
+
+type statsMethodKey struct{}
+
+// statsHandler counts the requests received by the methods and the ones replied with the errors.
+type statsHandler struct {
+	jsonrpc2.EmptyHandler
+	stats *sessionStats
+}
+
+func (h *statsHandler) Request(ctx context.Context, direction jsonrpc2.Direction, r *jsonrpc2.WireRequest) context.Context {
+	if direction != jsonrpc2.Receive {
+		return ctx
+	}
+	h.stats.mu.Lock()
+	h.stats.requests[r.Method]++
+	h.stats.mu.Unlock()
+	return context.WithValue(ctx, statsMethodKey{}, r.Method)
+}
+
+func (h *statsHandler) Response(ctx context.Context, direction jsonrpc2.Direction, r *jsonrpc2.WireResponse) context.Context {
+	method, ok := ctx.Value(statsMethodKey{}).(string)
+	if direction != jsonrpc2.Send || r.Error == nil || !ok {
+		return ctx
+	}
+	h.stats.mu.Lock()
+	h.stats.errors[method]++
+	h.stats.mu.Unlock()
+	return ctx
+}
+
+// emitSummary sends the summary of the session by the 'elastic/sessionSummary' notification, and writes it into the
+// file specified by the option 'sessionSummaryFile' if any.
+func (s *ElasticServer) emitSummary(ctx context.Context) {
+	if s.stats == nil {
+		return
+	}
+	summary := s.stats.summary()
+	if path := s.session.Options().SessionSummaryFile; path != "" {
+		data, err := json.MarshalIndent(summary, "", "\t")
+		if err == nil {
+			err = ioutil.WriteFile(path, data, 0644)
+		}
+		if err != nil {
+			log.Error(ctx, "failed to write the session summary", err, tag.Of("File", path))
+		}
+	}
+	if s.Conn == nil || !s.clientCaps.notifies("elastic/sessionSummary") {
+		return
+	}
+	if err := s.Conn.Notify(xcontext.Detach(ctx), "elastic/sessionSummary", &summary); err != nil {
+		log.Error(ctx, "failed to notify the session summary", err)
+	}
+}
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestSessionSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	stats := newSessionStats()
+	h := &statsHandler{stats: stats}
+	serve := func(method string, failed bool) {
+		reqCtx := h.Request(ctx, jsonrpc2.Receive, &jsonrpc2.WireRequest{Method: method})
+		resp := &jsonrpc2.WireResponse{}
+		if failed {
+			resp.Error = jsonrpc2.NewErrorf(jsonrpc2.CodeInternalError, "failed")
+		}
+		h.Response(reqCtx, jsonrpc2.Send, resp)
+	}
+	serve("textDocument/full", false)
+	serve("textDocument/full", true)
+	serve("textDocument/edefinition", false)
+	// The requests sent to the client aren't counted.
+	h.Request(ctx, jsonrpc2.Send, &jsonrpc2.WireRequest{Method: "window/showMessage"})
+	stats.folderManaged("/repo")
+	stats.moduleSynthesized("/repo/legacy")
+	stats.depsDownload(true)
+	stats.depsDownload(false)
+	stats.depsFailure(protocol.DepsFailure{Folder: "/repo/legacy", Attempts: 1, Error: "404 Not Found"})
+	stats.depsFailure(protocol.DepsFailure{Folder: "/repo/legacy", Attempts: 3, Transient: true, Error: "503 Service Unavailable"})
+
+	options := source.DefaultOptions
+	options.SessionSummaryFile = filepath.Join(dir, "summary.json")
+	s := newTestSessionServer(ctx, options)
+	s.stats = stats
+	s.emitSummary(ctx)
+
+	data, err := ioutil.ReadFile(options.SessionSummaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got protocol.SessionSummary
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.PeakMemory == 0 || got.Uptime <= 0 {
+		t.Errorf("got peak memory %d and uptime %v, want both positive", got.PeakMemory, got.Uptime)
+	}
+	got.PeakMemory, got.Uptime = 0, 0
+	want := protocol.SessionSummary{
+		Folders:            []string{"/repo"},
+		ModulesSynthesized: []string{"/repo/legacy"},
+		DepsDownloaded:     1,
+		DepsFailed:         1,
+		DepsFailures:       []protocol.DepsFailure{{Folder: "/repo/legacy", Attempts: 3, Transient: true, Error: "503 Service Unavailable"}},
+		Requests:           map[string]int{"textDocument/full": 2, "textDocument/edefinition": 1},
+		Errors:             map[string]int{"textDocument/full": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got summary %+v, want %+v", got, want)
+	}
+
+	// The servers without the stats emit nothing.
+	(&ElasticServer{Server: Server{session: s.session}}).emitSummary(ctx)
+}
@@ -0,0 +1,197 @@
+package lsp
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/fuzzy"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// defaultSymbolLimit is the maximum number of the symbols returned by a search if the client doesn't specify one.
+const defaultSymbolLimit = 100
+
+// symbolIndex holds the detail symbols of the workspace files by the versions of the files, it's filled by the 'full'
+// requests and the searches.
+type symbolIndex struct {
+	mu    sync.Mutex
+	files map[span.URI]indexedFile
+}
+
+type indexedFile struct {
+	version string
+	// The hash of the content of the file, which validates the symbols restored from the warm state.
+	hash     string
+	symbols  []protocol.DetailSymbolInformation
+	restored bool
+}
+
+func (idx *symbolIndex) get(uri span.URI, version string) ([]protocol.DetailSymbolInformation, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	f, ok := idx.files[uri]
+	return f.symbols, ok && f.version == version
+}
+
+func (idx *symbolIndex) update(uri span.URI, version, hash string, symbols []protocol.DetailSymbolInformation) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.files == nil {
+		idx.files = make(map[span.URI]indexedFile)
+	}
+	idx.files[uri] = indexedFile{version: version, hash: hash, symbols: symbols}
+}
+
+// forget drops the symbols of the files under the folder.
+func (idx *symbolIndex) forget(folder string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for uri := range idx.files {
+		if hostPaths.hasPrefix(uri.Filename(), folder) {
+			delete(idx.files, uri)
+		}
+	}
+}
+
+// restored returns the symbols of the file restored from the warm state if the content of the file is unchanged, the
+// symbols are bound to the version of the file then. The hash of the content is only computed if there are such
+// symbols.
+func (idx *symbolIndex) restored(uri span.URI, version string, hash func() string) ([]protocol.DetailSymbolInformation, bool) {
+	idx.mu.Lock()
+	f, ok := idx.files[uri]
+	idx.mu.Unlock()
+	if !ok || !f.restored || f.hash == "" || f.hash != hash() {
+		return nil, false
+	}
+	idx.update(uri, version, f.hash, f.symbols)
+	return f.symbols, true
+}
+
+// Symbol implements 'workspace/symbol' by the fuzzy matching of the symbol names.
+func (s *ElasticServer) Symbol(ctx context.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	detailSyms, err := s.ESymbol(ctx, &protocol.ESymbolParams{Query: params.Query, Match: protocol.FuzzyMatch})
+	if err != nil {
+		return nil, err
+	}
+	syms := make([]protocol.SymbolInformation, 0, len(detailSyms))
+	for _, sym := range detailSyms {
+		syms = append(syms, sym.Symbol)
+	}
+	return syms, nil
+}
+
+// ESymbol searches the symbols across all the workspace folders by the qualified names, the query is matched against
+// the qualified names exactly, by prefix or fuzzily.
+func (s *ElasticServer) ESymbol(ctx context.Context, params *protocol.ESymbolParams) ([]protocol.DetailSymbolInformation, error) {
+	match := symbolMatcher(params.Query, params.Match)
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultSymbolLimit
+	}
+	type scored struct {
+		sym   protocol.DetailSymbolInformation
+		score float32
+	}
+	var results []scored
+	for _, view := range s.session.Views() {
+		err := walkGoFiles(view.Folder().Filename(), func(path string) {
+			if ctx.Err() != nil {
+				return
+			}
+			for _, sym := range s.indexedSymbols(ctx, view, span.FileURI(path)) {
+				if score := match(sym); score > 0 {
+					results = append(results, scored{sym, score})
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].sym.Qname < results[j].sym.Qname
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	syms := make([]protocol.DetailSymbolInformation, 0, len(results))
+	for _, r := range results {
+		syms = append(syms, r.sym)
+	}
+	return syms, nil
+}
+
+// symbolMatcher returns the function scoring the symbols against the query, zero means the symbol doesn't match.
+func symbolMatcher(query string, match protocol.SymbolMatch) func(protocol.DetailSymbolInformation) float32 {
+	switch match {
+	case protocol.ExactMatch:
+		return func(sym protocol.DetailSymbolInformation) float32 {
+			if sym.Qname == query {
+				return 1
+			}
+			return 0
+		}
+	case protocol.PrefixMatch:
+		return func(sym protocol.DetailSymbolInformation) float32 {
+			if strings.HasPrefix(sym.Qname, query) {
+				// Prefer the shorter names, i.e. the closer matches.
+				return 1 / float32(1+len(sym.Qname)-len(query))
+			}
+			return 0
+		}
+	}
+	if query == "" {
+		return func(protocol.DetailSymbolInformation) float32 { return 1 }
+	}
+	matcher := fuzzy.NewMatcher(query, fuzzy.Symbol)
+	return func(sym protocol.DetailSymbolInformation) float32 {
+		return matcher.Score(sym.Qname)
+	}
+}
+
+// indexedSymbols returns the detail symbols of the file, they are computed if the version of the file isn't indexed.
+func (s *ElasticServer) indexedSymbols(ctx context.Context, view source.View, uri span.URI) []protocol.DetailSymbolInformation {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil
+	}
+	fh := view.Snapshot().Handle(ctx, f)
+	version := fh.Identity().Version
+	if syms, ok := s.symbols.get(uri, version); ok {
+		return syms
+	}
+	if syms, ok := s.symbols.restored(uri, version, func() string { return contentHash(ctx, fh) }); ok {
+		return syms
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil
+	}
+	cph := source.NarrowestCheckPackageHandle(cphs)
+	pkg, err := cph.Check(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to index the symbols", err, tag.Of("File", uri))
+		return nil
+	}
+	s.packages.use(ctx, view, cph)
+	pkgLocator := collectPkgMetadata(pkg.GetTypes(), view.Folder().Filename(), uri.Filename(), view.Options())
+	ordinals := widestDeclOrdinals(ctx, view.Session().Cache().FileSet(), cphs)
+	syms, err := constructDetailSymbol(ctx, view, pkg, ordinals, protocol.NewURI(uri), &pkgLocator)
+	if err != nil {
+		return nil
+	}
+	s.symbols.update(uri, version, contentHash(ctx, fh), syms)
+	return syms
+}
@@ -0,0 +1,48 @@
+package lsp
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+func TestSymbolMatcher(t *testing.T) {
+	sym := func(qname string) protocol.DetailSymbolInformation {
+		return protocol.DetailSymbolInformation{Qname: qname}
+	}
+	for _, test := range []struct {
+		query string
+		match protocol.SymbolMatch
+		qname string
+		want  bool
+	}{
+		{"pkg.Server.Run", protocol.ExactMatch, "pkg.Server.Run", true},
+		{"pkg.Server", protocol.ExactMatch, "pkg.Server.Run", false},
+		{"pkg.Server", protocol.PrefixMatch, "pkg.Server.Run", true},
+		{"pkg.Client", protocol.PrefixMatch, "pkg.Server.Run", false},
+		{"SrvRun", protocol.FuzzyMatch, "pkg.Server.Run", true},
+		{"xyz", protocol.FuzzyMatch, "pkg.Server.Run", false},
+		{"", protocol.FuzzyMatch, "pkg.Server.Run", true},
+	} {
+		if got := symbolMatcher(test.query, test.match)(sym(test.qname)) > 0; got != test.want {
+			t.Errorf("%s match of %q against %q: got %v, want %v", test.match, test.query, test.qname, got, test.want)
+		}
+	}
+
+	prefix := symbolMatcher("pkg.S", protocol.PrefixMatch)
+	if prefix(sym("pkg.S")) <= prefix(sym("pkg.Server")) {
+		t.Errorf("expected the closer prefix match to score higher")
+	}
+}
+
+func TestSymbolIndex(t *testing.T) {
+	var idx symbolIndex
+	syms := []protocol.DetailSymbolInformation{{Qname: "pkg.T"}}
+	idx.update("file:///a.go", "v1", "", syms)
+	if got, ok := idx.get("file:///a.go", "v1"); !ok || len(got) != 1 {
+		t.Errorf("got %v, %v for the indexed version", got, ok)
+	}
+	if _, ok := idx.get("file:///a.go", "v2"); ok {
+		t.Errorf("the stale version is reported as indexed")
+	}
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// realDirs caches the real paths of the directories, i.e. the ones with the symlinks resolved, since the locations of
+// the symbols are checked against the folders repeatedly.
+var realDirs = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// realDir returns the real path of the directory, the path is returned as is if it can't be resolved, like the
+// directories which don't exist.
+func realDir(dir string) string {
+	realDirs.Lock()
+	real, ok := realDirs.m[dir]
+	realDirs.Unlock()
+	if ok {
+		return real
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		real = dir
+	}
+	realDirs.Lock()
+	realDirs.m[dir] = real
+	realDirs.Unlock()
+	return real
+}
+
+// realPath returns the real path of the file by resolving its directory.
+func realPath(loc string) string {
+	return filepath.Join(realDir(filepath.Dir(loc)), filepath.Base(loc))
+}
+
+// inFolder reports whether the file is located in the folder. The folder opened by a symlink, or the files reached
+// through the symlinks, are compared by the real paths, since the loader reports the locations either way.
+func inFolder(loc, folder string) bool {
+	if hostPaths.hasPrefix(loc, folder) {
+		return true
+	}
+	return hostPaths.hasPrefix(realPath(loc), realDir(folder))
+}
+
+// dirVisitor records the directories visited by a walk, the symlinked directories are followed, and the ones already
+// visited by their real paths are skipped, so that the cycles formed by the symlinks never loop the walk.
+type dirVisitor map[string]bool
+
+// visit reports whether the directory is visited for the first time.
+func (v dirVisitor) visit(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		real = dir
+	}
+	real = hostPaths.clean(real)
+	if v[real] {
+		return false
+	}
+	v[real] = true
+	return true
+}
+
+// isDir reports whether the entry of the directory is a directory or a symlink to a directory.
+func isDir(dir string, info os.FileInfo) bool {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info.IsDir()
+	}
+	target, err := os.Stat(filepath.Join(dir, info.Name()))
+	return err == nil && target.IsDir()
+}
@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestSymlinkedFolders(t *testing.T) {
+	dir := newTestDir(t, "symlink", map[string]string{
+		"repo/main.go":        "package main\n",
+		"repo/lib/lib.go":     "package lib\n",
+		"shared/mod/go.mod":   "module example.com/mod\n",
+		"shared/mod/mod.go":   "package mod\n",
+		"shared/plain/foo.go": "package plain\n",
+	})
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "repo")
+	// The symlinks into the shared trees, and the cycle back to the repository.
+	for link, target := range map[string]string{
+		"repo/mod":      "../shared/mod",
+		"repo/plain":    "../shared/plain",
+		"repo/lib/loop": "..",
+		"link":          "repo",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Skipf("symlinks are unsupported: %v", err)
+		}
+	}
+
+	modules, err := collectModules(repo, dirVisitor{}, newScanFilter(repo, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(repo, "mod"); len(modules) != 1 || modules[0] != want {
+		t.Errorf("got modules %v, want [%s]", modules, want)
+	}
+	uncovered, needMod, err := collectUncoveredSrc(repo, dirVisitor{}, newScanFilter(repo, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(uncovered)
+	want := []string{repo, filepath.Join(repo, "lib"), filepath.Join(repo, "plain")}
+	sort.Strings(want)
+	if len(needMod) != 0 || len(uncovered) != len(want) {
+		t.Fatalf("got uncovered %v and needing modules %v, want uncovered %v", uncovered, needMod, want)
+	}
+	for i := range want {
+		if uncovered[i] != want[i] {
+			t.Errorf("got uncovered %v, want %v", uncovered, want)
+			break
+		}
+	}
+
+	// The files reached by the real paths are located in the folder opened by the symlink, and vice versa.
+	link := filepath.Join(dir, "link")
+	if !inFolder(filepath.Join(repo, "lib", "lib.go"), link) || !inFolder(filepath.Join(link, "main.go"), repo) {
+		t.Errorf("the files are expected to be located in the symlinked folder")
+	}
+	if inFolder(filepath.Join(dir, "shared", "mod", "mod.go"), link) {
+		t.Errorf("the shared file isn't expected to be located in the symlinked folder")
+	}
+}
@@ -0,0 +1,158 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/debug"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// tagEntry is a symbol of the tags file.
+type tagEntry struct {
+	name  string
+	file  string
+	line  int
+	text  string
+	kind  string
+	qname string
+}
+
+// WriteTags writes the tags file of the workspace folder of the view from the same symbols as the 'full' requests,
+// either in the extended format of universal-ctags or in the etags format of Emacs. The ctags entries carry the line
+// numbers and the qualified names as the extension fields, which etags has no room for. The file names are relative
+// to the workspace folder, and the files of the packages failing to be checked are skipped.
+func WriteTags(ctx context.Context, view source.View, w io.Writer, etags bool) error {
+	folder := view.Folder().Filename()
+	var files []string
+	if err := walkGoFiles(folder, func(path string) { files = append(files, path) }); err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	var entries []tagEntry
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fileEntries, lines, err := fileTags(ctx, view, folder, path)
+		if err != nil {
+			log.Error(ctx, "failed to collect the tags of the file", err, tag.Of("File", path))
+			continue
+		}
+		if etags {
+			writeEtagsSection(&buf, fileEntries, lines)
+		}
+		entries = append(entries, fileEntries...)
+	}
+	if !etags {
+		writeCtags(&buf, entries)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// fileTags returns the tags of the file along with its lines.
+func fileTags(ctx context.Context, view source.View, folder, path string) ([]tagEntry, []string, error) {
+	_, _, syms, err := fileSymbols(ctx, view, folder, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	var entries []tagEntry
+	for _, sym := range syms {
+		line := int(sym.Symbol.Location.Range.Start.Line)
+		if line >= len(lines) {
+			continue
+		}
+		entries = append(entries, tagEntry{
+			name:  sym.Symbol.Name,
+			file:  filepath.ToSlash(rel),
+			line:  line + 1,
+			text:  strings.TrimRight(lines[line], "\r\n"),
+			kind:  ctagsKind(sym.Symbol.Kind),
+			qname: sym.Qname,
+		})
+	}
+	return entries, lines, nil
+}
+
+// ctagsKind maps the symbol kind to the kind letter of the Go parser of universal-ctags, the named types other than
+// the structs and the interfaces are the 't' types.
+func ctagsKind(kind protocol.SymbolKind) string {
+	switch kind {
+	case protocol.Function, protocol.Method:
+		return "f"
+	case protocol.Constant:
+		return "c"
+	case protocol.Variable:
+		return "v"
+	case protocol.Field:
+		return "m"
+	case protocol.Struct:
+		return "s"
+	case protocol.Interface:
+		return "i"
+	case protocol.Key:
+		return "l"
+	}
+	return "t"
+}
+
+// writeCtags writes the entries sorted by the names, the files and the lines, as 'LC_ALL=C sort' does.
+func writeCtags(buf *bytes.Buffer, entries []tagEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		if entries[i].file != entries[j].file {
+			return entries[i].file < entries[j].file
+		}
+		return entries[i].line < entries[j].line
+	})
+	fmt.Fprintf(buf, "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n")
+	fmt.Fprintf(buf, "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n")
+	fmt.Fprintf(buf, "!_TAG_PROGRAM_NAME\tgopls\t//\n")
+	fmt.Fprintf(buf, "!_TAG_PROGRAM_VERSION\t%s\t//\n", debug.Version)
+	pattern := strings.NewReplacer(`\`, `\\`, `/`, `\/`)
+	field := strings.NewReplacer(`\`, `\\`, "\t", `\t`)
+	for _, e := range entries {
+		fmt.Fprintf(buf, "%s\t%s\t/^%s$/;\"\t%s\tline:%d\tqname:%s\n", e.name, e.file, pattern.Replace(e.text), e.kind, e.line, field.Replace(e.qname))
+	}
+}
+
+// writeEtagsSection writes the section of the file, whose header is followed by the size of the tag lines. The tag
+// lines carry the explicit names and the byte offsets of the lines.
+func writeEtagsSection(buf *bytes.Buffer, entries []tagEntry, lines []string) {
+	if len(entries) == 0 {
+		return
+	}
+	offsets := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		offsets[i] = offsets[i-1] + len(lines[i-1])
+	}
+	var section bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&section, "%s\x7f%s\x01%d,%d\n", e.text, e.name, e.line, offsets[e.line-1])
+	}
+	fmt.Fprintf(buf, "\x0c\n%s,%d\n", entries[0].file, section.Len())
+	buf.Write(section.Bytes())
+}
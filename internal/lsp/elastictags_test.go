@@ -0,0 +1,50 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestWriteTags(t *testing.T) {
+	dir := newTestDir(t, "tags", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package a\n\ntype T struct {\n\tF int\n}\n\nfunc (T) M() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	_, view := newTestServer(ctx, dir, "m", source.DefaultOptions)
+
+	var buf bytes.Buffer
+	if err := WriteTags(ctx, view, &buf, false); err != nil {
+		t.Fatal(err)
+	}
+	var entries []string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "!_TAG_") {
+			entries = append(entries, line)
+		}
+	}
+	want := []string{
+		"F\ta.go\t/^\tF int$/;\"\tm\tline:4\tqname:a.T.F",
+		"M\ta.go\t/^func (T) M() {}$/;\"\tf\tline:7\tqname:a.T.M",
+		"T\ta.go\t/^type T struct {$/;\"\ts\tline:3\tqname:a.T",
+	}
+	if strings.Join(entries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got the ctags entries\n%s\nwant\n%s", strings.Join(entries, "\n"), strings.Join(want, "\n"))
+	}
+
+	buf.Reset()
+	if err := WriteTags(ctx, view, &buf, true); err != nil {
+		t.Fatal(err)
+	}
+	section := "type T struct {\x7fT\x013,11\n\tF int\x7fF\x014,27\nfunc (T) M() {}\x7fM\x017,37\n"
+	if got, want := buf.String(), "\x0c\na.go,"+strconv.Itoa(len(section))+"\n"+section; got != want {
+		t.Errorf("got the etags %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// removedViews returns the views removed by the event, including the views of the build contexts of the folders.
+func (s *ElasticServer) removedViews(event protocol.WorkspaceFoldersChangeEvent) []source.View {
+	var views []source.View
+	for _, view := range s.session.Views() {
+		for _, folder := range event.Removed {
+			if view.Name() == folder.Name || view.Options().BuildContextView && strings.HasPrefix(view.Name(), folder.Name+"@") {
+				views = append(views, view)
+				break
+			}
+		}
+	}
+	return views
+}
+
+// teardownFolders releases the state kept for the workspace folders removed once their views are removed, so that
+// nothing of them is retained for the lifetime of the session, and nothing is answered for the closed folders. The
+// packages of the views are dropped along with their entries in the package cache, and so are the vendor mode recorded
+// by the dependency management, the go.mod synthesized, and the symbols, the references and the diagnostics of the
+// files in the folders. The folders added back by the same event are only reloaded, their state is kept.
+func (s *ElasticServer) teardownFolders(ctx context.Context, views []source.View, event protocol.WorkspaceFoldersChangeEvent) {
+	for _, view := range views {
+		s.packages.forget(view)
+		view.EvictPackages(ctx, 1)
+	}
+	var added folderSet
+	for _, folder := range event.Added {
+		added.add(folder)
+	}
+	for _, folder := range event.Removed {
+		dir := hostPaths.clean(span.NewURI(folder.URI).Filename())
+		if added.contains(dir) {
+			continue
+		}
+		forgetVendorFolders(dir)
+		for _, cleaned := range s.FolderNeedsCleanup.cleanupUnder(dir) {
+			log.Print(ctx, "removed the synthesized go.mod", tag.Of("Folder", cleaned))
+		}
+		s.symbols.forget(dir)
+		s.references.forget(dir)
+		s.forgetCheckDiagnostics(ctx, dir)
+	}
+}
+
+// forgetCheckDiagnostics clears the diagnostics published for the files under the folder by the type-checks of the
+// elastic requests, since they're never updated once the folder is removed.
+func (s *ElasticServer) forgetCheckDiagnostics(ctx context.Context, folder string) {
+	s.checkDiags.mu.Lock()
+	defer s.checkDiags.mu.Unlock()
+	for uri, published := range s.checkDiags.published {
+		if !hostPaths.hasPrefix(uri.Filename(), folder) {
+			continue
+		}
+		delete(s.checkDiags.published, uri)
+		if published && s.client != nil {
+			s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+				URI:         protocol.NewURI(uri),
+				Diagnostics: []protocol.Diagnostic{},
+			})
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestTeardownFolders(t *testing.T) {
+	root, err := ioutil.TempDir("", "teardown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	folders := make(map[string]protocol.WorkspaceFolder)
+	for _, name := range []string{"a", "b"} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// The go.mod is synthesized like the dependency management does.
+		if err := constructGoModManually(dir, "example.com/"+name); err != nil {
+			t.Fatal(err)
+		}
+		folders[name] = protocol.WorkspaceFolder{URI: string(span.FileURI(dir)), Name: name}
+	}
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.PackageCacheEntries = 10
+	s := newTestSessionServer(ctx, options)
+	for name, folder := range folders {
+		s.FolderNeedsCleanup.add(span.NewURI(folder.URI).Filename())
+		view := s.session.NewView(ctx, name, span.NewURI(folder.URI), options)
+		uri := span.FileURI(filepath.Join(span.NewURI(folder.URI).Filename(), "main.go"))
+		f, err := view.GetFile(ctx, uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, cphs, err := view.CheckPackageHandles(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.packages.use(ctx, view, cphs[0])
+		s.symbols.update(uri, "1", "", nil)
+		storeVendorFolder(span.NewURI(folder.URI).Filename())
+	}
+
+	if err := s.DidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{Event: protocol.WorkspaceFoldersChangeEvent{
+		Removed: []protocol.WorkspaceFolder{folders["a"]},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	if s.session.View("a") != nil || s.session.View("b") == nil {
+		t.Errorf("got the views %v, want b only", s.session.Views())
+	}
+	if _, err := os.Stat(filepath.Join(a, "go.mod")); !os.IsNotExist(err) {
+		t.Errorf("got the synthesized go.mod of the removed folder kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(b, "go.mod")); err != nil {
+		t.Errorf("got the synthesized go.mod of the folder kept removed: %v", err)
+	}
+	if got := s.FolderNeedsCleanup.list(); len(got) != 1 || !hostPaths.equal(got[0], b) {
+		t.Errorf("got the folders to clean up %v, want %s", got, b)
+	}
+	if len(s.packages.items) != 1 {
+		t.Errorf("got %d packages cached, want the one of b", len(s.packages.items))
+	}
+	if len(s.symbols.files) != 1 {
+		t.Errorf("got the symbols of %d files indexed, want the ones of b", len(s.symbols.files))
+	}
+	if checkVendorFolder(a) >= 0 || checkVendorFolder(b) < 0 {
+		t.Errorf("got the vendor mode of the removed folder kept")
+	}
+	forgetVendorFolders(root)
+}
@@ -0,0 +1,46 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// The keys of the option 'requestTimeouts'.
+const (
+	fullTimeout        = "full"
+	edefinitionTimeout = "edefinition"
+	manageDepsTimeout  = "manageDeps"
+)
+
+// withRequestTimeout returns the context canceled once the timeout of the method expires, along with the timeout,
+// which is zero if the method has no timeout.
+func withRequestTimeout(ctx context.Context, timeouts map[string]time.Duration, method string) (context.Context, context.CancelFunc, time.Duration) {
+	timeout := timeouts[method]
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// timedOut reports whether the request failed because its timeout expired, rather than canceled by the client.
+func timedOut(ctx context.Context, timeout time.Duration, err error) bool {
+	return err != nil && timeout > 0 && ctx.Err() == context.DeadlineExceeded
+}
+
+// timeoutError returns the error of the request exceeding its timeout, the partial result is carried by the data of
+// the error if any.
+func timeoutError(method string, timeout time.Duration, partial interface{}) error {
+	rpcErr := jsonrpc2.NewErrorf(protocol.CodeRequestTimeout, "%s exceeds the timeout of %v", method, timeout)
+	data, err := json.Marshal(protocol.RequestTimeout{Method: method, Timeout: timeout.Seconds(), Partial: partial})
+	if err == nil {
+		raw := json.RawMessage(data)
+		rpcErr.Data = &raw
+	}
+	return rpcErr
+}
@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestRequestTimeoutOptions(t *testing.T) {
+	options := source.DefaultOptions
+	results := source.SetOptions(&options, map[string]interface{}{
+		"requestTimeouts": map[string]interface{}{"full": 1.5, "manageDeps": 600.0, "hover": 1.0, "edefinition": -1.0},
+	})
+	if want := map[string]time.Duration{"full": 1500 * time.Millisecond, "manageDeps": 10 * time.Minute}; len(options.RequestTimeouts) != len(want) ||
+		options.RequestTimeouts["full"] != want["full"] || options.RequestTimeouts["manageDeps"] != want["manageDeps"] {
+		t.Errorf("got the timeouts %v, want %v", options.RequestTimeouts, want)
+	}
+	if len(results) != 1 || results[0].Error == nil {
+		t.Errorf("got %v, want the errors of the unknown method and the negative timeout", results)
+	}
+}
+
+func TestFullTimeout(t *testing.T) {
+	dir := newTestDir(t, "timeout", map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "package a\n\ntype T struct{}\n\nfunc F() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	options := source.DefaultOptions
+	options.RequestTimeouts = map[string]time.Duration{fullTimeout: time.Nanosecond}
+	s, _ := newTestServer(ctx, dir, "a", options)
+
+	// The type-checking never finishes in time, the symbols collected by the syntax come along with the error.
+	_, err := s.Full(ctx, &protocol.FullParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "a.go")))},
+	})
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	if !ok || rpcErr.Code != protocol.CodeRequestTimeout || rpcErr.Data == nil {
+		t.Fatalf("got %v, want the timeout error with the data", err)
+	}
+	var data struct {
+		protocol.RequestTimeout
+		Partial protocol.FullResponse `json:"partial"`
+	}
+	if err := json.Unmarshal(*rpcErr.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Method != fullTimeout || data.Timeout != time.Nanosecond.Seconds() {
+		t.Errorf("got the method %q and the timeout %v, want %q and %v", data.Method, data.Timeout, fullTimeout, time.Nanosecond.Seconds())
+	}
+	if !data.Partial.Partial || len(data.Partial.Symbols) != 2 {
+		t.Errorf("got the partial result %+v, want the 2 symbols collected by the syntax", data.Partial)
+	}
+}
@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// tokenLegend is the names of the token types, the token types are encoded as the indexes in the legend.
+var tokenLegend = []string{"keyword", "string", "comment", "number", "identifier", "operator"}
+
+const (
+	tokenKeyword = iota
+	tokenString
+	tokenComment
+	tokenNumber
+	tokenIdentifier
+	tokenOperator
+)
+
+// rawToken is a classified token located by the byte offset in the file, the multi-line tokens, like the raw strings
+// and the block comments, are split by the lines.
+type rawToken struct {
+	offset int
+	length int
+	typ    int
+}
+
+// scanTokens scans the source and classifies the tokens, the punctuation and the automatically inserted semicolons
+// are excluded.
+func scanTokens(src []byte) []rawToken {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	// The errors are ignored, the tokens are highlighted as much as possible.
+	s.Init(file, src, nil, scanner.ScanComments)
+	var toks []rawToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		typ := -1
+		switch {
+		case tok == token.COMMENT:
+			typ = tokenComment
+		case tok == token.STRING || tok == token.CHAR:
+			typ = tokenString
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			typ = tokenNumber
+		case tok == token.IDENT:
+			typ = tokenIdentifier
+		case tok.IsKeyword():
+			typ = tokenKeyword
+			lit = tok.String()
+		case tok.IsOperator() && tok != token.SEMICOLON && !isPunctuation(tok):
+			typ = tokenOperator
+			lit = tok.String()
+		}
+		if typ < 0 {
+			continue
+		}
+		start := file.Offset(pos)
+		end := tokenEnd(src, start, len(lit))
+		// Split the multi-line tokens by the lines.
+		for start < end {
+			n := strings.IndexByte(string(src[start:end]), '\n')
+			if n < 0 {
+				n = end - start
+			}
+			if length := len(strings.TrimSuffix(string(src[start:start+n]), "\r")); length > 0 {
+				toks = append(toks, rawToken{offset: start, length: length, typ: typ})
+			}
+			start += n + 1
+		}
+	}
+	return toks
+}
+
+// tokenEnd returns the end offset of the token starting at the offset in the source. The carriage returns are removed
+// from the literals of the comments and the raw strings by the scanner, so their ends are located in the source.
+func tokenEnd(src []byte, start, length int) int {
+	rest := string(src[start:])
+	switch {
+	case strings.HasPrefix(rest, "/*"):
+		if i := strings.Index(rest[2:], "*/"); i >= 0 {
+			return start + 2 + i + 2
+		}
+		return len(src)
+	case strings.HasPrefix(rest, "//"):
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			return start + i
+		}
+		return len(src)
+	case strings.HasPrefix(rest, "`"):
+		if i := strings.IndexByte(rest[1:], '`'); i >= 0 {
+			return start + 1 + i + 1
+		}
+		return len(src)
+	}
+	return start + length
+}
+
+// tokenFile is a type-checked file to tokenize, along with the objects of the identifiers by their positions.
+type tokenFile struct {
+	pkg  source.Package
+	file *ast.File
+	m    *protocol.ColumnMapper
+	src  []byte
+	fset *token.FileSet
+	tok  *token.File
+	objs map[token.Pos]types.Object
+}
+
+// checkTokenFile checks the package of the file and resolves the objects of the identifiers of the file.
+func (s *ElasticServer) checkTokenFile(ctx context.Context, view source.View, uri span.URI) (*tokenFile, error) {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	cph := source.NarrowestCheckPackageHandle(cphs)
+	pkg, err := cph.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.packages.use(ctx, view, cph)
+	ph, err := pkg.File(uri)
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := ph.File().Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := view.Session().Cache().FileSet()
+	tok := fset.File(file.Pos())
+	if tok == nil {
+		return nil, errors.Errorf("no token.File for %s", uri)
+	}
+
+	info := pkg.GetTypesInfo()
+	objs := make(map[token.Pos]types.Object)
+	for id, obj := range info.Defs {
+		if obj != nil && fset.File(id.Pos()) == tok {
+			objs[id.Pos()] = obj
+		}
+	}
+	for id, obj := range info.Uses {
+		if fset.File(id.Pos()) == tok {
+			objs[id.Pos()] = obj
+		}
+	}
+	return &tokenFile{pkg: pkg, file: file, m: m, src: src, fset: fset, tok: tok, objs: objs}, nil
+}
+
+func isPunctuation(tok token.Token) bool {
+	switch tok {
+	case token.LPAREN, token.RPAREN, token.LBRACK, token.RBRACK, token.LBRACE, token.RBRACE, token.COMMA, token.PERIOD, token.COLON:
+		return true
+	}
+	return false
+}
+
+// Tokens exports the classified tokens of the document, the identifiers are linked to the symbols they refer to, so
+// that the source can be rendered highlighted and hyperlinked without tokenizing it on the client side.
+//
+// The tokens are encoded as the groups of five integers, which are the line delta to the previous token, the start
+// character, relative to the previous token if they are on the same line, the length, the type as the index in the
+// legend, and the target as the index in the targets plus one, or zero if the token isn't linked. The characters are
+// counted in UTF-16 code units like the other positions in the protocol.
+func (s *ElasticServer) Tokens(ctx context.Context, params *protocol.TokensParams) (protocol.TokensResponse, error) {
+	resp := protocol.TokensResponse{Legend: tokenLegend, Data: []uint32{}, Targets: []protocol.SymbolLocator{}}
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	tf, err := s.checkTokenFile(ctx, view, uri)
+	if err != nil {
+		return resp, err
+	}
+	pkg, m, src, fset, tok, objs := tf.pkg, tf.m, tf.src, tf.fset, tf.tok, tf.objs
+	c := newReferenceCollector(ctx, view, pkg, uri, m)
+	targetIndex := make(map[*protocol.SymbolLocator]uint32)
+
+	var prev protocol.Position
+	for _, t := range scanTokens(src) {
+		start := tok.Pos(t.offset)
+		rng, err := toProtocolRange(fset, m, start, start+token.Pos(t.length))
+		if err != nil {
+			continue
+		}
+		var target uint32
+		if obj, ok := objs[start]; ok && t.typ == tokenIdentifier {
+			if loc := c.target(obj); loc != nil {
+				if _, ok := targetIndex[loc]; !ok {
+					resp.Targets = append(resp.Targets, *loc)
+					targetIndex[loc] = uint32(len(resp.Targets))
+				}
+				target = targetIndex[loc]
+			}
+		}
+		deltaStart := rng.Start.Character
+		if rng.Start.Line == prev.Line {
+			deltaStart -= prev.Character
+		}
+		resp.Data = append(resp.Data,
+			uint32(rng.Start.Line-prev.Line),
+			uint32(deltaStart),
+			uint32(rng.End.Character-rng.Start.Character),
+			uint32(t.typ),
+			target,
+		)
+		prev = rng.Start
+	}
+	return resp, nil
+}
@@ -0,0 +1,39 @@
+package lsp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanTokens(t *testing.T) {
+	src := "package p\r\n\r\n/* a\r\nb */\r\nvar s = `x\r\ny` + \"z\" // c\r\nconst n = 1\r\n"
+	type tok struct {
+		text string
+		typ  int
+	}
+	var got []tok
+	for _, rt := range scanTokens([]byte(src)) {
+		got = append(got, tok{src[rt.offset : rt.offset+rt.length], rt.typ})
+	}
+	want := []tok{
+		{"package", tokenKeyword},
+		{"p", tokenIdentifier},
+		{"/* a", tokenComment},
+		{"b */", tokenComment},
+		{"var", tokenKeyword},
+		{"s", tokenIdentifier},
+		{"=", tokenOperator},
+		{"`x", tokenString},
+		{"y`", tokenString},
+		{"+", tokenOperator},
+		{`"z"`, tokenString},
+		{"// c", tokenComment},
+		{"const", tokenKeyword},
+		{"n", tokenIdentifier},
+		{"=", tokenOperator},
+		{"1", tokenNumber},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got tokens\n%v\nwant\n%v", got, want)
+	}
+}
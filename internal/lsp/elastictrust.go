@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// The actions of the message asking the client to trust a workspace folder.
+const (
+	trustAction    = "Trust"
+	distrustAction = "Don't trust"
+)
+
+// trustRegistry records the workspace folders trusted or not by the client, so that the client is asked once per
+// folder in the session, and the module folders under them aren't asked again.
+type trustRegistry struct {
+	mu      sync.Mutex
+	decided map[string]bool
+	// asking are the folders being confirmed by the client, whose channels are closed once the client answers.
+	asking map[string]chan struct{}
+}
+
+// lookup returns the decision covering the folder, or the confirmation in flight covering it if there's no decision
+// yet. It's called with the lock held.
+func (r *trustRegistry) lookup(dir string) (trusted, decided bool, asking chan struct{}) {
+	for root, trusted := range r.decided {
+		if hostPaths.hasPrefix(dir, root) {
+			return trusted, true, nil
+		}
+	}
+	for root, asking := range r.asking {
+		if hostPaths.hasPrefix(dir, root) {
+			return false, false, asking
+		}
+	}
+	return false, false, nil
+}
+
+// trusted tells whether the go commands may run in the folder and the files may be written into it, like the go.mod
+// initialized by the dependency management. All the folders are trusted unless the options 'trustedRoots' or
+// 'confirmTrust' are set, then only the folders under the trusted roots are, or the ones confirmed by the client.
+func (s *Server) trusted(ctx context.Context, options source.Options, dir string) bool {
+	if len(options.TrustedRoots) == 0 && !options.ConfirmTrust {
+		return true
+	}
+	for _, root := range options.TrustedRoots {
+		if hostPaths.hasPrefix(dir, rootPath(root)) {
+			return true
+		}
+	}
+	if !options.ConfirmTrust || s.client == nil {
+		return false
+	}
+	// The concurrent requests of the same folder wait for the confirmation in flight rather than asking once again,
+	// while the other folders are asked meanwhile.
+	s.trust.mu.Lock()
+	for {
+		trusted, decided, asking := s.trust.lookup(dir)
+		if decided {
+			s.trust.mu.Unlock()
+			return trusted
+		}
+		if asking == nil {
+			break
+		}
+		s.trust.mu.Unlock()
+		select {
+		case <-asking:
+		case <-ctx.Done():
+			return false
+		}
+		s.trust.mu.Lock()
+	}
+	asking := make(chan struct{})
+	if s.trust.asking == nil {
+		s.trust.asking = make(map[string]chan struct{})
+	}
+	s.trust.asking[dir] = asking
+	s.trust.mu.Unlock()
+
+	item, err := s.client.ShowMessageRequest(ctx, &protocol.ShowMessageRequestParams{
+		Type:    protocol.Warning,
+		Message: fmt.Sprintf("Trust the workspace folder %s? The go commands run in the trusted folders may download the modules and write the files.", dir),
+		Actions: []protocol.MessageActionItem{{Title: trustAction}, {Title: distrustAction}},
+	})
+	if err != nil {
+		log.Error(ctx, "failed to confirm the trust of the folder", err, tag.Of("Folder", dir))
+	}
+	trusted := err == nil && item != nil && item.Title == trustAction
+
+	s.trust.mu.Lock()
+	defer s.trust.mu.Unlock()
+	delete(s.trust.asking, dir)
+	close(asking)
+	// The failed confirmations are asked once again, like the ones canceled by the shutdown.
+	if err != nil {
+		return false
+	}
+	if s.trust.decided == nil {
+		s.trust.decided = make(map[string]bool)
+	}
+	s.trust.decided[dir] = trusted
+	return trusted
+}
+
+// rootPath returns the path of the trusted root, which is either a path or a file URI.
+func rootPath(root string) string {
+	if strings.HasPrefix(root, "file://") {
+		return span.NewURI(root).Filename()
+	}
+	return root
+}
@@ -0,0 +1,131 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// trustClient answers the confirmations of the trust by the action, and records the folders asked.
+type trustClient struct {
+	protocol.Client
+	action string
+	mu     sync.Mutex
+	asked  int
+}
+
+func (c *trustClient) ShowMessageRequest(ctx context.Context, params *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.asked++
+	return &protocol.MessageActionItem{Title: c.action}, nil
+}
+
+func TestTrustedFolders(t *testing.T) {
+	root := newTestDir(t, "trust", map[string]string{"repo/main.go": "package main\n"})
+	defer os.RemoveAll(root)
+	folder := filepath.Join(root, "repo")
+	goMod := filepath.Join(folder, "go.mod")
+	folders := []protocol.WorkspaceFolder{{URI: string(span.FileURI(folder)), Name: "repo"}}
+	ctx := context.Background()
+	manage := func(client protocol.Client, options map[string]interface{}) *ElasticServer {
+		t.Helper()
+		os.Remove(goMod)
+		s := newTestSessionServer(ctx, source.DefaultOptions)
+		s.client = client
+		s.ManageDeps(ctx, folders, options)
+		s.ManageDeps(ctx, folders, options)
+		return s
+	}
+	exists := func() bool {
+		_, err := os.Stat(goMod)
+		return err == nil
+	}
+
+	// All the folders are trusted by default.
+	if manage(nil, nil); !exists() {
+		t.Error("got no go.mod synthesized in the folder trusted by default")
+	}
+	if manage(nil, map[string]interface{}{"trustedRoots": []interface{}{filepath.Join(root, "other")}}); exists() {
+		t.Error("got the go.mod synthesized in the folder out of the trusted roots")
+	}
+	if manage(nil, map[string]interface{}{"trustedRoots": []interface{}{string(span.FileURI(root))}}); !exists() {
+		t.Error("got no go.mod synthesized in the folder under the trusted root")
+	}
+
+	// The client is asked once per folder.
+	distrust := &trustClient{action: distrustAction}
+	if manage(distrust, map[string]interface{}{"confirmTrust": true}); exists() || distrust.asked != 1 {
+		t.Errorf("got the go.mod synthesized %v in the folder distrusted by the client, asked %d times", exists(), distrust.asked)
+	}
+	trust := &trustClient{action: trustAction}
+	if manage(trust, map[string]interface{}{"confirmTrust": true}); !exists() || trust.asked != 1 {
+		t.Errorf("got the go.mod synthesized %v in the folder trusted by the client, asked %d times", exists(), trust.asked)
+	}
+
+	// The decisions cover the module folders under the folders.
+	s := &ElasticServer{Server: Server{client: distrust}}
+	options := source.DefaultOptions
+	options.ConfirmTrust = true
+	distrust.asked = 0
+	if s.trusted(ctx, options, folder) || s.trusted(ctx, options, filepath.Join(folder, "sub")) || distrust.asked != 1 {
+		t.Errorf("got the folders trusted, asked %d times", distrust.asked)
+	}
+}
+
+// blockingTrustClient answers the confirmations once they're released, and reports the folders asked.
+type blockingTrustClient struct {
+	protocol.Client
+	asked   chan string
+	release chan struct{}
+}
+
+func (c *blockingTrustClient) ShowMessageRequest(ctx context.Context, params *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
+	c.asked <- params.Message
+	<-c.release
+	return &protocol.MessageActionItem{Title: trustAction}, nil
+}
+
+func TestTrustedConcurrently(t *testing.T) {
+	client := &blockingTrustClient{asked: make(chan string, 3), release: make(chan struct{})}
+	s := &ElasticServer{Server: Server{client: client}}
+	options := source.DefaultOptions
+	options.ConfirmTrust = true
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	folder, other := filepath.Join(os.TempDir(), "trust", "a"), filepath.Join(os.TempDir(), "trust", "b")
+
+	results := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- s.trusted(ctx, options, folder)
+		}()
+	}
+	<-client.asked
+	// The other folder is asked while the confirmation of the folder is in flight.
+	go func() {
+		results <- s.trusted(ctx, options, other)
+	}()
+	select {
+	case <-client.asked:
+	case <-ctx.Done():
+		t.Fatal("the other folder is expected to be asked while the client is confirming the folder")
+	}
+	close(client.release)
+	for i := 0; i < 3; i++ {
+		if !<-results {
+			t.Error("got the folder distrusted, want it trusted by the client")
+		}
+	}
+	// The concurrent requests of the folder wait for the confirmation in flight.
+	if n := len(client.asked); n != 0 {
+		t.Errorf("got the client asked %d more times, want each folder asked once", n)
+	}
+}
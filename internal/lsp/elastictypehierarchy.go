@@ -0,0 +1,183 @@
+package lsp
+
+import (
+	"context"
+	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// TypeHierarchy relates the named type at the position to the types it embeds and the interfaces it satisfies, and to
+// the workspace types embedding it or satisfying it. It returns nil if the position isn't on a named type.
+//
+// The types of the different packages are only comparable if they're checked together, so the subtypes are searched
+// in the packages which are, or import, the package of the type, where the type is resolved again.
+func (s *ElasticServer) TypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyParams) (*protocol.TypeHierarchy, error) {
+	release, err := s.admitTypeCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	uri := span.NewURI(params.TextDocument.URI)
+	view := s.session.ViewOf(uri)
+	obj, pkg, err := declAt(ctx, view, uri, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok || tn.IsAlias() || tn.Pkg() == nil {
+		return nil, nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil, nil
+	}
+	c := newReferenceCollector(ctx, view, pkg, uri, nil)
+	hierarchy := &protocol.TypeHierarchy{
+		Type:          typeLocator(ctx, view, c, tn),
+		Embeds:        []protocol.SymbolLocator{},
+		Implements:    []protocol.SymbolLocator{},
+		EmbeddedBy:    []protocol.SymbolLocator{},
+		ImplementedBy: []protocol.SymbolLocator{},
+	}
+	for _, embedded := range embeddedTypes(named) {
+		hierarchy.Embeds = append(hierarchy.Embeds, typeLocator(ctx, view, c, embedded.Obj()))
+	}
+	for _, iface := range satisfiedInterfaces(named) {
+		hierarchy.Implements = append(hierarchy.Implements, typeLocator(ctx, view, c, iface.Obj()))
+	}
+
+	seen := make(map[string]bool)
+	add := func(locators []protocol.SymbolLocator, loc protocol.SymbolLocator) []protocol.SymbolLocator {
+		key := loc.Package.RepoURI + " " + loc.Package.Name + " " + loc.Qname
+		if seen[key] {
+			return locators
+		}
+		seen[key] = true
+		return append(locators, loc)
+	}
+	for _, view := range s.session.Views() {
+		err := workspacePackages(ctx, view, func(p source.Package) {
+			target := resolveType(ctx, p, tn)
+			if target == nil {
+				return
+			}
+			c := newReferenceCollector(ctx, view, p, "", nil)
+			_, isIface := target.Underlying().(*types.Interface)
+			scope := p.GetTypes().Scope()
+			for _, name := range scope.Names() {
+				candidate, ok := scope.Lookup(name).(*types.TypeName)
+				if !ok || candidate.IsAlias() || candidate == target.Obj() {
+					continue
+				}
+				cn, ok := candidate.Type().(*types.Named)
+				if !ok {
+					continue
+				}
+				for _, embedded := range embeddedTypes(cn) {
+					if embedded.Obj() == target.Obj() {
+						hierarchy.EmbeddedBy = add(hierarchy.EmbeddedBy, typeLocator(ctx, view, c, candidate))
+						break
+					}
+				}
+				if _, ok := cn.Underlying().(*types.Interface); isIface && !ok && implements(cn, target) {
+					hierarchy.ImplementedBy = add(hierarchy.ImplementedBy, typeLocator(ctx, view, c, candidate))
+				}
+			}
+		})
+		if err != nil {
+			return hierarchy, err
+		}
+	}
+	return hierarchy, nil
+}
+
+// resolveType returns the type of the type name as it's seen by the package, it's nil if the package neither is nor
+// imports the package of the type.
+func resolveType(ctx context.Context, pkg source.Package, tn *types.TypeName) *types.Named {
+	scope := pkg.GetTypes().Scope()
+	if pkg.GetTypes().Path() != tn.Pkg().Path() {
+		imp, err := pkg.GetImport(ctx, tn.Pkg().Path())
+		if err != nil || imp.GetTypes() == nil {
+			return nil
+		}
+		scope = imp.GetTypes().Scope()
+	}
+	obj, ok := scope.Lookup(tn.Name()).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, _ := obj.Type().(*types.Named)
+	return named
+}
+
+// embeddedTypes returns the named types embedded in the struct or the interface.
+func embeddedTypes(named *types.Named) []*types.Named {
+	var embedded []*types.Named
+	add := func(t types.Type) {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if n, ok := t.(*types.Named); ok {
+			embedded = append(embedded, n)
+		}
+	}
+	switch u := named.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if u.Field(i).Embedded() {
+				add(u.Field(i).Type())
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			add(u.EmbeddedType(i))
+		}
+	}
+	return embedded
+}
+
+// satisfiedInterfaces returns the interfaces with methods satisfied by the type or its pointer, which are declared in
+// the package of the type or in the packages it imports.
+func satisfiedInterfaces(named *types.Named) []*types.Named {
+	var ifaces []*types.Named
+	pkg := named.Obj().Pkg()
+	for _, p := range append([]*types.Package{pkg}, pkg.Imports()...) {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !tn.Exported() && p != pkg || tn == named.Obj() {
+				continue
+			}
+			iface, ok := tn.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			if u, ok := iface.Underlying().(*types.Interface); ok && u.NumMethods() > 0 && implements(named, iface) {
+				ifaces = append(ifaces, iface)
+			}
+		}
+	}
+	return ifaces
+}
+
+// implements reports whether the type or its pointer satisfies the interface.
+func implements(t types.Type, iface *types.Named) bool {
+	u := iface.Underlying().(*types.Interface)
+	return types.Implements(t, u) || types.Implements(types.NewPointer(t), u)
+}
+
+// typeLocator returns the symbol locator of the type name, it's located by the location as well if it's declared in
+// the workspace folder of the view.
+func typeLocator(ctx context.Context, view source.View, c *referenceCollector, tn *types.TypeName) protocol.SymbolLocator {
+	var loc protocol.SymbolLocator
+	if target := c.target(tn); target != nil {
+		loc = *target
+	}
+	if declLoc, ok := workspaceLocation(ctx, view, c.fset, tn); ok {
+		loc.Loc = &declLoc
+	}
+	return loc
+}
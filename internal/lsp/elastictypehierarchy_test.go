@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestTypeHierarchy(t *testing.T) {
+	dir := newTestDir(t, "typehierarchy", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\ntype I interface{ M() }\n\ntype Base struct{}\n\nfunc (Base) M() {}\n\ntype T struct{ Base }\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\ntype U struct{ a.Base }\n\ntype V struct{}\n\nfunc (*V) M() {}\n",
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	a := protocol.NewURI(span.FileURI(filepath.Join(dir, "a", "a.go")))
+	hierarchyAt := func(line, character float64) *protocol.TypeHierarchy {
+		t.Helper()
+		hierarchy, err := s.TypeHierarchy(ctx, &protocol.TypeHierarchyParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: a},
+			Position:     protocol.Position{Line: line, Character: character},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hierarchy == nil {
+			t.Fatalf("got no hierarchy at %v:%v", line, character)
+		}
+		return hierarchy
+	}
+	names := func(locators []protocol.SymbolLocator) []string {
+		var names []string
+		for _, loc := range locators {
+			if loc.Loc == nil {
+				t.Errorf("the workspace type %s is expected to be located", loc.Qname)
+			}
+			names = append(names, loc.Qname)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	base := hierarchyAt(4, 6)
+	if got := names(base.Implements); len(got) != 1 || got[0] != "a.I" {
+		t.Errorf("got the interfaces %v satisfied by Base, want a.I", got)
+	}
+	if got := names(base.EmbeddedBy); len(got) != 2 || got[0] != "a.T" || got[1] != "b.U" {
+		t.Errorf("got the types %v embedding Base, want a.T and b.U", got)
+	}
+	if got := names(hierarchyAt(8, 6).Embeds); len(got) != 1 || got[0] != "a.Base" {
+		t.Errorf("got the types %v embedded by T, want a.Base", got)
+	}
+	if got := names(hierarchyAt(2, 6).ImplementedBy); len(got) != 4 {
+		t.Errorf("got the types %v implementing I, want a.Base, a.T, b.U and b.V", got)
+	}
+}
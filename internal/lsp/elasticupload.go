@@ -0,0 +1,189 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// ArtifactURI expands the key layout of the uploaded artifact, i.e. the destination URI with the '{folder}', '{ref}'
+// and '{file}' placeholders, which are replaced by the base name of the workspace folder, the indexed git ref whose
+// slashes are replaced by dashes, and the base name of the artifact file.
+func ArtifactURI(layout, folder, ref, file string) string {
+	return strings.NewReplacer(
+		"{folder}", folder,
+		"{ref}", strings.Replace(ref, "/", "-", -1),
+		"{file}", file,
+	).Replace(layout)
+}
+
+// UploadArtifact uploads the artifact to the object storage of the destination URI, whose scheme tells the storage.
+// The 's3://bucket/key' objects are signed by AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN in
+// AWS_REGION, and uploaded to Amazon S3 or the S3 compatible storage of AWS_ENDPOINT_URL. The 'gs://bucket/object'
+// objects are authorized by GOOGLE_OAUTH_ACCESS_TOKEN, like the output of 'gcloud auth print-access-token', and
+// uploaded to Google Cloud Storage or the emulator of STORAGE_EMULATOR_HOST. The 'azblob://account/container/blob'
+// blobs are authorized by the SAS token of AZURE_STORAGE_SAS_TOKEN and uploaded to Azure Blob Storage or the endpoint
+// of AZURE_STORAGE_BLOB_ENDPOINT. The 'http' and 'https' URLs are put as they are, like the presigned URLs of any of
+// the storages. The credentials are looked up by getenv, like the CLIs of the storages do.
+func UploadArtifact(ctx context.Context, dest string, data []byte, getenv func(string) string) error {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return err
+	}
+	var req *http.Request
+	switch u.Scheme {
+	case "s3":
+		req, err = s3Request(u, data, getenv, time.Now())
+	case "gs":
+		req, err = gcsRequest(u, data, getenv)
+	case "azblob":
+		req, err = azureRequest(u, data, getenv)
+	case "http", "https":
+		req, err = http.NewRequest(http.MethodPut, dest, bytes.NewReader(data))
+	default:
+		return errors.Errorf("unsupported artifact destination %s", dest)
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("failed to upload %s: %s %s", dest, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// s3Request returns the PUT of the object signed by the AWS Signature Version 4. The objects of the custom endpoints
+// are addressed by the paths, which the S3 compatible storages support more widely than the virtual hosts.
+func s3Request(u *url.URL, data []byte, getenv func(string) string, now time.Time) (*http.Request, error) {
+	accessKey, secretKey := getenv("AWS_ACCESS_KEY_ID"), getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("expected AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to upload to S3")
+	}
+	region := getenv("AWS_REGION")
+	if region == "" {
+		region = getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := "https://" + u.Host + ".s3." + region + ".amazonaws.com"
+	path := "/" + strings.TrimPrefix(u.Path, "/")
+	if custom := getenv("AWS_ENDPOINT_URL"); custom != "" {
+		endpoint = strings.TrimSuffix(custom, "/")
+		path = "/" + u.Host + path
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint+awsEscape(path), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	payload := sha256.Sum256(data)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	req.Header.Set("X-Amz-Date", amzDate)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if token := getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+		signed = append(signed, "x-amz-security-token")
+	}
+	var canonical bytes.Buffer
+	fmt.Fprintf(&canonical, "%s\n%s\n\n", req.Method, awsEscape(path))
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&canonical, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	fmt.Fprintf(&canonical, "\n%s\n%s", strings.Join(signed, ";"), hex.EncodeToString(payload[:]))
+
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256(canonical.Bytes())
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%x",
+		accessKey, scope, strings.Join(signed, ";"), hmacSHA256(key, stringToSign)))
+	return req, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape escapes the path like the canonical URIs of the AWS signatures, i.e. all the bytes but the unreserved
+// characters and the slashes.
+func awsEscape(path string) string {
+	var buf strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// gcsRequest returns the simple upload of the object by the JSON API.
+func gcsRequest(u *url.URL, data []byte, getenv func(string) string) (*http.Request, error) {
+	endpoint := "https://storage.googleapis.com"
+	token := getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if host := getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	} else if token == "" {
+		return nil, errors.New("expected GOOGLE_OAUTH_ACCESS_TOKEN to upload to Google Cloud Storage")
+	}
+	query := url.Values{"uploadType": {"media"}, "name": {strings.TrimPrefix(u.Path, "/")}}
+	req, err := http.NewRequest(http.MethodPost, endpoint+"/upload/storage/v1/b/"+url.PathEscape(u.Host)+"/o?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// azureRequest returns the PUT of the block blob authorized by the SAS token.
+func azureRequest(u *url.URL, data []byte, getenv func(string) string) (*http.Request, error) {
+	endpoint := "https://" + u.Host + ".blob.core.windows.net"
+	if custom := getenv("AZURE_STORAGE_BLOB_ENDPOINT"); custom != "" {
+		endpoint = strings.TrimSuffix(custom, "/")
+	}
+	sas := strings.TrimPrefix(getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	if sas == "" {
+		return nil, errors.New("expected AZURE_STORAGE_SAS_TOKEN to upload to Azure Blob Storage")
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint+(&url.URL{Path: u.Path}).EscapedPath()+"?"+sas, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", "2019-02-02")
+	return req, nil
+}
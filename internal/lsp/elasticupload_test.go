@@ -0,0 +1,67 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadArtifact(t *testing.T) {
+	type upload struct {
+		method, uri, auth, body string
+	}
+	var got upload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = upload{r.Method, r.URL.RequestURI(), r.Header.Get("Authorization"), string(body)}
+		if strings.Contains(r.URL.Path, "denied") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":           "AKID",
+		"AWS_SECRET_ACCESS_KEY":       "SECRET",
+		"AWS_ENDPOINT_URL":            srv.URL,
+		"STORAGE_EMULATOR_HOST":       srv.URL,
+		"GOOGLE_OAUTH_ACCESS_TOKEN":   "TOKEN",
+		"AZURE_STORAGE_BLOB_ENDPOINT": srv.URL,
+		"AZURE_STORAGE_SAS_TOKEN":     "?sv=2019-02-02&sig=SIG",
+	}
+	getenv := func(key string) string { return env[key] }
+
+	if uri := ArtifactURI("s3://bucket/{folder}/{ref}/{file}", "m", "release/v1", "index.scip"); uri != "s3://bucket/m/release-v1/index.scip" {
+		t.Errorf("got the artifact URI %s", uri)
+	}
+	for _, test := range []struct {
+		dest string
+		want upload
+	}{
+		{"s3://bucket/m/v1 x.scip", upload{"PUT", "/bucket/m/v1%20x.scip", "AWS4-HMAC-SHA256 Credential=AKID/", "index"}},
+		{"gs://bucket/m/v1.scip", upload{"POST", "/upload/storage/v1/b/bucket/o?name=m%2Fv1.scip&uploadType=media", "Bearer TOKEN", "index"}},
+		{"azblob://account/container/m/v1.scip", upload{"PUT", "/container/m/v1.scip?sv=2019-02-02&sig=SIG", "", "index"}},
+		{srv.URL + "/presigned?sig=SIG", upload{"PUT", "/presigned?sig=SIG", "", "index"}},
+	} {
+		got = upload{}
+		if err := UploadArtifact(context.Background(), test.dest, []byte("index"), getenv); err != nil {
+			t.Errorf("failed to upload %s: %v", test.dest, err)
+			continue
+		}
+		if got.method != test.want.method || got.uri != test.want.uri || !strings.HasPrefix(got.auth, test.want.auth) || got.body != test.want.body {
+			t.Errorf("got the upload %+v of %s, want %+v", got, test.dest, test.want)
+		}
+	}
+
+	if err := UploadArtifact(context.Background(), srv.URL+"/denied", []byte("index"), getenv); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("got the error %v, want the denied upload", err)
+	}
+	if err := UploadArtifact(context.Background(), "s3://bucket/key", nil, func(string) string { return "" }); err == nil {
+		t.Error("got no error without the credentials")
+	}
+	if err := UploadArtifact(context.Background(), "ftp://host/key", nil, getenv); err == nil {
+		t.Error("got no error for the unsupported scheme")
+	}
+}
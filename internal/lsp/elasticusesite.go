@@ -0,0 +1,67 @@
+package lsp
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// useSite is the use-site of a reference in the original file, which the line directives of a generated file map the
+// reference to, like the grammar file of a parser generated by goyacc. The references at the same use-site are
+// repeated by every file of the package generated from the same original file.
+type useSite struct {
+	posn token.Position
+	obj  types.Object
+	kind protocol.ReferenceKind
+}
+
+// foreignUseSite returns the use-site of the reference at the node if the line directives map it to another file
+// than the one the node is in.
+func foreignUseSite(fset *token.FileSet, n ast.Node, obj types.Object, kind protocol.ReferenceKind) (useSite, bool) {
+	posn, ok := originalPosition(fset, n.Pos())
+	if !ok {
+		return useSite{}, false
+	}
+	// The offset is the one in the generated file, rather than in the original file.
+	posn.Offset = 0
+	return useSite{posn: posn, obj: obj, kind: kind}, true
+}
+
+// visitReferences visits the references of the file classified by walkReferences, followed by the implements
+// references of the types declared in the file, i.e. all the kinds of the references collected for 'Full'.
+func visitReferences(file *ast.File, info *types.Info, pkg *types.Package, visit referenceVisitor) {
+	walkReferences(file, info, visit)
+	for _, w := range findImplementsWitnesses(file, info, pkg) {
+		visit(w.name, w.iface, protocol.ImplementsReference, protocol.IMPLEMENT, w.name)
+	}
+}
+
+// useSiteOwners returns the files of the package owning the foreign use-sites, i.e. the first file by the URIs which
+// has a reference at the use-site. The references at a foreign use-site are only reported for the owner, so that a
+// package indexed file by file reports each of them once.
+func useSiteOwners(ctx context.Context, fset *token.FileSet, pkg source.Package) map[useSite]span.URI {
+	files := append([]source.ParseGoHandle(nil), pkg.Files()...)
+	sort.Slice(files, func(i, j int) bool { return files[i].File().Identity().URI < files[j].File().Identity().URI })
+	owners := make(map[useSite]span.URI)
+	for _, ph := range files {
+		file, _, _, err := ph.Cached(ctx)
+		if err != nil {
+			continue
+		}
+		uri := ph.File().Identity().URI
+		visitReferences(file, pkg.GetTypesInfo(), pkg.GetTypes(), func(n ast.Node, obj types.Object, kind protocol.ReferenceKind, _ protocol.ReferenceCategory, _ *ast.Ident) {
+			if site, ok := foreignUseSite(fset, n, obj, kind); ok {
+				if _, ok := owners[site]; !ok {
+					owners[site] = uri
+				}
+			}
+		})
+	}
+	return owners
+}
@@ -0,0 +1,67 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestForeignUseSites(t *testing.T) {
+	// Both the generated files are mapped to the same line of the grammar.
+	generated := func(name string) string {
+		return "// Code generated by goyacc. DO NOT EDIT.\n\npackage m\n\nfunc " + name + "() int {\n//line m.y:2\n\treturn F()\n}\n"
+	}
+	dir := newTestDir(t, "usesite", map[string]string{
+		"go.mod": "module example.com/m\n",
+		"m.y":    "%%\n\treturn F()\n",
+		"m.go":   "package m\n\nfunc F() int { return 0 }\n",
+		"a.go":   generated("A"),
+		"b.go":   generated("B"),
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	calls := func(name string, localOnly bool) int {
+		t.Helper()
+		resp, err := s.Full(ctx, &protocol.FullParams{
+			TextDocument:    protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, name)))},
+			Reference:       true,
+			LocalReferences: localOnly,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for _, ref := range resp.References {
+			if ref.Kind == protocol.CallReference && ref.Target.Qname == "m.F" {
+				// The call is located in the generated file itself.
+				if ref.Loc.Range.Start != (protocol.Position{Line: 6, Character: 8}) {
+					t.Errorf("%s: got the call of F at %v, want 6:8", name, ref.Loc.Range.Start)
+				}
+				n++
+			}
+		}
+		return n
+	}
+	for _, test := range []struct {
+		name      string
+		localOnly bool
+		want      int
+	}{
+		// The call of the grammar is reported by the first file generated from it.
+		{"a.go", false, 1},
+		{"b.go", false, 0},
+		// The calls mapped to the grammar aren't in the generated files.
+		{"a.go", true, 0},
+		{"b.go", true, 0},
+	} {
+		if got := calls(test.name, test.localOnly); got != test.want {
+			t.Errorf("%s (local %v): got %d calls of F, want %d", test.name, test.localOnly, got, test.want)
+		}
+	}
+}
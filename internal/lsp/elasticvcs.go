@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// gitRemoteTimeout bounds the time of querying the git repository of a folder.
+const gitRemoteTimeout = 10 * time.Second
+
+// modulePathOfRemote guesses the module path by the remote 'origin' of the git repository containing the folder,
+// like 'github.com/owner/repo/sub' for the folder 'sub' of the repository cloned from 'git@github.com:owner/repo.git'.
+// Git runs under the environment of the folder by the runner of the options.
+func modulePathOfRemote(options source.Options, folder string, env []string) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitRemoteTimeout)
+	defer cancel()
+	remote, err := runGitCommand(ctx, options, folder, env, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return "", false
+	}
+	repoPath, ok := importPathOfRemote(strings.TrimSpace(remote.String()))
+	if !ok {
+		return "", false
+	}
+	// The folder may be a sub directory of the repository, like a nested module.
+	prefix, err := runGitCommand(ctx, options, folder, env, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", false
+	}
+	return path.Join(repoPath, strings.TrimSpace(prefix.String())), true
+}
+
+// importPathOfRemote normalizes the URL of the git remote to the import path of the repository, i.e. the
+// 'host/owner/repo' with the scheme, the user info, the port and the '.git' suffix dropped. The scp-like syntax
+// 'user@host:owner/repo' is supported as well. The local remotes, like the file URLs and the paths, aren't.
+func importPathOfRemote(remote string) (string, bool) {
+	var host, repoPath string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		switch u.Scheme {
+		case "http", "https", "ssh", "git", "git+ssh", "ssh+git":
+		default:
+			return "", false
+		}
+		host, repoPath = u.Hostname(), u.Path
+	} else if i := strings.Index(remote, ":"); i > 0 && !strings.Contains(remote[:i], "/") {
+		host, repoPath = remote[:i], remote[i+1:]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+	} else {
+		return "", false
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(path.Clean("/"+repoPath), "/"), ".git")
+	// The import paths start with a domain name.
+	if !strings.Contains(host, ".") || repoPath == "" || repoPath == "." {
+		return "", false
+	}
+	return strings.ToLower(host) + "/" + repoPath, true
+}
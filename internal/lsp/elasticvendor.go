@@ -0,0 +1,180 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vendoredModule is a module recorded in 'vendor/modules.txt'.
+type vendoredModule struct {
+	Path    string
+	Version string
+	// The replacement of the module, the version is empty if the module is replaced by a local directory.
+	ReplacePath    string
+	ReplaceVersion string
+}
+
+// revision returns the version of the module which is actually vendored.
+func (mod *vendoredModule) revision() string {
+	if mod.ReplacePath != "" {
+		return mod.ReplaceVersion
+	}
+	return mod.Version
+}
+
+// sourcePath returns the import path of the package in the module which is actually vendored, i.e. the path in the
+// replacement module if the module is replaced by another module.
+func (mod *vendoredModule) sourcePath(pkgPath string) string {
+	if mod.ReplacePath == "" || mod.ReplaceVersion == "" {
+		return pkgPath
+	}
+	return mod.ReplacePath + strings.TrimPrefix(pkgPath, mod.Path)
+}
+
+// vendorManifest is the parsed content of 'vendor/modules.txt'.
+type vendorManifest struct {
+	modules []*vendoredModule
+	// packages maps the import paths of the vendored packages to the modules they belong to.
+	packages map[string]*vendoredModule
+}
+
+// parseVendorManifest parses the content of 'vendor/modules.txt', which is in the form of:
+//
+//	# github.com/foo/bar v1.2.3
+//	## explicit
+//	github.com/foo/bar
+//	github.com/foo/bar/baz
+//	# github.com/foo/qux v1.0.0 => github.com/fork/qux v1.0.1
+//	github.com/foo/qux
+func parseVendorManifest(data []byte) *vendorManifest {
+	manifest := &vendorManifest{packages: make(map[string]*vendoredModule)}
+	var current *vendoredModule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "##"):
+			// Skip the annotations, like '## explicit'.
+		case strings.HasPrefix(line, "#"):
+			fields := strings.Fields(strings.TrimPrefix(line, "#"))
+			if len(fields) == 0 {
+				current = nil
+				continue
+			}
+			current = &vendoredModule{Path: fields[0]}
+			if len(fields) > 1 && fields[1] != "=>" {
+				current.Version = fields[1]
+			}
+			for i, f := range fields {
+				if f == "=>" && i+1 < len(fields) {
+					current.ReplacePath = fields[i+1]
+					if i+2 < len(fields) {
+						current.ReplaceVersion = fields[i+2]
+					}
+				}
+			}
+			manifest.modules = append(manifest.modules, current)
+		case current != nil:
+			manifest.packages[line] = current
+		}
+	}
+	return manifest
+}
+
+// lookup returns the module which the vendored package belongs to. If the package isn't listed explicitly, the module
+// with the longest matching path is used.
+func (m *vendorManifest) lookup(pkgPath string) *vendoredModule {
+	if mod, ok := m.packages[pkgPath]; ok {
+		return mod
+	}
+	var longest *vendoredModule
+	for _, mod := range m.modules {
+		if (pkgPath == mod.Path || strings.HasPrefix(pkgPath, mod.Path+"/")) && (longest == nil || len(mod.Path) > len(longest.Path)) {
+			longest = mod
+		}
+	}
+	return longest
+}
+
+// vendorManifests records the keys of the parsed 'vendor/modules.txt' in the shared metadata by the path of the file,
+// the recorded keys are invalidated by the modification time of the file. The parsed ones are addressed by the content,
+// so that the checkouts of the same modules share them.
+var vendorManifests = struct {
+	sync.Mutex
+	m map[string]cachedManifest
+}{m: make(map[string]cachedManifest)}
+
+type cachedManifest struct {
+	modTime time.Time
+	key     string
+}
+
+// loadVendorManifest returns the parsed 'modules.txt' located in the vendor directory.
+func loadVendorManifest(vendorDir string) (*vendorManifest, error) {
+	path := filepath.Join(vendorDir, "modules.txt")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	vendorManifests.Lock()
+	cached, ok := vendorManifests.m[path]
+	vendorManifests.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		if v, ok := sharedMetadata.get(cached.key); ok {
+			return v.(*vendorManifest), nil
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := contentKey(vendorManifestKind, data)
+	v, err := sharedMetadata.load(key, func() (interface{}, error) {
+		return parseVendorManifest(data), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	vendorManifests.Lock()
+	vendorManifests.m[path] = cachedManifest{modTime: info.ModTime(), key: key}
+	vendorManifests.Unlock()
+	return v.(*vendorManifest), nil
+}
+
+// vendorDirOf returns the innermost vendor directory which contains the file located at loc.
+func vendorDirOf(loc string) (string, bool) {
+	i := strings.LastIndex(loc, folderSkip)
+	if i < 0 {
+		return "", false
+	}
+	return loc[:i+len(folderSkip)-1], true
+}
+
+// stripVendorPrefix strips the vendor prefix of the import paths of the vendored packages under the GOPATH mode, like
+// 'github.com/foo/proj/vendor/github.com/bar/baz'.
+func stripVendorPrefix(pkgPath string) string {
+	if i := strings.LastIndex(pkgPath, "/vendor/"); i >= 0 {
+		return pkgPath[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(pkgPath, "vendor/")
+}
+
+// vendoredModuleOf returns the module recorded in 'vendor/modules.txt' for the vendored package located at loc.
+func vendoredModuleOf(loc string, pkgPath string) (*vendoredModule, string) {
+	vendorDir, ok := vendorDirOf(loc)
+	if !ok {
+		return nil, pkgPath
+	}
+	pkgPath = stripVendorPrefix(pkgPath)
+	manifest, err := loadVendorManifest(vendorDir)
+	if err != nil {
+		return nil, pkgPath
+	}
+	return manifest.lookup(pkgPath), pkgPath
+}
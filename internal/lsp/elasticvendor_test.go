@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const vendorModulesTxt = `# github.com/foo/bar v1.2.3
+## explicit
+github.com/foo/bar
+github.com/foo/bar/baz
+# github.com/foo/qux v1.0.0 => github.com/fork/qux v0.0.0-20190620200207-3b0461eec859
+github.com/foo/qux
+# github.com/foo/local v1.0.0 => ../local
+github.com/foo/local/pkg
+`
+
+func TestParseVendorManifest(t *testing.T) {
+	manifest := parseVendorManifest([]byte(vendorModulesTxt))
+	if len(manifest.modules) != 3 {
+		t.Fatalf("got %d modules, expected 3", len(manifest.modules))
+	}
+	for pkgPath, want := range map[string]struct{ path, rev string }{
+		"github.com/foo/bar":           {"github.com/foo/bar", "v1.2.3"},
+		"github.com/foo/bar/baz":       {"github.com/foo/bar", "v1.2.3"},
+		"github.com/foo/bar/unlisted":  {"github.com/foo/bar", "v1.2.3"},
+		"github.com/foo/qux":           {"github.com/foo/qux", "v0.0.0-20190620200207-3b0461eec859"},
+		"github.com/foo/local/pkg":     {"github.com/foo/local", ""},
+		"github.com/foo/local/pkg/sub": {"github.com/foo/local", ""},
+	} {
+		mod := manifest.lookup(pkgPath)
+		if mod == nil {
+			t.Errorf("%s: no module found", pkgPath)
+			continue
+		}
+		if mod.Path != want.path || mod.revision() != want.rev {
+			t.Errorf("%s: got %s@%s want %s@%s", pkgPath, mod.Path, mod.revision(), want.path, want.rev)
+		}
+	}
+	if mod := manifest.lookup("github.com/other/pkg"); mod != nil {
+		t.Errorf("github.com/other/pkg: got unexpected module %v", mod)
+	}
+}
+
+func TestVendoredModuleOf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vendored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vendorDir := filepath.Join(dir, "vendor")
+	if err := os.MkdirAll(filepath.Join(vendorDir, "github.com", "foo", "bar"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(vendorDir, "modules.txt"), []byte(vendorModulesTxt), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loc := filepath.Join(vendorDir, "github.com", "foo", "bar", "bar.go")
+	mod, pkgPath := vendoredModuleOf(loc, "example.com/proj/vendor/github.com/foo/bar")
+	if pkgPath != "github.com/foo/bar" {
+		t.Errorf("got package path %q", pkgPath)
+	}
+	if mod == nil || mod.Version != "v1.2.3" {
+		t.Errorf("got module %v", mod)
+	}
+	if mod, _ := vendoredModuleOf(filepath.Join(dir, "main.go"), "example.com/proj"); mod != nil {
+		t.Errorf("got unexpected module %v for the non-vendored package", mod)
+	}
+}
+
+func TestVendoredModuleSourcePath(t *testing.T) {
+	manifest := parseVendorManifest([]byte(vendorModulesTxt))
+	for pkgPath, want := range map[string]string{
+		"github.com/foo/bar/baz":   "github.com/foo/bar/baz",
+		"github.com/foo/qux":       "github.com/fork/qux",
+		"github.com/foo/local/pkg": "github.com/foo/local/pkg",
+	} {
+		if got := manifest.lookup(pkgPath).sourcePath(pkgPath); got != want {
+			t.Errorf("%s: got source path %q, want %q", pkgPath, got, want)
+		}
+	}
+}
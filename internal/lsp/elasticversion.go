@@ -0,0 +1,331 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// versionCheck holds the outcome of type-checking a package under a single Go language version.
+type versionCheck struct {
+	goVersion string
+	errors    []types.Error
+	// defs maps the position of every declared identifier to the string form of the declared object.
+	defs map[token.Pos]string
+}
+
+// checkGoVersion type-checks the files of pkg again under the language version goVersion. The imports of pkg are
+// reused as they are, so only the package itself is checked under the requested version. The semantics changed
+// without a type error are reported as the errors of the versions before the change, i.e. the loop variables shared
+// by the iterations before go1.22 yet captured by the func literals, see sharedLoopVars.
+func checkGoVersion(fset *token.FileSet, pkg *types.Package, files []*ast.File, goVersion string) versionCheck {
+	res := versionCheck{
+		goVersion: goVersion,
+		defs:      make(map[token.Pos]string),
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	cfg := &types.Config{
+		GoVersion: goVersion,
+		Error: func(err error) {
+			if terr, ok := err.(types.Error); ok {
+				res.errors = append(res.errors, terr)
+			}
+		},
+		Importer: packageImporter(pkg),
+	}
+	check := types.NewChecker(cfg, fset, types.NewPackage(pkg.Path(), pkg.Name()), info)
+	// Type checking errors are handled via the config, so ignore them here.
+	_ = check.Files(files)
+	for id, obj := range info.Defs {
+		if obj == nil {
+			continue
+		}
+		res.defs[id.Pos()] = types.ObjectString(obj, types.RelativeTo(obj.Pkg()))
+	}
+	if goMinor(goVersion) < 22 {
+		res.errors = append(res.errors, sharedLoopVars(fset, files, info)...)
+	}
+	return res
+}
+
+// sharedLoopVars returns the captures of the loop variables by the func literals in the bodies of the loops, which
+// see the variables shared by all the iterations before go1.22, and the ones of their own iterations since. The
+// capture is reported once for each outermost func literal at the first use of the variable, and the func literals
+// run by the 'go' statements are told as the goroutines.
+func sharedLoopVars(fset *token.FileSet, files []*ast.File, info *types.Info) []types.Error {
+	var errs []types.Error
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			var vars []ast.Expr
+			var body *ast.BlockStmt
+			switch n := n.(type) {
+			case *ast.ForStmt:
+				if init, ok := n.Init.(*ast.AssignStmt); ok && init.Tok == token.DEFINE {
+					vars = init.Lhs
+				}
+				body = n.Body
+			case *ast.RangeStmt:
+				if n.Tok == token.DEFINE {
+					vars = []ast.Expr{n.Key, n.Value}
+				}
+				body = n.Body
+			default:
+				return true
+			}
+			loopVars := make(map[types.Object]bool)
+			for _, v := range vars {
+				if id, ok := v.(*ast.Ident); ok && info.Defs[id] != nil {
+					loopVars[info.Defs[id]] = true
+				}
+			}
+			if len(loopVars) == 0 {
+				return true
+			}
+			goroutines := make(map[*ast.FuncLit]bool)
+			ast.Inspect(body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.GoStmt:
+					if lit, ok := n.Call.Fun.(*ast.FuncLit); ok {
+						goroutines[lit] = true
+					}
+				case *ast.FuncLit:
+					lit := n
+					captured := make(map[types.Object]bool)
+					ast.Inspect(lit.Body, func(n ast.Node) bool {
+						id, ok := n.(*ast.Ident)
+						if !ok || !loopVars[info.Uses[id]] || captured[info.Uses[id]] {
+							return true
+						}
+						captured[info.Uses[id]] = true
+						by := "func literal"
+						if goroutines[lit] {
+							by = "goroutine"
+						}
+						errs = append(errs, types.Error{
+							Fset: fset,
+							Pos:  id.Pos(),
+							Msg:  fmt.Sprintf("loop variable %s captured by %s is shared by all the iterations before go1.22", id.Name, by),
+							Soft: true,
+						})
+						return true
+					})
+					// The uses in the nested func literals are captured by this one as well.
+					return false
+				}
+				return true
+			})
+			return true
+		})
+	}
+	return errs
+}
+
+// goMinor returns the minor version of the language version of the form 'go1.N'.
+func goMinor(v string) int {
+	minor, err := strconv.Atoi(strings.TrimPrefix(v, "go1."))
+	if err != nil {
+		return 0
+	}
+	return minor
+}
+
+// packageImporter returns an importer which resolves the import paths against the already type-checked imports of
+// pkg.
+func packageImporter(pkg *types.Package) types.Importer {
+	imports := make(map[string]*types.Package)
+	for _, imp := range pkg.Imports() {
+		imports[imp.Path()] = imp
+	}
+	return importerFunc(func(path string) (*types.Package, error) {
+		if imp, ok := imports[path]; ok {
+			return imp, nil
+		}
+		// The imports of the vendored packages are recorded with the 'vendor' prefix.
+		for p, imp := range imports {
+			if strings.HasSuffix(p, "/vendor/"+path) || p == "vendor/"+path {
+				return imp, nil
+			}
+		}
+		return nil, fmt.Errorf("can't find import %q", path)
+	})
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// diffGoVersions compares the results of type-checking under different Go language versions. For each version, it
+// returns the errors which are not reported under every version and the positions of the declarations whose types
+// are not identical under every version. Only the errors and declarations located in tok are considered.
+func diffGoVersions(checks []versionCheck, tok *token.File) (errs map[string][]types.Error, defs map[string][]token.Pos) {
+	errs = make(map[string][]types.Error)
+	defs = make(map[string][]token.Pos)
+	inFile := func(pos token.Pos) bool {
+		return pos.IsValid() && tok.Base() <= int(pos) && int(pos) <= tok.Base()+tok.Size()
+	}
+	errKey := func(err types.Error) string {
+		return fmt.Sprintf("%d:%s", err.Pos, err.Msg)
+	}
+	errCount := make(map[string]int)
+	for _, check := range checks {
+		seen := make(map[string]bool)
+		for _, err := range check.errors {
+			if key := errKey(err); !seen[key] {
+				seen[key] = true
+				errCount[key]++
+			}
+		}
+	}
+	for _, check := range checks {
+		for _, err := range check.errors {
+			if inFile(err.Pos) && errCount[errKey(err)] != len(checks) {
+				errs[check.goVersion] = append(errs[check.goVersion], err)
+			}
+		}
+		for pos, obj := range check.defs {
+			if !inFile(pos) {
+				continue
+			}
+			for _, other := range checks {
+				if other.defs[pos] != obj {
+					defs[check.goVersion] = append(defs[check.goVersion], pos)
+					break
+				}
+			}
+		}
+		sort.Slice(defs[check.goVersion], func(i, j int) bool {
+			return defs[check.goVersion][i] < defs[check.goVersion][j]
+		})
+	}
+	return errs, defs
+}
+
+// collectVersionDiffs type-checks the package of the document under each of the requested Go language versions and
+// reports the symbols and diagnostics of the document that differ between them. The versions are checked by the loader.
+func collectVersionDiffs(ctx context.Context, loader packageLoader, view source.View, pkg source.Package, uri span.URI, versions []string, symbols []protocol.DetailSymbolInformation) ([]protocol.VersionDiff, error) {
+	for _, v := range versions {
+		if !isValidGoVersion(v) {
+			return nil, fmt.Errorf("invalid Go version %q", v)
+		}
+	}
+	ph, err := pkg.File(uri)
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fset := view.Session().Cache().FileSet()
+	tok := fset.File(file.Pos())
+	if tok == nil {
+		return nil, fmt.Errorf("no token.File for %s", uri)
+	}
+	// The versions are checked in parallel, each of them type-checks the package once again.
+	checks := make([]versionCheck, len(versions))
+	files := pkg.GetSyntax(ctx)
+	if err := loader.run(ctx, len(versions), func(ctx context.Context, i int) error {
+		checks[i] = checkGoVersion(fset, pkg.GetTypes(), files, versions[i])
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	errs, defs := diffGoVersions(checks, tok)
+
+	symbolAt := make(map[protocol.Position]protocol.DetailSymbolInformation)
+	for _, sym := range symbols {
+		symbolAt[sym.Symbol.Location.Range.Start] = sym
+	}
+	var diffs []protocol.VersionDiff
+	for _, check := range checks {
+		diff := protocol.VersionDiff{
+			GoVersion:   check.goVersion,
+			Symbols:     []protocol.VersionSymbol{},
+			Diagnostics: []protocol.Diagnostic{},
+		}
+		for _, pos := range defs[check.goVersion] {
+			rng, err := toProtocolRange(fset, m, pos, pos)
+			if err != nil {
+				continue
+			}
+			// Only the declarations which are reported as symbols by 'Full' have a qualified name.
+			sym, ok := symbolAt[rng.Start]
+			if !ok {
+				continue
+			}
+			diff.Symbols = append(diff.Symbols, protocol.VersionSymbol{
+				Qname: sym.Qname,
+				Type:  check.defs[pos],
+			})
+		}
+		for _, terr := range errs[check.goVersion] {
+			rng, err := toProtocolRange(fset, m, terr.Pos, terr.Pos)
+			if err != nil {
+				continue
+			}
+			diff.Diagnostics = append(diff.Diagnostics, protocol.Diagnostic{
+				Range:    rng,
+				Severity: protocol.SeverityError,
+				Source:   "compiler",
+				Message:  terr.Msg,
+			})
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// isValidGoVersion reports whether v is a language version of the form 'go1.N'.
+func isValidGoVersion(v string) bool {
+	if !strings.HasPrefix(v, "go1.") {
+		return false
+	}
+	minor := strings.TrimPrefix(v, "go1.")
+	if minor == "" {
+		return false
+	}
+	for _, r := range minor {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// toProtocolRange converts the given token positions to a protocol range by the column mapper of the file. The
+// positions of the file itself are used rather than the ones adjusted by the line directives, so that the ranges in
+// the generated files, like the ones generated by goyacc, are located in the generated files.
+func toProtocolRange(fset *token.FileSet, m *protocol.ColumnMapper, start, end token.Pos) (protocol.Range, error) {
+	tok := fset.File(start)
+	if tok == nil {
+		return protocol.Range{}, fmt.Errorf("file not found in FileSet")
+	}
+	if uri := span.FileURI(tok.Name()); span.CompareURI(m.URI, uri) != 0 {
+		return protocol.Range{}, fmt.Errorf("column mapper is for file %q instead of %q", m.URI, uri)
+	}
+	position := func(pos token.Pos) (protocol.Position, error) {
+		posn := fset.PositionFor(pos, false)
+		return m.Position(span.NewPoint(posn.Line, posn.Column, posn.Offset))
+	}
+	rs, err := position(start)
+	if err != nil {
+		return protocol.Range{}, err
+	}
+	re, err := position(end)
+	if err != nil {
+		return protocol.Range{}, err
+	}
+	return protocol.Range{Start: rs, End: re}, nil
+}
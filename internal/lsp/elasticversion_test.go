@@ -0,0 +1,154 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const versionDiffSrc = `package p
+
+func Map[T any](s []T) []T { return s }
+
+func Sum(n int) int {
+	total := 0
+	for i := range n {
+		total += i
+	}
+	return total
+}
+
+var Answer = 42
+`
+
+func TestDiffGoVersions(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", versionDiffSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*ast.File{file}
+	pkg := types.NewPackage("example.com/p", "p")
+
+	checks := []versionCheck{
+		checkGoVersion(fset, pkg, files, "go1.17"),
+		checkGoVersion(fset, pkg, files, "go1.23"),
+	}
+	errs, defs := diffGoVersions(checks, fset.File(file.Pos()))
+
+	if len(errs["go1.23"]) != 0 {
+		t.Errorf("got %d errors for go1.23, expected 0: %v", len(errs["go1.23"]), errs["go1.23"])
+	}
+	// Both the type parameters and the range over int are unavailable in go1.17.
+	if len(errs["go1.17"]) < 2 {
+		t.Errorf("got %d errors for go1.17, expected at least 2: %v", len(errs["go1.17"]), errs["go1.17"])
+	}
+	answer := file.Scope.Lookup("Answer").Decl.(*ast.ValueSpec).Names[0].Pos()
+	for version, positions := range defs {
+		for _, pos := range positions {
+			if pos == answer {
+				t.Errorf("%s: 'Answer' is reported as a differing declaration", version)
+			}
+		}
+	}
+}
+
+const loopVarSrc = `package p
+
+func Funcs(values []int) []func() int {
+	var funcs []func() int
+	for _, v := range values {
+		funcs = append(funcs, func() int { return v + v })
+	}
+	return funcs
+}
+
+func Spawn(n int, done chan int) {
+	for i := 0; i < n; i++ {
+		go func() { done <- i }()
+		// The argument is evaluated by the iteration itself.
+		go func(i int) { done <- i }(i)
+	}
+}
+`
+
+func TestDiffGoVersionsLoopVars(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", loopVarSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*ast.File{file}
+	pkg := types.NewPackage("example.com/p", "p")
+
+	checks := []versionCheck{
+		checkGoVersion(fset, pkg, files, "go1.21"),
+		checkGoVersion(fset, pkg, files, "go1.22"),
+	}
+	errs, _ := diffGoVersions(checks, fset.File(file.Pos()))
+	if len(errs["go1.22"]) != 0 {
+		t.Errorf("got the errors %v for go1.22, want none", errs["go1.22"])
+	}
+	var msgs []string
+	for _, err := range errs["go1.21"] {
+		msgs = append(msgs, fmt.Sprintf("%d: %s", fset.Position(err.Pos).Line, err.Msg))
+	}
+	want := []string{
+		"6: loop variable v captured by func literal is shared by all the iterations before go1.22",
+		"13: loop variable i captured by goroutine is shared by all the iterations before go1.22",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got the errors for go1.21\n%s\nwant\n%s", strings.Join(msgs, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFullVersionDiffs(t *testing.T) {
+	dir := newTestDir(t, "versions", map[string]string{
+		"go.mod": "module example.com/p\n",
+		"p.go":   loopVarSrc,
+	})
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "p", source.DefaultOptions)
+
+	resp, err := s.Full(ctx, &protocol.FullParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.NewURI(span.FileURI(filepath.Join(dir, "p.go")))},
+		GoVersions:   []string{"go1.21", "go1.22"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.VersionDiffs) != 2 || resp.VersionDiffs[0].GoVersion != "go1.21" || len(resp.VersionDiffs[1].Diagnostics) != 0 {
+		t.Fatalf("got the version diffs %+v, want the ones of go1.21 and go1.22", resp.VersionDiffs)
+	}
+	diags := resp.VersionDiffs[0].Diagnostics
+	if len(diags) != 2 || diags[0].Range.Start.Line != 5 || !strings.Contains(diags[0].Message, "loop variable v") || diags[1].Range.Start.Line != 12 {
+		t.Errorf("got the diagnostics %+v of go1.21, want the captures of v and i", diags)
+	}
+}
+
+func TestIsValidGoVersion(t *testing.T) {
+	for v, want := range map[string]bool{
+		"go1.21":  true,
+		"go1.9":   true,
+		"go1":     false,
+		"go1.":    false,
+		"1.21":    false,
+		"go1.21x": false,
+	} {
+		if got := isValidGoVersion(v); got != want {
+			t.Errorf("isValidGoVersion(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package lsp
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+)
+
+// warmStateVersion is bumped once the format of the warm state changes, the warm state of the other versions is
+// discarded.
+const warmStateVersion = 1
+
+// warmState is saved at the shutdown into the directory of the option 'warmStateDir', so that the next session of the
+// same workspace folders starts with the symbols and the references indexed and the metadata resolved. The symbols
+// and the references are only reused if the content of their files is unchanged.
+type warmState struct {
+	Version int `json:"version"`
+	// The sorted paths of the workspace folders, which identify the warm state.
+	Folders    []string         `json:"folders"`
+	Files      []warmFile       `json:"files"`
+	References []warmReferences `json:"references,omitempty"`
+	Caches     warmCaches       `json:"caches"`
+	Saved      time.Time        `json:"saved"`
+}
+
+type warmFile struct {
+	URI     span.URI                           `json:"uri"`
+	Hash    string                             `json:"hash"`
+	Symbols []protocol.DetailSymbolInformation `json:"symbols"`
+}
+
+type warmReferences struct {
+	URI        span.URI           `json:"uri"`
+	Hash       string             `json:"hash"`
+	References []indexedReference `json:"references"`
+}
+
+// warmCaches are the process-wide caches of the metadata, i.e. the repository URLs resolved by the import paths and
+// by the redirects, and the last uses of the module versions in the module caches.
+type warmCaches struct {
+	RepoRoots     map[string]string    `json:"repoRoots,omitempty"`
+	RepoRedirects map[string]string    `json:"repoRedirects,omitempty"`
+	ModCacheUsage map[string]time.Time `json:"modCacheUsage,omitempty"`
+}
+
+// contentHash returns the hash of the content of the file, it's empty if the file can't be read.
+func contentHash(ctx context.Context, fh source.FileHandle) string {
+	_, hash, err := fh.Read(ctx)
+	if err != nil {
+		return ""
+	}
+	return hash
+}
+
+// warmFolders returns the sorted paths of the workspace folders sent by the 'initialize' request.
+func warmFolders(params *protocol.ParamInitia) []string {
+	var folders []string
+	for _, folder := range params.WorkspaceFolders {
+		folders = append(folders, hostPaths.clean(span.NewURI(folder.URI).Filename()))
+	}
+	if len(folders) == 0 && params.RootURI != "" {
+		folders = append(folders, hostPaths.clean(span.NewURI(params.RootURI).Filename()))
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// warmStatePath returns the path of the warm state of the workspace folders in dir.
+func warmStatePath(dir string, folders []string) string {
+	return filepath.Join(dir, fmt.Sprintf("%x.json", sha1.Sum([]byte(strings.Join(folders, "\n")))))
+}
+
+// saveWarmState saves the symbols indexed and the caches of the metadata as the warm state of the workspace folders.
+func (s *ElasticServer) saveWarmState(ctx context.Context) {
+	dir := s.session.Options().WarmStateDir
+	if dir == "" || len(s.warmFolders) == 0 {
+		return
+	}
+	state := warmState{Version: warmStateVersion, Folders: s.warmFolders, Saved: time.Now()}
+	s.symbols.mu.Lock()
+	for uri, f := range s.symbols.files {
+		if f.hash != "" {
+			state.Files = append(state.Files, warmFile{URI: uri, Hash: f.hash, Symbols: f.symbols})
+		}
+	}
+	s.symbols.mu.Unlock()
+	sort.Slice(state.Files, func(i, j int) bool { return state.Files[i].URI < state.Files[j].URI })
+	s.references.mu.Lock()
+	for uri, f := range s.references.files {
+		if f.hash != "" {
+			state.References = append(state.References, warmReferences{URI: uri, Hash: f.hash, References: f.refs})
+		}
+	}
+	s.references.mu.Unlock()
+	sort.Slice(state.References, func(i, j int) bool { return state.References[i].URI < state.References[j].URI })
+	state.Caches = snapshotCaches()
+
+	path := warmStatePath(dir, s.warmFolders)
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+	// The warm state is written aside and renamed, so that the next session never reads a partial one.
+	if err == nil {
+		err = ioutil.WriteFile(path+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		log.Error(ctx, "failed to save the warm state", err, tag.Of("File", path))
+		return
+	}
+	log.Print(ctx, "saved the warm state", tag.Of("File", path), tag.Of("Files", len(state.Files)))
+}
+
+// restoreWarmState restores the warm state of the workspace folders if any. The symbols are restored as they are, and
+// validated by the content of their files once they're looked up. The references are counted as a whole by the code
+// lenses, so they're validated by the content of their files on the disk right away.
+func (s *ElasticServer) restoreWarmState(ctx context.Context, folders []string) {
+	s.warmFolders = folders
+	dir := s.session.Options().WarmStateDir
+	if dir == "" || len(folders) == 0 {
+		return
+	}
+	path := warmStatePath(dir, folders)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error(ctx, "failed to read the warm state", err, tag.Of("File", path))
+		}
+		return
+	}
+	var state warmState
+	if err := json.Unmarshal(data, &state); err != nil || state.Version != warmStateVersion || strings.Join(state.Folders, "\n") != strings.Join(folders, "\n") {
+		log.Print(ctx, "discarded the stale warm state", tag.Of("File", path))
+		return
+	}
+	s.symbols.mu.Lock()
+	if s.symbols.files == nil {
+		s.symbols.files = make(map[span.URI]indexedFile)
+	}
+	for _, f := range state.Files {
+		if _, ok := s.symbols.files[f.URI]; !ok {
+			s.symbols.files[f.URI] = indexedFile{hash: f.Hash, symbols: f.Symbols, restored: true}
+		}
+	}
+	s.symbols.mu.Unlock()
+	for _, f := range state.References {
+		data, err := ioutil.ReadFile(f.URI.Filename())
+		if err != nil || fmt.Sprintf("%x", sha1.Sum(data)) != f.Hash {
+			continue
+		}
+		s.references.mu.Lock()
+		if s.references.files == nil {
+			s.references.files = make(map[span.URI]indexedReferences)
+		}
+		if _, ok := s.references.files[f.URI]; !ok {
+			s.references.files[f.URI] = indexedReferences{hash: f.Hash, refs: f.References}
+		}
+		s.references.mu.Unlock()
+	}
+	restoreCaches(state.Caches)
+	log.Print(ctx, "restored the warm state", tag.Of("File", path), tag.Of("Files", len(state.Files)))
+}
+
+// snapshotCaches returns a copy of the process-wide caches of the metadata.
+func snapshotCaches() warmCaches {
+	caches := warmCaches{
+		RepoRoots:     sharedMetadata.strings(repoRootKind),
+		RepoRedirects: sharedMetadata.strings(repoRedirectKind),
+		ModCacheUsage: make(map[string]time.Time),
+	}
+	modCacheUsage.Lock()
+	for k, v := range modCacheUsage.used {
+		caches.ModCacheUsage[k] = v
+	}
+	modCacheUsage.Unlock()
+	return caches
+}
+
+// restoreCaches merges the caches of the warm state into the process-wide ones, the entries resolved by this process
+// win. The uses of the module versions no longer in the module caches are dropped.
+func restoreCaches(caches warmCaches) {
+	for importPath, repo := range caches.RepoRoots {
+		sharedMetadata.put(nameKey(repoRootKind, importPath), repo, false)
+	}
+	for repoURL, live := range caches.RepoRedirects {
+		sharedMetadata.put(nameKey(repoRedirectKind, repoURL), live, false)
+	}
+	for dir, used := range caches.ModCacheUsage {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		modCacheUsage.Lock()
+		if last, ok := modCacheUsage.used[dir]; !ok || used.After(last) {
+			modCacheUsage.used[dir] = used
+		}
+		modCacheUsage.Unlock()
+	}
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestWarmState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warmstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	newServer := func() *ElasticServer {
+		options := source.DefaultOptions
+		options.WarmStateDir = filepath.Join(dir, "state")
+		return newTestSessionServer(ctx, options)
+	}
+	folders := warmFolders(&protocol.ParamInitia{InitializeParams: protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: "file:///repo/b"}, {URI: "file:///repo/a"}},
+	}})
+	uri := span.FileURI("/repo/a/a.go")
+	syms := []protocol.DetailSymbolInformation{{Qname: "a.F"}}
+
+	s := newServer()
+	s.restoreWarmState(ctx, folders)
+	s.symbols.update(uri, "v1", "hash", syms)
+	// The symbols of the files which can't be hashed aren't saved.
+	s.symbols.update(span.FileURI("/repo/a/b.go"), "v1", "", syms)
+	s.references.update(uri, "hash", []protocol.Reference{{Target: protocol.SymbolLocator{Qname: "a.F"}}})
+	sharedMetadata.put(nameKey(repoRootKind, "example.com/warm/pkg"), "https://example.com/warm", true)
+	s.saveWarmState(ctx)
+
+	// The warm state of the other workspace folders isn't restored.
+	other := newServer()
+	other.restoreWarmState(ctx, []string{"/repo/a"})
+	if _, ok := other.symbols.restored(uri, "v2", func() string { return "hash" }); ok {
+		t.Errorf("the warm state of the other workspace folders is expected to be discarded")
+	}
+
+	sharedMetadata.delete(nameKey(repoRootKind, "example.com/warm/pkg"))
+	restarted := newServer()
+	restarted.restoreWarmState(ctx, folders)
+	if len(restarted.symbols.files) != 1 {
+		t.Errorf("got %d files restored, want 1", len(restarted.symbols.files))
+	}
+	// The references are validated by the content of their files on the disk, where /repo/a/a.go doesn't exist.
+	if len(restarted.references.files) != 0 {
+		t.Errorf("got %d files of the references restored, want none", len(restarted.references.files))
+	}
+	if repo, ok := sharedMetadata.get(nameKey(repoRootKind, "example.com/warm/pkg")); !ok || repo != "https://example.com/warm" {
+		t.Errorf("got the repository %q of the restored cache, want https://example.com/warm", repo)
+	}
+	// The content of the file is changed since the warm state is saved.
+	if _, ok := restarted.symbols.restored(uri, "v2", func() string { return "changed" }); ok {
+		t.Errorf("the symbols of the changed file are expected to be discarded")
+	}
+	got, ok := restarted.symbols.restored(uri, "v2", func() string { return "hash" })
+	if !ok || len(got) != 1 || got[0].Qname != "a.F" {
+		t.Fatalf("got the symbols %v, want the restored ones", got)
+	}
+	// The symbols are bound to the current version of the file once validated.
+	if _, ok := restarted.symbols.get(uri, "v2"); !ok {
+		t.Errorf("the validated symbols are expected to be bound to the version of the file")
+	}
+}
@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	"golang.org/x/tools/internal/xcontext"
+)
+
+// errWarmUpStopped stops the loads of the warm-up once the memory limit is exceeded.
+var errWarmUpStopped = errors.New("warm-up stopped under the memory pressure")
+
+// warmUpTracker keeps track of the background warm-up of the workspace packages started after 'initialized'.
+type warmUpTracker struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (t *warmUpTracker) start(cancel context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.cancel = cancel
+}
+
+// stop cancels the running warm-up if there is one.
+func (t *warmUpTracker) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+// Initialized starts warming up the workspace packages in the background once the views are created, if the option
+// 'warmUpWorkspace' is set.
+func (s *ElasticServer) Initialized(ctx context.Context, params *protocol.InitializedParams) error {
+	if err := s.Server.Initialized(ctx, params); err != nil {
+		return err
+	}
+	var views []source.View
+	for _, view := range s.session.Views() {
+		if view.Options().WarmUpWorkspace {
+			views = append(views, view)
+		}
+	}
+	s.startWarmUp(ctx, views)
+	return nil
+}
+
+// startWarmUp warms up the views in the background, the running warm-up is canceled.
+func (s *ElasticServer) startWarmUp(ctx context.Context, views []source.View) {
+	if len(views) == 0 {
+		return
+	}
+	// The warm-up outlives the request starting it.
+	ctx, cancel := context.WithCancel(xcontext.Detach(ctx))
+	s.warmUps.start(cancel)
+	completion := protocol.CompletionEvent{Event: protocol.CompletionWarmUp, Folders: []protocol.DocumentURI{}}
+	for _, view := range views {
+		completion.Folders = append(completion.Folders, protocol.NewURI(view.Folder()))
+	}
+	go func() {
+		defer cancel()
+		start := time.Now()
+		defer func() {
+			completion.Duration = time.Since(start).Seconds()
+			completion.Canceled = ctx.Err() != nil
+			s.notifyCompletion(ctx, completion)
+		}()
+		for _, view := range views {
+			if err := s.warmUp(ctx, view); err != nil {
+				log.Error(ctx, "failed to warm up the workspace", err, tag.Of("View", view.Name()))
+				completion.Errors = append(completion.Errors, err.Error())
+				return
+			}
+		}
+	}()
+}
+
+// CancelWarmUp cancels the running warm-up, the packages already checked are kept.
+func (s *ElasticServer) CancelWarmUp(ctx context.Context) error {
+	s.warmUps.stop()
+	return nil
+}
+
+// warmUp loads and type-checks all the packages of the view in parallel, so that the first requests don't pay the
+// cold-start latency. The progress is reported by the 'elastic/warmUpProgress' notifications. The warm-up is stopped
+// once the memory limit is exceeded, since the checked packages would be evicted immediately.
+func (s *ElasticServer) warmUp(ctx context.Context, view source.View) error {
+	files, err := packageFiles(view.Folder().Filename())
+	if err != nil {
+		return err
+	}
+	progress := protocol.WarmUpProgressParams{
+		Folder: protocol.NewURI(view.Folder()),
+		Total:  len(files),
+	}
+	defer func() {
+		progress.Done = true
+		s.notifyWarmUp(ctx, &progress)
+	}()
+	// The progress and the packages seen are shared by the loads.
+	var mu sync.Mutex
+	start := time.Now()
+	seen := make(map[string]bool)
+	err = newPackageLoader(view.Options().LoadParallelism, s.stats).run(ctx, len(files), func(ctx context.Context, i int) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.memory.underPressure() {
+			log.Print(ctx, "warm-up stopped under the memory pressure", tag.Of("View", view.Name()))
+			return errWarmUpStopped
+		}
+		s.warmUpPackage(ctx, view, span.FileURI(files[i]), &mu, seen)
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Checked++
+		// Throttle the notifications for the large workspaces.
+		if time.Since(start) > time.Second || progress.Checked == progress.Total {
+			start = time.Now()
+			s.notifyWarmUp(ctx, &progress)
+		}
+		return nil
+	})
+	if err != nil {
+		progress.Canceled = true
+	}
+	return nil
+}
+
+// warmUpPackage checks the packages of the file which haven't been seen yet, the packages seen are guarded by mu.
+func (s *ElasticServer) warmUpPackage(ctx context.Context, view source.View, uri span.URI, mu *sync.Mutex, seen map[string]bool) {
+	f, err := view.GetFile(ctx, uri)
+	if err != nil {
+		return
+	}
+	_, cphs, err := view.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return
+	}
+	for _, cph := range cphs {
+		mu.Lock()
+		checked := seen[cph.ID()]
+		seen[cph.ID()] = true
+		mu.Unlock()
+		if checked {
+			continue
+		}
+		if _, err := cph.Check(ctx); err != nil {
+			log.Error(ctx, "failed to warm up the package", err, tag.Of("Package", cph.ID()))
+			continue
+		}
+		// The warmed packages are bound by the same limits as the requested ones.
+		s.packages.use(ctx, view, cph)
+	}
+}
+
+func (s *ElasticServer) notifyWarmUp(ctx context.Context, progress *protocol.WarmUpProgressParams) {
+	if s.Conn == nil || !s.clientCaps.notifies("elastic/warmUpProgress") {
+		return
+	}
+	// The context of the warm-up may be already canceled.
+	if err := s.Conn.Notify(xcontext.Detach(ctx), "elastic/warmUpProgress", progress); err != nil {
+		log.Error(ctx, "failed to notify the warm-up progress", err)
+	}
+}
+
+// packageFiles returns one Go file for each directory under the folder, which is enough to locate the packages of the
+// directory.
+func packageFiles(folder string) ([]string, error) {
+	var files []string
+	err := walkGoFiles(folder, func(path string) {
+		if len(files) == 0 || filepath.Dir(files[len(files)-1]) != filepath.Dir(path) {
+			files = append(files, path)
+		}
+	})
+	return files, err
+}
+
+// walkGoFiles calls fn for the non-test Go files under the folder, the vendor, testdata and hidden directories are
+// skipped.
+func walkGoFiles(folder string, fn func(path string)) error {
+	return walkGoSources(folder, func(path string) {
+		if !strings.HasSuffix(path, "_test.go") {
+			fn(path)
+		}
+	})
+}
+
+// walkGoSources calls fn for the Go files under the folder including the test files, the directories are skipped like
+// walkGoFiles.
+func walkGoSources(folder string, fn func(path string)) error {
+	return walkFolderFiles(folder, func(path string) {
+		if strings.HasSuffix(path, ".go") {
+			fn(path)
+		}
+	})
+}
+
+// walkFolderFiles calls fn for the files under the folder, the directories are skipped like walkGoFiles.
+func walkFolderFiles(folder string, fn func(path string)) error {
+	return filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != folder && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fn(path)
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
+	"golang.org/x/tools/internal/xcontext"
+	errors "golang.org/x/xerrors"
+)
+
+// webhookTimeout bounds the post of a completion event, so that a stuck orchestrator doesn't pile up the posts.
+const webhookTimeout = 10 * time.Second
+
+// PostCompletion posts the completion event to the webhook in JSON, any status other than 2xx is an error.
+func PostCompletion(ctx context.Context, webhook string, event protocol.CompletionEvent) error {
+	if event.Folders == nil {
+		event.Folders = []protocol.DocumentURI{}
+	}
+	if event.Errors == nil {
+		event.Errors = []string{}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("the completion webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyCompletion posts the completion event to the webhook of the options in the background. The failed posts are
+// only logged, the orchestrator is still able to poll the server.
+func (s *ElasticServer) notifyCompletion(ctx context.Context, event protocol.CompletionEvent) {
+	webhook := s.session.Options().CompletionWebhook
+	if webhook == "" {
+		return
+	}
+	// The post outlives the work completed.
+	ctx = xcontext.Detach(ctx)
+	go func() {
+		if err := PostCompletion(ctx, webhook, event); err != nil {
+			log.Error(ctx, "failed to post the completion event", err, tag.Of("Event", event.Event))
+		}
+	}()
+}
+
+// folderURIs returns the URIs of the folders.
+func folderURIs(folders []protocol.WorkspaceFolder) []protocol.DocumentURI {
+	uris := make([]protocol.DocumentURI, 0, len(folders))
+	for _, folder := range folders {
+		uris = append(uris, folder.URI)
+	}
+	return uris
+}
@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCompletionWebhook(t *testing.T) {
+	events := make(chan protocol.CompletionEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event protocol.CompletionEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		if event.Event == "rejected" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		events <- event
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if err := PostCompletion(ctx, srv.URL, protocol.CompletionEvent{Event: "rejected"}); err == nil {
+		t.Error("got no error of the rejected post")
+	}
+	<-events
+
+	root := newTestDir(t, "webhook", map[string]string{"go.mod": "module example.com/m\n\ngo 1.11\n"})
+	defer os.RemoveAll(root)
+	options := source.DefaultOptions
+	options.CompletionWebhook = srv.URL
+	s := newTestSessionServer(ctx, options)
+	folder := protocol.WorkspaceFolder{URI: string(span.FileURI(root)), Name: filepath.Base(root)}
+	s.ManageDeps(ctx, []protocol.WorkspaceFolder{folder}, nil)
+	select {
+	case event := <-events:
+		if event.Event != protocol.CompletionManageDeps || !reflect.DeepEqual(event.Folders, []protocol.DocumentURI{folder.URI}) || len(event.Errors) != 0 || event.Canceled {
+			t.Errorf("got the completion event %+v, want the succeeded dependency management of %s", event, folder.URI)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("got no completion event of the dependency management")
+	}
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/debug"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// WorkspaceHash returns the hash of the workspace folder which tells whether a stored index of the folder is still
+// valid without extracting the symbols again. The hash covers the contents of the Go files including the tests, the
+// contents of the 'go.mod' and 'go.sum' files, which pin the versions of the dependencies, the toolchain and the
+// server version. The contents are the ones of the session, i.e. the open files are hashed by their unsaved changes
+// as the 'full' requests see them.
+func (s *ElasticServer) WorkspaceHash(ctx context.Context, params *protocol.WorkspaceHashParams) (protocol.WorkspaceHash, error) {
+	var resp protocol.WorkspaceHash
+	folder := span.NewURI(params.Folder).Filename()
+	view := s.folderView(folder)
+	if view == nil {
+		return resp, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%s is not a workspace folder", folder)
+	}
+	stdout, err := runGoCommand(ctx, view.Options(), folder, view.Options().Env, "version")
+	if err != nil {
+		return resp, err
+	}
+	resp.GoVersion = strings.TrimSpace(stdout.String())
+
+	kinds := make(map[string]source.FileKind)
+	if err := walkFolderFiles(folder, func(path string) {
+		switch filepath.Base(path) {
+		case "go.mod":
+			kinds[path] = source.Mod
+		case "go.sum":
+			kinds[path] = source.Sum
+		default:
+			if strings.HasSuffix(path, ".go") {
+				kinds[path] = source.Go
+			}
+		}
+	}); err != nil {
+		return resp, err
+	}
+	// The files are hashed by the slash separated relative paths, so that the hash doesn't depend on the location of
+	// the folder or the order of the walk.
+	names := make(map[string]string, len(kinds))
+	var sorted []string
+	for path := range kinds {
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return resp, err
+		}
+		name := filepath.ToSlash(rel)
+		names[name] = path
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "gopls %s\n%s\n", debug.Version, resp.GoVersion)
+	for _, name := range sorted {
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+		path := names[name]
+		_, hash, err := s.session.GetFile(span.FileURI(path), kinds[path]).Read(ctx)
+		if err != nil {
+			return resp, err
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", name, hash)
+	}
+	resp.Hash = fmt.Sprintf("sha256:%x", h.Sum(nil))
+	resp.Files = len(sorted)
+	return resp, nil
+}
@@ -0,0 +1,58 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestWorkspaceHash(t *testing.T) {
+	dir := newTestDir(t, "workspacehash", map[string]string{
+		"go.mod":        "module example.com/m\n\ngo 1.11\n",
+		"a/a.go":        "package a\n\nfunc F() {}\n",
+		"a/a_test.go":   "package a\n",
+		"testdata/x.go": "package x\n",
+	})
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	s, _ := newTestServer(ctx, dir, "m", source.DefaultOptions)
+	hash := func() protocol.WorkspaceHash {
+		resp, err := s.WorkspaceHash(ctx, &protocol.WorkspaceHashParams{Folder: protocol.NewURI(span.FileURI(dir))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := hash()
+	if first.Files != 3 {
+		t.Errorf("got %d files, want 3", first.Files)
+	}
+	if first.GoVersion == "" {
+		t.Error("got no go version")
+	}
+	if again := hash(); again.Hash != first.Hash {
+		t.Errorf("got the hash %s, want the same %s", again.Hash, first.Hash)
+	}
+	// The skipped directories aren't hashed.
+	writeTestFiles(t, dir, map[string]string{"testdata/x.go": "package y\n"})
+	if again := hash(); again.Hash != first.Hash {
+		t.Errorf("got the hash %s after changing testdata, want the same %s", again.Hash, first.Hash)
+	}
+	writeTestFiles(t, dir, map[string]string{"go.mod": "module example.com/m\n\ngo 1.11\n\nrequire example.com/dep v1.0.0\n"})
+	required := hash()
+	if required.Hash == first.Hash {
+		t.Error("got the same hash after requiring a module")
+	}
+	// The unsaved changes of the open files are hashed.
+	s.session.DidOpen(ctx, span.FileURI(filepath.Join(dir, "a", "a.go")), source.Go, []byte("package a\n\nfunc G() {}\n"))
+	if opened := hash(); opened.Hash == required.Hash {
+		t.Error("got the same hash after changing the open file")
+	}
+}
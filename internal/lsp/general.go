@@ -34,8 +34,8 @@ func (s *Server) initialize(ctx context.Context, params *protocol.ParamInitia) (
 	options := s.session.Options()
 	defer func() { s.session.SetOptions(options) }()
 
-	// TODO: Handle results here.
-	source.SetOptions(&options, params.InitializationOptions)
+	// The invalid and the unknown initialization options are reported, like the typos of the option names.
+	s.handleOptionResults(ctx, source.SetOptions(&options, params.InitializationOptions))
 	options.ForClientCapabilities(params.Capabilities)
 
 	s.pendingFolders = params.WorkspaceFolders
@@ -147,10 +147,10 @@ func (s *Server) initialized(ctx context.Context, params *protocol.InitializedPa
 			ID:     "workspace/didChangeWatchedFiles",
 			Method: "workspace/didChangeWatchedFiles",
 			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
-				Watchers: []protocol.FileSystemWatcher{{
+				Watchers: append([]protocol.FileSystemWatcher{{
 					GlobPattern: "**/*.go",
 					Kind:        float64(protocol.WatchChange),
-				}},
+				}}, modFileWatchers...),
 			},
 		})
 	}
@@ -195,33 +195,36 @@ func (s *Server) fetchConfig(ctx context.Context, name string, folder span.URI,
 		return err
 	}
 	for _, config := range configs {
-		results := source.SetOptions(o, config)
-		for _, result := range results {
-			if result.Error != nil {
-				s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
-					Type:    protocol.Error,
-					Message: result.Error.Error(),
-				})
-			}
-			switch result.State {
-			case source.OptionUnexpected:
-				s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
-					Type:    protocol.Error,
-					Message: fmt.Sprintf("unexpected config %s", result.Name),
-				})
-			case source.OptionDeprecated:
-				msg := fmt.Sprintf("config %s is deprecated", result.Name)
-				if result.Replacement != "" {
-					msg = fmt.Sprintf("%s, use %s instead", msg, result.Replacement)
-				}
-				s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
-					Type:    protocol.Warning,
-					Message: msg,
-				})
+		s.handleOptionResults(ctx, source.SetOptions(o, config))
+	}
+	return nil
+}
+
+func (s *Server) handleOptionResults(ctx context.Context, results source.OptionResults) {
+	for _, result := range results {
+		if result.Error != nil {
+			s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.Error,
+				Message: result.Error.Error(),
+			})
+		}
+		switch result.State {
+		case source.OptionUnexpected:
+			s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.Error,
+				Message: fmt.Sprintf("unexpected config %s", result.Name),
+			})
+		case source.OptionDeprecated:
+			msg := fmt.Sprintf("config %s is deprecated", result.Name)
+			if result.Replacement != "" {
+				msg = fmt.Sprintf("%s, use %s instead", msg, result.Replacement)
 			}
+			s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.Warning,
+				Message: msg,
+			})
 		}
 	}
-	return nil
 }
 
 func (s *Server) shutdown(ctx context.Context) error {
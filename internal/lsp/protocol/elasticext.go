@@ -1,13 +1,23 @@
 package protocol
 
 type PackageLocator struct {
+	// The IndexSchemaVersion of the locator, it's only set for the locators of the 'textDocument/edefinition'
+	// responses, the ones carried by the other documents take the versions of the documents.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
 	Version string `json:"version"`
 	Name    string `json:"name"`
 	RepoURI string `json:"uri"`
+	// The path of the module which the package belongs to, it's only resolved for the vendored packages.
+	Module string `json:"module,omitempty"`
 }
 
 // SymbolLocator is the response type for the `textDocument/edefinition` extension.
 type SymbolLocator struct {
+	// The IndexSchemaVersion of the locator, it's only set for the locators of the 'textDocument/edefinition'
+	// responses, the ones carried by the other documents take the versions of the documents.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
 	// The fully qualified name of the symbol.
 	Qname string `json:"qname,omitempty"`
 
@@ -22,11 +32,78 @@ type SymbolLocator struct {
 	Loc *Location `json:"location,omitempty"`
 
 	Package PackageLocator `json:"package,omitempty"`
+
+	// Generated is true if the symbol is declared in a generated file, like the cgo generated files. The location is
+	// mapped back to the original file by the line directives if possible.
+	Generated bool `json:"generated,omitempty"`
+
+	// Signature is the one-line signature of the declaration, like 'func Foo(ctx context.Context) error', and Snippet
+	// is the excerpt of the first few lines of its source, so that the client is able to preview the definition
+	// without fetching the file from the repository declaring it.
+	Signature string `json:"signature,omitempty"`
+	Snippet   string `json:"snippet,omitempty"`
+
+	// Approximate is true if the definition is resolved from the syntax alone, since the package fails to type check.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// EDefinitionParams is the request type for the `textDocument/edefinition` extension.
+type EDefinitionParams struct {
+	DefinitionParams
+	// The ID of the snapshot which the definition is resolved as of, it's either the current snapshot or one of the
+	// snapshots retained by the server. Zero means the current snapshot.
+	Snapshot uint64 `json:"snapshot,omitempty"`
 }
 
 type FullParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
 	Reference    bool                   `json:"reference"`
+	// The ID of the snapshot which the symbols and the references are collected as of, see EDefinitionParams.
+	Snapshot uint64 `json:"snapshot,omitempty"`
+	// GoVersions lists the Go language versions, like 'go1.21', under which the package of the document will be
+	// type-checked additionally. The symbols and diagnostics which differ between the versions are reported in the
+	// 'versionDiffs' of the response, along with the changes of the semantics without a type error, i.e. the loop
+	// variables captured by the func literals, which are shared by the iterations before go1.22.
+	GoVersions []string `json:"goVersions,omitempty"`
+	// ReferenceKinds selects the kinds of the references to collect if 'reference' is true, all the kinds are collected
+	// if it's empty.
+	ReferenceKinds []ReferenceKind `json:"referenceKinds,omitempty"`
+	// LocalReferences selects only the references whose use-sites are in the document, i.e. the ones which aren't
+	// mapped to other files by the line directives of a generated document, like a parser generated by goyacc. Such
+	// references are otherwise reported once in the package, by the first file of it mapped to the use-site.
+	LocalReferences bool `json:"localReferences,omitempty"`
+	// BuildContext overrides the build context of the document, so that the files excluded by the default build
+	// constraints, like the windows variants on a linux server, can be indexed as well.
+	BuildContext BuildContext `json:"buildContext"`
+	// SymbolFilter narrows down the symbols and the references of the response.
+	SymbolFilter SymbolFilter `json:"symbolFilter"`
+	// Limit caps the number of the symbols and the references of a page, zero means no pagination. The following
+	// pages are fetched by the 'nextCursor' of the responses, which expires once the document changes.
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// SymbolFilter selects the symbols and the references of the document to report, the zero value selects all of them.
+type SymbolFilter struct {
+	// ExcludeLocals excludes the symbols declared in the functions, like the labels, and the references to them, like
+	// the uses of the local variables.
+	ExcludeLocals bool `json:"excludeLocals,omitempty"`
+	// ExcludeTests reports nothing for the test files.
+	ExcludeTests bool `json:"excludeTests,omitempty"`
+	// Kinds selects the kinds of the symbols to report, all the kinds are reported if it's empty.
+	Kinds []SymbolKind `json:"kinds,omitempty"`
+	// MaxSymbols and MaxReferences cap the numbers of the symbols and the references, zero means no limit. The
+	// response is marked as truncated if any of them is exceeded.
+	MaxSymbols    int `json:"maxSymbols,omitempty"`
+	MaxReferences int `json:"maxReferences,omitempty"`
+}
+
+// BuildContext is the build context which the document is loaded and type-checked under, the empty fields take the
+// defaults of the server.
+type BuildContext struct {
+	GOOS   string   `json:"goos,omitempty"`
+	GOARCH string   `json:"goarch,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
 }
 
 type DetailSymbolInformation struct {
@@ -35,6 +112,16 @@ type DetailSymbolInformation struct {
 	// Use for hover
 	// contents MarkupContent MarkedString MarkedString[] `json:"content"`
 	Package PackageLocator `json:"package"`
+	// The enum-like group of the constant, which is only set for the constants declared in a const block with iota.
+	ConstGroup *ConstGroup `json:"constGroup,omitempty"`
+}
+
+type ConstGroup struct {
+	// The identifier of the group, i.e. the qualified name of the type of the constants if it's a named type, or the
+	// qualified name of the first constant in the const block.
+	Group string `json:"group"`
+	// The ordinal of the constant in the const block, the blank constants are counted as well.
+	Ordinal int `json:"ordinal"`
 }
 
 type ReferenceCategory int
@@ -47,14 +134,622 @@ const (
 	IMPLEMENT
 )
 
+// ReferenceKind classifies the syntactic role of a reference, which is finer than the 'ReferenceCategory'.
+type ReferenceKind string
+
+const (
+	// CallReference is a call of a function or a method.
+	CallReference ReferenceKind = "call"
+	// TypeUseReference is a use of a type name, including the embedding of a type.
+	TypeUseReference ReferenceKind = "typeUse"
+	// ImportReference is an import of a package.
+	ImportReference ReferenceKind = "import"
+	// AddressTakenReference is a use of a variable or a function whose address is taken.
+	AddressTakenReference ReferenceKind = "addressTaken"
+	// ImplementsReference witnesses that a type declared in the document satisfies an interface.
+	ImplementsReference ReferenceKind = "implements"
+	// DefinitionReference is the identifier declaring a symbol.
+	DefinitionReference ReferenceKind = "definition"
+	// ReadReference is any other use of a symbol reading it, like reading a variable or converting to a type.
+	ReadReference ReferenceKind = "read"
+	// WriteReference is any other use of a symbol writing it, like assigning to a variable or a field.
+	WriteReference ReferenceKind = "write"
+	// OtherReference selects both the reads and the writes, it's never reported but kept for the clients selecting
+	// the references by it.
+	OtherReference ReferenceKind = "other"
+)
+
 type Reference struct {
 	Category ReferenceCategory `json:"category"`
+	Kind     ReferenceKind     `json:"kind,omitempty"`
 	Loc      Location          `json:"location"`
-	Symbol   SymbolInformation `json:"symbol"`
-	Target   SymbolLocator     `json:"target"`
+	// The declaration enclosing the reference, which is empty for the references at the top level of the file.
+	Symbol SymbolInformation `json:"symbol"`
+	Target SymbolLocator     `json:"target"`
 }
 
+// IndexSchemaVersion is the version of the shapes of the index documents, i.e. the FullResponse along with the symbols,
+// the references and the locators it carries, the locators of the 'textDocument/edefinition' responses, and the
+// 'elastic/indexChanged' responses. The documents of the LegacyElasticProtocol take the LegacySchemaVersion, as do the
+// stored ones without the version. The version is bumped along with a migration of the former documents whenever the
+// shapes change, see 'gopls migrate'.
+const IndexSchemaVersion = 2
+
+// LegacySchemaVersion is the version of the shapes of the index documents of the LegacyElasticProtocol.
+const LegacySchemaVersion = 1
+
 type FullResponse struct {
-	Symbols    []DetailSymbolInformation `json:"symbols"`
-	References []Reference               `json:"references"`
+	// The IndexSchemaVersion of the document, or the LegacySchemaVersion for the legacy clients.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	// The symbols and the references are sorted by their positions, then by the qualified names of the symbols and
+	// the targets respectively, so that the responses for the same version of a document are identical.
+	Symbols      []DetailSymbolInformation `json:"symbols"`
+	References   []Reference               `json:"references"`
+	VersionDiffs []VersionDiff             `json:"versionDiffs,omitempty"`
+	// The ID of the snapshot which the response is collected as of, the following requests can pin the same snapshot
+	// by it to get the consistent results while the documents are being edited.
+	Snapshot uint64 `json:"snapshot"`
+	// The qualified names shared by more than one declaration of the package, the symbols of the document are
+	// disambiguated by the ordinals of the declarations, like 'pkg.init~2'.
+	QnameCollisions []QnameCollision `json:"qnameCollisions,omitempty"`
+	// Partial reports that the package of the document fails to be type checked, the symbols are collected from the
+	// syntax alone with the best effort qualified names, and Errors lists the causes.
+	Partial bool     `json:"partial,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	// Truncated reports that the symbols or the references are capped by the symbol filter of the request.
+	Truncated bool `json:"truncated,omitempty"`
+	// NextCursor is the cursor of the next page if the response is paginated, it's empty for the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Encoding is the content coding which the large responses are compressed by, like 'gzip', if the client accepts
+	// it, see ElasticClientCapabilities. Data holds the compressed JSON object of the 'symbols' and the 'references'
+	// then, which are left empty in the response itself.
+	Encoding string `json:"encoding,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+type QnameCollision struct {
+	// The qualified name without the disambiguators.
+	Qname     string     `json:"qname"`
+	Locations []Location `json:"locations"`
+	// The disambiguated qualified names of the symbols at the locations respectively.
+	Qnames []string `json:"qnames"`
+}
+
+// VersionDiff describes how the result of type-checking a document under a specific Go language version differs from
+// the results under the other requested versions.
+type VersionDiff struct {
+	GoVersion string `json:"goVersion"`
+	// The symbols whose types are not identical under every requested version.
+	Symbols []VersionSymbol `json:"symbols"`
+	// The diagnostics which are not reported under every requested version.
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type VersionSymbol struct {
+	Qname string `json:"qname"`
+	// The type of the symbol under the specific version.
+	Type string `json:"type"`
+}
+
+type ModuleAnomaliesParams struct {
+	// The URI of the folder which contains the 'go.mod'.
+	Folder string `json:"folder"`
+}
+
+// ModuleGraphReport is the response type for the `elastic/moduleAnomalies` extension.
+type ModuleGraphReport struct {
+	Folder    string          `json:"folder"`
+	GoMod     string          `json:"goMod"`
+	Anomalies []ModuleAnomaly `json:"anomalies"`
+}
+
+type ModuleAnomaly struct {
+	// One of 'duplicateMajor', 'pseudoVersionDrift' and 'forkReplace'.
+	Kind    string `json:"kind"`
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Message string `json:"message"`
+	// The related modules in the form of 'path@version', like the other major versions or the fork.
+	Related []string `json:"related,omitempty"`
+}
+
+type ModuleGraphParams struct {
+	// The URIs of the workspace folders whose module graphs are merged, all the workspace folders if it's empty.
+	Folders []DocumentURI `json:"folders,omitempty"`
+}
+
+// ModuleGraph is the response type for the `elastic/moduleGraph` extension, which is the dependency graph of the
+// modules selected by the workspace folders.
+type ModuleGraph struct {
+	Modules []ModuleNode `json:"modules"`
+	Edges   []ModuleEdge `json:"edges"`
+}
+
+type ModuleNode struct {
+	// The 'path@version' of the module versions, or the directory URI of the main modules and the modules replaced by
+	// directories.
+	ID      string `json:"id"`
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	// Main is true for the main modules of the workspace folders.
+	Main bool        `json:"main,omitempty"`
+	Dir  DocumentURI `json:"dir,omitempty"`
+	// The innermost workspace folder containing the directory of the module, which owns the module.
+	Folder DocumentURI `json:"folder,omitempty"`
+}
+
+// ModuleEdge relates the modules by their IDs.
+type ModuleEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// One of 'require' and 'replace'.
+	Kind string `json:"kind"`
+}
+
+type APISurfaceParams struct {
+	// The import path of the package, or the module path if Module is true.
+	Path string `json:"path"`
+	// Module is true if the API surfaces of all the packages of the module, i.e. the ones under the path, are exported.
+	Module bool `json:"module,omitempty"`
+}
+
+// APISurface is the response type for the `elastic/apiSurface` extension, which is the exported API of the packages.
+type APISurface struct {
+	// The exported types, functions, variables, constants, methods and fields keyed by their qualified names.
+	Symbols map[string]APISymbol `json:"symbols"`
+}
+
+type APISymbol struct {
+	Kind SymbolKind `json:"kind"`
+	// The declaration of the symbol, the identifiers of the same package are unqualified, like 'func (*T).M(x int)'.
+	Signature string         `json:"signature"`
+	Doc       string         `json:"doc,omitempty"`
+	Package   PackageLocator `json:"package"`
+}
+
+type IndexChangedParams struct {
+	// The URI of the workspace folder.
+	Folder DocumentURI `json:"folder"`
+	// The git revisions whose differences are the changed files, To defaults to the working tree. The untracked files
+	// aren't found by the revisions.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// The URIs of the changed files, which are used instead of the revisions if they're specified.
+	Files []DocumentURI `json:"files,omitempty"`
+	// Reference is true if the references are collected like the 'full' requests.
+	Reference bool `json:"reference,omitempty"`
+}
+
+// IndexChangedResponse is the response type for the `elastic/indexChanged` extension, which carries the 'full'
+// responses of the documents impacted by the changed files.
+type IndexChangedResponse struct {
+	// The IndexSchemaVersion of the response, or the LegacySchemaVersion for the legacy clients, the documents carry
+	// theirs as well.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	Documents []IndexedDocument `json:"documents"`
+	// The changed Go files which no longer exist.
+	Removed []DocumentURI `json:"removed"`
+	// The import paths of the impacted packages, i.e. the ones of the changed files and their importers.
+	Packages []string `json:"packages"`
+	// All is true if the module files, i.e. 'go.mod' or 'go.sum', changed, in which case the folder is rebuilt and all
+	// the documents are impacted.
+	All bool `json:"all,omitempty"`
+}
+
+type IndexedDocument struct {
+	URI  DocumentURI  `json:"uri"`
+	Full FullResponse `json:"full"`
+}
+
+type WorkspaceHashParams struct {
+	// The URI of the workspace folder.
+	Folder DocumentURI `json:"folder"`
+}
+
+// WorkspaceHash is the response type for the `elastic/workspaceHash` extension, the hash is the same as long as the
+// files of the folder, the module requirements and the toolchain are, so that a stored index of the folder is still
+// valid.
+type WorkspaceHash struct {
+	// The hex encoded SHA-256 hash, like 'sha256:0123...'.
+	Hash string `json:"hash"`
+	// The number of the hashed files, i.e. the Go files and the module files.
+	Files int `json:"files"`
+	// The output of 'go version', like 'go version go1.13 linux/amd64'.
+	GoVersion string `json:"goVersion"`
+}
+
+type PrepareParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	// The time box of the preparation in milliseconds.
+	Timeout float64 `json:"timeout,omitempty"`
+}
+
+// PrepareResponse is the response type for the `elastic/prepare` extension.
+type PrepareResponse struct {
+	// The token which will be carried by the 'elastic/prepared' notification.
+	Token string `json:"token"`
+	// The estimated time in milliseconds to finish the preparation, it's zero if there is no estimation yet.
+	ETA float64 `json:"eta"`
+}
+
+// PreparedParams is the params of the `elastic/prepared` notification sent when a preparation is finished.
+type PreparedParams struct {
+	Token        string                 `json:"token"`
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	// The duration of the preparation in milliseconds.
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// WarmUpProgressParams is the params of the `elastic/warmUpProgress` notification sent during the warm-up of a
+// workspace folder.
+type WarmUpProgressParams struct {
+	Folder DocumentURI `json:"folder"`
+	// The number of the directories checked and to check.
+	Checked int  `json:"checked"`
+	Total   int  `json:"total"`
+	Done    bool `json:"done"`
+	// Canceled is true if the warm-up is canceled or stopped under the memory pressure before all the directories are
+	// checked.
+	Canceled bool `json:"canceled,omitempty"`
+}
+
+// SessionSummary is the params of the `elastic/sessionSummary` notification sent at the shutdown, which summarizes the
+// work done and the problems met in the session.
+type SessionSummary struct {
+	// The workspace folders managed and the folders which the 'go.mod' files are synthesized for.
+	Folders            []string `json:"folders"`
+	ModulesSynthesized []string `json:"modulesSynthesized"`
+	// The number of the module folders the dependencies are downloaded for and failed to download for.
+	DepsDownloaded int `json:"depsDownloaded"`
+	DepsFailed     int `json:"depsFailed"`
+	// The details of the failed downloads, one for each module folder.
+	DepsFailures []DepsFailure `json:"depsFailures,omitempty"`
+	// The bytes added to the module caches by the downloads, and the number of the module versions evicted from the
+	// module caches to fit in the quota.
+	ModCacheAdded   int64 `json:"modCacheAdded"`
+	ModCacheEvicted int   `json:"modCacheEvicted"`
+	// The number of the requests, including the notifications, served by the methods and replied with the errors.
+	Requests map[string]int `json:"requests"`
+	Errors   map[string]int `json:"errors"`
+	// The peak resident memory of the process in bytes, zero if unknown.
+	PeakMemory uint64 `json:"peakMemory"`
+	// The duration of the session in seconds.
+	Uptime float64 `json:"uptime"`
+	// The number of the packages loaded in parallel, like by the warm-up, their total and longest time in seconds, and
+	// the peak number of the loads waiting for the parallelism limit.
+	Loads         int     `json:"loads"`
+	LoadTime      float64 `json:"loadTime"`
+	SlowestLoad   float64 `json:"slowestLoad"`
+	PeakLoadQueue int     `json:"peakLoadQueue"`
+}
+
+// DepsFailure describes the failed download of the dependencies of a module folder, the folder is put under the
+// vendor mode after the retries are exhausted.
+type DepsFailure struct {
+	Folder string `json:"folder"`
+	// The number of the attempts made, including the retries.
+	Attempts int `json:"attempts"`
+	// Whether the last failure is transient, like the outages of the proxies, rather than permanent, like the missing
+	// modules.
+	Transient bool   `json:"transient"`
+	Error     string `json:"error"`
+}
+
+// The completed work of the completion events.
+const (
+	CompletionManageDeps = "manageDeps"
+	CompletionWarmUp     = "warmUp"
+	CompletionIndex      = "index"
+)
+
+// CompletionEvent is posted to the completion webhook once the dependency management, the warm-up of the workspace or
+// a batch index run is done.
+type CompletionEvent struct {
+	// The completed work, i.e. 'manageDeps', 'warmUp' or 'index'.
+	Event string `json:"event"`
+	// The URIs of the workspace folders the work is done for.
+	Folders []DocumentURI `json:"folders"`
+	// The duration of the work in seconds.
+	Duration float64 `json:"duration"`
+	// The errors met by the work, like the failed downloads, empty if the work succeeded.
+	Errors []string `json:"errors"`
+	// Canceled is true if the work is canceled or aborted by the timeout before it's done.
+	Canceled bool `json:"canceled,omitempty"`
+	// The URIs of the artifacts produced by a batch index run, like the uploaded indexes.
+	Artifacts []string `json:"artifacts,omitempty"`
+}
+
+type TokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// TokensResponse is the response type for the `elastic/tokens` extension.
+type TokensResponse struct {
+	// The names of the token types.
+	Legend []string `json:"legend"`
+	// The tokens encoded as the groups of five integers: the line delta, the start character delta, the length, the
+	// token type and the target, see 'ElasticServer.Tokens' for the details.
+	Data []uint32 `json:"data"`
+	// The symbols referred by the identifiers.
+	Targets []SymbolLocator `json:"targets"`
+}
+
+type ASTParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// ASTNode is the response type for the `elastic/ast` extension, which is the root node of the file.
+type ASTNode struct {
+	// The type name of the node in 'go/ast', like 'FuncDecl' and 'Ident'.
+	Kind  string `json:"kind"`
+	Range Range  `json:"range"`
+	// The name of the identifiers.
+	Name string `json:"name,omitempty"`
+	// The literal of the basic literals, or the operator or the token of the expressions, the statements and the
+	// declarations.
+	Value string `json:"value,omitempty"`
+	// The symbol defined or referred by the identifiers.
+	Target   *SymbolLocator `json:"target,omitempty"`
+	Children []*ASTNode     `json:"children,omitempty"`
+}
+
+// SymbolMatch specifies how the query of the `elastic/symbol` extension is matched against the qualified names.
+type SymbolMatch string
+
+const (
+	FuzzyMatch  SymbolMatch = "fuzzy"
+	ExactMatch  SymbolMatch = "exact"
+	PrefixMatch SymbolMatch = "prefix"
+)
+
+type ESymbolParams struct {
+	Query string `json:"query"`
+	// The default is the fuzzy matching.
+	Match SymbolMatch `json:"match,omitempty"`
+	// The maximum number of the symbols to return, the default is 100.
+	Limit int `json:"limit,omitempty"`
+}
+
+type DoctorParams struct {
+	// The least free disk space of the module cache in MB, the default is 1024.
+	MinFreeSpace float64 `json:"minFreeSpace,omitempty"`
+}
+
+// DoctorStatus is the result of a check of the `elastic/doctor` extension.
+type DoctorStatus string
+
+const (
+	DoctorPass DoctorStatus = "pass"
+	DoctorFail DoctorStatus = "fail"
+	// The check isn't applicable, like the proxy check under the offline mode.
+	DoctorSkip DoctorStatus = "skip"
+)
+
+// DoctorReport is the response type for the `elastic/doctor` extension, it tells whether the environment is ready for
+// indexing.
+type DoctorReport struct {
+	// Passed is false if any of the checks fails.
+	Passed bool          `json:"passed"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+type DoctorCheck struct {
+	Name   string       `json:"name"`
+	Status DoctorStatus `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// BenchParams selects the corpus and the requests run by the `elastic/bench` extension.
+type BenchParams struct {
+	// The files of the corpus, which the requests are run over in order.
+	Files []DocumentURI `json:"files"`
+	// The number of the runs over the corpus, the default is 1. The first run is usually the cold one.
+	Iterations int `json:"iterations,omitempty"`
+	// The requests to run over each file, 'full' and 'edefinition', both of them are run if it's empty.
+	Methods []string `json:"methods,omitempty"`
+	// Reference collects the references by the 'full' requests.
+	Reference bool `json:"reference,omitempty"`
+	// The number of the identifiers of each file which the 'edefinition' requests are run at, they're spread over the
+	// file evenly. The default is 10.
+	Definitions int `json:"definitions,omitempty"`
+}
+
+// BenchReport is the response type for the `elastic/bench` extension, the measurements of the requests by the methods.
+type BenchReport struct {
+	Files      int           `json:"files"`
+	Iterations int           `json:"iterations"`
+	Results    []BenchResult `json:"results"`
+	// The duration of the benchmark in milliseconds.
+	Elapsed float64 `json:"elapsed"`
+}
+
+type BenchResult struct {
+	Method string `json:"method"`
+	// The number of the requests run and the ones failed.
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// The percentiles and the maximum of the latencies in milliseconds.
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+	// The ratio of the requests whose packages are type-checked already when the requests start.
+	CacheHitRate float64 `json:"cacheHitRate"`
+	// The average number and bytes of the heap allocations of the process during a request.
+	AllocsPerRequest float64 `json:"allocsPerRequest"`
+	BytesPerRequest  float64 `json:"bytesPerRequest"`
+}
+
+type EffectiveConfigParams struct {
+	// The workspace folder whose configuration is returned, all the folders are returned if it's empty.
+	Folder DocumentURI `json:"folder,omitempty"`
+}
+
+// EffectiveConfig is the response type for the `elastic/effectiveConfig` extension, it is the configuration the
+// server actually operates with, so that the misconfigured deployments can be told without guessing.
+type EffectiveConfig struct {
+	// Session is the configuration of the session, i.e. the defaults merged with the initialization options.
+	Session FolderConfig `json:"session"`
+	// Folders are the configurations of the views, which apply the configuration fetched for the folders, the feature
+	// flags and the environment of the folders on top of the session.
+	Folders []FolderConfig `json:"folders"`
+}
+
+type FolderConfig struct {
+	URI     DocumentURI    `json:"uri,omitempty"`
+	Name    string         `json:"name,omitempty"`
+	Options ElasticOptions `json:"options"`
+	// The environment overrides of the go commands, the later entries take precedence over the former ones. The
+	// values of the variables looking like secrets are redacted.
+	Env        []string `json:"env,omitempty"`
+	BuildFlags []string `json:"buildFlags,omitempty"`
+	// GoEnv is the environment resolved by the go command in the folder under Env, like GOROOT and GOPROXY.
+	GoEnv EnvMap `json:"goEnv"`
+}
+
+// CodeRequestTimeout is the error code of the requests exceeding the timeouts set by the option 'requestTimeouts', the
+// data of the error is the RequestTimeout.
+const CodeRequestTimeout = -32010
+
+// RequestTimeout is the data of the error of the request exceeding its timeout.
+type RequestTimeout struct {
+	Method string `json:"method"`
+	// The timeout in seconds.
+	Timeout float64 `json:"timeout"`
+	// The partial result computed before the timeout, like the symbols of the 'full' request collected by the syntax.
+	Partial interface{} `json:"partial,omitempty"`
+}
+
+// The versions of the elastic protocol. The responses to the clients of the legacy version, i.e. the clients declaring
+// no version, are stripped of the fields added since, like the 'snapshot' and the 'nextCursor' of the FullResponse and
+// the 'generated' of the SymbolLocator.
+const (
+	LegacyElasticProtocol  = 1
+	ElasticProtocolVersion = 2
+)
+
+// ElasticServerCapabilities is advertised under 'experimental.elastic' of the capabilities of the 'initialize' result,
+// so that the clients detect the extensions served rather than assuming them.
+type ElasticServerCapabilities struct {
+	// The version of the elastic protocol served, it's the ElasticProtocolVersion.
+	Version int `json:"version"`
+	// The extension requests and notifications handled by the server, like 'textDocument/full'.
+	Methods []string `json:"methods"`
+	// The extension notifications sent by the server, like 'elastic/sessionSummary'.
+	Notifications []string `json:"notifications"`
+	// The elastic commands served by 'workspace/executeCommand', like 'elastic.deps.download'.
+	Commands []string `json:"commands"`
+	// References is true if the 'textDocument/full' responses carry the references.
+	References bool `json:"references"`
+	// The legend of the semantic tokens.
+	SemanticTokens SemanticTokensLegend `json:"semanticTokens"`
+	// The content codings which the large responses may be compressed by, like 'gzip'.
+	Compression []string `json:"compression"`
+}
+
+// ElasticClientCapabilities is declared by the client under 'experimental.elastic' of the client capabilities of the
+// 'initialize' request. The absent fields keep the behaviour for the clients declaring nothing.
+type ElasticClientCapabilities struct {
+	// The version of the elastic protocol spoken by the client, the LegacyElasticProtocol if it's absent. The responses
+	// take the shapes of the lower of the versions of the client and the server.
+	Version int `json:"version,omitempty"`
+	// References is false if the client doesn't handle the references of the 'textDocument/full' responses, which are
+	// then never collected. The default is true.
+	References *bool `json:"references,omitempty"`
+	// Notifications lists the extension notifications handled by the client, the others aren't sent. All of them are
+	// sent if it's absent.
+	Notifications []string `json:"notifications,omitempty"`
+	// Compression lists the content codings accepted by the client in the order of its preference, the large
+	// responses are compressed by the first one served. The responses are never compressed if it's absent.
+	Compression []string `json:"compression,omitempty"`
+}
+
+// ElasticCommandResult is the result of the elastic commands of 'workspace/executeCommand', like
+// 'elastic.deps.download'.
+type ElasticCommandResult struct {
+	// The folders which the command is applied to, including the nested modules found by the dependency management.
+	Folders []DocumentURI `json:"folders"`
+}
+
+type CallHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+}
+
+// CallHierarchyItem is a function or a method of the call hierarchy, the `callHierarchy/*` extensions augment the items
+// with the qualified names and the package locators so that the call trees span the repositories.
+type CallHierarchyItem struct {
+	Name string     `json:"name"`
+	Kind SymbolKind `json:"kind"`
+	// The full name of the function, like '(*pkg.T).M'.
+	Detail string `json:"detail,omitempty"`
+	// The URI and the ranges of the name of the function are only set for the functions declared in the workspace
+	// folder, the others are located by the qname and the package like the `textDocument/edefinition`.
+	URI            DocumentURI    `json:"uri,omitempty"`
+	Range          *Range         `json:"range,omitempty"`
+	SelectionRange *Range         `json:"selectionRange,omitempty"`
+	Qname          string         `json:"qname"`
+	Package        PackageLocator `json:"package"`
+}
+
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a caller of the item, the ranges are the calls of the item in the caller.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyOutgoingCall is a callee of the item, the ranges are the calls of the callee in the item.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+type TypeHierarchyParams struct {
+	TextDocumentPositionParams
+}
+
+// TypeHierarchy is the response type for the `elastic/typeHierarchy` extension, which relates the named type at the
+// position to the other types. The types declared in the workspace folder are located by the locations as well.
+type TypeHierarchy struct {
+	Type SymbolLocator `json:"type"`
+	// The types embedded in the struct or the interface.
+	Embeds []SymbolLocator `json:"embeds"`
+	// The interfaces satisfied by the type or its pointer, out of the package of the type and the packages it imports.
+	Implements []SymbolLocator `json:"implements"`
+	// The workspace types embedding the type, and the ones satisfying the type if it's an interface. Only the
+	// packages of the type and importing the package of the type are searched.
+	EmbeddedBy    []SymbolLocator `json:"embeddedBy"`
+	ImplementedBy []SymbolLocator `json:"implementedBy"`
+}
+
+type SemanticTokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type SemanticTokensRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// SemanticTokens is the response type for the `textDocument/semanticTokens/full` and `/range` requests. The tokens
+// are encoded as the groups of five integers like the LSP semantic tokens, i.e. the line delta, the start character
+// delta, the length, the token type and the bit set of the token modifiers, indexed by the SemanticTokensLegend.
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// SemanticTokensLegend is advertised under 'semanticTokens' of the elastic capabilities.
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
 }
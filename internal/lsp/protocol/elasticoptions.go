@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	errors "golang.org/x/xerrors"
+)
+
+// ElasticOptions are the initialization options of the elastic server besides the ones of gopls, which are also the
+// configuration of the workspace folders. The durations are in seconds and the sizes in megabytes, zero means the
+// default or no limit.
+type ElasticOptions struct {
+	// InstallGoDependency downloads the dependencies of the workspace folders.
+	InstallGoDependency bool `json:"installGoDependency,omitempty"`
+	// DownloadOnModChange downloads the dependencies once again after go.mod or go.sum is changed on the disk.
+	DownloadOnModChange bool `json:"downloadOnModChange,omitempty"`
+	// VendorMode loads the dependencies from the vendor folders, nothing is downloaded.
+	VendorMode bool `json:"vendorMode,omitempty"`
+	// Offline forbids the network access, like downloading the dependencies and resolving the repository URIs.
+	Offline bool `json:"offline,omitempty"`
+
+	GoProxies       []string          `json:"goProxies,omitempty"`
+	ProxyCooldown   float64           `json:"proxyCooldown,omitempty"`
+	DownloadRetries int               `json:"downloadRetries,omitempty"`
+	DownloadBackoff float64           `json:"downloadBackoff,omitempty"`
+	ModCacheQuota   float64           `json:"modCacheQuota,omitempty"`
+	Credentials     *Credentials      `json:"credentials,omitempty"`
+	ExcludePatterns []string          `json:"excludePatterns,omitempty"`
+	FolderEnv       map[string]EnvMap `json:"folderEnv,omitempty"`
+
+	// TrustedRoots are the directories whose workspace folders may run the go commands and have the files written.
+	TrustedRoots []string `json:"trustedRoots,omitempty"`
+	// ConfirmTrust asks the client to trust the other workspace folders.
+	ConfirmTrust bool `json:"confirmTrust,omitempty"`
+
+	ImportPathAliases       map[string]string `json:"importPathAliases,omitempty"`
+	DetectRepoRedirects     bool              `json:"detectRepoRedirects,omitempty"`
+	QnameStyle              string            `json:"qnameStyle,omitempty"`
+	MaxQnameDepth           int               `json:"maxQnameDepth,omitempty"`
+	QualifyLocalDefinitions bool              `json:"qualifyLocalDefinitions,omitempty"`
+
+	MemoryLimit               float64            `json:"memoryLimit,omitempty"`
+	RejectUnderMemoryPressure bool               `json:"rejectUnderMemoryPressure,omitempty"`
+	TypeCheckLimit            int                `json:"typeCheckLimit,omitempty"`
+	TypeCheckQueue            int                `json:"typeCheckQueue,omitempty"`
+	TypeCheckQueueTimeout     float64            `json:"typeCheckQueueTimeout,omitempty"`
+	PackageCacheEntries       int                `json:"packageCacheEntries,omitempty"`
+	PackageCacheBudget        float64            `json:"packageCacheBudget,omitempty"`
+	MaxResponseSize           float64            `json:"maxResponseSize,omitempty"`
+	RequestTimeouts           map[string]float64 `json:"requestTimeouts,omitempty"`
+	SnapshotHistory           int                `json:"snapshotHistory,omitempty"`
+	LoadParallelism           int                `json:"loadParallelism,omitempty"`
+	ExportDataDependencies    bool               `json:"exportDataDependencies,omitempty"`
+
+	WarmUpWorkspace          bool                       `json:"warmUpWorkspace,omitempty"`
+	WarmStateDir             string                     `json:"warmStateDir,omitempty"`
+	SessionSummaryFile       string                     `json:"sessionSummaryFile,omitempty"`
+	CompletionWebhook        string                     `json:"completionWebhook,omitempty"`
+	ASTDump                  bool                       `json:"astDump,omitempty"`
+	DisableReferenceIndexing bool                       `json:"disableReferenceIndexing,omitempty"`
+	FeatureFlags             map[string]map[string]bool `json:"featureFlags,omitempty"`
+}
+
+// EnvMap is the environment of the go commands keyed by the variables.
+type EnvMap map[string]string
+
+// Credentials authenticate the go commands downloading the private modules.
+type Credentials struct {
+	GoPrivate     string            `json:"goPrivate,omitempty"`
+	NetrcFile     string            `json:"netrcFile,omitempty"`
+	GitAskPass    string            `json:"gitAskPass,omitempty"`
+	SSHAuthSock   string            `json:"sshAuthSock,omitempty"`
+	GitSSHCommand string            `json:"gitSSHCommand,omitempty"`
+	GitConfig     map[string]string `json:"gitConfig,omitempty"`
+}
+
+// DecodeElasticOptions decodes the elastic options from the initialization options, which are either decoded from
+// JSON or the ElasticOptions themselves. The options of gopls are ignored, and the options of the wrong types are
+// errors naming the options.
+func DecodeElasticOptions(opts interface{}) (ElasticOptions, error) {
+	var options ElasticOptions
+	switch opts := opts.(type) {
+	case nil:
+		return options, nil
+	case ElasticOptions:
+		return opts, nil
+	case *ElasticOptions:
+		return *opts, nil
+	}
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return options, err
+	}
+	if err := json.Unmarshal(data, &options); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return options, errors.Errorf("invalid value of type %s for the %s option %q", typeErr.Value, typeErr.Type, typeErr.Field)
+		}
+		return options, err
+	}
+	return options, nil
+}
+
+// Map returns the options in the form decoded from JSON, like the initialization options.
+func (o ElasticOptions) Map() map[string]interface{} {
+	data, _ := json.Marshal(o)
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m
+}
@@ -9,9 +9,28 @@ import (
 
 type ElasticServer interface {
 	Server
-	EDefinition(context.Context, *DefinitionParams) ([]SymbolLocator, error)
+	EDefinition(context.Context, *EDefinitionParams) ([]SymbolLocator, error)
 	Full(context.Context, *FullParams) (FullResponse, error)
-	ManageDeps(context.Context, *[]WorkspaceFolder, interface{})
+	ManageDeps(context.Context, []WorkspaceFolder, interface{}) []WorkspaceFolder
+	ModuleAnomalies(context.Context, *ModuleAnomaliesParams) (ModuleGraphReport, error)
+	ModuleGraph(context.Context, *ModuleGraphParams) (ModuleGraph, error)
+	APISurface(context.Context, *APISurfaceParams) (APISurface, error)
+	IndexChanged(context.Context, *IndexChangedParams) (IndexChangedResponse, error)
+	WorkspaceHash(context.Context, *WorkspaceHashParams) (WorkspaceHash, error)
+	Prepare(context.Context, *PrepareParams) (PrepareResponse, error)
+	CancelWarmUp(context.Context) error
+	Tokens(context.Context, *TokensParams) (TokensResponse, error)
+	AST(context.Context, *ASTParams) (*ASTNode, error)
+	ESymbol(context.Context, *ESymbolParams) ([]DetailSymbolInformation, error)
+	Doctor(context.Context, *DoctorParams) (DoctorReport, error)
+	Bench(context.Context, *BenchParams) (BenchReport, error)
+	EffectiveConfig(context.Context, *EffectiveConfigParams) (EffectiveConfig, error)
+	PrepareCallHierarchy(context.Context, *CallHierarchyPrepareParams) ([]CallHierarchyItem, error)
+	IncomingCalls(context.Context, *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error)
+	OutgoingCalls(context.Context, *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error)
+	TypeHierarchy(context.Context, *TypeHierarchyParams) (*TypeHierarchy, error)
+	SemanticTokensFull(context.Context, *SemanticTokensParams) (*SemanticTokens, error)
+	SemanticTokensRange(context.Context, *SemanticTokensRangeParams) (*SemanticTokens, error)
 	Cleanup()
 }
 
@@ -56,7 +75,7 @@ func (h elasticServerHandler) Deliver(ctx context.Context, r *jsonrpc2.Request,
 			sendParseError(ctx, r, err)
 			return true
 		}
-		h.server.ManageDeps(ctx, &params.Event.Added, nil)
+		params.Event.Added = append(params.Event.Added, h.server.ManageDeps(ctx, params.Event.Added, nil)...)
 		if err := h.server.DidChangeWorkspaceFolders(ctx, &params); err != nil {
 			log.Error(ctx, "", err)
 		}
@@ -238,7 +257,7 @@ func (h elasticServerHandler) Deliver(ctx context.Context, r *jsonrpc2.Request,
 			sendParseError(ctx, r, err)
 			return true
 		}
-		h.server.ManageDeps(ctx, &params.WorkspaceFolders, params.InitializationOptions)
+		params.WorkspaceFolders = append(params.WorkspaceFolders, h.server.ManageDeps(ctx, params.WorkspaceFolders, params.InitializationOptions)...)
 		resp, err := h.server.Initialize(ctx, &params)
 		if err := r.Reply(ctx, resp, err); err != nil {
 			log.Error(ctx, "", err)
@@ -250,7 +269,8 @@ func (h elasticServerHandler) Deliver(ctx context.Context, r *jsonrpc2.Request,
 			return true
 		}
 		h.server.Cleanup()
-		if err := h.server.Shutdown(ctx); err != nil {
+		err := h.server.Shutdown(ctx)
+		if err := r.Reply(ctx, nil, err); err != nil {
 			log.Error(ctx, "", err)
 		}
 		return true
@@ -321,11 +341,13 @@ func (h elasticServerHandler) Deliver(ctx context.Context, r *jsonrpc2.Request,
 		}
 		return true
 	case "textDocument/edefinition":
-		var params DefinitionParams
+		var params EDefinitionParams
 		if err := json.Unmarshal(*r.Params, &params); err != nil {
 			sendParseError(ctx, r, err)
 			return true
 		}
+		// The definitions are served in parallel with the other requests of the connection.
+		r.Parallel()
 		resp, err := h.server.EDefinition(ctx, &params)
 		if err := r.Reply(ctx, resp, err); err != nil {
 			log.Error(ctx, "", err)
@@ -425,12 +447,216 @@ func (h elasticServerHandler) Deliver(ctx context.Context, r *jsonrpc2.Request,
 			sendParseError(ctx, r, err)
 			return true
 		}
-
+		// The requests for the same document run in parallel, so that the duplicated ones share one computation.
+		r.Parallel()
 		resp, err := h.server.Full(ctx, &fullParams)
 		if err := r.Reply(ctx, resp, err); err != nil {
 			log.Error(ctx, "", err)
 		}
 		return true
+	case "elastic/moduleAnomalies": // req
+		var params ModuleAnomaliesParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.ModuleAnomalies(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/moduleGraph": // req
+		var params ModuleGraphParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.ModuleGraph(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/apiSurface": // req
+		var params APISurfaceParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.APISurface(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/indexChanged": // req
+		var params IndexChangedParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.IndexChanged(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/workspaceHash": // req
+		var params WorkspaceHashParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.WorkspaceHash(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/prepare": // req
+		var params PrepareParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.Prepare(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/cancelWarmUp": // notif
+		if err := h.server.CancelWarmUp(ctx); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/tokens": // req
+		var params TokensParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.Tokens(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/ast": // req
+		var params ASTParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.AST(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/symbol": // req
+		var params ESymbolParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.ESymbol(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/doctor": // req
+		var params DoctorParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.Doctor(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/bench": // req
+		var params BenchParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.Bench(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/effectiveConfig": // req
+		var params EffectiveConfigParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.EffectiveConfig(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "textDocument/prepareCallHierarchy": // req
+		var params CallHierarchyPrepareParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.PrepareCallHierarchy(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "callHierarchy/incomingCalls": // req
+		var params CallHierarchyIncomingCallsParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.IncomingCalls(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "callHierarchy/outgoingCalls": // req
+		var params CallHierarchyOutgoingCallsParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.OutgoingCalls(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "elastic/typeHierarchy": // req
+		var params TypeHierarchyParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.TypeHierarchy(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "textDocument/semanticTokens/full": // req
+		var params SemanticTokensParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.SemanticTokensFull(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
+	case "textDocument/semanticTokens/range": // req
+		var params SemanticTokensRangeParams
+		if err := json.Unmarshal(*r.Params, &params); err != nil {
+			sendParseError(ctx, r, err)
+			return true
+		}
+		resp, err := h.server.SemanticTokensRange(ctx, &params)
+		if err := r.Reply(ctx, resp, err); err != nil {
+			log.Error(ctx, "", err)
+		}
+		return true
 	case "textDocument/rangeFormatting": // req
 		var params DocumentRangeFormattingParams
 		if err := json.Unmarshal(*r.Params, &params); err != nil {
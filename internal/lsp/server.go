@@ -87,6 +87,9 @@ type Server struct {
 	// folders is only valid between initialize and initialized, and holds the
 	// set of folders to build views for when we are ready
 	pendingFolders []protocol.WorkspaceFolder
+
+	// trust records the workspace folders confirmed by the client to be trusted or not.
+	trust trustRegistry
 }
 
 // General
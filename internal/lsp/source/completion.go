@@ -73,6 +73,10 @@ type CompletionItem struct {
 
 	// Documentation is the documentation for the completion item.
 	Documentation string
+
+	// Object is the object which the completion item refers to. It's nil for
+	// the builtins and the literals.
+	Object types.Object
 }
 
 // Snippet is a convenience returns the snippet if available, otherwise
@@ -109,6 +109,7 @@ func (c *completer) item(cand candidate) (CompletionItem, error) {
 		Score:               cand.score,
 		Depth:               len(c.deepState.chain),
 		snippet:             snip,
+		Object:              obj,
 	}
 	// If the user doesn't want documentation for completion items.
 	if !c.opts.Documentation {
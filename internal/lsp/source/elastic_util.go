@@ -1,9 +1,70 @@
 package source
 
 import (
+	"context"
+	"go/ast"
 	"go/types"
+
+	"golang.org/x/tools/internal/lsp/protocol"
 )
 
 func (ident IdentifierInfo) GetDeclObject() types.Object {
 	return ident.Declaration.obj
 }
+
+// GetDeclPackage returns the type-checked package which the identifier is resolved in, the file declaring the
+// identifier is either in the package or in one of its dependencies.
+func (ident IdentifierInfo) GetDeclPackage() Package {
+	return ident.pkg
+}
+
+// GetImportedPackage returns the package imported by the import which the identifier stands for, i.e. the package
+// names, like the aliases and the dots of the imports, and the import paths. It returns nil for the other identifiers.
+func (ident IdentifierInfo) GetImportedPackage() *types.Package {
+	if pkgName, ok := ident.Declaration.obj.(*types.PkgName); ok {
+		return pkgName.Imported()
+	}
+	imp, ok := ident.Declaration.node.(*ast.ImportSpec)
+	if !ok || ident.pkg == nil {
+		return nil
+	}
+	info := ident.pkg.GetTypesInfo()
+	obj := info.Defs[imp.Name]
+	if obj == nil {
+		obj = info.Implicits[imp]
+	}
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		return pkgName.Imported()
+	}
+	return nil
+}
+
+// IdentifierAt is the same as Identifier except that the identifier is resolved as of the given snapshot, which may
+// be replaced by the view already.
+func IdentifierAt(ctx context.Context, view View, snapshot Snapshot, f File, pos protocol.Position) (*IdentifierInfo, error) {
+	cphs, err := snapshot.CheckPackageHandles(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := WidestCheckPackageHandle(cphs).Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := pkg.File(f.URI())
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := ph.Cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spn, err := m.PointSpan(pos)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := spn.Range(m.Converter)
+	if err != nil {
+		return nil, err
+	}
+	return findIdentifier(ctx, view, snapshot, pkg, file, rng.Start)
+}
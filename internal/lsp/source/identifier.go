@@ -106,7 +106,7 @@ func identifier(ctx context.Context, view View, snapshot Snapshot, pkg Package,
 	if path == nil {
 		return nil, errors.Errorf("can't find node enclosing position")
 	}
-	uri := span.FileURI(view.Session().Cache().FileSet().Position(pos).Filename)
+	uri := span.FileURI(view.Session().Cache().FileSet().PositionFor(pos, false).Filename)
 	var ph ParseGoHandle
 	for _, h := range pkg.Files() {
 		if h.File().Identity().URI == uri {
@@ -188,6 +188,10 @@ func identifier(ctx context.Context, view View, snapshot Snapshot, pkg Package,
 		}
 	}
 
+	// The declarations in the dependencies imported from the export data are located by their source.
+	if result.Declaration.obj, err = pkg.SourceObject(ctx, result.Declaration.obj); err != nil {
+		return nil, err
+	}
 	if result.Declaration.mappedRange, err = objToMappedRange(ctx, view, pkg, result.Declaration.obj); err != nil {
 		return nil, err
 	}
@@ -205,6 +209,9 @@ func identifier(ctx context.Context, view View, snapshot Snapshot, pkg Package,
 		if hasErrorType(result.Type.Object) {
 			return result, nil
 		}
+		if result.Type.Object, err = pkg.SourceObject(ctx, result.Type.Object); err != nil {
+			return nil, err
+		}
 		if result.Type.mappedRange, err = objToMappedRange(ctx, view, pkg, result.Type.Object); err != nil {
 			return nil, err
 		}
@@ -240,7 +247,7 @@ func hasErrorType(obj types.Object) bool {
 }
 
 func objToNode(ctx context.Context, view View, pkg Package, obj types.Object) (ast.Decl, error) {
-	uri := span.FileURI(view.Session().Cache().FileSet().Position(obj.Pos()).Filename)
+	uri := span.FileURI(view.Session().Cache().FileSet().PositionFor(obj.Pos(), false).Filename)
 	ph, _, err := pkg.FindFile(ctx, uri)
 	if err != nil {
 		return nil, err
@@ -289,7 +296,7 @@ func importSpec(ctx context.Context, view View, snapshot Snapshot, file *ast.Fil
 	if err != nil {
 		return nil, errors.Errorf("import path not quoted: %s (%v)", imp.Path.Value, err)
 	}
-	uri := span.FileURI(view.Session().Cache().FileSet().Position(pos).Filename)
+	uri := span.FileURI(view.Session().Cache().FileSet().PositionFor(pos, false).Filename)
 	var ph ParseGoHandle
 	for _, h := range pkg.Files() {
 		if h.File().Identity().URI == uri {
@@ -311,12 +318,21 @@ func importSpec(ctx context.Context, view View, snapshot Snapshot, file *ast.Fil
 	if err != nil {
 		return nil, err
 	}
-	if importedPkg.GetSyntax(ctx) == nil {
+	// The files of the packages imported from the export data aren't parsed yet.
+	var syntax []*ast.File
+	for _, ph := range importedPkg.Files() {
+		if f, _, _, err := ph.Parse(ctx); f != nil {
+			syntax = append(syntax, f)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if syntax == nil {
 		return nil, errors.Errorf("no syntax for for %q", importPath)
 	}
 	// Heuristic: Jump to the longest (most "interesting") file of the package.
 	var dest *ast.File
-	for _, f := range importedPkg.GetSyntax(ctx) {
+	for _, f := range syntax {
 		if dest == nil || f.End()-f.Pos() > dest.End()-dest.Pos() {
 			dest = f
 		}
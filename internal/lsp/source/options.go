@@ -5,8 +5,11 @@
 package source
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"golang.org/x/tools/internal/lsp/diff"
@@ -42,7 +45,13 @@ var (
 			FuzzyMatching: true,
 			Budget:        100 * time.Millisecond,
 		},
-		ComputeEdits: myers.ComputeEdits,
+		ComputeEdits:          myers.ComputeEdits,
+		GoProxies:             []string{"https://proxy.golang.org"},
+		ProxyCooldown:         5 * time.Minute,
+		DownloadRetries:       2,
+		DownloadBackoff:       2 * time.Second,
+		TypeCheckQueue:        16,
+		TypeCheckQueueTimeout: 30 * time.Second,
 	}
 )
 
@@ -68,6 +77,154 @@ type Options struct {
 
 	InstallGoDependency bool
 
+	// DownloadOnModChange downloads the dependencies of the folders once again after their go.mod or go.sum is changed
+	// on the disk, as reported by 'workspace/didChangeWatchedFiles'.
+	DownloadOnModChange bool
+
+	// VendorMode loads all the dependency packages from the vendor folders and indexes the vendored packages as the
+	// read-only dependencies, whose versions are resolved from 'vendor/modules.txt'.
+	VendorMode bool
+
+	// ImportPathAliases maps the import path prefixes of the moved repositories to their current import paths, it is
+	// applied when resolving the repository URI of a package.
+	ImportPathAliases map[string]string
+
+	// DetectRepoRedirects enables following the HTTP redirects of the repository URL to find the live repository.
+	DetectRepoRedirects bool
+
+	// MemoryLimit is the limit of the resident memory in bytes, the least recently used caches are evicted once it is
+	// exceeded. It is set in megabytes by the option 'memoryLimit', zero means no limit.
+	MemoryLimit uint64
+
+	// RejectUnderMemoryPressure rejects the 'full' requests with a retryable error while the memory limit is exceeded.
+	RejectUnderMemoryPressure bool
+
+	// TypeCheckLimit bounds the concurrent type-checks of the connection, i.e. the 'full' and 'edefinition' requests,
+	// zero means no limit.
+	TypeCheckLimit int
+
+	// TypeCheckQueue is the number of the type-checks waiting for a free slot once the limit is reached, the ones
+	// beyond it are rejected with a retryable error right away.
+	TypeCheckQueue int
+
+	// TypeCheckQueueTimeout is the longest time a type-check waits in the queue, it is rejected with a retryable error
+	// once the timeout expires. It is set in seconds by the option 'typeCheckQueueTimeout', zero means no timeout.
+	TypeCheckQueueTimeout time.Duration
+
+	// RequestTimeouts bounds the time of the 'full' and 'edefinition' requests and the dependency management, which is
+	// keyed by 'full', 'edefinition' and 'manageDeps'. It is set in seconds by the option 'requestTimeouts', the
+	// missing ones or zero mean no timeout.
+	RequestTimeouts map[string]time.Duration
+
+	// PackageCacheEntries bounds the number of the type-checked packages retained for the 'full' and 'edefinition'
+	// requests, zero means no limit.
+	PackageCacheEntries int
+
+	// PackageCacheBudget bounds the estimated bytes of the retained type-checked packages. It is set in megabytes by the
+	// option 'packageCacheBudget', zero means no limit.
+	PackageCacheBudget uint64
+
+	// MaxResponseSize caps the estimated bytes of the symbols and the references of a 'full' response, the larger
+	// responses are paginated as if the limit of the request were lowered. It is set in megabytes by the option
+	// 'maxResponseSize', zero means no cap.
+	MaxResponseSize uint64
+
+	// WarmUpWorkspace loads and type-checks all the workspace packages in the background after 'initialized'.
+	WarmUpWorkspace bool
+
+	// LoadParallelism bounds the packages loaded in parallel by the warm-up, the indexing of the folders and the checks
+	// of the 'full' requests under the Go versions, zero means GOMAXPROCS.
+	LoadParallelism int
+
+	// ExportDataDependencies imports the dependencies out of the folders from their export data, found by
+	// 'go list -export', instead of type-checking them from the source. A dependency is type-checked from the source
+	// only once a location inside it is requested, like the declaration of a definition.
+	ExportDataDependencies bool
+
+	// ASTDump enables the 'elastic/ast' request, which serializes the AST of a file.
+	ASTDump bool
+
+	// MaxQnameDepth limits the number of the components following the package name in the qualified names, the
+	// truncated components are replaced by their hash. Zero means no limit.
+	MaxQnameDepth int
+
+	// ExcludePatterns are the patterns of the paths skipped by the scanning of the workspace folders, like the generated
+	// output directories, in addition to the '.gitignore' files. The patterns are in the syntax of '.gitignore' and
+	// relative to the workspace folders.
+	ExcludePatterns []string
+
+	// GoProxies is the fallback chain of the module proxies used to download the dependencies, the entries are tried in
+	// order, like the internal proxy, then 'https://proxy.golang.org', then 'direct'.
+	GoProxies []string
+
+	// ProxyCooldown is the period of time a failing module proxy is demoted for, the demoted proxies are skipped until
+	// the cooldown expires. It is set in seconds by the option 'proxyCooldown'.
+	ProxyCooldown time.Duration
+
+	// DownloadRetries is the number of the retries of the downloads failed transiently, like the outages of the
+	// proxies. The downloads failed permanently, like the missing modules, are never retried.
+	DownloadRetries int
+
+	// DownloadBackoff is the delay before the first retry of a download, which doubles by the retries. It is set in
+	// seconds by the option 'downloadBackoff'.
+	DownloadBackoff time.Duration
+
+	// ModCacheQuota bounds the bytes of the module cache, the least recently used module versions are evicted once the
+	// downloads exceed it. It is set in megabytes by the option 'modCacheQuota', zero means no limit.
+	ModCacheQuota uint64
+
+	// Credentials are passed to the go commands downloading the dependencies, so that the private modules can be
+	// authenticated. It is set by the option 'credentials'.
+	Credentials CredentialOptions
+
+	// SnapshotHistory is the number of the recently replaced snapshots retained by the views, so that the 'full' and
+	// 'edefinition' requests can be served as of a snapshot shortly after it is replaced. Zero disables the retention.
+	SnapshotHistory int
+
+	// SessionSummaryFile is the path of the file which the summary of the session is written to in JSON at the
+	// shutdown, besides the 'elastic/sessionSummary' notification. Empty means no file.
+	SessionSummaryFile string
+
+	// WarmStateDir is the directory which the index of the symbols and the caches of the metadata are saved to at the
+	// shutdown, they're restored by the next session of the same workspace folders. Empty disables the warm restart.
+	WarmStateDir string
+
+	// CompletionWebhook is the HTTP URL which the completion events are posted to in JSON once the dependency
+	// management or the warm-up of the workspace is done, so that the orchestrator needn't poll. Empty means no webhook.
+	CompletionWebhook string
+
+	// QnameStyle decides how the qualified names are prefixed, i.e. by the package names or the import paths.
+	QnameStyle QnameStyle
+
+	// QualifyLocalDefinitions populates the qualified names, the symbol kinds and the package locators of the
+	// 'edefinition' results within the workspace folders besides the locations, like the cross-repository ones.
+	QualifyLocalDefinitions bool
+
+	// DisableReferenceIndexing ignores the 'reference' of the 'full' requests.
+	DisableReferenceIndexing bool
+
+	// Offline forbids the network access, like downloading the dependencies and resolving the repository URIs.
+	Offline bool
+
+	// FeatureFlags overrides the feature flags of the experimental subsystems per workspace folder, which is keyed by
+	// the URI or the name of the folder, or '*' for all the folders.
+	FeatureFlags map[string]map[string]bool
+
+	// FolderEnv overrides the environment of the go commands per workspace folder, i.e. the packages loading and the
+	// dependency downloading, which is keyed by the URI or the name of the folder, or '*' for all the folders.
+	FolderEnv map[string]map[string]string
+
+	// TrustedRoots are the directories under which the workspace folders are trusted, either the paths or the file
+	// URIs. The untrusted folders never run the dependency management nor download, and their views are offline.
+	TrustedRoots []string
+
+	// ConfirmTrust asks the client to trust the workspace folders out of the trusted roots, once per folder.
+	ConfirmTrust bool
+
+	// BuildContextView marks the views created for the build contexts requested explicitly, like the other GOOS. Such
+	// views only serve the requests of their build contexts, they are never picked as the views of the files.
+	BuildContextView bool
+
 	SupportedCodeActions map[FileKind]map[protocol.CodeActionKind]bool
 
 	SupportedCommands []string
@@ -78,6 +235,33 @@ type Options struct {
 	Completion CompletionOptions
 
 	ComputeEdits diff.ComputeEdits
+
+	// RunCommand runs the go and git commands besides the packages loading, with the environment of the folder, like
+	// the sandboxed commands of the elastic server. The commands are run directly if it's nil.
+	RunCommand func(ctx context.Context, dir string, env []string, name string, args ...string) (*bytes.Buffer, error)
+}
+
+// CredentialOptions are the credentials of the private module hosts, they're passed to the go commands by the
+// environment variables, and the empty ones leave the environment of the process as is.
+type CredentialOptions struct {
+	// GoPrivate is the 'GOPRIVATE' of the private modules, which are fetched directly rather than by the proxies.
+	GoPrivate string
+
+	// NetrcFile is the '.netrc' file of the HTTPS credentials, i.e. 'NETRC'.
+	NetrcFile string
+
+	// GitAskPass is the program asked for the git credentials, i.e. 'GIT_ASKPASS'.
+	GitAskPass string
+
+	// SSHAuthSock is the socket of the SSH agent, i.e. 'SSH_AUTH_SOCK'.
+	SSHAuthSock string
+
+	// GitSSHCommand is the SSH command used by git, i.e. 'GIT_SSH_COMMAND'.
+	GitSSHCommand string
+
+	// GitConfig is the git configuration, like 'url.<base>.insteadOf', passed by 'GIT_CONFIG_COUNT' and the pairs of
+	// 'GIT_CONFIG_KEY_<n>' and 'GIT_CONFIG_VALUE_<n>'.
+	GitConfig map[string]string
 }
 
 type CompletionOptions struct {
@@ -113,6 +297,18 @@ const (
 	Structured
 )
 
+type QnameStyle int
+
+const (
+	// PackageNameQname prefixes the qualified names with the package names, like 'util.Foo'.
+	PackageNameQname = QnameStyle(iota)
+	// ImportPathQname prefixes the qualified names with the import paths, like 'github.com/foo/util.Foo'.
+	ImportPathQname
+	// ImportPathVersionQname prefixes the qualified names with the import paths and the versions of the modules, like
+	// 'github.com/foo/util@v1.2.3.Foo'.
+	ImportPathVersionQname
+)
+
 type OptionResults []OptionResult
 
 type OptionResult struct {
@@ -134,6 +330,10 @@ const (
 
 func SetOptions(options *Options, opts interface{}) OptionResults {
 	var results OptionResults
+	// The typed elastic options are set the same way as the ones decoded from JSON.
+	if elastic, ok := opts.(protocol.ElasticOptions); ok {
+		opts = elastic.Map()
+	}
 	switch opts := opts.(type) {
 	case nil:
 	case map[string]interface{}:
@@ -270,6 +470,350 @@ func (o *Options) set(name string, value interface{}) OptionResult {
 	case "installGoDependency":
 		result.setBool(&o.InstallGoDependency)
 
+	case "downloadOnModChange":
+		result.setBool(&o.DownloadOnModChange)
+
+	case "importPathAliases":
+		aliases, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for map[string]string option %q", value, name)
+			break
+		}
+		o.ImportPathAliases = make(map[string]string)
+		for k, v := range aliases {
+			o.ImportPathAliases[k] = fmt.Sprint(v)
+		}
+
+	case "vendorMode":
+		result.setBool(&o.VendorMode)
+
+	case "detectRepoRedirects":
+		result.setBool(&o.DetectRepoRedirects)
+
+	case "memoryLimit":
+		limit, ok := value.(float64)
+		if !ok || limit < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.MemoryLimit = uint64(limit * (1 << 20))
+
+	case "rejectUnderMemoryPressure":
+		result.setBool(&o.RejectUnderMemoryPressure)
+
+	case "typeCheckLimit":
+		n, ok := value.(float64)
+		if !ok || n < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.TypeCheckLimit = int(n)
+
+	case "loadParallelism":
+		n, ok := value.(float64)
+		if !ok || n < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.LoadParallelism = int(n)
+
+	case "exportDataDependencies":
+		result.setBool(&o.ExportDataDependencies)
+
+	case "typeCheckQueue":
+		n, ok := value.(float64)
+		if !ok || n < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.TypeCheckQueue = int(n)
+
+	case "typeCheckQueueTimeout":
+		seconds, ok := value.(float64)
+		if !ok || seconds < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.TypeCheckQueueTimeout = time.Duration(seconds * float64(time.Second))
+
+	case "packageCacheEntries":
+		entries, ok := value.(float64)
+		if !ok || entries < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.PackageCacheEntries = int(entries)
+
+	case "packageCacheBudget":
+		budget, ok := value.(float64)
+		if !ok || budget < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.PackageCacheBudget = uint64(budget * (1 << 20))
+
+	case "maxResponseSize":
+		size, ok := value.(float64)
+		if !ok || size < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.MaxResponseSize = uint64(size * (1 << 20))
+
+	case "warmUpWorkspace":
+		result.setBool(&o.WarmUpWorkspace)
+
+	case "astDump":
+		result.setBool(&o.ASTDump)
+
+	case "goProxies":
+		iproxies, ok := value.([]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for string list option %q", value, name)
+			break
+		}
+		proxies := make([]string, 0, len(iproxies))
+		for _, proxy := range iproxies {
+			proxies = append(proxies, fmt.Sprintf("%s", proxy))
+		}
+		o.GoProxies = proxies
+
+	case "proxyCooldown":
+		seconds, ok := value.(float64)
+		if !ok || seconds < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.ProxyCooldown = time.Duration(seconds * float64(time.Second))
+
+	case "credentials":
+		creds, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for map[string]interface{} option %q", value, name)
+			break
+		}
+		for k, v := range creds {
+			var s *string
+			switch k {
+			case "goPrivate":
+				s = &o.Credentials.GoPrivate
+			case "netrcFile":
+				s = &o.Credentials.NetrcFile
+			case "gitAskPass":
+				s = &o.Credentials.GitAskPass
+			case "sshAuthSock":
+				s = &o.Credentials.SSHAuthSock
+			case "gitSSHCommand":
+				s = &o.Credentials.GitSSHCommand
+			case "gitConfig":
+				config, ok := v.(map[string]interface{})
+				if !ok {
+					result.errorf("Invalid type %T for the credential %q", v, k)
+					continue
+				}
+				o.Credentials.GitConfig = make(map[string]string)
+				for key, value := range config {
+					o.Credentials.GitConfig[key] = fmt.Sprint(value)
+				}
+				continue
+			default:
+				result.errorf("Unsupported credential %q", k)
+				continue
+			}
+			str, ok := v.(string)
+			if !ok {
+				result.errorf("Invalid type %T for the credential %q", v, k)
+				continue
+			}
+			*s = str
+		}
+
+	case "modCacheQuota":
+		quota, ok := value.(float64)
+		if !ok || quota < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.ModCacheQuota = uint64(quota * (1 << 20))
+
+	case "downloadRetries":
+		n, ok := value.(float64)
+		if !ok || n < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.DownloadRetries = int(n)
+
+	case "downloadBackoff":
+		seconds, ok := value.(float64)
+		if !ok || seconds < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.DownloadBackoff = time.Duration(seconds * float64(time.Second))
+
+	case "snapshotHistory":
+		n, ok := value.(float64)
+		if !ok || n < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.SnapshotHistory = int(n)
+
+	case "sessionSummaryFile":
+		path, ok := value.(string)
+		if !ok {
+			result.errorf("Invalid type %T for string option %q", value, name)
+			break
+		}
+		o.SessionSummaryFile = path
+
+	case "warmStateDir":
+		dir, ok := value.(string)
+		if !ok {
+			result.errorf("Invalid type %T for string option %q", value, name)
+			break
+		}
+		o.WarmStateDir = dir
+
+	case "completionWebhook":
+		webhook, ok := value.(string)
+		if !ok {
+			result.errorf("Invalid type %T for string option %q", value, name)
+			break
+		}
+		if webhook != "" && !strings.HasPrefix(webhook, "http://") && !strings.HasPrefix(webhook, "https://") {
+			result.errorf("Invalid value %q for HTTP URL option %q", webhook, name)
+			break
+		}
+		o.CompletionWebhook = webhook
+
+	case "qnameStyle":
+		style, ok := value.(string)
+		if !ok {
+			result.errorf("Invalid type %T for string option %q", value, name)
+			break
+		}
+		switch style {
+		case "packageName":
+			o.QnameStyle = PackageNameQname
+		case "importPath":
+			o.QnameStyle = ImportPathQname
+		case "importPathVersion":
+			o.QnameStyle = ImportPathVersionQname
+		default:
+			result.errorf("Unsupported qname style", tag.Of("QnameStyle", style))
+		}
+
+	case "qualifyLocalDefinitions":
+		result.setBool(&o.QualifyLocalDefinitions)
+
+	case "disableReferenceIndexing":
+		result.setBool(&o.DisableReferenceIndexing)
+
+	case "offline":
+		result.setBool(&o.Offline)
+
+	case "featureFlags":
+		folders, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for map[string]map[string]bool option %q", value, name)
+			break
+		}
+		o.FeatureFlags = make(map[string]map[string]bool)
+		for folder, v := range folders {
+			flags, ok := v.(map[string]interface{})
+			if !ok {
+				result.errorf("Invalid type %T for the feature flags of %q", v, folder)
+				continue
+			}
+			o.FeatureFlags[folder] = make(map[string]bool)
+			for flag, enabled := range flags {
+				b, ok := enabled.(bool)
+				if !ok {
+					result.errorf("Invalid type %T for the feature flag %q of %q", enabled, flag, folder)
+					continue
+				}
+				o.FeatureFlags[folder][flag] = b
+			}
+		}
+
+	case "requestTimeouts":
+		timeouts, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for map[string]float64 option %q", value, name)
+			break
+		}
+		o.RequestTimeouts = make(map[string]time.Duration)
+		for method, v := range timeouts {
+			switch method {
+			case "full", "edefinition", "manageDeps":
+			default:
+				result.errorf("Unknown method %q for option %q", method, name)
+				continue
+			}
+			seconds, ok := v.(float64)
+			if !ok || seconds < 0 {
+				result.errorf("Invalid value %v for the non-negative timeout of %q", v, method)
+				continue
+			}
+			o.RequestTimeouts[method] = time.Duration(seconds * float64(time.Second))
+		}
+
+	case "folderEnv":
+		folders, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for map[string]map[string]string option %q", value, name)
+			break
+		}
+		o.FolderEnv = make(map[string]map[string]string)
+		for folder, v := range folders {
+			env, ok := v.(map[string]interface{})
+			if !ok {
+				result.errorf("Invalid type %T for the environment of %q", v, folder)
+				continue
+			}
+			o.FolderEnv[folder] = make(map[string]string)
+			for k, v := range env {
+				o.FolderEnv[folder][k] = fmt.Sprint(v)
+			}
+		}
+
+	case "maxQnameDepth":
+		depth, ok := value.(float64)
+		if !ok || depth < 0 {
+			result.errorf("Invalid value %v for non-negative number option %q", value, name)
+			break
+		}
+		o.MaxQnameDepth = int(depth)
+
+	case "excludePatterns":
+		ipatterns, ok := value.([]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for string list option %q", value, name)
+			break
+		}
+		patterns := make([]string, 0, len(ipatterns))
+		for _, pattern := range ipatterns {
+			patterns = append(patterns, fmt.Sprintf("%s", pattern))
+		}
+		o.ExcludePatterns = patterns
+
+	case "trustedRoots":
+		iroots, ok := value.([]interface{})
+		if !ok {
+			result.errorf("Invalid type %T for string list option %q", value, name)
+			break
+		}
+		roots := make([]string, 0, len(iroots))
+		for _, root := range iroots {
+			roots = append(roots, fmt.Sprintf("%s", root))
+		}
+		o.TrustedRoots = roots
+
+	case "confirmTrust":
+		result.setBool(&o.ConfirmTrust)
+
 	default:
 		result.State = OptionUnexpected
 	}
@@ -11,6 +11,7 @@ import (
 	"go/types"
 
 	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
 	"golang.org/x/tools/internal/telemetry/trace"
 )
 
@@ -27,7 +28,13 @@ func DocumentSymbols(ctx context.Context, view View, f File) ([]protocol.Documen
 	if err != nil {
 		return nil, err
 	}
-	ph, err := pkg.File(f.URI())
+	return PackageDocumentSymbols(ctx, view, pkg, f.URI())
+}
+
+// PackageDocumentSymbols returns the symbols of the file in the package that
+// has already been type-checked.
+func PackageDocumentSymbols(ctx context.Context, view View, pkg Package, uri span.URI) ([]protocol.DocumentSymbol, error) {
+	ph, err := pkg.File(uri)
 	if err != nil {
 		return nil, err
 	}
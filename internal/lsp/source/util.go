@@ -170,7 +170,8 @@ func posToRange(ctx context.Context, view View, m *protocol.ColumnMapper, pos, e
 }
 
 func posToMapper(ctx context.Context, view View, pkg Package, pos token.Pos) (*protocol.ColumnMapper, error) {
-	posn := view.Session().Cache().FileSet().Position(pos)
+	// The files are found by their own names rather than the ones of the line directives, like the cgo generated files.
+	posn := view.Session().Cache().FileSet().PositionFor(pos, false)
 	ph, _, err := pkg.FindFile(ctx, span.FileURI(posn.Filename))
 	if err != nil {
 		return nil, err
@@ -253,12 +253,43 @@ type View interface {
 
 	// Snapshot returns the current snapshot for the view.
 	Snapshot() Snapshot
+
+	// EvictPackages drops the least recently used fraction of the type-checked
+	// packages held by the view, so that their memory can be reclaimed.
+	// It returns the number of evicted packages.
+	EvictPackages(ctx context.Context, fraction float64) int
+
+	// EvictPackage drops the type-checked package with the given ID from the view.
+	// It reports whether the package was held by the view.
+	EvictPackage(ctx context.Context, id string) bool
+
+	// SnapshotAt returns the snapshot with the given ID if it is either the
+	// current snapshot or one of the recently replaced snapshots retained by
+	// the view, see Options.SnapshotHistory.
+	SnapshotAt(id uint64) (Snapshot, bool)
 }
 
 // Snapshot represents the current state for the given view.
 type Snapshot interface {
+	// ID returns the identifier of the snapshot, which increases
+	// monotonically within the view.
+	ID() uint64
+
 	// Handle returns the FileHandle for the given file.
 	Handle(ctx context.Context, f File) FileHandle
+
+	// CheckPackageHandles returns the CheckPackageHandles for the packages
+	// that this file belongs to as of the snapshot.
+	CheckPackageHandles(ctx context.Context, f File) ([]CheckPackageHandle, error)
+
+	// CachedPackageHandles returns the CheckPackageHandles for the packages
+	// that this file belongs to which the snapshot knows already, without
+	// loading them.
+	CachedPackageHandles(uri span.URI) []CheckPackageHandle
+
+	// MetadataFiles returns the files of all the packages whose metadata
+	// is loaded in the snapshot, including the dependencies.
+	MetadataFiles() []span.URI
 }
 
 // File represents a source file of any type.
@@ -293,6 +324,10 @@ type Package interface {
 	// FindFile returns the AST and type information for a file that may
 	// belong to or be part of a dependency of the given package.
 	FindFile(ctx context.Context, uri span.URI) (ParseGoHandle, Package, error)
+
+	// SourceObject returns the object declared by the source of the dependency imported from the export data, in
+	// place of obj imported from it. Other objects are returned as is.
+	SourceObject(ctx context.Context, obj types.Object) (types.Object, error)
 }
 
 type BuiltinPackage interface {
@@ -8,6 +8,8 @@ import (
 	"context"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/telemetry/log"
+	"golang.org/x/tools/internal/telemetry/tag"
 	errors "golang.org/x/xerrors"
 )
 
@@ -16,6 +18,7 @@ func (s *Server) changeFolders(ctx context.Context, event protocol.WorkspaceFold
 		view := s.session.View(folder.Name)
 		if view != nil {
 			view.Shutdown(ctx)
+			s.shutdownBuildContextViews(ctx, folder.Name)
 		} else {
 			return errors.Errorf("view %s for %v not found", folder.Name, folder.URI)
 		}
@@ -30,8 +33,28 @@ func (s *Server) changeFolders(ctx context.Context, event protocol.WorkspaceFold
 }
 
 func (s *Server) addView(ctx context.Context, name string, uri span.URI) error {
+	s.stateMu.Lock()
+	state := s.state
+	s.stateMu.Unlock()
+	if state < serverInitialized {
+		return errors.Errorf("addView called before server initialized")
+	}
+
 	options := s.session.Options()
-	if !options.InstallGoDependency {
+	s.fetchConfig(ctx, name, uri, &options)
+	for _, flag := range applyFeatureFlags(&options, uri, name) {
+		log.Print(ctx, "unknown feature flag", tag.Of("Flag", flag), tag.Of("Folder", uri))
+	}
+	applyFolderEnv(&options, uri, name)
+	// The untrusted folders are loaded without accessing the network, so that their go.mod can't trigger the fetches.
+	if !s.trusted(ctx, options, uri.Filename()) {
+		log.Print(ctx, "the folder is untrusted, loading it offline", tag.Of("Folder", uri))
+		options.Offline = true
+	}
+	if options.Offline {
+		options.Env = append(options.Env, offlineEnv()...)
+	}
+	if !options.InstallGoDependency || options.VendorMode {
 		// If we disable the go dependency download, trying to find the deps from the vendor folder.
 		ctx = context.WithValue(ctx, "ENABLEVENDOR", true)
 	} else {
@@ -42,14 +65,6 @@ func (s *Server) addView(ctx context.Context, name string, uri span.URI) error {
 		// Remove this specified entry once the corresponding view has been created.
 		clearVendorFolder(index)
 	}
-	s.stateMu.Lock()
-	state := s.state
-	s.stateMu.Unlock()
-	if state < serverInitialized {
-		return errors.Errorf("addView called before server initialized")
-	}
-
-	s.fetchConfig(ctx, name, uri, &options)
 	s.session.NewView(ctx, name, uri, options)
 	return nil
 }
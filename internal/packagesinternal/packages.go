@@ -0,0 +1,7 @@
+// Package packagesinternal exposes the internals of go/packages to the packages of golang.org/x/tools, without
+// making them a part of the API of go/packages.
+package packagesinternal
+
+// PurgeGoListCache drops the cached responses of "go list", so that the files created, deleted or changed since are
+// seen by the next loads. It's set by go/packages.
+var PurgeGoListCache = func() {}
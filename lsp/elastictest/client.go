@@ -0,0 +1,154 @@
+package elastictest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// Client is a fake client of the elastic server, which records the diagnostics, the messages and the extension
+// notifications sent by the server, and answers the configuration requests by the settings.
+type Client struct {
+	// Settings are the 'gopls' settings of every workspace folder answered to 'workspace/configuration'.
+	Settings map[string]interface{}
+	// Capabilities are the elastic capabilities declared by the client, the version is the current version of the
+	// elastic protocol if it's zero.
+	Capabilities ElasticClientCapabilities
+
+	mu            sync.Mutex
+	diagnostics   map[DocumentURI][]Diagnostic
+	messages      []LogMessageParams
+	notifications map[string][]json.RawMessage
+	// changed is closed and replaced once anything is recorded, so that the waiters check again.
+	changed chan struct{}
+}
+
+// NewClient returns a fake client answering the configuration requests by the settings.
+func NewClient(settings map[string]interface{}) *Client {
+	return &Client{
+		Settings:      settings,
+		diagnostics:   make(map[DocumentURI][]Diagnostic),
+		notifications: make(map[string][]json.RawMessage),
+		changed:       make(chan struct{}),
+	}
+}
+
+// record runs the update under the lock and wakes the waiters up.
+func (c *Client) record(update func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update()
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Diagnostics returns the diagnostics last published for the file.
+func (c *Client) Diagnostics(uri DocumentURI) []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diagnostics[uri]
+}
+
+// Messages returns the messages logged by the server.
+func (c *Client) Messages() []LogMessageParams {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]LogMessageParams(nil), c.messages...)
+}
+
+// Notifications returns the parameters of the extension notifications of the method sent by the server, like
+// 'elastic/prepared', in the order they're sent.
+func (c *Client) Notifications(method string) []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]json.RawMessage(nil), c.notifications[method]...)
+}
+
+// WaitNotification waits until the n-th extension notification of the method is sent by the server, counting from
+// one, and decodes its parameters into v unless v is nil.
+func (c *Client) WaitNotification(ctx context.Context, method string, n int, v interface{}) error {
+	for {
+		c.mu.Lock()
+		sent, changed := c.notifications[method], c.changed
+		c.mu.Unlock()
+		if len(sent) >= n {
+			if v == nil {
+				return nil
+			}
+			return json.Unmarshal(sent[n-1], v)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Client) ShowMessage(ctx context.Context, params *protocol.ShowMessageParams) error {
+	return nil
+}
+
+func (c *Client) LogMessage(ctx context.Context, params *LogMessageParams) error {
+	c.record(func() { c.messages = append(c.messages, *params) })
+	return nil
+}
+
+func (c *Client) Event(ctx context.Context, params *interface{}) error { return nil }
+
+func (c *Client) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	c.record(func() { c.diagnostics[params.URI] = params.Diagnostics })
+	return nil
+}
+
+func (c *Client) WorkspaceFolders(ctx context.Context) ([]WorkspaceFolder, error) {
+	return nil, nil
+}
+
+func (c *Client) Configuration(ctx context.Context, params *protocol.ParamConfig) ([]interface{}, error) {
+	results := make([]interface{}, len(params.Items))
+	for i, item := range params.Items {
+		if item.Section == "gopls" && c.Settings != nil {
+			results[i] = c.Settings
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) RegisterCapability(ctx context.Context, params *protocol.RegistrationParams) error {
+	return nil
+}
+
+func (c *Client) UnregisterCapability(ctx context.Context, params *protocol.UnregistrationParams) error {
+	return nil
+}
+
+func (c *Client) ShowMessageRequest(ctx context.Context, params *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
+	return nil, nil
+}
+
+func (c *Client) ApplyEdit(ctx context.Context, params *protocol.ApplyWorkspaceEditParams) (*protocol.ApplyWorkspaceEditResponse, error) {
+	return &protocol.ApplyWorkspaceEditResponse{Applied: false, FailureReason: "not implemented"}, nil
+}
+
+// notificationHandler records the extension notifications, which the client handler of the protocol doesn't know.
+type notificationHandler struct {
+	jsonrpc2.EmptyHandler
+	client *Client
+}
+
+func (h notificationHandler) Deliver(ctx context.Context, r *jsonrpc2.Request, delivered bool) bool {
+	if delivered || !r.IsNotify() || !strings.HasPrefix(r.Method, "elastic/") {
+		return false
+	}
+	var params json.RawMessage
+	if r.Params != nil {
+		params = append(params, *r.Params...)
+	}
+	h.client.record(func() { h.client.notifications[r.Method] = append(h.client.notifications[r.Method], params) })
+	return true
+}
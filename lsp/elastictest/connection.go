@@ -0,0 +1,219 @@
+// Package elastictest runs the elastic server in memory for the integration tests, like the ones of the clients built
+// on the elastic extensions, without the wiring internal to the server. The messages of the elastic protocol are
+// aliased by the package, so that the tests out of golang.org/x/tools can name them.
+package elastictest
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+
+	"golang.org/x/tools/internal/jsonrpc2"
+	"golang.org/x/tools/internal/lsp"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// Connection is a connection of the fake client to an elastic server over an in-memory pipe. The standard requests
+// are sent by the embedded server, and the elastic requests by the methods of the connection.
+type Connection struct {
+	protocol.Server
+	Client *Client
+
+	conn   *jsonrpc2.Conn
+	pipe   net.Conn
+	cancel context.CancelFunc
+	done   chan error
+}
+
+// Connect starts an elastic server of a new cache, which serves the client over an in-memory pipe until the
+// connection is closed.
+func Connect(ctx context.Context, client *Client) *Connection {
+	ctx, cancel := context.WithCancel(ctx)
+	clientPipe, serverPipe := net.Pipe()
+	c := &Connection{Client: client, pipe: clientPipe, cancel: cancel, done: make(chan error, 1)}
+
+	serverCtx, server := lsp.NewElasticServer(ctx, cache.New(), nil, jsonrpc2.NewHeaderStream(serverPipe, serverPipe))
+	go func() {
+		err := server.RunElasticServer(serverCtx)
+		serverPipe.Close()
+		c.done <- err
+	}()
+	ctx, c.conn, c.Server = protocol.NewClient(ctx, jsonrpc2.NewHeaderStream(clientPipe, clientPipe), client)
+	c.conn.AddHandler(notificationHandler{client: client})
+	go c.conn.Run(ctx)
+	return c
+}
+
+// Initialize initializes the server with the workspace folders, the initialization options and the capabilities of
+// the client, and then notifies it of the initialization, so that the views of the folders are created.
+func (c *Connection) Initialize(ctx context.Context, folders []WorkspaceFolder, options map[string]interface{}) (*InitializeResult, error) {
+	caps := c.Client.Capabilities
+	if caps.Version == 0 {
+		caps.Version = protocol.ElasticProtocolVersion
+	}
+	params := &protocol.ParamInitia{}
+	params.WorkspaceFolders = folders
+	params.InitializationOptions = options
+	params.Capabilities.Workspace.Configuration = true
+	params.Capabilities.Experimental = map[string]interface{}{"elastic": caps}
+	result, err := c.Server.Initialize(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Server.Initialized(ctx, &protocol.InitializedParams{}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OpenFile opens the file of the workspace by its slash-separated path, with the content on the disk.
+func (c *Connection) OpenFile(ctx context.Context, w *Workspace, path string) error {
+	content, err := ioutil.ReadFile(w.Path(path))
+	if err != nil {
+		return err
+	}
+	return c.Server.DidOpen(ctx, &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: w.URI(path), LanguageID: "go", Version: 1, Text: string(content)},
+	})
+}
+
+// Close shuts the server down and closes the pipe, then waits until the server stops serving. The 'exit'
+// notification isn't sent since the server exits the process on it.
+func (c *Connection) Close(ctx context.Context) error {
+	err := c.Server.Shutdown(ctx)
+	c.pipe.Close()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	c.cancel()
+	return err
+}
+
+func (c *Connection) EDefinition(ctx context.Context, params *EDefinitionParams) ([]SymbolLocator, error) {
+	var result []SymbolLocator
+	err := c.conn.Call(ctx, "textDocument/edefinition", params, &result)
+	return result, err
+}
+
+func (c *Connection) Full(ctx context.Context, params *FullParams) (FullResponse, error) {
+	var result FullResponse
+	err := c.conn.Call(ctx, "textDocument/full", params, &result)
+	return result, err
+}
+
+func (c *Connection) ModuleAnomalies(ctx context.Context, params *ModuleAnomaliesParams) (ModuleGraphReport, error) {
+	var result ModuleGraphReport
+	err := c.conn.Call(ctx, "elastic/moduleAnomalies", params, &result)
+	return result, err
+}
+
+func (c *Connection) ModuleGraph(ctx context.Context, params *ModuleGraphParams) (ModuleGraph, error) {
+	var result ModuleGraph
+	err := c.conn.Call(ctx, "elastic/moduleGraph", params, &result)
+	return result, err
+}
+
+func (c *Connection) APISurface(ctx context.Context, params *APISurfaceParams) (APISurface, error) {
+	var result APISurface
+	err := c.conn.Call(ctx, "elastic/apiSurface", params, &result)
+	return result, err
+}
+
+func (c *Connection) IndexChanged(ctx context.Context, params *IndexChangedParams) (IndexChangedResponse, error) {
+	var result IndexChangedResponse
+	err := c.conn.Call(ctx, "elastic/indexChanged", params, &result)
+	return result, err
+}
+
+func (c *Connection) WorkspaceHash(ctx context.Context, params *WorkspaceHashParams) (WorkspaceHash, error) {
+	var result WorkspaceHash
+	err := c.conn.Call(ctx, "elastic/workspaceHash", params, &result)
+	return result, err
+}
+
+func (c *Connection) Prepare(ctx context.Context, params *PrepareParams) (PrepareResponse, error) {
+	var result PrepareResponse
+	err := c.conn.Call(ctx, "elastic/prepare", params, &result)
+	return result, err
+}
+
+func (c *Connection) CancelWarmUp(ctx context.Context) error {
+	return c.conn.Notify(ctx, "elastic/cancelWarmUp", nil)
+}
+
+func (c *Connection) Tokens(ctx context.Context, params *TokensParams) (TokensResponse, error) {
+	var result TokensResponse
+	err := c.conn.Call(ctx, "elastic/tokens", params, &result)
+	return result, err
+}
+
+func (c *Connection) AST(ctx context.Context, params *ASTParams) (*ASTNode, error) {
+	var result *ASTNode
+	err := c.conn.Call(ctx, "elastic/ast", params, &result)
+	return result, err
+}
+
+func (c *Connection) ESymbol(ctx context.Context, params *ESymbolParams) ([]DetailSymbolInformation, error) {
+	var result []DetailSymbolInformation
+	err := c.conn.Call(ctx, "elastic/symbol", params, &result)
+	return result, err
+}
+
+func (c *Connection) Doctor(ctx context.Context, params *DoctorParams) (DoctorReport, error) {
+	var result DoctorReport
+	err := c.conn.Call(ctx, "elastic/doctor", params, &result)
+	return result, err
+}
+
+func (c *Connection) Bench(ctx context.Context, params *BenchParams) (BenchReport, error) {
+	var result BenchReport
+	err := c.conn.Call(ctx, "elastic/bench", params, &result)
+	return result, err
+}
+
+func (c *Connection) EffectiveConfig(ctx context.Context, params *EffectiveConfigParams) (EffectiveConfig, error) {
+	var result EffectiveConfig
+	err := c.conn.Call(ctx, "elastic/effectiveConfig", params, &result)
+	return result, err
+}
+
+func (c *Connection) PrepareCallHierarchy(ctx context.Context, params *CallHierarchyPrepareParams) ([]CallHierarchyItem, error) {
+	var result []CallHierarchyItem
+	err := c.conn.Call(ctx, "textDocument/prepareCallHierarchy", params, &result)
+	return result, err
+}
+
+func (c *Connection) IncomingCalls(ctx context.Context, params *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error) {
+	var result []CallHierarchyIncomingCall
+	err := c.conn.Call(ctx, "callHierarchy/incomingCalls", params, &result)
+	return result, err
+}
+
+func (c *Connection) OutgoingCalls(ctx context.Context, params *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error) {
+	var result []CallHierarchyOutgoingCall
+	err := c.conn.Call(ctx, "callHierarchy/outgoingCalls", params, &result)
+	return result, err
+}
+
+func (c *Connection) TypeHierarchy(ctx context.Context, params *TypeHierarchyParams) (*TypeHierarchy, error) {
+	var result *TypeHierarchy
+	err := c.conn.Call(ctx, "elastic/typeHierarchy", params, &result)
+	return result, err
+}
+
+func (c *Connection) SemanticTokensFull(ctx context.Context, params *SemanticTokensParams) (*SemanticTokens, error) {
+	var result *SemanticTokens
+	err := c.conn.Call(ctx, "textDocument/semanticTokens/full", params, &result)
+	return result, err
+}
+
+func (c *Connection) SemanticTokensRange(ctx context.Context, params *SemanticTokensRangeParams) (*SemanticTokens, error) {
+	var result *SemanticTokens
+	err := c.conn.Call(ctx, "textDocument/semanticTokens/range", params, &result)
+	return result, err
+}
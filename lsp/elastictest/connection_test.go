@@ -0,0 +1,77 @@
+package elastictest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/lsp/elastictest"
+)
+
+// TestConnection drives the server by the exported API alone, as the tests out of golang.org/x/tools do.
+func TestConnection(t *testing.T) {
+	w, err := elastictest.NewWorkspace("example.com/m", map[string]string{
+		"a/a.go": "package a\n\ntype T struct{ F int }\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nvar V = a.T{F: 1}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Remove()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	c := elastictest.Connect(ctx, elastictest.NewClient(map[string]interface{}{"qualifyLocalDefinitions": true}))
+	result, err := c.Initialize(ctx, []elastictest.WorkspaceFolder{w.Folder()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := result.Capabilities.Experimental.(map[string]interface{})["elastic"]; !ok {
+		t.Errorf("got the experimental capabilities %v, want the elastic ones", result.Capabilities.Experimental)
+	}
+	if err := c.OpenFile(ctx, w, "b/b.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The settings of the folder are answered by the client.
+	locs, err := c.EDefinition(ctx, &elastictest.EDefinitionParams{DefinitionParams: elastictest.DefinitionParams{
+		TextDocumentPositionParams: elastictest.TextDocumentPositionParams{
+			TextDocument: elastictest.TextDocumentIdentifier{URI: w.URI("b/b.go")},
+			Position:     elastictest.Position{Line: 4, Character: 10},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 1 || locs[0].Qname != "a.T" || locs[0].Kind != elastictest.Struct {
+		t.Errorf("got the definitions %+v, want a.T", locs)
+	}
+
+	full, err := c.Full(ctx, &elastictest.FullParams{TextDocument: elastictest.TextDocumentIdentifier{URI: w.URI("a/a.go")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var qnames []string
+	for _, sym := range full.Symbols {
+		qnames = append(qnames, sym.Qname)
+	}
+	if len(qnames) != 2 || qnames[0] != "a.T" || qnames[1] != "a.T.F" {
+		t.Errorf("got the symbols %v, want a.T and a.T.F", qnames)
+	}
+
+	prepare, err := c.Prepare(ctx, &elastictest.PrepareParams{TextDocument: elastictest.TextDocumentIdentifier{URI: w.URI("b/b.go")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prepared elastictest.PreparedParams
+	if err := c.Client.WaitNotification(ctx, "elastic/prepared", 1, &prepared); err != nil {
+		t.Fatal(err)
+	}
+	if prepared.Token != prepare.Token || prepared.Error != "" {
+		t.Errorf("got the preparation %+v, want the token %s without an error", prepared, prepare.Token)
+	}
+
+	if err := c.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,104 @@
+package elastictest
+
+import "golang.org/x/tools/internal/lsp/protocol"
+
+// The messages of the elastic protocol, which the packages out of golang.org/x/tools can't name by the protocol
+// package since it's internal.
+type (
+	DocumentURI            = protocol.DocumentURI
+	Position               = protocol.Position
+	Range                  = protocol.Range
+	Location               = protocol.Location
+	TextDocumentIdentifier = protocol.TextDocumentIdentifier
+	TextDocumentItem       = protocol.TextDocumentItem
+	WorkspaceFolder        = protocol.WorkspaceFolder
+	Diagnostic             = protocol.Diagnostic
+	LogMessageParams       = protocol.LogMessageParams
+	InitializeResult       = protocol.InitializeResult
+	SymbolKind             = protocol.SymbolKind
+	SymbolInformation      = protocol.SymbolInformation
+
+	DidOpenTextDocumentParams  = protocol.DidOpenTextDocumentParams
+	DidCloseTextDocumentParams = protocol.DidCloseTextDocumentParams
+	DefinitionParams           = protocol.DefinitionParams
+	TextDocumentPositionParams = protocol.TextDocumentPositionParams
+
+	ElasticClientCapabilities = protocol.ElasticClientCapabilities
+	ElasticServerCapabilities = protocol.ElasticServerCapabilities
+	PreparedParams            = protocol.PreparedParams
+	WarmUpProgressParams      = protocol.WarmUpProgressParams
+	SessionSummary            = protocol.SessionSummary
+
+	EDefinitionParams       = protocol.EDefinitionParams
+	SymbolLocator           = protocol.SymbolLocator
+	PackageLocator          = protocol.PackageLocator
+	FullParams              = protocol.FullParams
+	FullResponse            = protocol.FullResponse
+	DetailSymbolInformation = protocol.DetailSymbolInformation
+	Reference               = protocol.Reference
+	ModuleAnomaliesParams   = protocol.ModuleAnomaliesParams
+	ModuleGraphReport       = protocol.ModuleGraphReport
+	ModuleGraphParams       = protocol.ModuleGraphParams
+	ModuleGraph             = protocol.ModuleGraph
+	APISurfaceParams        = protocol.APISurfaceParams
+	APISurface              = protocol.APISurface
+	IndexChangedParams      = protocol.IndexChangedParams
+	IndexChangedResponse    = protocol.IndexChangedResponse
+	WorkspaceHashParams     = protocol.WorkspaceHashParams
+	WorkspaceHash           = protocol.WorkspaceHash
+	PrepareParams           = protocol.PrepareParams
+	PrepareResponse         = protocol.PrepareResponse
+	TokensParams            = protocol.TokensParams
+	TokensResponse          = protocol.TokensResponse
+	ASTParams               = protocol.ASTParams
+	ASTNode                 = protocol.ASTNode
+	ESymbolParams           = protocol.ESymbolParams
+	DoctorParams            = protocol.DoctorParams
+	DoctorReport            = protocol.DoctorReport
+	BenchParams             = protocol.BenchParams
+	BenchReport             = protocol.BenchReport
+	EffectiveConfigParams   = protocol.EffectiveConfigParams
+	EffectiveConfig         = protocol.EffectiveConfig
+
+	CallHierarchyPrepareParams       = protocol.CallHierarchyPrepareParams
+	CallHierarchyItem                = protocol.CallHierarchyItem
+	CallHierarchyIncomingCallsParams = protocol.CallHierarchyIncomingCallsParams
+	CallHierarchyIncomingCall        = protocol.CallHierarchyIncomingCall
+	CallHierarchyOutgoingCallsParams = protocol.CallHierarchyOutgoingCallsParams
+	CallHierarchyOutgoingCall        = protocol.CallHierarchyOutgoingCall
+	TypeHierarchyParams              = protocol.TypeHierarchyParams
+	TypeHierarchy                    = protocol.TypeHierarchy
+	SemanticTokensParams             = protocol.SemanticTokensParams
+	SemanticTokensRangeParams        = protocol.SemanticTokensRangeParams
+	SemanticTokens                   = protocol.SemanticTokens
+)
+
+// The kinds of the symbols and the symbol locators.
+const (
+	File          = protocol.File
+	Module        = protocol.Module
+	Namespace     = protocol.Namespace
+	Package       = protocol.Package
+	Class         = protocol.Class
+	Method        = protocol.Method
+	Property      = protocol.Property
+	Field         = protocol.Field
+	Constructor   = protocol.Constructor
+	Enum          = protocol.Enum
+	Interface     = protocol.Interface
+	Function      = protocol.Function
+	Variable      = protocol.Variable
+	Constant      = protocol.Constant
+	String        = protocol.String
+	Number        = protocol.Number
+	Boolean       = protocol.Boolean
+	Array         = protocol.Array
+	Object        = protocol.Object
+	Key           = protocol.Key
+	Null          = protocol.Null
+	EnumMember    = protocol.EnumMember
+	Struct        = protocol.Struct
+	Event         = protocol.Event
+	Operator      = protocol.Operator
+	TypeParameter = protocol.TypeParameter
+)
@@ -0,0 +1,74 @@
+package elastictest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+// Workspace is a temporary workspace folder on the disk.
+type Workspace struct {
+	// Dir is the absolute path of the folder.
+	Dir string
+}
+
+// NewWorkspace creates a temporary workspace folder of the files, keyed by their slash-separated paths relative to
+// the folder. Unless the module path is empty or the files have their own go.mod, a go.mod declaring the module is
+// written, so that the folder is loaded in the module mode as the folders of the real repositories are.
+func NewWorkspace(module string, files map[string]string) (*Workspace, error) {
+	dir, err := ioutil.TempDir("", "elastictest")
+	if err != nil {
+		return nil, err
+	}
+	// The temporary folders may be symlinks, like the ones on macOS, which the go command resolves.
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	w := &Workspace{Dir: dir}
+	if _, ok := files["go.mod"]; !ok && module != "" {
+		if err := w.WriteFile("go.mod", "module "+module+"\n"); err != nil {
+			w.Remove()
+			return nil, err
+		}
+	}
+	for path, content := range files {
+		if err := w.WriteFile(path, content); err != nil {
+			w.Remove()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Path returns the absolute path of the file by its slash-separated path relative to the folder.
+func (w *Workspace) Path(path string) string {
+	return filepath.Join(w.Dir, filepath.FromSlash(path))
+}
+
+// URI returns the URI of the file by its slash-separated path relative to the folder.
+func (w *Workspace) URI(path string) DocumentURI {
+	return protocol.NewURI(span.FileURI(w.Path(path)))
+}
+
+// Folder returns the workspace folder sent to the server.
+func (w *Workspace) Folder() WorkspaceFolder {
+	return WorkspaceFolder{URI: protocol.NewURI(span.FileURI(w.Dir)), Name: filepath.Base(w.Dir)}
+}
+
+// WriteFile writes the file by its slash-separated path relative to the folder, the parent folders are created if
+// they're missing.
+func (w *Workspace) WriteFile(path, content string) error {
+	path = w.Path(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
+
+// Remove removes the folder with all its files.
+func (w *Workspace) Remove() error {
+	return os.RemoveAll(w.Dir)
+}